@@ -16,7 +16,7 @@ import (
 // @securityDefinitions.apikey ApiKeyAuth
 // @in header
 // @name Authorization
-// @description JWT Authorization header using Bearer scheme. Example: "Bearer {token}"
+// @description JWT Authorization header using Bearer scheme. Example: "Bearer {token}". An API key can be sent the same way.
 func main() {
 	initialize.Run()
 }
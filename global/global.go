@@ -1,7 +1,10 @@
 package global
 
 import (
+	"app/pkg/jwt"
 	"app/pkg/logger"
+	"app/pkg/password"
+	"app/pkg/policy"
 	"app/pkg/setting"
 
 	"github.com/minio/minio-go/v7"
@@ -10,11 +13,15 @@ import (
 )
 
 var (
-	Config   setting.Config
-	Logger   *logger.LogZap
-	Redis    *redis.Client
-	MinIO    *minio.Client
-	Postgres *gorm.DB
+	Config         setting.Config
+	Logger         *logger.LogZap
+	Redis          *redis.Client
+	MinIO          *minio.Client
+	Postgres       *gorm.DB
+	JWTKeys        *jwt.KeySet
+	PasswordHasher *password.Hasher
+	PasswordPolicy *password.Policy
+	Policy         *policy.Engine
 )
 
 /*
@@ -0,0 +1,14 @@
+package initialize
+
+import (
+	"app/global"
+	"app/pkg/jwt"
+)
+
+// InitJWT loads the signing key and the verification keys used for JWT
+func InitJWT() {
+	keySet, err := jwt.NewKeySet(global.Config.JWT)
+	checkErrPanic(err, "Initialize JWT keys failed")
+	global.JWTKeys = keySet
+	global.Logger.Info("JWT keys loaded, signing algorithm: " + global.Config.JWT.SigningAlgorithm)
+}
@@ -5,7 +5,11 @@ import (
 	"app/internal/modules/user/repo"
 	"app/internal/modules/user/service"
 	"app/internal/third_party/kafka"
+	"app/internal/third_party/mail"
+	"app/internal/third_party/oidc"
 	"app/internal/third_party/redis"
+	"app/internal/third_party/s3"
+	"app/internal/third_party/webauthn"
 )
 
 func InitKafkaConsumer() {
@@ -14,7 +18,21 @@ func InitKafkaConsumer() {
 
 	// Init Kafka Delivery
 	userRepo := repo.NewUserRepository(global.Postgres)
-	userService := service.NewUserService(userRepo, redisProvider)
+	mfaRepo := repo.NewMFARepository(global.Postgres)
+	invitationRepo := repo.NewInvitationRepository(global.Postgres)
+	roleRepo := repo.NewRoleRepository(global.Postgres)
+	apiKeyRepo := repo.NewAPIKeyRepository(global.Postgres)
+	sessionRepo := repo.NewSessionRepository(global.Postgres)
+	identityRepo := repo.NewIdentityRepository(global.Postgres)
+	auditLogRepo := repo.NewAuditLogRepository(global.Postgres)
+	webAuthnRepo := repo.NewWebAuthnRepository(global.Postgres)
+	organizationRepo := repo.NewOrganizationRepository(global.Postgres)
+	permissionService := service.NewPermissionService(roleRepo, redisProvider)
+	authorizationService := service.NewAuthorizationService(permissionService, organizationRepo)
+	auditService := service.NewAuditService(auditLogRepo)
+	mailer, err := mail.NewMailer()
+	checkErrPanic(err, "Initialize mailer failed")
+	userService := service.NewUserService(userRepo, mfaRepo, invitationRepo, roleRepo, apiKeyRepo, sessionRepo, identityRepo, auditLogRepo, webAuthnRepo, organizationRepo, permissionService, authorizationService, auditService, redisProvider, oidc.NewOIDCProvider(), webauthn.NewWebAuthnProvider(), s3.NewS3Provider(), mailer)
 	deliveryHandler := kafka.NewKafkaDeliveryMessages(userService)
 
 	StartKafkaConsumer(deliveryHandler)
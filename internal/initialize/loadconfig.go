@@ -33,12 +33,13 @@ func LoadConfig() {
 func loadConfigFromEnv(config *setting.Config) error {
 	// Load Server settings
 	config.Server = setting.ServerSetting{
-		Port: getEnvAsInt("SERVER_PORT", 8082),
-		Mode: getEnv("SERVER_MODE", "dev"),
+		Port:           getEnvAsInt("SERVER_PORT", 8082),
+		Mode:           getEnv("SERVER_MODE", "dev"),
+		TrustedProxies: getEnvAsSlice("SERVER_TRUSTED_PROXIES"),
 	}
 
 	config.System = setting.SystemSetting{
-		DefaultPassWord: getEnv("SYSTEM_DEFAULT_PASSWORD", "123123"),
+		AppBaseURL: getEnv("APP_BASE_URL", "http://localhost:3000"),
 	}
 
 	// Load PostgreSQL settings
@@ -74,9 +75,13 @@ func loadConfigFromEnv(config *setting.Config) error {
 
 	// Load JWT settings
 	config.JWT = setting.JWTSetting{
-		SecretKey:     getEnv("JWT_SECRET_KEY", "your-secret-key-change-in-production"),
-		TokenExpiry:   getEnvAsDuration("JWT_TOKEN_EXPIRY", 24*time.Hour),
-		RefreshExpiry: getEnvAsDuration("JWT_REFRESH_EXPIRY", 7*24*time.Hour),
+		SecretKey:        getEnv("JWT_SECRET_KEY", "your-secret-key-change-in-production"),
+		TokenExpiry:      getEnvAsDuration("JWT_TOKEN_EXPIRY", 24*time.Hour),
+		RefreshExpiry:    getEnvAsDuration("JWT_REFRESH_EXPIRY", 7*24*time.Hour),
+		SigningAlgorithm: getEnv("JWT_SIGNING_ALGORITHM", "HS256"),
+		SigningKeyID:     getEnv("JWT_SIGNING_KEY_ID", ""),
+		SigningKeyFile:   getEnv("JWT_SIGNING_KEY_FILE", ""),
+		VerificationKeys: getEnvAsJWTKeys("JWT_VERIFICATION_KEYS"),
 	}
 
 	// Load Kafka settings
@@ -96,6 +101,99 @@ func loadConfigFromEnv(config *setting.Config) error {
 		UseSSL:          getEnvAsBool("MINIO_USE_SSL", false),
 	}
 
+	// Load Mail settings
+	config.Mail = setting.MailSetting{
+		Driver:   getEnv("MAIL_DRIVER", "file"),
+		Host:     getEnv("MAIL_HOST", "localhost"),
+		Port:     getEnvAsInt("MAIL_PORT", 587),
+		Username: getEnv("MAIL_USERNAME", ""),
+		Password: getEnv("MAIL_PASSWORD", ""),
+		From:     getEnv("MAIL_FROM", "noreply@localhost"),
+		FileDir:  getEnv("MAIL_FILE_DIR", "./storages/mails"),
+	}
+
+	// Load Auth settings
+	config.Auth = setting.AuthSetting{
+		PasswordResetExpiry:           getEnvAsDuration("AUTH_PASSWORD_RESET_EXPIRY", 30*time.Minute),
+		PasswordResetRequestPeriod:    getEnvAsDuration("AUTH_PASSWORD_RESET_REQUEST_PERIOD", time.Minute),
+		RequireEmailVerification:      getEnvAsBool("AUTH_REQUIRE_EMAIL_VERIFICATION", false),
+		EmailVerificationExpiry:       getEnvAsDuration("AUTH_EMAIL_VERIFICATION_EXPIRY", 24*time.Hour),
+		EmailVerificationResendPeriod: getEnvAsDuration("AUTH_EMAIL_VERIFICATION_RESEND_PERIOD", time.Minute),
+		MFAIssuer:                     getEnv("AUTH_MFA_ISSUER", "Go API"),
+		MFASecretKey:                  getEnv("AUTH_MFA_SECRET_KEY", "your-mfa-secret-key-change-in-production"),
+		MFATokenExpiry:                getEnvAsDuration("AUTH_MFA_TOKEN_EXPIRY", 5*time.Minute),
+		PasswordChangeTokenExpiry:     getEnvAsDuration("AUTH_PASSWORD_CHANGE_TOKEN_EXPIRY", 10*time.Minute),
+		ImpersonationTokenExpiry:      getEnvAsDuration("AUTH_IMPERSONATION_TOKEN_EXPIRY", 15*time.Minute),
+		MagicLinkEnabled:              getEnvAsBool("AUTH_MAGIC_LINK_ENABLED", false),
+		MagicLinkExpiry:               getEnvAsDuration("AUTH_MAGIC_LINK_EXPIRY", 15*time.Minute),
+		MagicLinkResendPeriod:         getEnvAsDuration("AUTH_MAGIC_LINK_RESEND_PERIOD", time.Minute),
+		LoginAttemptWindow:            getEnvAsDuration("AUTH_LOGIN_ATTEMPT_WINDOW", 15*time.Minute),
+		LoginDelayAfter:               getEnvAsInt("AUTH_LOGIN_DELAY_AFTER", 3),
+		LoginBaseDelay:                getEnvAsDuration("AUTH_LOGIN_BASE_DELAY", time.Second),
+		LoginMaxDelay:                 getEnvAsDuration("AUTH_LOGIN_MAX_DELAY", 30*time.Second),
+		LoginMaxAttempts:              getEnvAsInt("AUTH_LOGIN_MAX_ATTEMPTS", 10),
+		LoginMaxAttemptsPerIP:         getEnvAsInt("AUTH_LOGIN_MAX_ATTEMPTS_PER_IP", 50),
+		LoginLockoutDuration:          getEnvAsDuration("AUTH_LOGIN_LOCKOUT_DURATION", 15*time.Minute),
+		RegistrationMode:              getEnv("AUTH_REGISTRATION_MODE", "open"),
+		SelfRegistrationRoles:         getEnvAsSlice("AUTH_SELF_REGISTRATION_ROLES"),
+		InvitationExpiry:              getEnvAsDuration("AUTH_INVITATION_EXPIRY", 72*time.Hour),
+		PermissionCacheTTL:            getEnvAsDuration("AUTH_PERMISSION_CACHE_TTL", 10*time.Minute),
+		DeletedUserRetention:          getEnvAsDuration("AUTH_DELETED_USER_RETENTION", 30*24*time.Hour),
+		DeletedUserPurgeInterval:      getEnvAsDuration("AUTH_DELETED_USER_PURGE_INTERVAL", time.Hour),
+		UserImportMaxRows:             getEnvAsInt("AUTH_USER_IMPORT_MAX_ROWS", 1000),
+		UserImportMaxFileSize:         getEnvAsInt("AUTH_USER_IMPORT_MAX_FILE_SIZE", 5<<20),
+		UserImportJobTTL:              getEnvAsDuration("AUTH_USER_IMPORT_JOB_TTL", 24*time.Hour),
+		UserExportURLExpiry:           getEnvAsDuration("AUTH_USER_EXPORT_URL_EXPIRY", time.Hour),
+		AvatarMaxFileSize:             getEnvAsInt("AUTH_AVATAR_MAX_FILE_SIZE", 5<<20),
+		AvatarMaxPixels:               getEnvAsInt("AUTH_AVATAR_MAX_PIXELS", 25_000_000),
+		AvatarURLExpiry:               getEnvAsDuration("AUTH_AVATAR_URL_EXPIRY", time.Hour),
+	}
+	if len(config.Auth.SelfRegistrationRoles) == 0 {
+		config.Auth.SelfRegistrationRoles = []string{"USER"}
+	}
+
+	// Load OIDC settings
+	config.OIDC = setting.OIDCSetting{
+		RedirectURL: getEnv("OIDC_REDIRECT_URL", config.System.AppBaseURL+"/oidc/callback"),
+		StateExpiry: getEnvAsDuration("OIDC_STATE_EXPIRY", 10*time.Minute),
+		Providers:   getEnvAsOIDCProviders("OIDC_PROVIDERS"),
+	}
+
+	// Load WebAuthn settings
+	config.WebAuthn = setting.WebAuthnSetting{
+		RPID:            getEnv("WEBAUTHN_RP_ID", "localhost"),
+		RPDisplayName:   getEnv("WEBAUTHN_RP_DISPLAY_NAME", "Go API"),
+		RPOrigins:       getEnvAsSlice("WEBAUTHN_RP_ORIGINS"),
+		ChallengeExpiry: getEnvAsDuration("WEBAUTHN_CHALLENGE_EXPIRY", 5*time.Minute),
+	}
+	if len(config.WebAuthn.RPOrigins) == 0 {
+		config.WebAuthn.RPOrigins = []string{config.System.AppBaseURL}
+	}
+
+	// Load Password settings
+	config.Password = setting.PasswordSetting{
+		HashAlgorithm:     getEnv("PASSWORD_HASH_ALGORITHM", "argon2id"),
+		BcryptCost:        getEnvAsInt("PASSWORD_BCRYPT_COST", 12),
+		Argon2Memory:      getEnvAsInt("PASSWORD_ARGON2_MEMORY", 64*1024),
+		Argon2Iterations:  getEnvAsInt("PASSWORD_ARGON2_ITERATIONS", 3),
+		Argon2Parallelism: getEnvAsInt("PASSWORD_ARGON2_PARALLELISM", 2),
+		MinLength:         getEnvAsInt("PASSWORD_MIN_LENGTH", 8),
+		MaxLength:         getEnvAsInt("PASSWORD_MAX_LENGTH", 128),
+		RequireUppercase:  getEnvAsBool("PASSWORD_REQUIRE_UPPERCASE", false),
+		RequireLowercase:  getEnvAsBool("PASSWORD_REQUIRE_LOWERCASE", false),
+		RequireDigit:      getEnvAsBool("PASSWORD_REQUIRE_DIGIT", false),
+		RequireSymbol:     getEnvAsBool("PASSWORD_REQUIRE_SYMBOL", false),
+		DisallowUserInfo:  getEnvAsBool("PASSWORD_DISALLOW_USER_INFO", true),
+		HistorySize:       getEnvAsInt("PASSWORD_HISTORY_SIZE", 5),
+		MaxAge:            getEnvAsDuration("PASSWORD_MAX_AGE", 0),
+		BreachedListFile:  getEnv("PASSWORD_BREACHED_LIST_FILE", ""),
+	}
+
+	// Load Policy settings
+	config.Policy = setting.PolicySetting{
+		File: getEnv("POLICY_FILE", ""),
+	}
+
 	return nil
 }
 
@@ -130,3 +228,51 @@ func getEnvAsDuration(name string, defaultVal time.Duration) time.Duration {
 	}
 	return defaultVal
 }
+
+// getEnvAsSlice parses a comma separated list, empty entries are skipped
+func getEnvAsSlice(name string) []string {
+	var values []string
+	for _, value := range strings.Split(getEnv(name, ""), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// getEnvAsJWTKeys parses "kid=/path/to/key.pem,kid2=/path/to/key2.pem"
+func getEnvAsJWTKeys(name string) []setting.JWTKeySetting {
+	var keys []setting.JWTKeySetting
+	for _, entry := range strings.Split(getEnv(name, ""), ",") {
+		kid, file, found := strings.Cut(strings.TrimSpace(entry), "=")
+		if !found {
+			continue
+		}
+		keys = append(keys, setting.JWTKeySetting{KeyID: kid, KeyFile: file})
+	}
+	return keys
+}
+
+// getEnvAsOIDCProviders reads the providers listed in name, each configured by OIDC_<NAME>_* variables
+func getEnvAsOIDCProviders(name string) []setting.OIDCProviderSetting {
+	var providers []setting.OIDCProviderSetting
+	for _, provider := range getEnvAsSlice(name) {
+		prefix := "OIDC_" + strings.ToUpper(provider) + "_"
+		scopes := getEnvAsSlice(prefix + "SCOPES")
+		if len(scopes) == 0 {
+			scopes = []string{"openid", "email", "profile"}
+		}
+		providers = append(providers, setting.OIDCProviderSetting{
+			Name:           provider,
+			Issuer:         getEnv(prefix+"ISSUER", ""),
+			ClientID:       getEnv(prefix+"CLIENT_ID", ""),
+			ClientSecret:   getEnv(prefix+"CLIENT_SECRET", ""),
+			Scopes:         scopes,
+			LinkByEmail:    getEnvAsBool(prefix+"LINK_BY_EMAIL", false),
+			AutoProvision:  getEnvAsBool(prefix+"AUTO_PROVISION", false),
+			DefaultRole:    getEnv(prefix+"DEFAULT_ROLE", "USER"),
+			AllowedDomains: getEnvAsSlice(prefix + "ALLOWED_DOMAINS"),
+		})
+	}
+	return providers
+}
@@ -0,0 +1,18 @@
+package initialize
+
+import (
+	"app/global"
+	"app/pkg/password"
+)
+
+// InitPassword validates the password settings, creates the hasher and loads the password policy
+func InitPassword() {
+	hasher, err := password.NewHasher(global.Config.Password)
+	checkErrPanic(err, "Initialize password hasher failed")
+	global.PasswordHasher = hasher
+
+	policy, err := password.NewPolicy(global.Config.Password)
+	checkErrPanic(err, "Initialize password policy failed")
+	global.PasswordPolicy = policy
+	global.Logger.Info("Password hasher initialized, algorithm: " + global.Config.Password.HashAlgorithm)
+}
@@ -0,0 +1,29 @@
+package initialize
+
+import (
+	"app/global"
+	"app/pkg/policy"
+	_ "embed"
+)
+
+// defaultPolicy is used unless POLICY_FILE points to a policy of the deployment
+//
+//go:embed policies/default.policy.json
+var defaultPolicy []byte
+
+// InitPolicy loads the access policy services authorize requests with
+func InitPolicy() {
+	file := global.Config.Policy.File
+	if file == "" {
+		engine, err := policy.Parse(defaultPolicy)
+		checkErrPanic(err, "Initialize default access policy failed")
+		global.Policy = engine
+		global.Logger.Info("Access policy initialized, using the default policy")
+		return
+	}
+
+	engine, err := policy.Load(file)
+	checkErrPanic(err, "Initialize access policy failed")
+	global.Policy = engine
+	global.Logger.Info("Access policy initialized, file: " + file)
+}
@@ -21,6 +21,10 @@ func InitRouter() *gin.Engine {
 		r = gin.New()
 	}
 
+	// Only trust X-Forwarded-For from known proxies, the client IP is used for login throttling
+	err := r.SetTrustedProxies(global.Config.Server.TrustedProxies)
+	checkErrPanic(err, "Set trusted proxies failed")
+
 	// middleware - CORS cho tất cả origin (*)
 	r.Use(middlewares.CORSMiddleware())
 
@@ -30,6 +34,12 @@ func InitRouter() *gin.Engine {
 		userRouter.InitUserRouter(MainGroup)
 	}
 
+	// JWKS endpoint - public keys so other services can verify our tokens offline
+	r.GET("/.well-known/jwks.json", func(c *gin.Context) {
+		c.Header("Cache-Control", "public, max-age=300")
+		c.JSON(200, global.JWTKeys.JWKS())
+	})
+
 	// Swagger endpoint - với CORS đã được áp dụng
 	r.GET("/docs/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
@@ -18,9 +18,13 @@ func handleErr(err error) {
 func Run() {
 	LoadConfig()
 	InitLogger()
+	InitJWT()
+	InitPassword()
+	InitPolicy()
 	Postgres()
 	Redis()
 	InitMinIO()
+	InitUserPurge()
 	InitKafkaConsumer()
 
 	r := InitRouter()
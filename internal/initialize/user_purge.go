@@ -0,0 +1,57 @@
+package initialize
+
+import (
+	"app/global"
+	"app/internal/modules/user/service"
+	"app/internal/third_party/redis"
+	"app/internal/wire"
+	"context"
+	"fmt"
+	"time"
+)
+
+// userPurgeLockKey lets one instance purge per interval when several run
+const userPurgeLockKey = "user_purge_lock"
+
+// InitUserPurge starts the background job permanently deleting users past the deleted user retention window
+func InitUserPurge() {
+	retention := global.Config.Auth.DeletedUserRetention
+	interval := global.Config.Auth.DeletedUserPurgeInterval
+	if retention <= 0 || interval <= 0 {
+		global.Logger.Info("Deleted user purge disabled")
+		return
+	}
+
+	purgeService, err := wire.InitUserPurgeService()
+	checkErrPanic(err, "Initialize deleted user purge failed")
+	redisProvider := redis.NewRedisProvider()
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			purgeDeletedUsers(purgeService, redisProvider, retention, interval)
+			<-ticker.C
+		}
+	}()
+	global.Logger.Info(fmt.Sprintf("Deleted user purge started, retention: %s, interval: %s", retention, interval))
+}
+
+func purgeDeletedUsers(purgeService service.IUserPurgeService, redisProvider *redis.RedisProvider, retention time.Duration, interval time.Duration) {
+	locked, err := redisProvider.SetNX(context.Background(), userPurgeLockKey, 1, interval)
+	if err != nil {
+		global.Logger.Error("Failed to lock deleted user purge: " + err.Error())
+		return
+	}
+	if !locked {
+		return
+	}
+
+	purged, err := purgeService.PurgeDeletedUsers(retention)
+	if err != nil {
+		global.Logger.Error("Failed to purge deleted users: " + err.Error())
+	}
+	if purged > 0 {
+		global.Logger.Info(fmt.Sprintf("Purged %d deleted users", purged))
+	}
+}
@@ -2,49 +2,337 @@ package middlewares
 
 import (
 	"app/global"
+	"app/internal/modules/user/constants"
+	"app/internal/modules/user/model"
+	"app/internal/modules/user/service"
+	"app/internal/third_party/redis"
 	"app/pkg/jwt"
+	"app/pkg/policy"
 	"app/pkg/response"
+	"context"
+	"net/http"
+	"slices"
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 )
 
-func AuthMiddleware() gin.HandlerFunc {
+// AuthMiddleware builds the middlewares that authenticate requests and check what they may do
+type AuthMiddleware struct {
+	redisProvider     *redis.RedisProvider
+	apiKeyService     service.IAPIKeyService
+	sessionService    service.ISessionService
+	auditService      service.IAuditService
+	permissionService service.IPermissionService
+	membershipService service.IMembershipService
+}
+
+func NewAuthMiddleware(
+	redisProvider *redis.RedisProvider,
+	apiKeyService service.IAPIKeyService,
+	sessionService service.ISessionService,
+	auditService service.IAuditService,
+	permissionService service.IPermissionService,
+	membershipService service.IMembershipService,
+) *AuthMiddleware {
+	return &AuthMiddleware{
+		redisProvider:     redisProvider,
+		apiKeyService:     apiKeyService,
+		sessionService:    sessionService,
+		auditService:      auditService,
+		permissionService: permissionService,
+		membershipService: membershipService,
+	}
+}
+
+// Authenticate accepts a Bearer JWT. API keys are refused, they only reach the routes that check their scopes,
+// see AuthenticateWithAPIKey. With an impersonation token the real actor is set as actor_id and every write is
+// recorded in the audit log.
+func (m *AuthMiddleware) Authenticate() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		authHeader := c.Request.Header.Get("Authorization")
-		if authHeader == "" {
-			response.DataDetailResponse(c, 401, response.ErrCodeUnauthorized, nil)
+		if apiKeyFromRequest(c) != "" {
+			response.DataDetailResponse(c, 403, response.ErrCodeAccessDenied, nil)
 			c.Abort()
 			return
 		}
 
-		// Check if the header has the "Bearer" prefix
-		if !strings.HasPrefix(authHeader, "Bearer ") {
-			response.DataDetailResponse(c, 401, response.ErrInvalidToken, nil)
+		m.authenticateToken(c)
+	}
+}
+
+// AuthenticateWithAPIKey accepts a Bearer JWT like Authenticate or an API key, sent in the X-API-Key header or as
+// the Bearer token. Every route behind it must check the scopes of a key, with RequirePermission or RequireScopes.
+func (m *AuthMiddleware) AuthenticateWithAPIKey() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if apiKey := apiKeyFromRequest(c); apiKey != "" {
+			authenticateAPIKey(c, m.apiKeyService, apiKey)
+			return
+		}
+
+		m.authenticateToken(c)
+	}
+}
+
+func (m *AuthMiddleware) authenticateToken(c *gin.Context) {
+	authHeader := c.Request.Header.Get("Authorization")
+	if authHeader == "" {
+		response.DataDetailResponse(c, 401, response.ErrCodeUnauthorized, nil)
+		c.Abort()
+		return
+	}
+
+	// Check if the header has the "Bearer" prefix
+	if !strings.HasPrefix(authHeader, "Bearer ") {
+		response.DataDetailResponse(c, 401, response.ErrInvalidToken, nil)
+		c.Abort()
+		return
+	}
+
+	// Extract the token
+	tokenString := strings.TrimPrefix(authHeader, "Bearer ")
+	claims, err := jwt.ValidateToken(tokenString, global.JWTKeys)
+	if err != nil {
+		response.DataDetailResponse(c, 401, response.ErrInvalidToken, nil)
+		c.Abort()
+		return
+	}
+
+	// Reject tokens revoked by logout, by a token version bump or with their session
+	if revoked, err := m.isTokenRevoked(c.Request.Context(), claims); err != nil {
+		global.Logger.Error("Failed to check token revocation: " + err.Error())
+		response.DataDetailResponse(c, 500, response.ErrCodeInternalError, nil)
+		c.Abort()
+		return
+	} else if revoked {
+		response.DataDetailResponse(c, 401, response.ErrCodeTokenRevoked, nil)
+		c.Abort()
+		return
+	}
+
+	// Store user info in context for later use
+	c.Set("user_id", claims.UserID)
+	c.Set("email", claims.Email)
+	c.Set("system_role", claims.SystemRole)
+	c.Set("token_id", claims.ID)
+	c.Set("token_expires_at", claims.ExpiresAt.Time)
+	c.Set("session_id", claims.FamilyID)
+	if claims.OrganizationID != nil {
+		c.Set("token_organization_id", *claims.OrganizationID)
+	}
+	setPolicySubject(c, policy.Attributes{"id": claims.UserID, "role": claims.SystemRole})
+
+	if claims.FamilyID != "" {
+		m.sessionService.TouchSession(claims.FamilyID, c.ClientIP())
+	}
+
+	if claims.Act != nil {
+		c.Set("actor_id", claims.Act.Subject)
+		setPolicySubject(c, policy.Attributes{"actor_id": claims.Act.Subject})
+		c.Next()
+		auditImpersonatedRequest(c, m.auditService, claims)
+		return
+	}
+
+	c.Next()
+}
+
+// auditImpersonatedRequest records the writes made with an impersonation token, reads are not recorded
+func auditImpersonatedRequest(c *gin.Context, auditService service.IAuditService, claims *jwt.JWTClaims) {
+	switch c.Request.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return
+	}
+
+	auditService.Record(&model.AuditLog{
+		ActorID:   &claims.Act.Subject,
+		UserID:    &claims.UserID,
+		Action:    constants.AuditActionImpersonationRequest,
+		TokenID:   claims.ID,
+		Method:    c.Request.Method,
+		Path:      c.Request.URL.Path,
+		Status:    c.Writer.Status(),
+		IPAddress: c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	})
+}
+
+// ActorID returns the user really making the request when it is made with an impersonation token
+func ActorID(c *gin.Context) (uuid.UUID, bool) {
+	value, exists := c.Get("actor_id")
+	if !exists {
+		return uuid.Nil, false
+	}
+	actorID, ok := value.(uuid.UUID)
+	return actorID, ok
+}
+
+// RejectImpersonation keeps impersonation tokens away from sensitive endpoints, e.g. password, MFA or API keys
+func RejectImpersonation() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, impersonating := ActorID(c); impersonating {
+			response.DataDetailResponse(c, 403, response.ErrCodeImpersonation, nil)
 			c.Abort()
 			return
 		}
 
-		// Extract the token
-		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-		claims, err := jwt.ValidateToken(tokenString, global.Config.JWT.SecretKey)
+		c.Next()
+	}
+}
+
+// AuthenticatePasswordChange authenticates the change password endpoint. Besides a login it accepts the
+// restricted token login issues while the password must be changed, that token is refused everywhere else.
+func (m *AuthMiddleware) AuthenticatePasswordChange() gin.HandlerFunc {
+	authenticate := m.Authenticate()
+
+	return func(c *gin.Context) {
+		if apiKeyFromRequest(c) != "" {
+			response.DataDetailResponse(c, 403, response.ErrCodeAccessDenied, nil)
+			c.Abort()
+			return
+		}
+
+		tokenString, _ := strings.CutPrefix(c.Request.Header.Get("Authorization"), "Bearer ")
+		claims, err := jwt.ValidateScopedToken(tokenString, jwt.TokenTypePasswordChange, global.JWTKeys)
 		if err != nil {
-			response.DataDetailResponse(c, 401, response.ErrInvalidToken, nil)
+			authenticate(c)
+			return
+		}
+
+		// The restricted token is single use and dies with the user's other tokens
+		if revoked, err := m.isScopedTokenRevoked(c.Request.Context(), claims); err != nil || revoked {
+			response.DataDetailResponse(c, 401, response.ErrCodeTokenRevoked, nil)
 			c.Abort()
 			return
 		}
 
-		// Store user info in context for later use
 		c.Set("user_id", claims.UserID)
-		c.Set("email", claims.Email)
-		c.Set("system_role", claims.SystemRole)
+		c.Set("token_id", claims.ID)
+		c.Set("token_expires_at", claims.ExpiresAt.Time)
+		c.Set("password_change_required", true)
 
 		c.Next()
 	}
 }
 
-// RoleMiddleware checks if the user has a required role
-func RoleMiddleware(roles ...string) gin.HandlerFunc {
+func apiKeyFromRequest(c *gin.Context) string {
+	if apiKey := c.Request.Header.Get("X-API-Key"); apiKey != "" {
+		return apiKey
+	}
+	if token, ok := strings.CutPrefix(c.Request.Header.Get("Authorization"), "Bearer "); ok && service.IsAPIKey(token) {
+		return token
+	}
+	return ""
+}
+
+func authenticateAPIKey(c *gin.Context, apiKeyService service.IAPIKeyService, rawKey string) {
+	apiKey, user, err := apiKeyService.Authenticate(rawKey)
+	if err != nil {
+		response.DataDetailResponse(c, 401, response.ErrCodeAPIKeyInvalid, nil)
+		c.Abort()
+		return
+	}
+
+	// Same keys as a JWT login, plus the key and its scopes
+	c.Set("user_id", user.ID)
+	c.Set("email", user.Email)
+	c.Set("system_role", user.SystemRole)
+	c.Set("api_key_id", apiKey.ID)
+	c.Set("api_key_scopes", apiKey.Scopes)
+	setPolicySubject(c, policy.Attributes{"id": user.ID, "role": user.SystemRole, "scopes": apiKey.Scopes})
+
+	c.Next()
+}
+
+// setPolicySubject adds attributes to the policy subject of the request context, services authorize with it
+func setPolicySubject(c *gin.Context, attributes policy.Attributes) {
+	subject := policy.SubjectFromContext(c.Request.Context())
+	for name, value := range attributes {
+		subject[name] = value
+	}
+	c.Request = c.Request.WithContext(policy.WithSubject(c.Request.Context(), subject))
+}
+
+// HasScopes reports whether the request may use the permissions: always for a JWT, only within its scopes for an API key
+func HasScopes(c *gin.Context, permissions ...string) bool {
+	value, exists := c.Get("api_key_scopes")
+	if !exists {
+		return true
+	}
+
+	scopes, _ := value.([]string)
+	for _, permission := range permissions {
+		if !slices.Contains(scopes, permission) {
+			return false
+		}
+	}
+	return true
+}
+
+// RequireScopes limits API keys to routes within their scopes, a login passes. It guards the routes behind
+// AuthenticateWithAPIKey whose permissions the service checks itself, e.g. with the access policy.
+func RequireScopes(permissions ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !HasScopes(c, permissions...) {
+			response.DataDetailResponse(c, 403, response.ErrCodeAccessDenied, nil)
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// RejectAPIKey keeps API keys away from endpoints that manage the login itself, e.g. logout, MFA or API keys
+func RejectAPIKey() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, isAPIKey := c.Get("api_key_id"); isAPIKey {
+			response.DataDetailResponse(c, 403, response.ErrCodeAccessDenied, nil)
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+func (m *AuthMiddleware) isTokenRevoked(ctx context.Context, claims *jwt.JWTClaims) (bool, error) {
+	denied, err := m.redisProvider.IsTokenDenied(ctx, claims.ID)
+	if err != nil || denied {
+		return denied, err
+	}
+
+	// Tokens issued before sessions were recorded carry no session
+	if claims.FamilyID != "" {
+		active, err := m.sessionService.IsSessionActive(claims.FamilyID)
+		if err != nil || !active {
+			return !active, err
+		}
+	}
+
+	version, err := m.redisProvider.GetTokenVersion(ctx, claims.UserID.String())
+	if err != nil {
+		return false, err
+	}
+	return claims.TokenVersion != version, nil
+}
+
+func (m *AuthMiddleware) isScopedTokenRevoked(ctx context.Context, claims *jwt.JWTClaims) (bool, error) {
+	denied, err := m.redisProvider.IsTokenDenied(ctx, claims.ID)
+	if err != nil || denied {
+		return denied, err
+	}
+
+	version, err := m.redisProvider.GetTokenVersion(ctx, claims.UserID.String())
+	if err != nil {
+		return false, err
+	}
+	return claims.TokenVersion != version, nil
+}
+
+// RequirePermission checks that the user's role grants every given permission, directly or through its parents,
+// and for an API key that the permissions are within its scopes
+func (m *AuthMiddleware) RequirePermission(permissions ...string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userRole, exists := c.Get("system_role")
 		if !exists {
@@ -53,16 +341,15 @@ func RoleMiddleware(roles ...string) gin.HandlerFunc {
 			return
 		}
 
-		// Check if a user role is in the allowed roles
-		hasRole := false
-		for _, role := range roles {
-			if userRole == role {
-				hasRole = true
-				break
-			}
+		allowed, err := m.permissionService.HasPermission(userRole.(string), permissions...)
+		if err != nil {
+			global.Logger.Error("Failed to check permissions: " + err.Error())
+			response.DataDetailResponse(c, 500, response.ErrCodeInternalError, nil)
+			c.Abort()
+			return
 		}
 
-		if !hasRole {
+		if !allowed || !HasScopes(c, permissions...) {
 			response.DataDetailResponse(c, 403, response.ErrCodeAccessDenied, nil)
 			c.Abort()
 			return
@@ -0,0 +1,50 @@
+package middlewares
+
+import (
+	"app/internal/modules/user/constants"
+	"app/internal/modules/user/model"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type fakeAPIKeyService struct {
+	scopes []string
+}
+
+func (f *fakeAPIKeyService) Authenticate(rawKey string) (*model.APIKey, *model.User, error) {
+	return &model.APIKey{ID: uuid.New(), Scopes: f.scopes}, &model.User{ID: uuid.New(), SystemRole: constants.Admin}, nil
+}
+
+func TestAPIKeyNeedsScopeOfRoute(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	m := NewAuthMiddleware(nil, &fakeAPIKeyService{scopes: []string{constants.PermissionUserList}}, nil, nil, nil, nil)
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+
+	router := gin.New()
+	router.GET("/me", m.Authenticate(), ok)
+	router.GET("/users", m.AuthenticateWithAPIKey(), RequireScopes(constants.PermissionUserList), ok)
+	router.PUT("/users", m.AuthenticateWithAPIKey(), RequireScopes(constants.PermissionUserUpdate), ok)
+
+	tests := []struct {
+		method string
+		path   string
+		status int
+	}{
+		{http.MethodGet, "/me", http.StatusForbidden},
+		{http.MethodGet, "/users", http.StatusOK},
+		{http.MethodPut, "/users", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, nil)
+		req.Header.Set("X-API-Key", constants.APIKeyPrefix+"key")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != tt.status {
+			t.Errorf("%s %s with an API key = %d, want %d", tt.method, tt.path, w.Code, tt.status)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package middlewares
+
+import (
+	"app/global"
+	"app/internal/modules/user/constants"
+	"app/internal/modules/user/repo"
+	"app/pkg/policy"
+	"app/pkg/response"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// Tenant resolves the organization of the request, must run after Authenticate.
+// The organization selected in the token wins, otherwise it is read from the X-Organization-ID header,
+// which is how API keys pick one. The user must be a member of an active organization.
+// User queries of the request are then limited to the members of the organization.
+func (m *AuthMiddleware) Tenant() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		m.tenant(c)
+	}
+}
+
+// OptionalTenant is Tenant for routes that are global without an organization, it only resolves one when the
+// token has one selected or the header is sent
+func (m *AuthMiddleware) OptionalTenant() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		_, selected := c.Get("token_organization_id")
+		if !selected && c.GetHeader(constants.OrganizationHeader) == "" {
+			c.Next()
+			return
+		}
+		m.tenant(c)
+	}
+}
+
+func (m *AuthMiddleware) tenant(c *gin.Context) {
+	organizationID, ok := resolveOrganization(c)
+	if !ok {
+		return
+	}
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		response.DataDetailResponse(c, 401, response.ErrInvalidToken, nil)
+		c.Abort()
+		return
+	}
+
+	member, err := m.membershipService.GetMembership(organizationID, userID.(uuid.UUID))
+	if err != nil {
+		global.Logger.Error("Failed to get organization membership: " + err.Error())
+		response.DataDetailResponse(c, 500, response.ErrCodeInternalError, nil)
+		c.Abort()
+		return
+	}
+	if member == nil {
+		response.DataDetailResponse(c, 403, response.ErrCodeOrgAccessDenied, nil)
+		c.Abort()
+		return
+	}
+
+	c.Set("organization_id", organizationID)
+	c.Set("organization_role", member.Role)
+	setPolicySubject(c, policy.Attributes{"organization_id": organizationID, "organization_role": member.Role})
+	c.Request = c.Request.WithContext(repo.WithTenant(c.Request.Context(), organizationID))
+
+	c.Next()
+}
+
+// resolveOrganization aborts the request when no organization is given or the header disagrees with the token
+func resolveOrganization(c *gin.Context) (uuid.UUID, bool) {
+	var headerID *uuid.UUID
+	if header := c.GetHeader(constants.OrganizationHeader); header != "" {
+		id, err := uuid.Parse(header)
+		if err != nil {
+			response.DataDetailResponse(c, 400, response.ErrCodeInvalidParams, nil)
+			c.Abort()
+			return uuid.Nil, false
+		}
+		headerID = &id
+	}
+
+	if value, exists := c.Get("token_organization_id"); exists {
+		tokenID := value.(uuid.UUID)
+		if headerID != nil && *headerID != tokenID {
+			response.DataDetailResponse(c, 403, response.ErrCodeOrgAccessDenied, nil)
+			c.Abort()
+			return uuid.Nil, false
+		}
+		return tokenID, true
+	}
+
+	if headerID == nil {
+		response.DataDetailResponse(c, 400, response.ErrCodeOrgRequired, nil)
+		c.Abort()
+		return uuid.Nil, false
+	}
+	return *headerID, true
+}
+
+// OrganizationID returns the organization resolved by Tenant
+func OrganizationID(c *gin.Context) uuid.UUID {
+	value, _ := c.Get("organization_id")
+	organizationID, _ := value.(uuid.UUID)
+	return organizationID
+}
+
+// RequireOrganizationRole checks the role of the user in the organization resolved by Tenant
+func RequireOrganizationRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role, _ := c.Get("organization_role")
+		if !slices.Contains(roles, role.(string)) {
+			response.DataDetailResponse(c, 403, response.ErrCodeOrgAccessDenied, nil)
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -6,3 +6,89 @@ const (
 	Admin      = "ADMIN"
 	User       = "USER"
 )
+
+// Permissions, granted to roles through the role_permissions table
+const (
+	PermissionUserList             = "user:list"
+	PermissionUserCreate           = "user:create"
+	PermissionUserUpdate           = "user:update"
+	PermissionUserUnlock           = "user:unlock"
+	PermissionInvitationManage     = "invitation:manage"
+	PermissionMFAPolicyManage      = "mfa_policy:manage"
+	PermissionRoleManage           = "role:manage"
+	PermissionServiceAccountManage = "service_account:manage"
+	PermissionSessionView          = "session:view"
+	PermissionUserImpersonate      = "user:impersonate"
+	PermissionAuditLogView         = "audit_log:view"
+	PermissionOrganizationManage   = "organization:manage"
+	PermissionUserDelete           = "user:delete"
+	PermissionUserExport           = "user:export"
+)
+
+// Policy actions, authorized by the access policy on the attributes of the subject and the resource
+const (
+	ActionUserRead   = "user:read"
+	ActionUserUpdate = "user:update"
+	// ActionUserManage changes the role or the activation of a user
+	ActionUserManage = "user:manage"
+)
+
+// Organization roles, a member's role inside one organization, independent of the system role
+const (
+	OrganizationRoleOwner  = "OWNER"
+	OrganizationRoleAdmin  = "ADMIN"
+	OrganizationRoleMember = "MEMBER"
+)
+
+// OrganizationHeader selects the organization of a request when the token does not carry one
+const OrganizationHeader = "X-Organization-ID"
+
+// API keys start with this prefix so they are told apart from JWTs and easy to spot in leaked secrets
+const APIKeyPrefix = "ak_"
+
+// ServiceAccountEmailDomain service accounts get a placeholder email on this reserved, undeliverable domain
+const ServiceAccountEmailDomain = "service-account.invalid"
+
+// Registration modes
+const (
+	RegistrationOpen       = "open"
+	RegistrationClosed     = "closed"
+	RegistrationInviteOnly = "invite_only"
+)
+
+// One-time token purposes
+const (
+	TokenPurposePasswordReset     = "password_reset"
+	TokenPurposeEmailVerification = "email_verification"
+	TokenPurposeOIDCState         = "oidc_state"
+	TokenPurposeMagicLink         = "magic_link"
+	TokenPurposePasskeyRegister   = "passkey_registration"
+	TokenPurposePasskeyLogin      = "passkey_login"
+	TokenPurposeOrgInvitation     = "organization_invitation"
+)
+
+// Second factors offered by the MFA step of login
+const (
+	MFAMethodTOTP    = "totp"
+	MFAMethodPasskey = "passkey"
+)
+
+// Background job kinds and statuses
+const (
+	JobKindUserImport = "user_import"
+
+	JobStatusPending   = "pending"
+	JobStatusRunning   = "running"
+	JobStatusCompleted = "completed"
+	JobStatusFailed    = "failed"
+)
+
+// Audit log actions
+const (
+	AuditActionImpersonationStart   = "impersonation.start"
+	AuditActionImpersonationStop    = "impersonation.stop"
+	AuditActionImpersonationRequest = "impersonation.request"
+	AuditActionUserDelete           = "user.delete"
+	AuditActionUserRestore          = "user.restore"
+	AuditActionUserExport           = "user.export"
+)
@@ -2,9 +2,14 @@ package controller
 
 import (
 	"app/global"
+	"app/internal/middlewares"
+	"app/internal/modules/user/constants"
 	"app/internal/modules/user/dto"
 	"app/internal/modules/user/service"
 	"app/pkg/response"
+	"app/pkg/spreadsheet"
+	"fmt"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -20,16 +25,25 @@ func NewUserController(userService service.IUserService) *UserController {
 	}
 }
 
+// clientInfo describes the device of the request, recorded on the session of a login
+func clientInfo(c *gin.Context) dto.ClientInfo {
+	return dto.ClientInfo{
+		IPAddress: c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	}
+}
+
 // Register godoc
 // @Summary Register a new user
-// @Description Register a new user and return JWT token
+// @Description Register a new user and return JWT token. Depending on the registration policy an invitation token is required, it presets the role.
 // @Tags auth
 // @Accept json
 // @Produce json
 // @Param user body dto.RegisterRequestDto true "User Registration Data"
 // @Success 200 {object} response.Response{data=dto.AuthResponseDto} "Registration successful"
-// @Failure 400 {object} response.Response "Invalid request data"
-// @Failure 422 {object} response.Response "User already exists"
+// @Failure 400 {object} response.Response "Invalid request data or invitation"
+// @Failure 403 {object} response.Response "Registration closed or role not allowed"
+// @Failure 422 {object} response.Response{data=[]password.Violation} "User already exists, invalid request data or password policy violations"
 // @Router /user/register [post]
 func (uc *UserController) Register(c *gin.Context) {
 	var registerRequest dto.RegisterRequestDto
@@ -38,13 +52,13 @@ func (uc *UserController) Register(c *gin.Context) {
 		return
 	}
 
-	result := uc.userService.Register(registerRequest)
+	result := uc.userService.Register(registerRequest, clientInfo(c))
 	response.HandleServiceResult(c, result)
 }
 
 // Login godoc
 // @Summary Login user
-// @Description Authenticate user and return JWT token
+// @Description Authenticate user and return JWT token. Users who must change their password (temporary or expired password) get a password_change_token instead, only accepted by /user/change_password.
 // @Tags auth
 // @Accept json
 // @Produce json
@@ -52,6 +66,8 @@ func (uc *UserController) Register(c *gin.Context) {
 // @Success 200 {object} response.Response{data=dto.AuthResponseDto} "Login successful"
 // @Failure 400 {object} response.Response "Invalid request data"
 // @Failure 401 {object} response.Response "Invalid credentials"
+// @Failure 403 {object} response.Response "Account locked or email not verified"
+// @Failure 429 {object} response.Response "Too many failed attempts, see Retry-After"
 // @Router /user/login [post]
 func (uc *UserController) Login(c *gin.Context) {
 	var loginRequest dto.LoginRequestDto
@@ -60,146 +76,2082 @@ func (uc *UserController) Login(c *gin.Context) {
 		return
 	}
 
-	result := uc.userService.Login(loginRequest.Username, loginRequest.Password)
+	result := uc.userService.Login(loginRequest.Username, loginRequest.Password, clientInfo(c))
 	response.HandleServiceResult(c, result)
 }
 
-// GetUserByID godoc
-// @Summary Get user by ID
-// @Description Retrieves a user by their ID
-// @Tags user
+// RefreshToken godoc
+// @Summary Refresh tokens
+// @Description Exchange a refresh token for a new access token and a rotated refresh token. Reusing an already rotated refresh token revokes the whole token family.
+// @Tags auth
 // @Accept json
 // @Produce json
-// @Param id path string true "User ID"
-// @Success 200 {object} response.Response{data=dto.UserResponseDto} "User details"
-// @Failure 422 {object} response.Response "Invalid user ID"
-// @Router /user/get_user/{id} [get]
-func (uc *UserController) GetUserByID(c *gin.Context) {
-	idParam := c.Param("id")
-	id, err := uuid.Parse(idParam)
-	if err != nil {
+// @Param body body dto.RefreshTokenRequestDto true "Refresh Token"
+// @Success 200 {object} response.Response{data=dto.AuthResponseDto} "Tokens refreshed"
+// @Failure 401 {object} response.Response "Invalid or reused refresh token"
+// @Failure 422 {object} response.Response "Invalid request data"
+// @Router /user/refresh [post]
+func (uc *UserController) RefreshToken(c *gin.Context) {
+	var refreshRequest dto.RefreshTokenRequestDto
+	if err := c.ShouldBindJSON(&refreshRequest); err != nil {
 		response.DataDetailResponse(c, 422, response.ErrCodeInvalidData, nil)
 		return
 	}
 
-	result := uc.userService.GetUserByID(id)
+	result := uc.userService.RefreshToken(refreshRequest.RefreshToken, clientInfo(c))
 	response.HandleServiceResult(c, result)
 }
 
-// GetListUser godoc
-// @Summary Get all users (Admin only)
-// @Description Returns a paginated list of users with filtering options. Only admin users can access this endpoint.
-// @Tags user
+// Logout godoc
+// @Summary Logout
+// @Description Revoke the current access token and its session, including the refresh token family of the session
+// @Tags auth
 // @Accept json
 // @Produce json
 // @Security ApiKeyAuth
-// @Param skip query int false "Skip" default(0)
-// @Param limit query int false "Limit" default(10)
-// @Param email query string false "Email"
-// @Param username query string false "Username"
-// @Param system_role query string false "Status filter" Enums(ADMIN, USER, SUPER_ADMIN)
-// @Success 200 {object} response.Response{data=dto.UserListResponseDto} "Paginated list of users"
-// @Failure 400 {object} response.Response "Invalid query parameters"
+// @Param body body dto.LogoutRequestDto false "Refresh Token"
+// @Success 200 {object} response.Response "Logout successful"
 // @Failure 401 {object} response.Response "Unauthorized"
-// @Failure 403 {object} response.Response "Access denied: Only admin can view user list"
-// @Router /user/list_user [get]
-func (uc *UserController) GetListUser(c *gin.Context) {
-	var req dto.UserListRequestDto
+// @Router /user/logout [post]
+func (uc *UserController) Logout(c *gin.Context) {
+	var logoutRequest dto.LogoutRequestDto
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&logoutRequest); err != nil {
+			response.DataDetailResponse(c, 422, response.ErrCodeInvalidData, nil)
+			return
+		}
+	}
 
-	if err := c.ShouldBindQuery(&req); err != nil {
-		global.Logger.Error("Failed to bind query parameters: " + err.Error())
-		response.DataDetailResponse(c, 422, response.ErrCodeInvalidParams, nil)
+	userID, _ := c.Get("user_id")
+	sessionID, _ := c.Get("session_id")
+	tokenID, _ := c.Get("token_id")
+	expiresAt, _ := c.Get("token_expires_at")
+	result := uc.userService.Logout(userID.(uuid.UUID), sessionID.(string), tokenID.(string), expiresAt.(time.Time), logoutRequest.RefreshToken)
+	response.HandleServiceResult(c, result)
+}
+
+// LogoutAll godoc
+// @Summary Logout everywhere
+// @Description Revoke every access and refresh token issued to the current user
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} response.Response "Logout successful"
+// @Failure 401 {object} response.Response "Unauthorized"
+// @Router /user/logout_all [post]
+func (uc *UserController) LogoutAll(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	result := uc.userService.LogoutAll(userID.(uuid.UUID))
+	response.HandleServiceResult(c, result)
+}
+
+// StartMagicLinkLogin godoc
+// @Summary Request a magic link
+// @Description Email a single-use sign-in link when magic link login is enabled. The response is the same whether or not the email is registered.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param body body dto.MagicLinkRequestDto true "Account Email"
+// @Success 200 {object} response.Response "Sign-in link sent if the account exists"
+// @Failure 403 {object} response.Response "Magic link login disabled"
+// @Failure 422 {object} response.Response "Invalid request data"
+// @Failure 429 {object} response.Response "A link was requested for this email too recently"
+// @Router /user/login/magic [post]
+func (uc *UserController) StartMagicLinkLogin(c *gin.Context) {
+	var magicRequest dto.MagicLinkRequestDto
+	if err := c.ShouldBindJSON(&magicRequest); err != nil {
+		response.DataDetailResponse(c, 422, response.ErrCodeInvalidData, nil)
 		return
 	}
 
-	role, _ := c.Get("system_role")
-	result := uc.userService.GetListUser(req, role.(string))
+	result := uc.userService.StartMagicLinkLogin(magicRequest.Email)
 	response.HandleServiceResult(c, result)
 }
 
-// CreateUser godoc
-// @Summary Create a new user
-// @Description Creates a new user with the provided information
-// @Tags user
+// MagicLinkCallback godoc
+// @Summary Login with a magic link
+// @Description Exchange the token of a magic link for JWT tokens, or an mfa_token when two-factor authentication is required. The link also verifies the email.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param body body dto.MagicLinkCallbackRequestDto true "Magic Link Token"
+// @Success 200 {object} response.Response{data=dto.AuthResponseDto} "Login successful"
+// @Failure 400 {object} response.Response "Invalid, expired or used magic link"
+// @Failure 403 {object} response.Response "Magic link login disabled or account locked"
+// @Failure 422 {object} response.Response "Invalid request data"
+// @Router /user/login/magic/callback [post]
+func (uc *UserController) MagicLinkCallback(c *gin.Context) {
+	var callbackRequest dto.MagicLinkCallbackRequestDto
+	if err := c.ShouldBindJSON(&callbackRequest); err != nil {
+		response.DataDetailResponse(c, 422, response.ErrCodeInvalidData, nil)
+		return
+	}
+
+	result := uc.userService.MagicLinkCallback(callbackRequest.Token, clientInfo(c))
+	response.HandleServiceResult(c, result)
+}
+
+// ForgotPassword godoc
+// @Summary Forgot password
+// @Description Email a single-use password reset link. The response is the same whether or not the email is registered.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param body body dto.ForgotPasswordRequestDto true "Account Email"
+// @Success 200 {object} response.Response "Reset link sent if the account exists"
+// @Failure 422 {object} response.Response "Invalid request data"
+// @Failure 429 {object} response.Response "A reset link was requested for this email too recently"
+// @Router /user/forgot_password [post]
+func (uc *UserController) ForgotPassword(c *gin.Context) {
+	var forgotRequest dto.ForgotPasswordRequestDto
+	if err := c.ShouldBindJSON(&forgotRequest); err != nil {
+		response.DataDetailResponse(c, 422, response.ErrCodeInvalidData, nil)
+		return
+	}
+
+	result := uc.userService.ForgotPassword(forgotRequest.Email)
+	response.HandleServiceResult(c, result)
+}
+
+// ResetPassword godoc
+// @Summary Reset password
+// @Description Set a new password with a reset token and log out every existing session
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param body body dto.ResetPasswordRequestDto true "Reset Token and New Password"
+// @Success 200 {object} response.Response "Password reset successful"
+// @Failure 400 {object} response.Response "Invalid, expired or used reset token"
+// @Failure 422 {object} response.Response{data=[]password.Violation} "Invalid request data or password policy violations"
+// @Router /user/reset_password [post]
+func (uc *UserController) ResetPassword(c *gin.Context) {
+	var resetRequest dto.ResetPasswordRequestDto
+	if err := c.ShouldBindJSON(&resetRequest); err != nil {
+		response.DataDetailResponse(c, 422, response.ErrCodeInvalidData, nil)
+		return
+	}
+
+	result := uc.userService.ResetPassword(resetRequest.Token, resetRequest.NewPassword)
+	response.HandleServiceResult(c, result)
+}
+
+// VerifyEmail godoc
+// @Summary Verify email
+// @Description Confirm the account email address with the token sent by email
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param body body dto.VerifyEmailRequestDto true "Verification Token"
+// @Success 200 {object} response.Response "Email verified"
+// @Failure 400 {object} response.Response "Invalid, expired or used verification token"
+// @Failure 422 {object} response.Response "Invalid request data"
+// @Router /user/verify_email [post]
+func (uc *UserController) VerifyEmail(c *gin.Context) {
+	var verifyRequest dto.VerifyEmailRequestDto
+	if err := c.ShouldBindJSON(&verifyRequest); err != nil {
+		response.DataDetailResponse(c, 422, response.ErrCodeInvalidData, nil)
+		return
+	}
+
+	result := uc.userService.VerifyEmail(verifyRequest.Token)
+	response.HandleServiceResult(c, result)
+}
+
+// ResendVerification godoc
+// @Summary Resend verification email
+// @Description Send a new verification link, previous links stop working. Throttled per email address.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param body body dto.ResendVerificationRequestDto true "Account Email"
+// @Success 200 {object} response.Response "Verification link sent if the account needs it"
+// @Failure 422 {object} response.Response "Invalid request data"
+// @Failure 429 {object} response.Response "Too many requests"
+// @Router /user/resend_verification [post]
+func (uc *UserController) ResendVerification(c *gin.Context) {
+	var resendRequest dto.ResendVerificationRequestDto
+	if err := c.ShouldBindJSON(&resendRequest); err != nil {
+		response.DataDetailResponse(c, 422, response.ErrCodeInvalidData, nil)
+		return
+	}
+
+	result := uc.userService.ResendVerification(resendRequest.Email)
+	response.HandleServiceResult(c, result)
+}
+
+// LoginMFA godoc
+// @Summary Login MFA step
+// @Description Exchange the mfa_token returned by login and a TOTP code (or a recovery code) for tokens. For an enrollment required by the role, the first code from /user/login/mfa/enroll completes enrollment and recovery codes are returned once.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param body body dto.MFALoginRequestDto true "MFA Token and Code"
+// @Success 200 {object} response.Response{data=dto.AuthResponseDto} "Login successful"
+// @Failure 401 {object} response.Response "Invalid MFA token or code"
+// @Failure 422 {object} response.Response "Invalid request data"
+// @Failure 429 {object} response.Response "Too many attempts"
+// @Router /user/login/mfa [post]
+func (uc *UserController) LoginMFA(c *gin.Context) {
+	var mfaRequest dto.MFALoginRequestDto
+	if err := c.ShouldBindJSON(&mfaRequest); err != nil {
+		response.DataDetailResponse(c, 422, response.ErrCodeInvalidData, nil)
+		return
+	}
+
+	result := uc.userService.LoginMFA(mfaRequest.MFAToken, mfaRequest.Code, mfaRequest.RecoveryCode, clientInfo(c))
+	response.HandleServiceResult(c, result)
+}
+
+// StartLoginMFAEnrollment godoc
+// @Summary Enroll MFA during login
+// @Description Start TOTP enrollment with the mfa_token of a login whose role requires MFA
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param body body dto.MFALoginEnrollRequestDto true "MFA Token"
+// @Success 200 {object} response.Response{data=dto.MFAEnrollResponseDto} "TOTP secret and provisioning URI"
+// @Failure 401 {object} response.Response "Invalid MFA token"
+// @Failure 409 {object} response.Response "MFA already enabled"
+// @Router /user/login/mfa/enroll [post]
+func (uc *UserController) StartLoginMFAEnrollment(c *gin.Context) {
+	var enrollRequest dto.MFALoginEnrollRequestDto
+	if err := c.ShouldBindJSON(&enrollRequest); err != nil {
+		response.DataDetailResponse(c, 422, response.ErrCodeInvalidData, nil)
+		return
+	}
+
+	result := uc.userService.StartLoginMFAEnrollment(enrollRequest.MFAToken)
+	response.HandleServiceResult(c, result)
+}
+
+// EnrollMFA godoc
+// @Summary Start MFA enrollment
+// @Description Generate a TOTP secret for the current user, MFA is enabled once a code is confirmed
+// @Tags mfa
 // @Accept json
 // @Produce json
 // @Security ApiKeyAuth
-// @Param user body dto.CreateUserDto true "User Information"
-// @Success 200 {object} response.Response{data=map[string]interface{}} "User created successfully"
-// @Failure 400 {object} response.Response "Invalid request payload"
+// @Success 200 {object} response.Response{data=dto.MFAEnrollResponseDto} "TOTP secret and provisioning URI"
 // @Failure 401 {object} response.Response "Unauthorized"
-// @Failure 403 {object} response.Response "Forbidden"
-// @Failure 405 {object} response.Response "User already exists"
-// @Router /user/create_user [post]
-func (uc *UserController) CreateUser(c *gin.Context) {
-	userRequest := dto.CreateUserDto{}
+// @Failure 409 {object} response.Response "MFA already enabled"
+// @Router /user/mfa/enroll [post]
+func (uc *UserController) EnrollMFA(c *gin.Context) {
+	userID, _ := c.Get("user_id")
 
-	if err := c.ShouldBindJSON(&userRequest); err != nil {
+	result := uc.userService.EnrollMFA(userID.(uuid.UUID))
+	response.HandleServiceResult(c, result)
+}
+
+// ConfirmMFA godoc
+// @Summary Confirm MFA enrollment
+// @Description Enable MFA with the first TOTP code, recovery codes are returned once
+// @Tags mfa
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param body body dto.MFACodeRequestDto true "TOTP Code"
+// @Success 200 {object} response.Response{data=dto.MFARecoveryCodesResponseDto} "MFA enabled"
+// @Failure 400 {object} response.Response "Enrollment not started"
+// @Failure 401 {object} response.Response "Invalid code"
+// @Router /user/mfa/confirm [post]
+func (uc *UserController) ConfirmMFA(c *gin.Context) {
+	var codeRequest dto.MFACodeRequestDto
+	if err := c.ShouldBindJSON(&codeRequest); err != nil {
 		response.DataDetailResponse(c, 422, response.ErrCodeInvalidData, nil)
 		return
 	}
 
-	dataUser := dto.UserRequestDto{
-		Email:      userRequest.Email,
-		Username:   userRequest.Username,
-		SystemRole: userRequest.SystemRole,
-		Password:   global.Config.System.DefaultPassWord,
+	userID, _ := c.Get("user_id")
+	result := uc.userService.ConfirmMFA(userID.(uuid.UUID), codeRequest.Code)
+	response.HandleServiceResult(c, result)
+}
+
+// DisableMFA godoc
+// @Summary Disable MFA
+// @Description Disable MFA with a TOTP code or a recovery code, not allowed when the role requires MFA
+// @Tags mfa
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param body body dto.MFACodeRequestDto true "TOTP Code or Recovery Code"
+// @Success 200 {object} response.Response "MFA disabled"
+// @Failure 401 {object} response.Response "Invalid code"
+// @Failure 403 {object} response.Response "MFA required for role"
+// @Router /user/mfa/disable [post]
+func (uc *UserController) DisableMFA(c *gin.Context) {
+	var codeRequest dto.MFACodeRequestDto
+	if err := c.ShouldBindJSON(&codeRequest); err != nil {
+		response.DataDetailResponse(c, 422, response.ErrCodeInvalidData, nil)
+		return
 	}
-	if userRequest.FullName != "" {
-		dataUser.FullName = userRequest.FullName
+
+	userID, _ := c.Get("user_id")
+	result := uc.userService.DisableMFA(userID.(uuid.UUID), codeRequest.Code)
+	response.HandleServiceResult(c, result)
+}
+
+// RegenerateRecoveryCodes godoc
+// @Summary Regenerate MFA recovery codes
+// @Description Replace every recovery code, requires a TOTP code
+// @Tags mfa
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param body body dto.MFACodeRequestDto true "TOTP Code"
+// @Success 200 {object} response.Response{data=dto.MFARecoveryCodesResponseDto} "New recovery codes"
+// @Failure 401 {object} response.Response "Invalid code"
+// @Router /user/mfa/recovery_codes [post]
+func (uc *UserController) RegenerateRecoveryCodes(c *gin.Context) {
+	var codeRequest dto.MFACodeRequestDto
+	if err := c.ShouldBindJSON(&codeRequest); err != nil {
+		response.DataDetailResponse(c, 422, response.ErrCodeInvalidData, nil)
+		return
 	}
 
-	result := uc.userService.CreateUser(dataUser)
+	userID, _ := c.Get("user_id")
+	result := uc.userService.RegenerateRecoveryCodes(userID.(uuid.UUID), codeRequest.Code)
 	response.HandleServiceResult(c, result)
 }
 
-// UpdateUser godoc
-// @Summary Update user by ID
-// @Description Updates user information by user ID (email cannot be updated)
-// @Tags user
+// GetMFAPolicies godoc
+// @Summary Get MFA policies (Admin only)
+// @Description List the roles and whether they must use MFA
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} response.Response{data=[]model.RoleMFAPolicy} "MFA policies"
+// @Failure 403 {object} response.Response "Access denied"
+// @Router /admin/mfa_policies [get]
+func (uc *UserController) GetMFAPolicies(c *gin.Context) {
+	result := uc.userService.GetMFAPolicies()
+	response.HandleServiceResult(c, result)
+}
+
+// SetMFAPolicy godoc
+// @Summary Set MFA policy (Admin only)
+// @Description Require or stop requiring MFA for a role
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param body body dto.RoleMFAPolicyRequestDto true "MFA Policy"
+// @Success 200 {object} response.Response{data=model.RoleMFAPolicy} "MFA policy saved"
+// @Failure 403 {object} response.Response "Access denied"
+// @Failure 422 {object} response.Response "Invalid request data"
+// @Router /admin/mfa_policies [put]
+func (uc *UserController) SetMFAPolicy(c *gin.Context) {
+	var policyRequest dto.RoleMFAPolicyRequestDto
+	if err := c.ShouldBindJSON(&policyRequest); err != nil {
+		response.DataDetailResponse(c, 422, response.ErrCodeInvalidData, nil)
+		return
+	}
+
+	result := uc.userService.SetMFAPolicy(policyRequest.SystemRole, *policyRequest.MFARequired)
+	response.HandleServiceResult(c, result)
+}
+
+// UnlockUser godoc
+// @Summary Unlock user login (Admin only)
+// @Description Clear the failed login counters and the temporary lockout of a user
+// @Tags admin
 // @Accept json
 // @Produce json
 // @Security ApiKeyAuth
 // @Param id path string true "User ID"
-// @Param user body dto.UserUpdateRequestDto true "User Update Data (username, password, role only)"
-// @Success 200 {object} response.Response{data=dto.UserResponseDto} "User updated successfully"
-// @Failure 400 {object} response.Response "Invalid request data"
-// @Failure 401 {object} response.Response "Unauthorized"
+// @Success 200 {object} response.Response "User unlocked"
+// @Failure 403 {object} response.Response "Access denied"
 // @Failure 404 {object} response.Response "User not found"
-// @Router /user/update_user/{id} [put]
-func (uc *UserController) UpdateUser(c *gin.Context) {
-	idParam := c.Param("id")
-	id, err := uuid.Parse(idParam)
+// @Router /admin/unlock_user/{id} [post]
+func (uc *UserController) UnlockUser(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
 	if err != nil {
 		response.DataDetailResponse(c, 422, response.ErrCodeInvalidParams, nil)
 		return
 	}
 
-	var updateRequest dto.UserUpdateRequestDto
-	if err := c.ShouldBindJSON(&updateRequest); err != nil {
+	result := uc.userService.UnlockUser(id)
+	response.HandleServiceResult(c, result)
+}
+
+// CreateInvitation godoc
+// @Summary Invite a user (Admin only)
+// @Description Email a signed invitation link that lets the address register with a preset role
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param body body dto.CreateInvitationRequestDto true "Invitation"
+// @Success 200 {object} response.Response{data=dto.InvitationResponseDto} "Invitation created"
+// @Failure 403 {object} response.Response "Access denied or role not allowed"
+// @Failure 409 {object} response.Response "User already exists"
+// @Router /admin/invitations [post]
+func (uc *UserController) CreateInvitation(c *gin.Context) {
+	var invitationRequest dto.CreateInvitationRequestDto
+	if err := c.ShouldBindJSON(&invitationRequest); err != nil {
 		response.DataDetailResponse(c, 422, response.ErrCodeInvalidData, nil)
 		return
 	}
 
 	userID, _ := c.Get("user_id")
 	userRole, _ := c.Get("system_role")
-	result := uc.userService.UpdateUser(id, updateRequest, userRole.(string), userID.(uuid.UUID))
+	result := uc.userService.CreateInvitation(invitationRequest, userID.(uuid.UUID), userRole.(string))
 	response.HandleServiceResult(c, result)
 }
 
-// GetCurrentUser godoc
-// @Summary Get current user
-// @Description Get the currently log in user's information
-// @Tags user
+// GetListInvitation godoc
+// @Summary List invitations (Admin only)
+// @Description Returns a paginated list of invitations
+// @Tags admin
 // @Accept json
 // @Produce json
 // @Security ApiKeyAuth
-// @Success 200 {object} response.Response{data=dto.UserResponseDto} "Current user"
-// @Failure 401 {object} response.Response "Unauthorized"
-// @Router /user/me [get]
-func (uc *UserController) GetCurrentUser(c *gin.Context) {
-	userID, _ := c.Get("user_id")
+// @Param skip query int false "Skip" default(0)
+// @Param limit query int false "Limit" default(10)
+// @Success 200 {object} response.Response{data=dto.InvitationListResponseDto} "Paginated list of invitations"
+// @Failure 403 {object} response.Response "Access denied"
+// @Router /admin/invitations [get]
+func (uc *UserController) GetListInvitation(c *gin.Context) {
+	var req dto.InvitationListRequestDto
+	if err := c.ShouldBindQuery(&req); err != nil {
+		response.DataDetailResponse(c, 422, response.ErrCodeInvalidParams, nil)
+		return
+	}
+
+	result := uc.userService.GetListInvitation(req)
+	response.HandleServiceResult(c, result)
+}
+
+// RevokeInvitation godoc
+// @Summary Revoke an invitation (Admin only)
+// @Description Revoke an invitation that has not been accepted yet
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path string true "Invitation ID"
+// @Success 200 {object} response.Response "Invitation revoked"
+// @Failure 403 {object} response.Response "Access denied"
+// @Failure 404 {object} response.Response "Invitation not found or no longer pending"
+// @Router /admin/invitations/{id} [delete]
+func (uc *UserController) RevokeInvitation(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		response.DataDetailResponse(c, 422, response.ErrCodeInvalidParams, nil)
+		return
+	}
+
+	result := uc.userService.RevokeInvitation(id)
+	response.HandleServiceResult(c, result)
+}
+
+// GetRoles godoc
+// @Summary List roles (Admin only)
+// @Description Returns every role with its own and its effective (inherited) permissions
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} response.Response{data=[]dto.RoleResponseDto} "Roles"
+// @Failure 403 {object} response.Response "Access denied"
+// @Router /admin/roles [get]
+func (uc *UserController) GetRoles(c *gin.Context) {
+	result := uc.userService.GetRoles()
+	response.HandleServiceResult(c, result)
+}
+
+// GetPermissions godoc
+// @Summary List permissions (Admin only)
+// @Description Returns every permission that can be granted to a role
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} response.Response{data=[]model.Permission} "Permissions"
+// @Failure 403 {object} response.Response "Access denied"
+// @Router /admin/permissions [get]
+func (uc *UserController) GetPermissions(c *gin.Context) {
+	result := uc.userService.GetPermissions()
+	response.HandleServiceResult(c, result)
+}
 
-	result := uc.userService.GetUserByID(userID.(uuid.UUID))
+// CreateRole godoc
+// @Summary Create a role (Admin only)
+// @Description Create a role with its permissions, it also inherits the permissions of its parent role. Only SUPER_ADMIN grants permissions its role does not hold.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param body body dto.CreateRoleRequestDto true "Role"
+// @Success 200 {object} response.Response{data=dto.RoleResponseDto} "Role created"
+// @Failure 400 {object} response.Response "Invalid parent role or permission"
+// @Failure 403 {object} response.Response "Access denied, or a permission or parent role granting more than the actor holds"
+// @Failure 409 {object} response.Response "Role already exists"
+// @Router /admin/roles [post]
+func (uc *UserController) CreateRole(c *gin.Context) {
+	var roleRequest dto.CreateRoleRequestDto
+	if err := c.ShouldBindJSON(&roleRequest); err != nil {
+		response.DataDetailResponse(c, 422, response.ErrCodeInvalidData, nil)
+		return
+	}
+
+	userRole, _ := c.Get("system_role")
+	result := uc.userService.CreateRole(roleRequest, userRole.(string))
 	response.HandleServiceResult(c, result)
 }
+
+// UpdateRole godoc
+// @Summary Update a role (Admin only)
+// @Description Replace the description, parent role and permissions of a role. Only SUPER_ADMIN grants permissions its role does not hold.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param name path string true "Role name"
+// @Param body body dto.UpdateRoleRequestDto true "Role"
+// @Success 200 {object} response.Response{data=dto.RoleResponseDto} "Role updated"
+// @Failure 400 {object} response.Response "Invalid parent role or permission"
+// @Failure 403 {object} response.Response "Access denied, or a permission or parent role granting more than the actor holds"
+// @Failure 404 {object} response.Response "Role not found"
+// @Router /admin/roles/{name} [put]
+func (uc *UserController) UpdateRole(c *gin.Context) {
+	var roleRequest dto.UpdateRoleRequestDto
+	if err := c.ShouldBindJSON(&roleRequest); err != nil {
+		response.DataDetailResponse(c, 422, response.ErrCodeInvalidData, nil)
+		return
+	}
+
+	userRole, _ := c.Get("system_role")
+	result := uc.userService.UpdateRole(c.Param("name"), roleRequest, userRole.(string))
+	response.HandleServiceResult(c, result)
+}
+
+// DeleteRole godoc
+// @Summary Delete a role (Admin only)
+// @Description Delete a custom role that is not assigned to any user nor inherited by another role
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param name path string true "Role name"
+// @Success 200 {object} response.Response "Role deleted"
+// @Failure 403 {object} response.Response "Access denied"
+// @Failure 404 {object} response.Response "Role not found"
+// @Failure 409 {object} response.Response "Role in use"
+// @Router /admin/roles/{name} [delete]
+func (uc *UserController) DeleteRole(c *gin.Context) {
+	result := uc.userService.DeleteRole(c.Param("name"))
+	response.HandleServiceResult(c, result)
+}
+
+// CreateAPIKey godoc
+// @Summary Create an API key
+// @Description Create an API key for the current user. The key is only returned once, its scopes must be permissions of the user's role.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param body body dto.CreateAPIKeyRequestDto true "API key"
+// @Success 200 {object} response.Response{data=dto.APIKeyResponseDto} "API key created"
+// @Failure 403 {object} response.Response "Scope not allowed"
+// @Router /user/api_keys [post]
+func (uc *UserController) CreateAPIKey(c *gin.Context) {
+	var apiKeyRequest dto.CreateAPIKeyRequestDto
+	if err := c.ShouldBindJSON(&apiKeyRequest); err != nil {
+		response.DataDetailResponse(c, 422, response.ErrCodeInvalidData, nil)
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	result := uc.userService.CreateAPIKey(userID.(uuid.UUID), apiKeyRequest)
+	response.HandleServiceResult(c, result)
+}
+
+// GetAPIKeys godoc
+// @Summary List API keys
+// @Description List the API keys of the current user, revoked ones included
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} response.Response{data=[]model.APIKey} "API keys"
+// @Failure 401 {object} response.Response "Unauthorized"
+// @Router /user/api_keys [get]
+func (uc *UserController) GetAPIKeys(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	result := uc.userService.GetAPIKeys(userID.(uuid.UUID))
+	response.HandleServiceResult(c, result)
+}
+
+// RevokeAPIKey godoc
+// @Summary Revoke an API key
+// @Description Revoke an API key of the current user
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path string true "API key ID"
+// @Success 200 {object} response.Response "API key revoked"
+// @Failure 404 {object} response.Response "API key not found"
+// @Router /user/api_keys/{id} [delete]
+func (uc *UserController) RevokeAPIKey(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		response.DataDetailResponse(c, 422, response.ErrCodeInvalidParams, nil)
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	result := uc.userService.RevokeAPIKey(userID.(uuid.UUID), id)
+	response.HandleServiceResult(c, result)
+}
+
+// CreateServiceAccount godoc
+// @Summary Create a service account (Admin only)
+// @Description Create a user that cannot log in and authenticates with API keys only
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param body body dto.CreateServiceAccountRequestDto true "Service account"
+// @Success 200 {object} response.Response{data=dto.UserResponseDto} "Service account created"
+// @Failure 403 {object} response.Response "Access denied or role not allowed"
+// @Failure 409 {object} response.Response "User already exists"
+// @Router /admin/service_accounts [post]
+func (uc *UserController) CreateServiceAccount(c *gin.Context) {
+	var accountRequest dto.CreateServiceAccountRequestDto
+	if err := c.ShouldBindJSON(&accountRequest); err != nil {
+		response.DataDetailResponse(c, 422, response.ErrCodeInvalidData, nil)
+		return
+	}
+
+	userRole, _ := c.Get("system_role")
+	result := uc.userService.CreateServiceAccount(accountRequest, userRole.(string))
+	response.HandleServiceResult(c, result)
+}
+
+// CreateServiceAccountAPIKey godoc
+// @Summary Create a service account API key (Admin only)
+// @Description Create an API key for a service account. The key is only returned once.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path string true "Service account ID"
+// @Param body body dto.CreateAPIKeyRequestDto true "API key"
+// @Success 200 {object} response.Response{data=dto.APIKeyResponseDto} "API key created"
+// @Failure 403 {object} response.Response "Access denied or scope not allowed"
+// @Failure 404 {object} response.Response "Service account not found"
+// @Router /admin/service_accounts/{id}/api_keys [post]
+func (uc *UserController) CreateServiceAccountAPIKey(c *gin.Context) {
+	accountID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		response.DataDetailResponse(c, 422, response.ErrCodeInvalidParams, nil)
+		return
+	}
+
+	var apiKeyRequest dto.CreateAPIKeyRequestDto
+	if err := c.ShouldBindJSON(&apiKeyRequest); err != nil {
+		response.DataDetailResponse(c, 422, response.ErrCodeInvalidData, nil)
+		return
+	}
+
+	userRole, _ := c.Get("system_role")
+	result := uc.userService.CreateServiceAccountAPIKey(accountID, apiKeyRequest, userRole.(string))
+	response.HandleServiceResult(c, result)
+}
+
+// GetServiceAccountAPIKeys godoc
+// @Summary List service account API keys (Admin only)
+// @Description List the API keys of a service account, revoked ones included
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path string true "Service account ID"
+// @Success 200 {object} response.Response{data=[]model.APIKey} "API keys"
+// @Failure 403 {object} response.Response "Access denied"
+// @Failure 404 {object} response.Response "Service account not found"
+// @Router /admin/service_accounts/{id}/api_keys [get]
+func (uc *UserController) GetServiceAccountAPIKeys(c *gin.Context) {
+	accountID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		response.DataDetailResponse(c, 422, response.ErrCodeInvalidParams, nil)
+		return
+	}
+
+	userRole, _ := c.Get("system_role")
+	result := uc.userService.GetServiceAccountAPIKeys(accountID, userRole.(string))
+	response.HandleServiceResult(c, result)
+}
+
+// RevokeServiceAccountAPIKey godoc
+// @Summary Revoke a service account API key (Admin only)
+// @Description Revoke an API key of a service account
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path string true "Service account ID"
+// @Param key_id path string true "API key ID"
+// @Success 200 {object} response.Response "API key revoked"
+// @Failure 403 {object} response.Response "Access denied"
+// @Failure 404 {object} response.Response "Service account or API key not found"
+// @Router /admin/service_accounts/{id}/api_keys/{key_id} [delete]
+func (uc *UserController) RevokeServiceAccountAPIKey(c *gin.Context) {
+	accountID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		response.DataDetailResponse(c, 422, response.ErrCodeInvalidParams, nil)
+		return
+	}
+	id, err := uuid.Parse(c.Param("key_id"))
+	if err != nil {
+		response.DataDetailResponse(c, 422, response.ErrCodeInvalidParams, nil)
+		return
+	}
+
+	userRole, _ := c.Get("system_role")
+	result := uc.userService.RevokeServiceAccountAPIKey(accountID, id, userRole.(string))
+	response.HandleServiceResult(c, result)
+}
+
+// GetSessions godoc
+// @Summary List sessions
+// @Description List the active sessions of the current user, the one of this request is flagged as current
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} response.Response{data=[]dto.SessionResponseDto} "Sessions"
+// @Failure 401 {object} response.Response "Unauthorized"
+// @Router /user/sessions [get]
+func (uc *UserController) GetSessions(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	sessionID, _ := c.Get("session_id")
+
+	result := uc.userService.GetSessions(userID.(uuid.UUID), sessionID.(string))
+	response.HandleServiceResult(c, result)
+}
+
+// RevokeSession godoc
+// @Summary Revoke a session
+// @Description Sign out one session of the current user, its tokens stop working immediately
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path string true "Session ID"
+// @Success 200 {object} response.Response "Session revoked"
+// @Failure 404 {object} response.Response "Session not found"
+// @Router /user/sessions/{id} [delete]
+func (uc *UserController) RevokeSession(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		response.DataDetailResponse(c, 422, response.ErrCodeInvalidParams, nil)
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	result := uc.userService.RevokeSession(userID.(uuid.UUID), id)
+	response.HandleServiceResult(c, result)
+}
+
+// RevokeOtherSessions godoc
+// @Summary Revoke all other sessions
+// @Description Sign out every session of the current user except the one of this request
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} response.Response "Sessions revoked"
+// @Failure 401 {object} response.Response "Unauthorized"
+// @Router /user/sessions/revoke_others [post]
+func (uc *UserController) RevokeOtherSessions(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	sessionID, _ := c.Get("session_id")
+
+	result := uc.userService.RevokeOtherSessions(userID.(uuid.UUID), sessionID.(string))
+	response.HandleServiceResult(c, result)
+}
+
+// GetUserSessions godoc
+// @Summary List the sessions of a user (Admin only)
+// @Description List the active sessions of a user
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path string true "User ID"
+// @Success 200 {object} response.Response{data=[]dto.SessionResponseDto} "Sessions"
+// @Failure 403 {object} response.Response "Access denied"
+// @Failure 404 {object} response.Response "User not found"
+// @Router /admin/users/{id}/sessions [get]
+func (uc *UserController) GetUserSessions(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		response.DataDetailResponse(c, 422, response.ErrCodeInvalidParams, nil)
+		return
+	}
+
+	userRole, _ := c.Get("system_role")
+	result := uc.userService.GetUserSessions(id, userRole.(string))
+	response.HandleServiceResult(c, result)
+}
+
+// GetUserByID godoc
+// @Summary Get user by ID
+// @Description Retrieves a user by their ID when the access policy allows it, by default the user themselves, holders of user:list and members of a shared organization
+// @Tags user
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param X-Organization-ID header string false "Organization ID limiting users to its members, when the token has none selected"
+// @Param id path string true "User ID"
+// @Success 200 {object} response.Response{data=dto.UserResponseDto} "User details"
+// @Failure 401 {object} response.Response "Unauthorized"
+// @Failure 403 {object} response.Response "Access denied by the access policy"
+// @Failure 422 {object} response.Response "Invalid user ID"
+// @Router /user/get_user/{id} [get]
+func (uc *UserController) GetUserByID(c *gin.Context) {
+	idParam := c.Param("id")
+	id, err := uuid.Parse(idParam)
+	if err != nil {
+		response.DataDetailResponse(c, 422, response.ErrCodeInvalidData, nil)
+		return
+	}
+
+	result := uc.userService.GetUserByID(c.Request.Context(), id)
+	response.HandleServiceResult(c, result)
+}
+
+// GetListUser godoc
+// @Summary Get all users
+// @Description Returns a paginated list of users with filtering options. Requires the user:list permission.
+// @Tags user
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param X-Organization-ID header string false "Organization ID limiting users to its members, when the token has none selected"
+// @Param skip query int false "Skip, ignored with a cursor" default(0)
+// @Param limit query int false "Limit" default(10)
+// @Param cursor query string false "next_cursor of the previous page"
+// @Param sort query string false "Comma separated fields among created_at, updated_at, username, email and system_role, prefixed by - for descending order" default(-created_at)
+// @Param skip_total query bool false "Leave the total out, null in the response"
+// @Param email query string false "Part of the email, case insensitive"
+// @Param username query string false "Part of the username, case insensitive"
+// @Param system_role query string false "Role filter"
+// @Param system_roles query []string false "Any of the roles" collectionFormat(multi)
+// @Param is_active query bool false "Active or inactive users only"
+// @Param created_from query string false "Created at or after, RFC 3339"
+// @Param created_to query string false "Created before, RFC 3339"
+// @Success 200 {object} response.Response{data=dto.UserListResponseDto} "Paginated list of users"
+// @Failure 401 {object} response.Response "Unauthorized"
+// @Failure 403 {object} response.Response "Access denied: user:list permission required"
+// @Failure 422 {object} response.Response "Invalid query parameters, sort or cursor"
+// @Router /user/list_user [get]
+func (uc *UserController) GetListUser(c *gin.Context) {
+	var req dto.UserListRequestDto
+
+	if err := c.ShouldBindQuery(&req); err != nil {
+		global.Logger.Error("Failed to bind query parameters: " + err.Error())
+		response.DataDetailResponse(c, 422, response.ErrCodeInvalidParams, nil)
+		return
+	}
+
+	result := uc.userService.GetListUser(c.Request.Context(), req)
+	response.HandleServiceResult(c, result)
+}
+
+// CreateUser godoc
+// @Summary Create a new user
+// @Description Creates a new user with the provided information. Requires the user:create permission, the role cannot grant more than the caller's own. The user gets a random temporary password, returned only in this response, and must change it at the first login.
+// @Tags user
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param user body dto.CreateUserDto true "User Information"
+// @Success 200 {object} response.Response{data=dto.CreateUserResponseDto} "User created with a temporary password"
+// @Failure 400 {object} response.Response "Invalid request payload"
+// @Failure 401 {object} response.Response "Unauthorized"
+// @Failure 403 {object} response.Response "Forbidden"
+// @Failure 405 {object} response.Response "User already exists"
+// @Failure 422 {object} response.Response "Invalid request data"
+// @Router /user/create_user [post]
+func (uc *UserController) CreateUser(c *gin.Context) {
+	userRequest := dto.CreateUserDto{}
+
+	if err := c.ShouldBindJSON(&userRequest); err != nil {
+		response.DataDetailResponse(c, 422, response.ErrCodeInvalidData, nil)
+		return
+	}
+
+	dataUser := dto.UserRequestDto{
+		Email:      userRequest.Email,
+		Username:   userRequest.Username,
+		SystemRole: userRequest.SystemRole,
+	}
+	if userRequest.FullName != "" {
+		dataUser.FullName = userRequest.FullName
+	}
+
+	userRole, _ := c.Get("system_role")
+	result := uc.userService.CreateUser(dataUser, userRole.(string))
+	response.HandleServiceResult(c, result)
+}
+
+// UpdateUser godoc
+// @Summary Update user by ID
+// @Description Updates user information by user ID (email cannot be updated)
+// @Tags user
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param X-Organization-ID header string false "Organization ID limiting users to its members, when the token has none selected"
+// @Param id path string true "User ID"
+// @Param user body dto.UserUpdateRequestDto true "User Update Data (username, password, role only)"
+// @Success 200 {object} response.Response{data=dto.UserResponseDto} "User updated successfully"
+// @Failure 400 {object} response.Response "Invalid request data"
+// @Failure 401 {object} response.Response "Unauthorized"
+// @Failure 403 {object} response.Response "Permission denied, or a password for the own account, which is changed through change_password"
+// @Failure 404 {object} response.Response "User not found"
+// @Failure 422 {object} response.Response{data=[]password.Violation} "Password policy violations"
+// @Router /user/update_user/{id} [put]
+func (uc *UserController) UpdateUser(c *gin.Context) {
+	idParam := c.Param("id")
+	id, err := uuid.Parse(idParam)
+	if err != nil {
+		response.DataDetailResponse(c, 422, response.ErrCodeInvalidParams, nil)
+		return
+	}
+
+	var updateRequest dto.UserUpdateRequestDto
+	if err := c.ShouldBindJSON(&updateRequest); err != nil {
+		response.DataDetailResponse(c, 422, response.ErrCodeInvalidData, nil)
+		return
+	}
+
+	// Passwords are not changed on behalf of somebody being impersonated
+	if _, impersonating := middlewares.ActorID(c); impersonating && updateRequest.Password != "" {
+		response.DataDetailResponse(c, 403, response.ErrCodeImpersonation, nil)
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	userRole, _ := c.Get("system_role")
+	result := uc.userService.UpdateUser(c.Request.Context(), id, updateRequest, userRole.(string), userID.(uuid.UUID))
+	response.HandleServiceResult(c, result)
+}
+
+// GetCurrentUser godoc
+// @Summary Get current user
+// @Description Get the currently log in user's information
+// @Tags user
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} response.Response{data=dto.UserResponseDto} "Current user"
+// @Failure 401 {object} response.Response "Unauthorized"
+// @Router /user/me [get]
+func (uc *UserController) GetCurrentUser(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	result := uc.userService.GetUserByID(c.Request.Context(), userID.(uuid.UUID))
+	response.HandleServiceResult(c, result)
+}
+
+// UploadAvatar godoc
+// @Summary Upload the avatar of the current user
+// @Description Replaces the avatar with square thumbnails of the uploaded JPEG, PNG, GIF or WebP image, the type is detected from the content. Returns presigned thumbnail URLs, also returned with the user as avatar.
+// @Tags user
+// @Accept multipart/form-data
+// @Produce json
+// @Security ApiKeyAuth
+// @Param file formData file true "Image"
+// @Success 200 {object} response.Response{data=dto.AvatarDto} "Avatar thumbnails"
+// @Failure 401 {object} response.Response "Unauthorized"
+// @Failure 422 {object} response.Response "Missing, too large or unsupported image"
+// @Router /user/me/avatar [put]
+func (uc *UserController) UploadAvatar(c *gin.Context) {
+	file, err := c.FormFile("file")
+	if err != nil {
+		response.DataDetailResponse(c, 422, response.ErrCodeAvatarInvalid, nil)
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	result := uc.userService.UploadAvatar(c.Request.Context(), userID.(uuid.UUID), file)
+	response.HandleServiceResult(c, result)
+}
+
+// DeleteAvatar godoc
+// @Summary Delete the avatar of the current user
+// @Description Removes the avatar and its thumbnails
+// @Tags user
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} response.Response "Avatar deleted"
+// @Failure 401 {object} response.Response "Unauthorized"
+// @Router /user/me/avatar [delete]
+func (uc *UserController) DeleteAvatar(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+
+	result := uc.userService.DeleteAvatar(userID.(uuid.UUID))
+	response.HandleServiceResult(c, result)
+}
+
+// GetOIDCProviders godoc
+// @Summary List OIDC providers
+// @Description List the names of the configured OpenID Connect providers
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Success 200 {object} response.Response{data=[]string} "Provider names"
+// @Router /user/oidc/providers [get]
+func (uc *UserController) GetOIDCProviders(c *gin.Context) {
+	result := uc.userService.GetOIDCProviders()
+	response.HandleServiceResult(c, result)
+}
+
+// StartOIDCLogin godoc
+// @Summary Start OIDC login
+// @Description Return the authorization URL of the provider. The browser is sent there and the provider redirects back to OIDC_REDIRECT_URL with a code and state.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param provider path string true "Provider name"
+// @Success 200 {object} response.Response{data=dto.OIDCAuthorizeResponseDto} "Authorization URL"
+// @Failure 404 {object} response.Response "Provider not found"
+// @Failure 502 {object} response.Response "Provider unavailable"
+// @Router /user/oidc/{provider}/authorize [post]
+func (uc *UserController) StartOIDCLogin(c *gin.Context) {
+	result := uc.userService.StartOIDCLogin(c.Param("provider"))
+	response.HandleServiceResult(c, result)
+}
+
+// OIDCCallback godoc
+// @Summary Complete OIDC login
+// @Description Exchange the code and state the provider redirected back with for tokens. An unknown identity is linked by verified email or provisioned when the provider allows it. Users with MFA get an mfa_token instead of tokens.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param provider path string true "Provider name"
+// @Param body body dto.OIDCCallbackRequestDto true "Code and State"
+// @Success 200 {object} response.Response{data=dto.AuthResponseDto} "Login successful"
+// @Failure 400 {object} response.Response "Invalid or expired state"
+// @Failure 401 {object} response.Response "Provider rejected the login"
+// @Failure 403 {object} response.Response "No linked account or account locked"
+// @Failure 422 {object} response.Response "Invalid request data"
+// @Router /user/oidc/{provider}/callback [post]
+func (uc *UserController) OIDCCallback(c *gin.Context) {
+	var callbackRequest dto.OIDCCallbackRequestDto
+	if err := c.ShouldBindJSON(&callbackRequest); err != nil {
+		response.DataDetailResponse(c, 422, response.ErrCodeInvalidData, nil)
+		return
+	}
+
+	result := uc.userService.OIDCCallback(c.Param("provider"), callbackRequest, clientInfo(c))
+	response.HandleServiceResult(c, result)
+}
+
+// StartOIDCLink godoc
+// @Summary Start linking an OIDC identity
+// @Description Return the authorization URL of the provider to link an identity to the current user
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param provider path string true "Provider name"
+// @Success 200 {object} response.Response{data=dto.OIDCAuthorizeResponseDto} "Authorization URL"
+// @Failure 404 {object} response.Response "Provider not found"
+// @Router /user/oidc/{provider}/link [post]
+func (uc *UserController) StartOIDCLink(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	result := uc.userService.StartOIDCLink(c.Param("provider"), userID.(uuid.UUID))
+	response.HandleServiceResult(c, result)
+}
+
+// OIDCLinkCallback godoc
+// @Summary Complete linking an OIDC identity
+// @Description Exchange the code and state the provider redirected back with and link the identity to the current user
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param provider path string true "Provider name"
+// @Param body body dto.OIDCCallbackRequestDto true "Code and State"
+// @Success 200 {object} response.Response{data=model.UserIdentity} "Identity linked"
+// @Failure 400 {object} response.Response "Invalid or expired state"
+// @Failure 401 {object} response.Response "Provider rejected the login"
+// @Failure 409 {object} response.Response "Identity linked to another account"
+// @Failure 422 {object} response.Response "Invalid request data"
+// @Router /user/oidc/{provider}/link/callback [post]
+func (uc *UserController) OIDCLinkCallback(c *gin.Context) {
+	var callbackRequest dto.OIDCCallbackRequestDto
+	if err := c.ShouldBindJSON(&callbackRequest); err != nil {
+		response.DataDetailResponse(c, 422, response.ErrCodeInvalidData, nil)
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	result := uc.userService.OIDCLinkCallback(c.Param("provider"), callbackRequest, userID.(uuid.UUID))
+	response.HandleServiceResult(c, result)
+}
+
+// GetIdentities godoc
+// @Summary List linked identities
+// @Description List the OpenID Connect identities linked to the current user
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} response.Response{data=[]model.UserIdentity} "Identities"
+// @Failure 401 {object} response.Response "Unauthorized"
+// @Router /user/identities [get]
+func (uc *UserController) GetIdentities(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	result := uc.userService.GetIdentities(userID.(uuid.UUID))
+	response.HandleServiceResult(c, result)
+}
+
+// UnlinkIdentity godoc
+// @Summary Unlink an identity
+// @Description Unlink an OpenID Connect identity from the current user
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path string true "Identity ID"
+// @Success 200 {object} response.Response "Identity unlinked"
+// @Failure 404 {object} response.Response "Identity not found"
+// @Router /user/identities/{id} [delete]
+func (uc *UserController) UnlinkIdentity(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		response.DataDetailResponse(c, 422, response.ErrCodeInvalidParams, nil)
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	result := uc.userService.UnlinkIdentity(userID.(uuid.UUID), id)
+	response.HandleServiceResult(c, result)
+}
+
+// ChangePassword godoc
+// @Summary Change password
+// @Description Change the password with the current one. Accepts an access token, every token issued before is then revoked, the other sessions are signed out and the current session gets new tokens, or the password_change_token returned by login, login then continues with the MFA step or returns tokens.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param body body dto.ChangePasswordRequestDto true "Current and New Password"
+// @Success 200 {object} response.Response{data=dto.AuthResponseDto} "Password changed, with the new tokens of the session"
+// @Failure 401 {object} response.Response "Unauthorized or wrong current password"
+// @Failure 422 {object} response.Response{data=[]password.Violation} "Invalid request data or password policy violations"
+// @Failure 429 {object} response.Response "Too many failed attempts, see Retry-After"
+// @Router /user/change_password [post]
+func (uc *UserController) ChangePassword(c *gin.Context) {
+	var changeRequest dto.ChangePasswordRequestDto
+	if err := c.ShouldBindJSON(&changeRequest); err != nil {
+		response.DataDetailResponse(c, 422, response.ErrCodeInvalidData, nil)
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	if _, restricted := c.Get("password_change_required"); restricted {
+		tokenID, _ := c.Get("token_id")
+		expiresAt, _ := c.Get("token_expires_at")
+		result := uc.userService.CompletePasswordChange(userID.(uuid.UUID), changeRequest, tokenID.(string), expiresAt.(time.Time), clientInfo(c))
+		response.HandleServiceResult(c, result)
+		return
+	}
+
+	sessionID, _ := c.Get("session_id")
+	result := uc.userService.ChangePassword(userID.(uuid.UUID), changeRequest, sessionID.(string), clientInfo(c))
+	response.HandleServiceResult(c, result)
+}
+
+// StartImpersonation godoc
+// @Summary Impersonate a user (Super admin only)
+// @Description Issue a short-lived token acting as the user, carrying the caller in its act claim. It cannot be refreshed, is refused by sensitive endpoints (password, MFA, API keys, sessions) and every write made with it is recorded in the audit log.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path string true "User ID"
+// @Success 200 {object} response.Response{data=dto.ImpersonationResponseDto} "Impersonation token"
+// @Failure 400 {object} response.Response "Cannot impersonate oneself"
+// @Failure 403 {object} response.Response "Access denied or user cannot be impersonated"
+// @Failure 404 {object} response.Response "User not found"
+// @Router /admin/users/{id}/impersonate [post]
+func (uc *UserController) StartImpersonation(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		response.DataDetailResponse(c, 422, response.ErrCodeInvalidParams, nil)
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	userRole, _ := c.Get("system_role")
+	result := uc.userService.StartImpersonation(id, userID.(uuid.UUID), userRole.(string), clientInfo(c))
+	response.HandleServiceResult(c, result)
+}
+
+// StopImpersonation godoc
+// @Summary Stop impersonating
+// @Description Revoke the impersonation token of the request
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} response.Response "Impersonation stopped"
+// @Failure 400 {object} response.Response "Not an impersonation token"
+// @Router /user/impersonation/stop [post]
+func (uc *UserController) StopImpersonation(c *gin.Context) {
+	actorID, impersonating := middlewares.ActorID(c)
+	if !impersonating {
+		response.DataDetailResponse(c, 400, response.ErrCodeImpersonation, nil)
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	tokenID, _ := c.Get("token_id")
+	expiresAt, _ := c.Get("token_expires_at")
+	result := uc.userService.StopImpersonation(userID.(uuid.UUID), actorID, tokenID.(string), expiresAt.(time.Time), clientInfo(c))
+	response.HandleServiceResult(c, result)
+}
+
+// GetListAuditLog godoc
+// @Summary List the audit log (Super admin only)
+// @Description Returns a paginated list of audit log entries, newest first
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param skip query int false "Skip" default(0)
+// @Param limit query int false "Limit" default(10)
+// @Param actor_id query string false "Actor ID"
+// @Param user_id query string false "User ID"
+// @Param action query string false "Action, e.g. impersonation.start"
+// @Success 200 {object} response.Response{data=dto.AuditLogListResponseDto} "Paginated audit log"
+// @Failure 403 {object} response.Response "Access denied"
+// @Router /admin/audit_logs [get]
+func (uc *UserController) GetListAuditLog(c *gin.Context) {
+	var req dto.AuditLogListRequestDto
+	if err := c.ShouldBindQuery(&req); err != nil {
+		response.DataDetailResponse(c, 422, response.ErrCodeInvalidParams, nil)
+		return
+	}
+
+	result := uc.userService.GetListAuditLog(req)
+	response.HandleServiceResult(c, result)
+}
+
+// StartPasskeyRegistration godoc
+// @Summary Start passkey registration
+// @Description Return the options for navigator.credentials.create, send the result to /user/passkeys/register/finish with the challenge ID
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} response.Response{data=dto.PasskeyChallengeResponseDto} "Registration options"
+// @Failure 401 {object} response.Response "Unauthorized"
+// @Router /user/passkeys/register/start [post]
+func (uc *UserController) StartPasskeyRegistration(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	result := uc.userService.StartPasskeyRegistration(userID.(uuid.UUID))
+	response.HandleServiceResult(c, result)
+}
+
+// FinishPasskeyRegistration godoc
+// @Summary Finish passkey registration
+// @Description Verify the response of the authenticator and save the passkey. Once registered it can be used to login without a password and as a second factor.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param body body dto.PasskeyRegistrationRequestDto true "Challenge ID, Passkey Name and Authenticator Response"
+// @Success 200 {object} response.Response{data=model.WebAuthnCredential} "Passkey registered"
+// @Failure 400 {object} response.Response "Invalid or expired challenge, or the response failed verification"
+// @Failure 422 {object} response.Response "Invalid request data"
+// @Router /user/passkeys/register/finish [post]
+func (uc *UserController) FinishPasskeyRegistration(c *gin.Context) {
+	var registrationRequest dto.PasskeyRegistrationRequestDto
+	if err := c.ShouldBindJSON(&registrationRequest); err != nil {
+		response.DataDetailResponse(c, 422, response.ErrCodeInvalidData, nil)
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	result := uc.userService.FinishPasskeyRegistration(userID.(uuid.UUID), registrationRequest)
+	response.HandleServiceResult(c, result)
+}
+
+// GetPasskeys godoc
+// @Summary List passkeys
+// @Description List the passkeys of the current user
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} response.Response{data=[]model.WebAuthnCredential} "Passkeys"
+// @Failure 401 {object} response.Response "Unauthorized"
+// @Router /user/passkeys [get]
+func (uc *UserController) GetPasskeys(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	result := uc.userService.GetPasskeys(userID.(uuid.UUID))
+	response.HandleServiceResult(c, result)
+}
+
+// DeletePasskey godoc
+// @Summary Delete a passkey
+// @Description Delete a passkey of the current user
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path string true "Passkey ID"
+// @Success 200 {object} response.Response "Passkey deleted"
+// @Failure 404 {object} response.Response "Passkey not found"
+// @Router /user/passkeys/{id} [delete]
+func (uc *UserController) DeletePasskey(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		response.DataDetailResponse(c, 422, response.ErrCodeInvalidParams, nil)
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	result := uc.userService.DeletePasskey(userID.(uuid.UUID), id)
+	response.HandleServiceResult(c, result)
+}
+
+// StartPasskeyLogin godoc
+// @Summary Start passkey login
+// @Description Return the options for navigator.credentials.get to login without a password, the browser lets the user pick a passkey
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Success 200 {object} response.Response{data=dto.PasskeyChallengeResponseDto} "Login options"
+// @Router /user/login/passkey/start [post]
+func (uc *UserController) StartPasskeyLogin(c *gin.Context) {
+	result := uc.userService.StartPasskeyLogin()
+	response.HandleServiceResult(c, result)
+}
+
+// PasskeyLogin godoc
+// @Summary Login with a passkey
+// @Description Verify the passkey response and return JWT tokens. The passkey verifies the user, so no MFA step follows.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param body body dto.PasskeyLoginRequestDto true "Challenge ID and Authenticator Response"
+// @Success 200 {object} response.Response{data=dto.AuthResponseDto} "Login successful"
+// @Failure 400 {object} response.Response "Invalid or expired challenge"
+// @Failure 401 {object} response.Response "Passkey response failed verification"
+// @Failure 403 {object} response.Response "Account locked or email not verified"
+// @Failure 422 {object} response.Response "Invalid request data"
+// @Router /user/login/passkey [post]
+func (uc *UserController) PasskeyLogin(c *gin.Context) {
+	var loginRequest dto.PasskeyLoginRequestDto
+	if err := c.ShouldBindJSON(&loginRequest); err != nil {
+		response.DataDetailResponse(c, 422, response.ErrCodeInvalidData, nil)
+		return
+	}
+
+	result := uc.userService.PasskeyLogin(loginRequest, clientInfo(c))
+	response.HandleServiceResult(c, result)
+}
+
+// StartPasskeyMFA godoc
+// @Summary Start passkey MFA step
+// @Description Return the options for navigator.credentials.get limited to the passkeys of the user of the mfa_token returned by login
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param body body dto.PasskeyMFAStartRequestDto true "MFA Token"
+// @Success 200 {object} response.Response{data=dto.PasskeyChallengeResponseDto} "Login options"
+// @Failure 400 {object} response.Response "No passkey registered"
+// @Failure 401 {object} response.Response "Invalid MFA token"
+// @Failure 422 {object} response.Response "Invalid request data"
+// @Failure 429 {object} response.Response "Too many attempts"
+// @Router /user/login/mfa/passkey/start [post]
+func (uc *UserController) StartPasskeyMFA(c *gin.Context) {
+	var mfaRequest dto.PasskeyMFAStartRequestDto
+	if err := c.ShouldBindJSON(&mfaRequest); err != nil {
+		response.DataDetailResponse(c, 422, response.ErrCodeInvalidData, nil)
+		return
+	}
+
+	result := uc.userService.StartPasskeyMFA(mfaRequest.MFAToken)
+	response.HandleServiceResult(c, result)
+}
+
+// LoginPasskeyMFA godoc
+// @Summary Login passkey MFA step
+// @Description Exchange the mfa_token returned by login and a passkey response for tokens
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param body body dto.PasskeyMFARequestDto true "MFA Token, Challenge ID and Authenticator Response"
+// @Success 200 {object} response.Response{data=dto.AuthResponseDto} "Login successful"
+// @Failure 400 {object} response.Response "Invalid or expired challenge"
+// @Failure 401 {object} response.Response "Invalid MFA token or passkey response"
+// @Failure 422 {object} response.Response "Invalid request data"
+// @Failure 429 {object} response.Response "Too many attempts"
+// @Router /user/login/mfa/passkey [post]
+func (uc *UserController) LoginPasskeyMFA(c *gin.Context) {
+	var mfaRequest dto.PasskeyMFARequestDto
+	if err := c.ShouldBindJSON(&mfaRequest); err != nil {
+		response.DataDetailResponse(c, 422, response.ErrCodeInvalidData, nil)
+		return
+	}
+
+	result := uc.userService.LoginPasskeyMFA(mfaRequest, clientInfo(c))
+	response.HandleServiceResult(c, result)
+}
+
+// CreateOrganization godoc
+// @Summary Create an organization
+// @Description Creates an organization, the optional owner becomes its first OWNER. Requires the organization:manage permission.
+// @Tags organization
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param body body dto.CreateOrganizationRequestDto true "Organization"
+// @Success 200 {object} response.Response{data=model.Organization} "Organization created"
+// @Failure 404 {object} response.Response "Owner not found"
+// @Failure 409 {object} response.Response "Slug already exists"
+// @Router /admin/organizations [post]
+func (uc *UserController) CreateOrganization(c *gin.Context) {
+	var req dto.CreateOrganizationRequestDto
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.DataDetailResponse(c, 422, response.ErrCodeInvalidData, nil)
+		return
+	}
+
+	result := uc.userService.CreateOrganization(req)
+	response.HandleServiceResult(c, result)
+}
+
+// GetListOrganization godoc
+// @Summary List organizations
+// @Description Returns a paginated list of organizations. Requires the organization:manage permission.
+// @Tags organization
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param skip query int false "Skip" default(0)
+// @Param limit query int false "Limit" default(10)
+// @Param name query string false "Name"
+// @Success 200 {object} response.Response{data=dto.OrganizationListResponseDto} "Paginated list of organizations"
+// @Router /admin/organizations [get]
+func (uc *UserController) GetListOrganization(c *gin.Context) {
+	var req dto.OrganizationListRequestDto
+	if err := c.ShouldBindQuery(&req); err != nil {
+		response.DataDetailResponse(c, 422, response.ErrCodeInvalidParams, nil)
+		return
+	}
+
+	result := uc.userService.GetListOrganization(req)
+	response.HandleServiceResult(c, result)
+}
+
+// UpdateOrganization godoc
+// @Summary Update an organization
+// @Description Renames, deactivates or reactivates an organization. Requires the organization:manage permission.
+// @Tags organization
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path string true "Organization ID"
+// @Param body body dto.UpdateOrganizationRequestDto true "Organization"
+// @Success 200 {object} response.Response{data=model.Organization} "Organization updated"
+// @Failure 404 {object} response.Response "Organization not found"
+// @Router /admin/organizations/{id} [put]
+func (uc *UserController) UpdateOrganization(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		response.DataDetailResponse(c, 422, response.ErrCodeInvalidParams, nil)
+		return
+	}
+
+	var req dto.UpdateOrganizationRequestDto
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.DataDetailResponse(c, 422, response.ErrCodeInvalidData, nil)
+		return
+	}
+
+	result := uc.userService.UpdateOrganization(id, req)
+	response.HandleServiceResult(c, result)
+}
+
+// GetListOrganizationMemberAdmin godoc
+// @Summary List members of an organization
+// @Description Returns a paginated list of the members of any organization. Requires the organization:manage permission.
+// @Tags organization
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path string true "Organization ID"
+// @Param skip query int false "Skip" default(0)
+// @Param limit query int false "Limit" default(10)
+// @Param role query string false "Organization role filter"
+// @Success 200 {object} response.Response{data=dto.OrganizationMemberListResponseDto} "Paginated list of members"
+// @Router /admin/organizations/{id}/members [get]
+func (uc *UserController) GetListOrganizationMemberAdmin(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		response.DataDetailResponse(c, 422, response.ErrCodeInvalidParams, nil)
+		return
+	}
+
+	uc.getListOrganizationMember(c, id)
+}
+
+// AddOrganizationMemberAdmin godoc
+// @Summary Add a member to an organization
+// @Description Adds an existing user with any organization role. Requires the organization:manage permission.
+// @Tags organization
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path string true "Organization ID"
+// @Param body body dto.AddOrganizationMemberRequestDto true "Member"
+// @Success 200 {object} response.Response{data=model.OrganizationMember} "Member added"
+// @Failure 404 {object} response.Response "Organization or user not found"
+// @Failure 409 {object} response.Response "Already a member"
+// @Router /admin/organizations/{id}/members [post]
+func (uc *UserController) AddOrganizationMemberAdmin(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		response.DataDetailResponse(c, 422, response.ErrCodeInvalidParams, nil)
+		return
+	}
+
+	uc.addOrganizationMember(c, id, constants.OrganizationRoleOwner)
+}
+
+// UpdateOrganizationMemberAdmin godoc
+// @Summary Change the role of a member
+// @Description Changes the organization role of a member, the last owner cannot be demoted. Requires the organization:manage permission.
+// @Tags organization
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path string true "Organization ID"
+// @Param user_id path string true "User ID"
+// @Param body body dto.UpdateOrganizationMemberRequestDto true "Role"
+// @Success 200 {object} response.Response{data=model.OrganizationMember} "Member updated"
+// @Failure 404 {object} response.Response "Member not found"
+// @Failure 409 {object} response.Response "Last owner of the organization"
+// @Router /admin/organizations/{id}/members/{user_id} [put]
+func (uc *UserController) UpdateOrganizationMemberAdmin(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		response.DataDetailResponse(c, 422, response.ErrCodeInvalidParams, nil)
+		return
+	}
+
+	uc.updateOrganizationMember(c, id, constants.OrganizationRoleOwner)
+}
+
+// RemoveOrganizationMemberAdmin godoc
+// @Summary Remove a member from an organization
+// @Description Removes a member, the last owner cannot be removed. Requires the organization:manage permission.
+// @Tags organization
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path string true "Organization ID"
+// @Param user_id path string true "User ID"
+// @Success 200 {object} response.Response "Member removed"
+// @Failure 404 {object} response.Response "Member not found"
+// @Failure 409 {object} response.Response "Last owner of the organization"
+// @Router /admin/organizations/{id}/members/{user_id} [delete]
+func (uc *UserController) RemoveOrganizationMemberAdmin(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		response.DataDetailResponse(c, 422, response.ErrCodeInvalidParams, nil)
+		return
+	}
+
+	uc.removeOrganizationMember(c, id, constants.OrganizationRoleOwner)
+}
+
+// GetCurrentOrganization godoc
+// @Summary Get the current organization
+// @Description Returns the organization selected by the token or the X-Organization-ID header
+// @Tags organization
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param X-Organization-ID header string false "Organization ID, when the token has none selected"
+// @Success 200 {object} response.Response{data=model.Organization} "Current organization"
+// @Failure 400 {object} response.Response "No organization selected"
+// @Failure 403 {object} response.Response "Not a member of the organization"
+// @Router /organization [get]
+func (uc *UserController) GetCurrentOrganization(c *gin.Context) {
+	result := uc.userService.GetOrganization(middlewares.OrganizationID(c))
+	response.HandleServiceResult(c, result)
+}
+
+// GetListOrganizationUser godoc
+// @Summary List users of the current organization
+// @Description Returns a paginated list of the users of the current organization, users of other organizations are never visible
+// @Tags organization
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param X-Organization-ID header string false "Organization ID, when the token has none selected"
+// @Param skip query int false "Skip, ignored with a cursor" default(0)
+// @Param limit query int false "Limit" default(10)
+// @Param cursor query string false "next_cursor of the previous page"
+// @Param sort query string false "Comma separated fields among created_at, updated_at, username, email and system_role, prefixed by - for descending order" default(-created_at)
+// @Param skip_total query bool false "Leave the total out, null in the response"
+// @Param email query string false "Part of the email, case insensitive"
+// @Param username query string false "Part of the username, case insensitive"
+// @Param system_role query string false "Role filter"
+// @Param system_roles query []string false "Any of the roles" collectionFormat(multi)
+// @Param is_active query bool false "Active or inactive users only"
+// @Param created_from query string false "Created at or after, RFC 3339"
+// @Param created_to query string false "Created before, RFC 3339"
+// @Success 200 {object} response.Response{data=dto.UserListResponseDto} "Paginated list of users"
+// @Failure 403 {object} response.Response "Not a member of the organization"
+// @Failure 422 {object} response.Response "Invalid query parameters, sort or cursor"
+// @Router /organization/users [get]
+func (uc *UserController) GetListOrganizationUser(c *gin.Context) {
+	var req dto.UserListRequestDto
+	if err := c.ShouldBindQuery(&req); err != nil {
+		response.DataDetailResponse(c, 422, response.ErrCodeInvalidParams, nil)
+		return
+	}
+
+	result := uc.userService.GetListOrganizationUser(middlewares.OrganizationID(c), req)
+	response.HandleServiceResult(c, result)
+}
+
+// GetListOrganizationMember godoc
+// @Summary List members of the current organization
+// @Description Returns a paginated list of the members of the current organization with their organization role
+// @Tags organization
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param X-Organization-ID header string false "Organization ID, when the token has none selected"
+// @Param skip query int false "Skip" default(0)
+// @Param limit query int false "Limit" default(10)
+// @Param role query string false "Organization role filter"
+// @Success 200 {object} response.Response{data=dto.OrganizationMemberListResponseDto} "Paginated list of members"
+// @Router /organization/members [get]
+func (uc *UserController) GetListOrganizationMember(c *gin.Context) {
+	uc.getListOrganizationMember(c, middlewares.OrganizationID(c))
+}
+
+// AddOrganizationMember godoc
+// @Summary Invite a member to the current organization
+// @Description Emails an invitation the user joins the organization by accepting. Owners assign any role, admins only MEMBER. The answer is the same whether or not the email belongs to an account.
+// @Tags organization
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param X-Organization-ID header string false "Organization ID, when the token has none selected"
+// @Param body body dto.AddOrganizationMemberRequestDto true "Member"
+// @Success 200 {object} response.Response "Invitation sent if the email belongs to an account that is not a member"
+// @Failure 403 {object} response.Response "Organization role does not allow it"
+// @Router /organization/members [post]
+func (uc *UserController) AddOrganizationMember(c *gin.Context) {
+	var req dto.AddOrganizationMemberRequestDto
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.DataDetailResponse(c, 422, response.ErrCodeInvalidData, nil)
+		return
+	}
+
+	result := uc.userService.InviteOrganizationMember(middlewares.OrganizationID(c), req, c.GetString("organization_role"))
+	response.HandleServiceResult(c, result)
+}
+
+// UpdateOrganizationMember godoc
+// @Summary Change the role of a member of the current organization
+// @Description Owners change any role, admins only manage members. The last owner cannot be demoted.
+// @Tags organization
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param X-Organization-ID header string false "Organization ID, when the token has none selected"
+// @Param user_id path string true "User ID"
+// @Param body body dto.UpdateOrganizationMemberRequestDto true "Role"
+// @Success 200 {object} response.Response{data=model.OrganizationMember} "Member updated"
+// @Failure 403 {object} response.Response "Organization role does not allow it"
+// @Failure 409 {object} response.Response "Last owner of the organization"
+// @Router /organization/members/{user_id} [put]
+func (uc *UserController) UpdateOrganizationMember(c *gin.Context) {
+	uc.updateOrganizationMember(c, middlewares.OrganizationID(c), c.GetString("organization_role"))
+}
+
+// RemoveOrganizationMember godoc
+// @Summary Remove a member from the current organization
+// @Description Owners remove anybody, admins only members. The last owner cannot be removed.
+// @Tags organization
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param X-Organization-ID header string false "Organization ID, when the token has none selected"
+// @Param user_id path string true "User ID"
+// @Success 200 {object} response.Response "Member removed"
+// @Failure 403 {object} response.Response "Organization role does not allow it"
+// @Failure 409 {object} response.Response "Last owner of the organization"
+// @Router /organization/members/{user_id} [delete]
+func (uc *UserController) RemoveOrganizationMember(c *gin.Context) {
+	uc.removeOrganizationMember(c, middlewares.OrganizationID(c), c.GetString("organization_role"))
+}
+
+// GetUserOrganizations godoc
+// @Summary List my organizations
+// @Description Returns the organizations of the current user with the role in each
+// @Tags organization
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} response.Response{data=[]model.OrganizationMember} "Memberships"
+// @Router /user/organizations [get]
+func (uc *UserController) GetUserOrganizations(c *gin.Context) {
+	userID, _ := c.Get("user_id")
+	result := uc.userService.GetUserOrganizations(userID.(uuid.UUID))
+	response.HandleServiceResult(c, result)
+}
+
+// AcceptOrganizationInvitation godoc
+// @Summary Accept an organization invitation
+// @Description Adds the current user to the organization of an invitation emailed to them, with the invited role
+// @Tags organization
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param body body dto.AcceptOrganizationInvitationRequestDto true "Invitation token"
+// @Success 200 {object} response.Response{data=model.OrganizationMember} "Member added"
+// @Failure 400 {object} response.Response "Invitation invalid, expired, used or issued to another user"
+// @Failure 404 {object} response.Response "Organization not found or deactivated"
+// @Failure 409 {object} response.Response "Already a member"
+// @Router /user/organizations/invitations/accept [post]
+func (uc *UserController) AcceptOrganizationInvitation(c *gin.Context) {
+	var req dto.AcceptOrganizationInvitationRequestDto
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.DataDetailResponse(c, 422, response.ErrCodeInvalidData, nil)
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	result := uc.userService.AcceptOrganizationInvitation(userID.(uuid.UUID), req.Token)
+	response.HandleServiceResult(c, result)
+}
+
+// SwitchOrganization godoc
+// @Summary Switch organization
+// @Description Selects the organization of the current session and returns an access token carrying it, the current access token is revoked. Refreshed tokens keep the selection.
+// @Tags organization
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param body body dto.SwitchOrganizationRequestDto true "Organization, null to leave every organization"
+// @Success 200 {object} response.Response{data=dto.AuthResponseDto} "New access token"
+// @Failure 403 {object} response.Response "Not a member of the organization"
+// @Failure 404 {object} response.Response "Session not found"
+// @Router /user/organizations/switch [post]
+func (uc *UserController) SwitchOrganization(c *gin.Context) {
+	var req dto.SwitchOrganizationRequestDto
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.DataDetailResponse(c, 422, response.ErrCodeInvalidData, nil)
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	sessionID, _ := c.Get("session_id")
+	tokenID, _ := c.Get("token_id")
+	expiresAt, _ := c.Get("token_expires_at")
+	result := uc.userService.SwitchOrganization(userID.(uuid.UUID), sessionID.(string), tokenID.(string), expiresAt.(time.Time), req.OrganizationID)
+	response.HandleServiceResult(c, result)
+}
+
+func (uc *UserController) getListOrganizationMember(c *gin.Context, organizationID uuid.UUID) {
+	var req dto.OrganizationMemberListRequestDto
+	if err := c.ShouldBindQuery(&req); err != nil {
+		response.DataDetailResponse(c, 422, response.ErrCodeInvalidParams, nil)
+		return
+	}
+
+	result := uc.userService.GetListOrganizationMember(organizationID, req)
+	response.HandleServiceResult(c, result)
+}
+
+func (uc *UserController) addOrganizationMember(c *gin.Context, organizationID uuid.UUID, actorRole string) {
+	var req dto.AddOrganizationMemberRequestDto
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.DataDetailResponse(c, 422, response.ErrCodeInvalidData, nil)
+		return
+	}
+
+	result := uc.userService.AddOrganizationMember(organizationID, req, actorRole)
+	response.HandleServiceResult(c, result)
+}
+
+func (uc *UserController) updateOrganizationMember(c *gin.Context, organizationID uuid.UUID, actorRole string) {
+	userID, err := uuid.Parse(c.Param("user_id"))
+	if err != nil {
+		response.DataDetailResponse(c, 422, response.ErrCodeInvalidParams, nil)
+		return
+	}
+
+	var req dto.UpdateOrganizationMemberRequestDto
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.DataDetailResponse(c, 422, response.ErrCodeInvalidData, nil)
+		return
+	}
+
+	result := uc.userService.UpdateOrganizationMember(organizationID, userID, req, actorRole)
+	response.HandleServiceResult(c, result)
+}
+
+func (uc *UserController) removeOrganizationMember(c *gin.Context, organizationID uuid.UUID, actorRole string) {
+	userID, err := uuid.Parse(c.Param("user_id"))
+	if err != nil {
+		response.DataDetailResponse(c, 422, response.ErrCodeInvalidParams, nil)
+		return
+	}
+
+	result := uc.userService.RemoveOrganizationMember(organizationID, userID, actorRole)
+	response.HandleServiceResult(c, result)
+}
+
+// DeleteUser godoc
+// @Summary Delete a user
+// @Description Soft deletes a user and revokes its sessions and tokens. The user can be restored until it is purged after the retention window. Requires the user:delete permission.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path string true "User ID"
+// @Success 200 {object} response.Response "User deleted"
+// @Failure 403 {object} response.Response "Access denied or cannot delete oneself"
+// @Failure 404 {object} response.Response "User not found"
+// @Router /admin/users/{id} [delete]
+func (uc *UserController) DeleteUser(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		response.DataDetailResponse(c, 422, response.ErrCodeInvalidParams, nil)
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	userRole, _ := c.Get("system_role")
+	result := uc.userService.DeleteUser(id, userID.(uuid.UUID), userRole.(string), clientInfo(c))
+	response.HandleServiceResult(c, result)
+}
+
+// RestoreUser godoc
+// @Summary Restore a deleted user
+// @Description Restores a deleted user that has not been purged yet, the user signs in again. Requires the user:delete permission.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path string true "User ID"
+// @Success 200 {object} response.Response "User restored"
+// @Failure 403 {object} response.Response "Access denied"
+// @Failure 404 {object} response.Response "Deleted user not found"
+// @Failure 409 {object} response.Response "Username or email taken by another user"
+// @Router /admin/users/{id}/restore [post]
+func (uc *UserController) RestoreUser(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		response.DataDetailResponse(c, 422, response.ErrCodeInvalidParams, nil)
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	userRole, _ := c.Get("system_role")
+	result := uc.userService.RestoreUser(id, userID.(uuid.UUID), userRole.(string), clientInfo(c))
+	response.HandleServiceResult(c, result)
+}
+
+// GetListDeletedUser godoc
+// @Summary List deleted users
+// @Description Returns a paginated list of deleted users not purged yet, most recently deleted first. Requires the user:delete permission.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param skip query int false "Skip" default(0)
+// @Param limit query int false "Limit" default(10)
+// @Param email query string false "Part of the email, case insensitive"
+// @Param username query string false "Part of the username, case insensitive"
+// @Param system_role query string false "Role filter"
+// @Param system_roles query []string false "Any of the roles" collectionFormat(multi)
+// @Param is_active query bool false "Active or inactive users only"
+// @Param created_from query string false "Created at or after, RFC 3339"
+// @Param created_to query string false "Created before, RFC 3339"
+// @Success 200 {object} response.Response{data=dto.UserListResponseDto} "Paginated list of deleted users"
+// @Failure 403 {object} response.Response "Access denied"
+// @Router /admin/users/deleted [get]
+func (uc *UserController) GetListDeletedUser(c *gin.Context) {
+	var req dto.UserListRequestDto
+	if err := c.ShouldBindQuery(&req); err != nil {
+		response.DataDetailResponse(c, 422, response.ErrCodeInvalidParams, nil)
+		return
+	}
+
+	result := uc.userService.GetListDeletedUser(req)
+	response.HandleServiceResult(c, result)
+}
+
+// StartUserImport godoc
+// @Summary Import users from a CSV or XLSX file
+// @Description Starts a background job creating a user per row. The header row names the columns email, username, full_name (optional) and system_role, rows are validated like create_user. Each created user gets an email to choose a password. A dry run only validates. Requires the user:create permission.
+// @Tags admin
+// @Accept multipart/form-data
+// @Produce json
+// @Security ApiKeyAuth
+// @Param file formData file true "CSV or XLSX file"
+// @Param dry_run formData bool false "Validate only"
+// @Success 200 {object} response.Response{data=dto.UserImportJobDto} "Import job started"
+// @Failure 403 {object} response.Response "Access denied"
+// @Failure 422 {object} response.Response "Invalid file"
+// @Router /admin/users/import [post]
+func (uc *UserController) StartUserImport(c *gin.Context) {
+	var req dto.UserImportRequestDto
+	if err := c.ShouldBind(&req); err != nil {
+		response.DataDetailResponse(c, 422, response.ErrCodeInvalidData, nil)
+		return
+	}
+	file, err := c.FormFile("file")
+	if err != nil {
+		response.DataDetailResponse(c, 422, response.ErrCodeImportFileInvalid, nil)
+		return
+	}
+
+	userID, _ := c.Get("user_id")
+	userRole, _ := c.Get("system_role")
+	result := uc.userService.StartUserImport(file, req.DryRun, userID.(uuid.UUID), userRole.(string))
+	response.HandleServiceResult(c, result)
+}
+
+// GetUserImportJob godoc
+// @Summary Get the progress of a user import
+// @Description Returns the status and counters of an import job, jobs expire after a day. Requires the user:create permission.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param id path string true "Import job ID"
+// @Success 200 {object} response.Response{data=dto.UserImportJobDto} "Import job"
+// @Failure 404 {object} response.Response "Import job not found"
+// @Router /admin/users/import/{id} [get]
+func (uc *UserController) GetUserImportJob(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		response.DataDetailResponse(c, 422, response.ErrCodeInvalidParams, nil)
+		return
+	}
+
+	result := uc.userService.GetUserImportJob(id)
+	response.HandleServiceResult(c, result)
+}
+
+// GetUserImportReport godoc
+// @Summary Download the error report of a user import
+// @Description Returns the rejected rows of an import job as CSV with their line number and errors. Requires the user:create permission.
+// @Tags admin
+// @Produce text/csv
+// @Security ApiKeyAuth
+// @Param id path string true "Import job ID"
+// @Success 200 {file} file "Error report"
+// @Failure 404 {object} response.Response "Import job not found"
+// @Router /admin/users/import/{id}/report [get]
+func (uc *UserController) GetUserImportReport(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		response.DataDetailResponse(c, 422, response.ErrCodeInvalidParams, nil)
+		return
+	}
+
+	result := uc.userService.GetUserImportReport(id)
+	if result.Error != nil {
+		response.HandleServiceResult(c, result)
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="user-import-%s-errors.csv"`, id))
+	c.Data(200, "text/csv; charset=utf-8", result.Data.([]byte))
+}
+
+// exportContentTypes are the Accept types of an export, CSV first as the default of */*
+var exportContentTypes = []string{
+	"text/csv",
+	"application/x-ndjson",
+	"application/jsonl",
+	"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+}
+
+var exportFormats = map[string]string{
+	"text/csv":             spreadsheet.FormatCSV,
+	"application/x-ndjson": spreadsheet.FormatJSONL,
+	"application/jsonl":    spreadsheet.FormatJSONL,
+	"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet": spreadsheet.FormatXLSX,
+}
+
+// ExportUsers godoc
+// @Summary Export users as CSV, JSON Lines or XLSX
+// @Description Streams every user matching the filters, without the row limit of list_user. The format is taken from the format parameter, else from the Accept header, else CSV. With store the file is written to object storage and a presigned download URL is returned instead. Requires the user:export permission.
+// @Tags admin
+// @Accept json
+// @Produce text/csv,application/x-ndjson,application/vnd.openxmlformats-officedocument.spreadsheetml.sheet,json
+// @Security ApiKeyAuth
+// @Param X-Organization-ID header string false "Organization ID limiting users to its members, when the token has none selected"
+// @Param email query string false "Part of the email, case insensitive"
+// @Param username query string false "Part of the username, case insensitive"
+// @Param system_role query string false "Role filter"
+// @Param system_roles query []string false "Any of the roles" collectionFormat(multi)
+// @Param is_active query bool false "Active or inactive users only"
+// @Param created_from query string false "Created at or after, RFC 3339"
+// @Param created_to query string false "Created before, RFC 3339"
+// @Param format query string false "csv, jsonl or xlsx"
+// @Param columns query string false "Comma separated columns: id, username, full_name, email, phone_number, gender, address, system_role, is_active, is_service_account, must_change_password, email_verified_at, password_changed_at, created_at, updated_at"
+// @Param store query bool false "Store the file and return a download URL"
+// @Success 200 {file} file "Exported users, or the download URL with store"
+// @Failure 403 {object} response.Response "Access denied: user:export permission required"
+// @Failure 422 {object} response.Response "Invalid format or columns"
+// @Router /admin/users/export [get]
+func (uc *UserController) ExportUsers(c *gin.Context) {
+	var req dto.UserExportRequestDto
+	if err := c.ShouldBindQuery(&req); err != nil {
+		response.DataDetailResponse(c, 422, response.ErrCodeInvalidParams, nil)
+		return
+	}
+	if req.Format == "" {
+		req.Format = exportFormats[c.NegotiateFormat(exportContentTypes...)]
+	}
+
+	userID, _ := c.Get("user_id")
+	if req.Store {
+		result := uc.userService.StoreUserExport(c.Request.Context(), req, userID.(uuid.UUID), clientInfo(c))
+		response.HandleServiceResult(c, result)
+		return
+	}
+
+	result := uc.userService.ExportUsers(c.Request.Context(), req, userID.(uuid.UUID), clientInfo(c))
+	if result.Error != nil {
+		response.HandleServiceResult(c, result)
+		return
+	}
+
+	export := result.Data.(*service.UserExport)
+	c.Header("Content-Type", export.ContentType)
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, export.FileName))
+	c.Status(200)
+	if rows, err := export.Stream(c.Writer); err != nil {
+		// The status is sent, the client gets a truncated file
+		global.Logger.Error(fmt.Sprintf("User export failed after %d rows: %s", rows, err.Error()))
+	}
+}
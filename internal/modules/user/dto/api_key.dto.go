@@ -0,0 +1,26 @@
+package dto
+
+import (
+	"app/internal/modules/user/model"
+	"time"
+)
+
+// CreateAPIKeyRequestDto scopes are permission names the key may use, without expiry the key lives until revoked
+type CreateAPIKeyRequestDto struct {
+	Name      string     `json:"name" binding:"required,max=100"`
+	Scopes    []string   `json:"scopes"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// APIKeyResponseDto the key itself is only returned once, at creation
+type APIKeyResponseDto struct {
+	*model.APIKey
+	Key string `json:"key,omitempty"`
+}
+
+// CreateServiceAccountRequestDto represents the service account creation request structure
+type CreateServiceAccountRequestDto struct {
+	Username   string `json:"username" binding:"required,max=100"`
+	FullName   string `json:"full_name" binding:"max=100"`
+	SystemRole string `json:"system_role" binding:"required,max=50"`
+}
@@ -0,0 +1,30 @@
+package dto
+
+import (
+	"app/internal/modules/user/model"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ImpersonationResponseDto the token acts as the user until it expires or impersonation is stopped, it has no refresh token
+type ImpersonationResponseDto struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+	UserID    uuid.UUID `json:"user_id"`
+}
+
+// AuditLogListRequestDto for pagination, filtered by actor, user or action
+type AuditLogListRequestDto struct {
+	Skip    int    `form:"skip" binding:"min=0"`
+	Limit   int    `form:"limit" binding:"min=0,max=100"`
+	ActorID string `form:"actor_id" binding:"omitempty,uuid"`
+	UserID  string `form:"user_id" binding:"omitempty,uuid"`
+	Action  string `form:"action" binding:"omitempty,max=100"`
+}
+
+// AuditLogListResponseDto for paginated audit log response
+type AuditLogListResponseDto struct {
+	Total int64             `json:"total"`
+	Data  []*model.AuditLog `json:"data"`
+}
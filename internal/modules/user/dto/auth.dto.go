@@ -6,16 +6,76 @@ type LoginRequestDto struct {
 	Password string `json:"password" binding:"required"`
 }
 
-// RegisterRequestDto represents the registration request structure
+// RegisterRequestDto represents the registration request structure.
+// With an invitation token the role comes from the invitation, otherwise it defaults to USER.
 type RegisterRequestDto struct {
-	Username string `json:"username" binding:"required"`
-	Email    string `json:"email" binding:"required,email"`
-	Password string `json:"password" binding:"required,min=6"`
-	Role     string `json:"role" binding:"required,oneof=ADMIN USER SUPER_ADMIN"`
+	Username        string `json:"username" binding:"required"`
+	Email           string `json:"email" binding:"required,email"`
+	Password        string `json:"password" binding:"required"`
+	Role            string `json:"role" binding:"omitempty,max=50"`
+	InvitationToken string `json:"invitation_token"`
 }
 
-// AuthResponseDto represents the authentication response
+// AuthResponseDto represents the authentication response.
+// Tokens are omitted when the account must verify its email before logging in,
+// when login continues with the MFA step using MFAToken, or when the password must be
+// changed first with PasswordChangeToken.
 type AuthResponseDto struct {
-	Token        string `json:"token"`
+	Token                     string   `json:"token,omitempty"`
+	RefreshToken              string   `json:"refresh_token,omitempty"`
+	EmailVerificationRequired bool     `json:"email_verification_required,omitempty"`
+	MFARequired               bool     `json:"mfa_required,omitempty"`
+	MFAEnrollmentRequired     bool     `json:"mfa_enrollment_required,omitempty"`
+	MFAToken                  string   `json:"mfa_token,omitempty"`
+	MFAMethods                []string `json:"mfa_methods,omitempty"`
+	PasswordChangeRequired    bool     `json:"password_change_required,omitempty"`
+	PasswordChangeToken       string   `json:"password_change_token,omitempty"`
+	RecoveryCodes             []string `json:"recovery_codes,omitempty"`
+}
+
+// RefreshTokenRequestDto represents the refresh token request structure
+type RefreshTokenRequestDto struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// LogoutRequestDto represents the logout request structure, the refresh token is optional
+type LogoutRequestDto struct {
 	RefreshToken string `json:"refresh_token"`
 }
+
+// ForgotPasswordRequestDto represents the forgot password request structure
+type ForgotPasswordRequestDto struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// MagicLinkRequestDto represents the magic link login request structure
+type MagicLinkRequestDto struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// MagicLinkCallbackRequestDto represents the magic link callback request structure
+type MagicLinkCallbackRequestDto struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// ResetPasswordRequestDto represents the reset password request structure
+type ResetPasswordRequestDto struct {
+	Token       string `json:"token" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required"`
+}
+
+// ChangePasswordRequestDto represents the change password request structure
+type ChangePasswordRequestDto struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required"`
+}
+
+// VerifyEmailRequestDto represents the email verification request structure
+type VerifyEmailRequestDto struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// ResendVerificationRequestDto represents the resend verification email request structure
+type ResendVerificationRequestDto struct {
+	Email string `json:"email" binding:"required,email"`
+}
@@ -0,0 +1,11 @@
+package dto
+
+import "time"
+
+// AvatarDto presigned URLs of the avatar thumbnails, square PNG of 64, 256 and 512 pixels, valid until ExpiresAt
+type AvatarDto struct {
+	Small     string    `json:"small"`
+	Medium    string    `json:"medium"`
+	Large     string    `json:"large"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
@@ -0,0 +1,29 @@
+package dto
+
+import (
+	"app/internal/modules/user/model"
+)
+
+// CreateInvitationRequestDto represents the invitation request structure
+type CreateInvitationRequestDto struct {
+	Email      string `json:"email" binding:"required,email"`
+	SystemRole string `json:"system_role" binding:"required,max=50"`
+}
+
+// InvitationResponseDto carries the invitation and its signed link, the link is only returned at creation
+type InvitationResponseDto struct {
+	*model.Invitation
+	Link string `json:"link"`
+}
+
+// InvitationListRequestDto for pagination
+type InvitationListRequestDto struct {
+	Skip  int `form:"skip" binding:"min=0"`
+	Limit int `form:"limit" binding:"min=0,max=100"`
+}
+
+// InvitationListResponseDto for paginated invitation list response
+type InvitationListResponseDto struct {
+	Total int64               `json:"total"`
+	Data  []*model.Invitation `json:"data"`
+}
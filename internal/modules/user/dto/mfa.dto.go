@@ -0,0 +1,35 @@
+package dto
+
+// MFAEnrollResponseDto carries the TOTP secret and the otpauth:// URI to render as a QR code
+type MFAEnrollResponseDto struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+}
+
+// MFACodeRequestDto represents a request confirmed by a TOTP code or a recovery code
+type MFACodeRequestDto struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// MFARecoveryCodesResponseDto lists recovery codes, they are only shown once
+type MFARecoveryCodesResponseDto struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// MFALoginRequestDto represents the second step of login, with either a TOTP code or a recovery code
+type MFALoginRequestDto struct {
+	MFAToken     string `json:"mfa_token" binding:"required"`
+	Code         string `json:"code" binding:"required_without=RecoveryCode"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+// MFALoginEnrollRequestDto starts enrollment during login for a role that requires MFA
+type MFALoginEnrollRequestDto struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+}
+
+// RoleMFAPolicyRequestDto sets whether a role must use MFA
+type RoleMFAPolicyRequestDto struct {
+	SystemRole  string `json:"system_role" binding:"required,max=50"`
+	MFARequired *bool  `json:"mfa_required" binding:"required"`
+}
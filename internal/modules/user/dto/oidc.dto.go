@@ -0,0 +1,12 @@
+package dto
+
+// OIDCAuthorizeResponseDto the client sends the browser to the authorization URL of the provider
+type OIDCAuthorizeResponseDto struct {
+	AuthorizationURL string `json:"authorization_url"`
+}
+
+// OIDCCallbackRequestDto carries the code and state the provider redirected back with
+type OIDCCallbackRequestDto struct {
+	Code  string `json:"code" binding:"required"`
+	State string `json:"state" binding:"required"`
+}
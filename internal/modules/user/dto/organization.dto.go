@@ -0,0 +1,68 @@
+package dto
+
+import (
+	"app/internal/modules/user/model"
+
+	"github.com/google/uuid"
+)
+
+// CreateOrganizationRequestDto the owner, when given, becomes the first member with the OWNER role
+type CreateOrganizationRequestDto struct {
+	Name    string     `json:"name" binding:"required,max=255"`
+	Slug    string     `json:"slug" binding:"required,max=100"`
+	OwnerID *uuid.UUID `json:"owner_id"`
+}
+
+// UpdateOrganizationRequestDto only the given fields are changed, an inactive organization rejects every member request
+type UpdateOrganizationRequestDto struct {
+	Name     string `json:"name" binding:"omitempty,max=255"`
+	IsActive *bool  `json:"is_active"`
+}
+
+// OrganizationListRequestDto for pagination, filtered by name
+type OrganizationListRequestDto struct {
+	Skip  int    `form:"skip" binding:"min=0"`
+	Limit int    `form:"limit" binding:"min=0,max=100"`
+	Name  string `form:"name" binding:"omitempty,max=255"`
+}
+
+// OrganizationListResponseDto for paginated organization response
+type OrganizationListResponseDto struct {
+	Total int64                 `json:"total"`
+	Data  []*model.Organization `json:"data"`
+}
+
+// AddOrganizationMemberRequestDto adds an existing user, found by email, to the organization. Through the
+// organization API the user is invited instead and only joins by accepting.
+type AddOrganizationMemberRequestDto struct {
+	Email string `json:"email" binding:"required,email"`
+	Role  string `json:"role" binding:"required,oneof=OWNER ADMIN MEMBER"`
+}
+
+// AcceptOrganizationInvitationRequestDto the token of the invitation link
+type AcceptOrganizationInvitationRequestDto struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// UpdateOrganizationMemberRequestDto changes the role of a member
+type UpdateOrganizationMemberRequestDto struct {
+	Role string `json:"role" binding:"required,oneof=OWNER ADMIN MEMBER"`
+}
+
+// OrganizationMemberListRequestDto for pagination, filtered by role
+type OrganizationMemberListRequestDto struct {
+	Skip  int    `form:"skip" binding:"min=0"`
+	Limit int    `form:"limit" binding:"min=0,max=100"`
+	Role  string `form:"role" binding:"omitempty,oneof=OWNER ADMIN MEMBER"`
+}
+
+// OrganizationMemberListResponseDto for paginated member response, each member with its user
+type OrganizationMemberListResponseDto struct {
+	Total int64                       `json:"total"`
+	Data  []*model.OrganizationMember `json:"data"`
+}
+
+// SwitchOrganizationRequestDto selects the organization of the session, null leaves every organization
+type SwitchOrganizationRequestDto struct {
+	OrganizationID *uuid.UUID `json:"organization_id"`
+}
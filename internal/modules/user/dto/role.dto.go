@@ -0,0 +1,28 @@
+package dto
+
+import (
+	"app/internal/modules/user/model"
+)
+
+// CreateRoleRequestDto represents the role creation request structure
+type CreateRoleRequestDto struct {
+	Name        string   `json:"name" binding:"required,max=50"`
+	Description string   `json:"description" binding:"max=255"`
+	ParentRole  string   `json:"parent_role" binding:"omitempty,max=50"`
+	Permissions []string `json:"permissions"`
+}
+
+// UpdateRoleRequestDto replaces the description, parent and permissions of a role
+type UpdateRoleRequestDto struct {
+	Description string   `json:"description" binding:"max=255"`
+	ParentRole  string   `json:"parent_role" binding:"omitempty,max=50"`
+	Permissions []string `json:"permissions"`
+}
+
+// RoleResponseDto carries a role with the permissions granted to it directly and the effective ones,
+// which include everything inherited from parent roles
+type RoleResponseDto struct {
+	*model.Role
+	Permissions          []string `json:"permissions"`
+	EffectivePermissions []string `json:"effective_permissions"`
+}
@@ -0,0 +1,17 @@
+package dto
+
+import (
+	"app/internal/modules/user/model"
+)
+
+// ClientInfo describes the device a request comes from, it is recorded on the session at login
+type ClientInfo struct {
+	IPAddress string
+	UserAgent string
+}
+
+// SessionResponseDto flags the session the request was made with
+type SessionResponseDto struct {
+	*model.UserSession
+	Current bool `json:"current"`
+}
@@ -1,52 +1,65 @@
 package dto
 
 import (
+	"app/internal/modules/user/model"
 	"time"
 
 	"github.com/google/uuid"
 )
 
 type UserRequestDto struct {
-	Email       string `json:"email" binding:"required,email"`
-	Username    string `json:"username" binding:"required"`
-	FullName    string `json:"full_name"`
-	Password    string `json:"password" binding:"required,min=6"`
-	PhoneNumber string `json:"phone_number"`
-	Gender      string `json:"gender"`
-	Address     string `json:"address"`
-	SystemRole  string `json:"system_role" binding:"required,oneof=ADMIN USER SUPER_ADMIN"`
+	Email            string `json:"email" binding:"required,email"`
+	Username         string `json:"username" binding:"required"`
+	FullName         string `json:"full_name"`
+	Password         string `json:"password" binding:"required"`
+	PhoneNumber      string `json:"phone_number"`
+	Gender           string `json:"gender"`
+	Address          string `json:"address"`
+	SystemRole       string `json:"system_role" binding:"required,max=50"`
+	IsServiceAccount bool   `json:"-"`
+	// MustChangePassword makes the user change the password at the next login
+	MustChangePassword bool `json:"-"`
+}
+
+// CreateUserResponseDto the temporary password is only returned here, the user must change it at first login
+type CreateUserResponseDto struct {
+	ID                uuid.UUID `json:"id"`
+	TemporaryPassword string    `json:"temporary_password"`
 }
 
 type CreateUserDto struct {
 	Email      string `json:"email" binding:"required,email"`
 	Username   string `json:"username" binding:"required"`
 	FullName   string `json:"full_name"`
-	SystemRole string `json:"system_role" binding:"required,oneof=ADMIN USER SUPER_ADMIN"`
+	SystemRole string `json:"system_role" binding:"required,max=50"`
 }
 
 type UserUpdateRequestDto struct {
 	FullName    string `json:"full_name"`
 	Email       string `json:"email"`
-	Password    string `json:"password" binding:"omitempty,min=6"`
+	Password    string `json:"password"`
 	PhoneNumber string `json:"phone_number"`
 	Gender      string `json:"gender"`
 	Address     string `json:"address"`
-	SystemRole  string `json:"system_role" binding:"omitempty,oneof=ADMIN USER SUPER_ADMIN"`
+	SystemRole  string `json:"system_role" binding:"omitempty,max=50"`
 	IsActive    *bool  `json:"is_active"`
 }
 
 type UserResponseDto struct {
-	Id          uuid.UUID `json:"id"`
-	Email       string    `json:"email"`
-	Username    string    `json:"username"`
-	FullName    string    `json:"full_name"`
-	PhoneNumber string    `json:"phone_number"`
-	Gender      string    `json:"gender"`
-	Address     string    `json:"address"`
-	SystemRole  string    `json:"system_role"`
-	IsActive    bool      `json:"is_active"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	Id               uuid.UUID  `json:"id"`
+	Email            string     `json:"email"`
+	Username         string     `json:"username"`
+	FullName         string     `json:"full_name"`
+	PhoneNumber      string     `json:"phone_number"`
+	Gender           string     `json:"gender"`
+	Address          string     `json:"address"`
+	SystemRole       string     `json:"system_role"`
+	IsActive         bool       `json:"is_active"`
+	EmailVerifiedAt  *time.Time `json:"email_verified_at"`
+	IsServiceAccount bool       `json:"is_service_account"`
+	Avatar           *AvatarDto `json:"avatar"`
+	CreatedAt        time.Time  `json:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at"`
 }
 
 // UserResponseBaseDto for basic user information in responses
@@ -58,17 +71,30 @@ type UserResponseBaseDto struct {
 	SystemRole string    `json:"system_role"`
 }
 
-// UserListRequestDto for pagination and filtering
+// UserListRequestDto for pagination and filtering. Email and username match any part, case insensitively.
+// SystemRoles matches any of the roles, repeat the parameter for several. Cursor is the next_cursor of the
+// previous page and takes the place of skip, it is only valid with the same sort. Sort is a comma separated
+// list of fields, "-" for descending order, "-created_at" by default. SkipTotal leaves the count out.
+// Cursor and sort apply to the user list, the deleted user list pages with skip.
 type UserListRequestDto struct {
-	Skip       int    `form:"skip" binding:"min=0"`
-	Limit      int    `form:"limit" binding:"min=0,max=100"`
-	Email      string `form:"email"`
-	Username   string `form:"username"`
-	SystemRole string `form:"system_role" binding:"omitempty,oneof=ADMIN USER SUPER_ADMIN"`
+	Skip        int        `form:"skip" binding:"min=0"`
+	Limit       int        `form:"limit" binding:"min=0,max=100"`
+	Cursor      string     `form:"cursor"`
+	Sort        string     `form:"sort"`
+	Email       string     `form:"email"`
+	Username    string     `form:"username"`
+	SystemRole  string     `form:"system_role" binding:"omitempty,max=50"`
+	SystemRoles []string   `form:"system_roles" binding:"omitempty,max=20,dive,max=50"`
+	IsActive    *bool      `form:"is_active"`
+	CreatedFrom *time.Time `form:"created_from"`
+	CreatedTo   *time.Time `form:"created_to"`
+	SkipTotal   bool       `form:"skip_total"`
 }
 
-// UserListResponseDto for paginated user list response
+// UserListResponseDto for paginated user list response, Total is null when skipped and NextCursor is empty
+// on the last page
 type UserListResponseDto struct {
-	Total int64             `json:"total"`
-	Data  []UserResponseDto `json:"data"`
+	Total      *int64        `json:"total"`
+	NextCursor string        `json:"next_cursor"`
+	Data       []*model.User `json:"data"`
 }
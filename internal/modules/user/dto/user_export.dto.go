@@ -0,0 +1,41 @@
+package dto
+
+import "time"
+
+// UserExportRequestDto the filters of UserListRequestDto without pagination. Format is csv, jsonl or xlsx and
+// defaults to the Accept header, then to csv. Columns is a comma separated list, empty for the default columns.
+// Store writes the file to object storage and returns a download URL instead of streaming it.
+type UserExportRequestDto struct {
+	Email       string     `form:"email"`
+	Username    string     `form:"username"`
+	SystemRole  string     `form:"system_role" binding:"omitempty,max=50"`
+	SystemRoles []string   `form:"system_roles" binding:"omitempty,max=20,dive,max=50"`
+	IsActive    *bool      `form:"is_active"`
+	CreatedFrom *time.Time `form:"created_from"`
+	CreatedTo   *time.Time `form:"created_to"`
+	Format      string     `form:"format" binding:"omitempty,oneof=csv jsonl xlsx"`
+	Columns     string     `form:"columns"`
+	Store       bool       `form:"store"`
+}
+
+// Filter returns the export filters as a list request
+func (r UserExportRequestDto) Filter() UserListRequestDto {
+	return UserListRequestDto{
+		Email:       r.Email,
+		Username:    r.Username,
+		SystemRole:  r.SystemRole,
+		SystemRoles: r.SystemRoles,
+		IsActive:    r.IsActive,
+		CreatedFrom: r.CreatedFrom,
+		CreatedTo:   r.CreatedTo,
+	}
+}
+
+// UserExportResponseDto an export written to object storage, URL is presigned and expires at ExpiresAt
+type UserExportResponseDto struct {
+	URL       string    `json:"url"`
+	FileName  string    `json:"file_name"`
+	Format    string    `json:"format"`
+	Rows      int       `json:"rows"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
@@ -0,0 +1,36 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// UserImportRequestDto the file is sent as the multipart "file" field. A dry run validates every row and reports
+// the errors without creating any user
+type UserImportRequestDto struct {
+	DryRun bool `form:"dry_run"`
+}
+
+// UserImportJobDto progress of an import job, Succeeded counts the created users, or the valid rows of a dry run
+type UserImportJobDto struct {
+	ID         uuid.UUID  `json:"id"`
+	FileName   string     `json:"file_name"`
+	DryRun     bool       `json:"dry_run"`
+	Status     string     `json:"status"`
+	Total      int        `json:"total"`
+	Processed  int        `json:"processed"`
+	Succeeded  int        `json:"succeeded"`
+	Failed     int        `json:"failed"`
+	CreatedBy  uuid.UUID  `json:"created_by"`
+	CreatedAt  time.Time  `json:"created_at"`
+	FinishedAt *time.Time `json:"finished_at"`
+}
+
+// UserImportRowErrorDto a rejected row of the import file, Row is the line number in the file, the header being 1
+type UserImportRowErrorDto struct {
+	Row      int      `json:"row"`
+	Email    string   `json:"email"`
+	Username string   `json:"username"`
+	Errors   []string `json:"errors"`
+}
@@ -0,0 +1,35 @@
+package dto
+
+import "encoding/json"
+
+// PasskeyChallengeResponseDto the client passes the options to navigator.credentials.create or get
+// and sends the result back with the challenge ID
+type PasskeyChallengeResponseDto struct {
+	ChallengeID string `json:"challenge_id"`
+	Options     any    `json:"options" swaggertype:"object"`
+}
+
+// PasskeyRegistrationRequestDto carries the attestation response of navigator.credentials.create
+type PasskeyRegistrationRequestDto struct {
+	ChallengeID string          `json:"challenge_id" binding:"required"`
+	Name        string          `json:"name" binding:"max=100"`
+	Credential  json.RawMessage `json:"credential" binding:"required" swaggertype:"object"`
+}
+
+// PasskeyLoginRequestDto carries the assertion response of navigator.credentials.get
+type PasskeyLoginRequestDto struct {
+	ChallengeID string          `json:"challenge_id" binding:"required"`
+	Credential  json.RawMessage `json:"credential" binding:"required" swaggertype:"object"`
+}
+
+// PasskeyMFAStartRequestDto starts a passkey ceremony as the second factor of a login
+type PasskeyMFAStartRequestDto struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+}
+
+// PasskeyMFARequestDto completes the second factor of a login with a passkey
+type PasskeyMFARequestDto struct {
+	MFAToken    string          `json:"mfa_token" binding:"required"`
+	ChallengeID string          `json:"challenge_id" binding:"required"`
+	Credential  json.RawMessage `json:"credential" binding:"required" swaggertype:"object"`
+}
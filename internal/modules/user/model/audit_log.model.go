@@ -0,0 +1,27 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AuditLog records an action of ActorID concerning UserID. For requests made while impersonating,
+// TokenID is the impersonation token and Method, Path and Status describe the request.
+type AuditLog struct {
+	ID        uuid.UUID  `gorm:"type:uuid;primaryKey" json:"id"`
+	ActorID   *uuid.UUID `gorm:"type:uuid" json:"actor_id"`
+	UserID    *uuid.UUID `gorm:"type:uuid" json:"user_id"`
+	Action    string     `gorm:"type:varchar(100);not null" json:"action"`
+	TokenID   string     `gorm:"type:varchar(64);not null;default:''" json:"token_id"`
+	Method    string     `gorm:"type:varchar(10);not null;default:''" json:"method"`
+	Path      string     `gorm:"type:varchar(255);not null;default:''" json:"path"`
+	Status    int        `gorm:"not null;default:0" json:"status"`
+	IPAddress string     `gorm:"type:varchar(45);not null;default:''" json:"ip_address"`
+	UserAgent string     `gorm:"type:varchar(255);not null;default:''" json:"user_agent"`
+	CreatedAt time.Time  `gorm:"autoCreateTime" json:"created_at"`
+}
+
+func (a *AuditLog) TableName() string {
+	return "audit_logs"
+}
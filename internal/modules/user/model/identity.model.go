@@ -0,0 +1,22 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// UserIdentity links an account of an OpenID Connect provider, identified by its subject, to a local user
+type UserIdentity struct {
+	ID          uuid.UUID  `gorm:"type:uuid;primaryKey" json:"id"`
+	UserID      uuid.UUID  `gorm:"type:uuid;not null" json:"user_id"`
+	Provider    string     `gorm:"type:varchar(50);not null" json:"provider"`
+	Subject     string     `gorm:"type:varchar(255);not null" json:"subject"`
+	Email       string     `gorm:"type:varchar(255);not null;default:''" json:"email"`
+	LastLoginAt *time.Time `gorm:"type:timestamp" json:"last_login_at"`
+	CreatedAt   time.Time  `gorm:"autoCreateTime" json:"created_at"`
+}
+
+func (i *UserIdentity) TableName() string {
+	return "user_identities"
+}
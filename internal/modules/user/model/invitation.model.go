@@ -0,0 +1,28 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Invitation lets one email address self-register with a role preset by an admin
+type Invitation struct {
+	ID         uuid.UUID  `gorm:"type:uuid;primaryKey" json:"id"`
+	Email      string     `gorm:"type:varchar(255);not null" json:"email"`
+	SystemRole string     `gorm:"type:varchar(50);not null;default:'USER'" json:"system_role"`
+	InvitedBy  *uuid.UUID `gorm:"type:uuid" json:"invited_by"`
+	ExpiresAt  time.Time  `gorm:"not null" json:"expires_at"`
+	AcceptedAt *time.Time `gorm:"type:timestamp" json:"accepted_at"`
+	RevokedAt  *time.Time `gorm:"type:timestamp" json:"revoked_at"`
+	CreatedAt  time.Time  `gorm:"autoCreateTime" json:"created_at"`
+}
+
+func (i *Invitation) TableName() string {
+	return "invitations"
+}
+
+// IsUsable reports whether the invitation can still be accepted
+func (i *Invitation) IsUsable() bool {
+	return i.AcceptedAt == nil && i.RevokedAt == nil && time.Now().Before(i.ExpiresAt)
+}
@@ -0,0 +1,43 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// UserMFA holds the TOTP secret of a user, encrypted at rest. EnabledAt is nil until the first code is confirmed.
+type UserMFA struct {
+	UserID       uuid.UUID  `gorm:"type:uuid;primaryKey" json:"user_id"`
+	Secret       string     `gorm:"type:varchar(255);not null" json:"-"`
+	LastUsedStep int64      `gorm:"not null;default:0" json:"-"`
+	EnabledAt    *time.Time `gorm:"type:timestamp" json:"enabled_at"`
+	CreatedAt    time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt    time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+func (m *UserMFA) TableName() string {
+	return "user_mfa"
+}
+
+type UserMFARecoveryCode struct {
+	ID        uuid.UUID  `gorm:"type:uuid;primaryKey" json:"id"`
+	UserID    uuid.UUID  `gorm:"type:uuid;not null" json:"user_id"`
+	CodeHash  string     `gorm:"type:varchar(64);not null" json:"-"`
+	UsedAt    *time.Time `gorm:"type:timestamp" json:"used_at"`
+	CreatedAt time.Time  `gorm:"autoCreateTime" json:"created_at"`
+}
+
+func (c *UserMFARecoveryCode) TableName() string {
+	return "user_mfa_recovery_codes"
+}
+
+type RoleMFAPolicy struct {
+	SystemRole  string    `gorm:"type:varchar(50);primaryKey" json:"system_role"`
+	MFARequired bool      `gorm:"not null;default:false" json:"mfa_required"`
+	UpdatedAt   time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+func (p *RoleMFAPolicy) TableName() string {
+	return "role_mfa_policies"
+}
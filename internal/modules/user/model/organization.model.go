@@ -0,0 +1,35 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Organization a customer company, the tenant that scopes its members and their data
+type Organization struct {
+	ID        uuid.UUID `gorm:"type:uuid;primaryKey" json:"id"`
+	Name      string    `gorm:"type:varchar(255);not null" json:"name"`
+	Slug      string    `gorm:"type:varchar(100);not null;unique" json:"slug"`
+	IsActive  *bool     `gorm:"not null;default:true" json:"is_active"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+func (o *Organization) TableName() string {
+	return "organizations"
+}
+
+// OrganizationMember gives a user a role inside an organization, independent of the platform system role
+type OrganizationMember struct {
+	OrganizationID uuid.UUID     `gorm:"type:uuid;primaryKey" json:"organization_id"`
+	UserID         uuid.UUID     `gorm:"type:uuid;primaryKey" json:"user_id"`
+	Role           string        `gorm:"type:varchar(50);not null;default:'MEMBER'" json:"role"`
+	CreatedAt      time.Time     `gorm:"autoCreateTime" json:"created_at"`
+	User           *User         `gorm:"foreignKey:UserID" json:"user,omitempty"`
+	Organization   *Organization `gorm:"foreignKey:OrganizationID" json:"organization,omitempty"`
+}
+
+func (m *OrganizationMember) TableName() string {
+	return "organization_members"
+}
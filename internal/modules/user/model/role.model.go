@@ -0,0 +1,35 @@
+package model
+
+import "time"
+
+// Role groups permissions, a role also inherits every permission of its parent role
+type Role struct {
+	Name        string    `gorm:"type:varchar(50);primaryKey" json:"name"`
+	Description string    `gorm:"type:varchar(255);not null;default:''" json:"description"`
+	ParentRole  *string   `gorm:"type:varchar(50)" json:"parent_role"`
+	IsSystem    bool      `gorm:"not null;default:false" json:"is_system"`
+	CreatedAt   time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt   time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+func (r *Role) TableName() string {
+	return "roles"
+}
+
+type Permission struct {
+	Name        string `gorm:"type:varchar(100);primaryKey" json:"name"`
+	Description string `gorm:"type:varchar(255);not null;default:''" json:"description"`
+}
+
+func (p *Permission) TableName() string {
+	return "permissions"
+}
+
+type RolePermission struct {
+	RoleName       string `gorm:"type:varchar(50);primaryKey" json:"role_name"`
+	PermissionName string `gorm:"type:varchar(100);primaryKey" json:"permission_name"`
+}
+
+func (rp *RolePermission) TableName() string {
+	return "role_permissions"
+}
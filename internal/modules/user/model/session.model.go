@@ -0,0 +1,26 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// UserSession records a login on a device. ID is the refresh token family id,
+// every access and refresh token of the session carries it.
+// OrganizationID is the organization selected in the session, put in its access tokens.
+type UserSession struct {
+	ID             uuid.UUID  `gorm:"type:uuid;primaryKey" json:"id"`
+	UserID         uuid.UUID  `gorm:"type:uuid;not null" json:"user_id"`
+	OrganizationID *uuid.UUID `gorm:"type:uuid" json:"organization_id"`
+	UserAgent      string     `gorm:"type:varchar(255);not null;default:''" json:"user_agent"`
+	IPAddress      string     `gorm:"type:varchar(45);not null;default:''" json:"ip_address"`
+	CreatedAt      time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	LastSeenAt     time.Time  `gorm:"not null" json:"last_seen_at"`
+	ExpiresAt      time.Time  `gorm:"not null" json:"expires_at"`
+	RevokedAt      *time.Time `gorm:"type:timestamp" json:"revoked_at"`
+}
+
+func (s *UserSession) TableName() string {
+	return "user_sessions"
+}
@@ -4,21 +4,31 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
 type User struct {
-	ID          uuid.UUID `gorm:"type:uuid;primaryKey" json:"id"`
-	Username    string    `gorm:"type:varchar(100);not null;unique" json:"username"`
-	FullName    string    `gorm:"type:varchar(100)" json:"full_name"`
-	Email       string    `gorm:"type:varchar(255);unique;not null" json:"email"`
-	Password    string    `gorm:"type:varchar(255);not null" json:"-"`
-	PhoneNumber string    `gorm:"type:varchar(20)" json:"phone_number"`
-	Gender      string    `gorm:"type:varchar(15)" json:"gender"`
-	Address     string    `gorm:"type:varchar(100)" json:"address"`
-	SystemRole  string    `gorm:"type:varchar(50);not null;default:'USER'" json:"system_role"`
-	IsActive    *bool     `gorm:"not null;default:true" json:"is_active"`
-	CreatedAt   time.Time `gorm:"autoCreateTime" json:"created_at"`
-	UpdatedAt   time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+	ID                 uuid.UUID  `gorm:"type:uuid;primaryKey" json:"id"`
+	Username           string     `gorm:"type:varchar(100);not null" json:"username"`
+	FullName           string     `gorm:"type:varchar(100)" json:"full_name"`
+	Email              string     `gorm:"type:varchar(255);not null" json:"email"`
+	Password           string     `gorm:"type:varchar(255);not null" json:"-"`
+	PasswordChangedAt  *time.Time `gorm:"type:timestamp" json:"password_changed_at"`
+	MustChangePassword bool       `gorm:"not null;default:false" json:"must_change_password"`
+	PhoneNumber        string     `gorm:"type:varchar(20)" json:"phone_number"`
+	Gender             string     `gorm:"type:varchar(15)" json:"gender"`
+	Address            string     `gorm:"type:varchar(100)" json:"address"`
+	SystemRole         string     `gorm:"type:varchar(50);not null;default:'USER'" json:"system_role"`
+	IsActive           *bool      `gorm:"not null;default:true" json:"is_active"`
+	EmailVerifiedAt    *time.Time `gorm:"type:timestamp" json:"email_verified_at"`
+	IsServiceAccount   bool       `gorm:"not null;default:false" json:"is_service_account"`
+	CreatedAt          time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt          time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+	// AvatarKey is the object storage prefix of the avatar thumbnails, empty without avatar
+	AvatarKey string `gorm:"type:varchar(255);not null;default:''" json:"-"`
+	// DeletedAt soft deletes the user, GORM leaves deleted users out of every query unless Unscoped.
+	// Username and email are unique among the users that are not deleted.
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"deleted_at" swaggertype:"string"`
 }
 
 func (u *User) TableName() string {
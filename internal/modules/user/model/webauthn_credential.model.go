@@ -0,0 +1,30 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// WebAuthnCredential a passkey or security key registered by a user, identified by the credential ID of the authenticator.
+// SignCount is the last signature counter seen, a counter that does not increase hints at a cloned authenticator.
+type WebAuthnCredential struct {
+	ID              uuid.UUID  `gorm:"type:uuid;primaryKey" json:"id"`
+	UserID          uuid.UUID  `gorm:"type:uuid;not null" json:"user_id"`
+	Name            string     `gorm:"type:varchar(100);not null;default:''" json:"name"`
+	CredentialID    []byte     `gorm:"type:bytea;not null" json:"-"`
+	PublicKey       []byte     `gorm:"type:bytea;not null" json:"-"`
+	AttestationType string     `gorm:"type:varchar(50);not null;default:''" json:"attestation_type"`
+	AAGUID          []byte     `gorm:"column:aaguid;type:bytea" json:"-"`
+	SignCount       uint32     `gorm:"type:bigint;not null;default:0" json:"-"`
+	Transports      string     `gorm:"type:varchar(255);not null;default:''" json:"-"`
+	UserVerified    bool       `gorm:"not null;default:false" json:"-"`
+	BackupEligible  bool       `gorm:"not null;default:false" json:"backup_eligible"`
+	BackupState     bool       `gorm:"not null;default:false" json:"backup_state"`
+	LastUsedAt      *time.Time `gorm:"type:timestamp" json:"last_used_at"`
+	CreatedAt       time.Time  `gorm:"autoCreateTime" json:"created_at"`
+}
+
+func (c *WebAuthnCredential) TableName() string {
+	return "webauthn_credentials"
+}
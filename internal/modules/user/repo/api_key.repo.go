@@ -0,0 +1,61 @@
+package repo
+
+import (
+	"app/internal/modules/user/model"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type IAPIKeyRepository interface {
+	GetAPIKeyByPrefix(prefix string) *model.APIKey
+	GetAPIKeysByUserID(userID uuid.UUID) ([]*model.APIKey, error)
+	CreateAPIKey(apiKey *model.APIKey) error
+	RevokeAPIKey(userID uuid.UUID, id uuid.UUID) (bool, error)
+	TouchAPIKey(id uuid.UUID, usedAt time.Time, interval time.Duration) error
+}
+
+func NewAPIKeyRepository(db *gorm.DB) IAPIKeyRepository {
+	return &apiKeyRepository{db: db}
+}
+
+type apiKeyRepository struct {
+	db *gorm.DB
+}
+
+func (r *apiKeyRepository) GetAPIKeyByPrefix(prefix string) *model.APIKey {
+	var apiKey model.APIKey
+	err := r.db.Where("prefix = ?", prefix).First(&apiKey).Error
+	if err != nil {
+		return nil
+	}
+	return &apiKey
+}
+
+func (r *apiKeyRepository) GetAPIKeysByUserID(userID uuid.UUID) ([]*model.APIKey, error) {
+	var apiKeys []*model.APIKey
+	if err := r.db.Where("user_id = ?", userID).Order("created_at DESC").Find(&apiKeys).Error; err != nil {
+		return nil, err
+	}
+	return apiKeys, nil
+}
+
+func (r *apiKeyRepository) CreateAPIKey(apiKey *model.APIKey) error {
+	return r.db.Create(apiKey).Error
+}
+
+// RevokeAPIKey revokes a key of the user, it reports false when no active key matched
+func (r *apiKeyRepository) RevokeAPIKey(userID uuid.UUID, id uuid.UUID) (bool, error) {
+	result := r.db.Model(&model.APIKey{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", id, userID).
+		Update("revoked_at", time.Now())
+	return result.RowsAffected == 1, result.Error
+}
+
+// TouchAPIKey records the last use of a key, at most once per interval to spare a write on every request
+func (r *apiKeyRepository) TouchAPIKey(id uuid.UUID, usedAt time.Time, interval time.Duration) error {
+	return r.db.Model(&model.APIKey{}).
+		Where("id = ? AND (last_used_at IS NULL OR last_used_at < ?)", id, usedAt.Add(-interval)).
+		Update("last_used_at", usedAt).Error
+}
@@ -0,0 +1,49 @@
+package repo
+
+import (
+	"app/internal/modules/user/dto"
+	"app/internal/modules/user/model"
+
+	"gorm.io/gorm"
+)
+
+type IAuditLogRepository interface {
+	CreateAuditLog(entry *model.AuditLog) error
+	GetListAuditLog(req dto.AuditLogListRequestDto) ([]*model.AuditLog, int64, error)
+}
+
+func NewAuditLogRepository(db *gorm.DB) IAuditLogRepository {
+	return &auditLogRepository{db: db}
+}
+
+type auditLogRepository struct {
+	db *gorm.DB
+}
+
+func (r *auditLogRepository) CreateAuditLog(entry *model.AuditLog) error {
+	return r.db.Create(entry).Error
+}
+
+func (r *auditLogRepository) GetListAuditLog(req dto.AuditLogListRequestDto) ([]*model.AuditLog, int64, error) {
+	var entries []*model.AuditLog
+	var total int64
+
+	query := r.db.Model(&model.AuditLog{})
+	if req.ActorID != "" {
+		query = query.Where("actor_id = ?", req.ActorID)
+	}
+	if req.UserID != "" {
+		query = query.Where("user_id = ?", req.UserID)
+	}
+	if req.Action != "" {
+		query = query.Where("action = ?", req.Action)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	if err := query.Limit(req.Limit).Offset(req.Skip).Order("created_at DESC").Find(&entries).Error; err != nil {
+		return nil, 0, err
+	}
+	return entries, total, nil
+}
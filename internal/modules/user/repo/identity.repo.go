@@ -0,0 +1,55 @@
+package repo
+
+import (
+	"app/internal/modules/user/model"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type IIdentityRepository interface {
+	GetIdentity(provider string, subject string) *model.UserIdentity
+	GetIdentitiesByUserID(userID uuid.UUID) ([]*model.UserIdentity, error)
+	CreateIdentity(identity *model.UserIdentity) error
+	DeleteIdentity(userID uuid.UUID, id uuid.UUID) (bool, error)
+	TouchIdentity(id uuid.UUID) error
+}
+
+func NewIdentityRepository(db *gorm.DB) IIdentityRepository {
+	return &identityRepository{db: db}
+}
+
+type identityRepository struct {
+	db *gorm.DB
+}
+
+func (r *identityRepository) GetIdentity(provider string, subject string) *model.UserIdentity {
+	var identity model.UserIdentity
+	err := r.db.Where("provider = ? AND subject = ?", provider, subject).First(&identity).Error
+	if err != nil {
+		return nil
+	}
+	return &identity
+}
+
+func (r *identityRepository) GetIdentitiesByUserID(userID uuid.UUID) ([]*model.UserIdentity, error) {
+	var identities []*model.UserIdentity
+	if err := r.db.Where("user_id = ?", userID).Order("created_at").Find(&identities).Error; err != nil {
+		return nil, err
+	}
+	return identities, nil
+}
+
+func (r *identityRepository) CreateIdentity(identity *model.UserIdentity) error {
+	return r.db.Create(identity).Error
+}
+
+func (r *identityRepository) DeleteIdentity(userID uuid.UUID, id uuid.UUID) (bool, error) {
+	result := r.db.Where("id = ? AND user_id = ?", id, userID).Delete(&model.UserIdentity{})
+	return result.RowsAffected == 1, result.Error
+}
+
+func (r *identityRepository) TouchIdentity(id uuid.UUID) error {
+	return r.db.Model(&model.UserIdentity{}).Where("id = ?", id).Update("last_login_at", time.Now()).Error
+}
@@ -0,0 +1,73 @@
+package repo
+
+import (
+	"app/internal/modules/user/model"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type IInvitationRepository interface {
+	GetInvitationByID(id uuid.UUID) *model.Invitation
+	GetListInvitation(skip int, limit int) ([]*model.Invitation, int64, error)
+	CreateInvitation(invitation *model.Invitation) error
+	AcceptInvitation(id uuid.UUID) (bool, error)
+	ReleaseInvitation(id uuid.UUID) error
+	RevokeInvitation(id uuid.UUID) (bool, error)
+}
+
+func NewInvitationRepository(db *gorm.DB) IInvitationRepository {
+	return &invitationRepository{db: db}
+}
+
+type invitationRepository struct {
+	db *gorm.DB
+}
+
+func (r *invitationRepository) GetInvitationByID(id uuid.UUID) *model.Invitation {
+	var invitation model.Invitation
+	err := r.db.First(&invitation, id).Error
+	if err != nil {
+		return nil
+	}
+	return &invitation
+}
+
+func (r *invitationRepository) GetListInvitation(skip int, limit int) ([]*model.Invitation, int64, error) {
+	var invitations []*model.Invitation
+	var total int64
+
+	query := r.db.Model(&model.Invitation{})
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	if err := query.Limit(limit).Offset(skip).Order("created_at DESC").Find(&invitations).Error; err != nil {
+		return nil, 0, err
+	}
+	return invitations, total, nil
+}
+
+func (r *invitationRepository) CreateInvitation(invitation *model.Invitation) error {
+	return r.db.Create(invitation).Error
+}
+
+// AcceptInvitation marks a usable invitation as accepted, false when it was already used, revoked or expired
+func (r *invitationRepository) AcceptInvitation(id uuid.UUID) (bool, error) {
+	result := r.db.Model(&model.Invitation{}).
+		Where("id = ? AND accepted_at IS NULL AND revoked_at IS NULL AND expires_at > ?", id, time.Now()).
+		Update("accepted_at", time.Now())
+	return result.RowsAffected == 1, result.Error
+}
+
+// ReleaseInvitation undoes AcceptInvitation when the registration failed afterwards
+func (r *invitationRepository) ReleaseInvitation(id uuid.UUID) error {
+	return r.db.Model(&model.Invitation{}).Where("id = ?", id).Update("accepted_at", nil).Error
+}
+
+func (r *invitationRepository) RevokeInvitation(id uuid.UUID) (bool, error) {
+	result := r.db.Model(&model.Invitation{}).
+		Where("id = ? AND accepted_at IS NULL AND revoked_at IS NULL", id).
+		Update("revoked_at", time.Now())
+	return result.RowsAffected == 1, result.Error
+}
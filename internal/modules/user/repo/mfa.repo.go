@@ -0,0 +1,113 @@
+package repo
+
+import (
+	"app/internal/modules/user/model"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type IMFARepository interface {
+	GetUserMFA(userID uuid.UUID) *model.UserMFA
+	SaveUserMFA(mfa *model.UserMFA) error
+	DeleteUserMFA(userID uuid.UUID) error
+	UseTimeStep(userID uuid.UUID, step int64) (bool, error)
+	ReplaceRecoveryCodes(userID uuid.UUID, codeHashes []string) error
+	UseRecoveryCode(userID uuid.UUID, codeHash string) (bool, error)
+	GetRoleMFAPolicies() ([]*model.RoleMFAPolicy, error)
+	IsMFARequiredForRole(role string) bool
+	SaveRoleMFAPolicy(policy *model.RoleMFAPolicy) error
+}
+
+func NewMFARepository(db *gorm.DB) IMFARepository {
+	return &mfaRepository{db: db}
+}
+
+type mfaRepository struct {
+	db *gorm.DB
+}
+
+func (r *mfaRepository) GetUserMFA(userID uuid.UUID) *model.UserMFA {
+	var mfa model.UserMFA
+	err := r.db.Where("user_id = ?", userID).First(&mfa).Error
+	if err != nil {
+		return nil
+	}
+	return &mfa
+}
+
+// SaveUserMFA creates or replaces the MFA record of the user
+func (r *mfaRepository) SaveUserMFA(mfa *model.UserMFA) error {
+	return r.db.Save(mfa).Error
+}
+
+// DeleteUserMFA removes the secret and every recovery code of the user
+func (r *mfaRepository) DeleteUserMFA(userID uuid.UUID) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&model.UserMFARecoveryCode{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", userID).Delete(&model.UserMFA{}).Error
+	})
+}
+
+// UseTimeStep records the TOTP time step as used, it fails for a step not newer than the last one
+// so a code cannot be replayed
+func (r *mfaRepository) UseTimeStep(userID uuid.UUID, step int64) (bool, error) {
+	result := r.db.Model(&model.UserMFA{}).
+		Where("user_id = ? AND last_used_step < ?", userID, step).
+		Update("last_used_step", step)
+	return result.RowsAffected == 1, result.Error
+}
+
+func (r *mfaRepository) ReplaceRecoveryCodes(userID uuid.UUID, codeHashes []string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&model.UserMFARecoveryCode{}).Error; err != nil {
+			return err
+		}
+
+		codes := make([]*model.UserMFARecoveryCode, 0, len(codeHashes))
+		for _, hash := range codeHashes {
+			id, err := uuid.NewV7()
+			if err != nil {
+				return err
+			}
+			codes = append(codes, &model.UserMFARecoveryCode{ID: id, UserID: userID, CodeHash: hash})
+		}
+		return tx.Create(&codes).Error
+	})
+}
+
+// UseRecoveryCode marks an unused recovery code as used and reports whether one matched
+func (r *mfaRepository) UseRecoveryCode(userID uuid.UUID, codeHash string) (bool, error) {
+	result := r.db.Model(&model.UserMFARecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, codeHash).
+		Update("used_at", time.Now())
+	return result.RowsAffected == 1, result.Error
+}
+
+func (r *mfaRepository) GetRoleMFAPolicies() ([]*model.RoleMFAPolicy, error) {
+	var policies []*model.RoleMFAPolicy
+	if err := r.db.Order("system_role").Find(&policies).Error; err != nil {
+		return nil, err
+	}
+	return policies, nil
+}
+
+func (r *mfaRepository) IsMFARequiredForRole(role string) bool {
+	var policy model.RoleMFAPolicy
+	err := r.db.Where("system_role = ?", role).First(&policy).Error
+	if err != nil {
+		return false
+	}
+	return policy.MFARequired
+}
+
+func (r *mfaRepository) SaveRoleMFAPolicy(policy *model.RoleMFAPolicy) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "system_role"}},
+		DoUpdates: clause.AssignmentColumns([]string{"mfa_required", "updated_at"}),
+	}).Create(policy).Error
+}
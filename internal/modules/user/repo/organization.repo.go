@@ -0,0 +1,170 @@
+package repo
+
+import (
+	"app/internal/modules/user/dto"
+	"app/internal/modules/user/model"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type IOrganizationRepository interface {
+	GetOrganizationByID(id uuid.UUID) *model.Organization
+	GetOrganizationBySlug(slug string) *model.Organization
+	GetListOrganization(req dto.OrganizationListRequestDto) ([]*model.Organization, int64, error)
+	CreateOrganization(organization *model.Organization, owner *model.OrganizationMember) error
+	UpdateOrganization(id uuid.UUID, organization *model.Organization) (*model.Organization, error)
+	GetMember(organizationID uuid.UUID, userID uuid.UUID) *model.OrganizationMember
+	GetActiveMember(organizationID uuid.UUID, userID uuid.UUID) (*model.OrganizationMember, error)
+	GetListMember(organizationID uuid.UUID, req dto.OrganizationMemberListRequestDto) ([]*model.OrganizationMember, int64, error)
+	GetMembershipsByUserID(userID uuid.UUID) ([]*model.OrganizationMember, error)
+	AddMember(member *model.OrganizationMember) error
+	UpdateMemberRole(organizationID uuid.UUID, userID uuid.UUID, role string) (bool, error)
+	RemoveMember(organizationID uuid.UUID, userID uuid.UUID) (bool, error)
+	CountMembersWithRole(organizationID uuid.UUID, role string) (int64, error)
+}
+
+func NewOrganizationRepository(db *gorm.DB) IOrganizationRepository {
+	return &organizationRepository{db: db}
+}
+
+type organizationRepository struct {
+	db *gorm.DB
+}
+
+func (r *organizationRepository) GetOrganizationByID(id uuid.UUID) *model.Organization {
+	var organization model.Organization
+	err := r.db.First(&organization, id).Error
+	if err != nil {
+		return nil
+	}
+	return &organization
+}
+
+func (r *organizationRepository) GetOrganizationBySlug(slug string) *model.Organization {
+	var organization model.Organization
+	err := r.db.Where("slug = ?", slug).First(&organization).Error
+	if err != nil {
+		return nil
+	}
+	return &organization
+}
+
+func (r *organizationRepository) GetListOrganization(req dto.OrganizationListRequestDto) ([]*model.Organization, int64, error) {
+	var organizations []*model.Organization
+	var total int64
+
+	query := r.db.Model(&model.Organization{})
+	if req.Name != "" {
+		query = query.Where("name ILIKE ?", "%"+req.Name+"%")
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	if err := query.Limit(req.Limit).Offset(req.Skip).Order("created_at DESC").Find(&organizations).Error; err != nil {
+		return nil, 0, err
+	}
+	return organizations, total, nil
+}
+
+// CreateOrganization creates the organization and, when given, its first owner in one transaction
+func (r *organizationRepository) CreateOrganization(organization *model.Organization, owner *model.OrganizationMember) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(organization).Error; err != nil {
+			return err
+		}
+		if owner == nil {
+			return nil
+		}
+		owner.OrganizationID = organization.ID
+		return tx.Create(owner).Error
+	})
+}
+
+func (r *organizationRepository) UpdateOrganization(id uuid.UUID, organization *model.Organization) (*model.Organization, error) {
+	if err := r.db.Model(&model.Organization{}).Where("id = ?", id).Updates(organization).Error; err != nil {
+		return nil, err
+	}
+
+	var updated model.Organization
+	if err := r.db.First(&updated, id).Error; err != nil {
+		return nil, err
+	}
+	return &updated, nil
+}
+
+func (r *organizationRepository) GetMember(organizationID uuid.UUID, userID uuid.UUID) *model.OrganizationMember {
+	var member model.OrganizationMember
+	err := r.db.Where("organization_id = ? AND user_id = ?", organizationID, userID).First(&member).Error
+	if err != nil {
+		return nil
+	}
+	return &member
+}
+
+// GetActiveMember returns the membership when the organization is active, nil otherwise
+func (r *organizationRepository) GetActiveMember(organizationID uuid.UUID, userID uuid.UUID) (*model.OrganizationMember, error) {
+	var members []*model.OrganizationMember
+	err := r.db.Joins("JOIN organizations ON organizations.id = organization_members.organization_id AND organizations.is_active").
+		Where("organization_members.organization_id = ? AND organization_members.user_id = ?", organizationID, userID).
+		Limit(1).Find(&members).Error
+	if err != nil || len(members) == 0 {
+		return nil, err
+	}
+	return members[0], nil
+}
+
+func (r *organizationRepository) GetListMember(organizationID uuid.UUID, req dto.OrganizationMemberListRequestDto) ([]*model.OrganizationMember, int64, error) {
+	var members []*model.OrganizationMember
+	var total int64
+
+	// Deleted users stay members until purged, so a restore gives them their organizations back
+	deleted := r.db.Session(&gorm.Session{NewDB: true}).Unscoped().Model(&model.User{}).Select("id").Where("deleted_at IS NOT NULL")
+	query := r.db.Model(&model.OrganizationMember{}).Where("organization_id = ? AND user_id NOT IN (?)", organizationID, deleted)
+	if req.Role != "" {
+		query = query.Where("role = ?", req.Role)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := query.Preload("User").Limit(req.Limit).Offset(req.Skip).Order("created_at").Find(&members).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return members, total, nil
+}
+
+func (r *organizationRepository) GetMembershipsByUserID(userID uuid.UUID) ([]*model.OrganizationMember, error) {
+	var members []*model.OrganizationMember
+	err := r.db.Preload("Organization").Where("user_id = ?", userID).Order("created_at").Find(&members).Error
+	if err != nil {
+		return nil, err
+	}
+	return members, nil
+}
+
+func (r *organizationRepository) AddMember(member *model.OrganizationMember) error {
+	return r.db.Create(member).Error
+}
+
+func (r *organizationRepository) UpdateMemberRole(organizationID uuid.UUID, userID uuid.UUID, role string) (bool, error) {
+	result := r.db.Model(&model.OrganizationMember{}).
+		Where("organization_id = ? AND user_id = ?", organizationID, userID).
+		Update("role", role)
+	return result.RowsAffected == 1, result.Error
+}
+
+func (r *organizationRepository) RemoveMember(organizationID uuid.UUID, userID uuid.UUID) (bool, error) {
+	result := r.db.Where("organization_id = ? AND user_id = ?", organizationID, userID).Delete(&model.OrganizationMember{})
+	return result.RowsAffected == 1, result.Error
+}
+
+func (r *organizationRepository) CountMembersWithRole(organizationID uuid.UUID, role string) (int64, error) {
+	var count int64
+	err := r.db.Model(&model.OrganizationMember{}).
+		Where("organization_id = ? AND role = ?", organizationID, role).
+		Count(&count).Error
+	return count, err
+}
@@ -0,0 +1,125 @@
+package repo
+
+import (
+	"app/internal/modules/user/model"
+
+	"gorm.io/gorm"
+)
+
+type IRoleRepository interface {
+	GetRoles() ([]*model.Role, error)
+	GetRoleByName(name string) *model.Role
+	GetPermissions() ([]*model.Permission, error)
+	CountPermissions(names []string) (int64, error)
+	GetRolePermissionNames(roleName string) ([]string, error)
+	CreateRole(role *model.Role, permissions []string) error
+	UpdateRole(role *model.Role, permissions []string) error
+	DeleteRole(name string) error
+	CountUsersWithRole(name string) (int64, error)
+	CountChildRoles(name string) (int64, error)
+}
+
+func NewRoleRepository(db *gorm.DB) IRoleRepository {
+	return &roleRepository{db: db}
+}
+
+type roleRepository struct {
+	db *gorm.DB
+}
+
+func (r *roleRepository) GetRoles() ([]*model.Role, error) {
+	var roles []*model.Role
+	if err := r.db.Order("name").Find(&roles).Error; err != nil {
+		return nil, err
+	}
+	return roles, nil
+}
+
+func (r *roleRepository) GetRoleByName(name string) *model.Role {
+	var role model.Role
+	err := r.db.Where("name = ?", name).First(&role).Error
+	if err != nil {
+		return nil
+	}
+	return &role
+}
+
+func (r *roleRepository) GetPermissions() ([]*model.Permission, error) {
+	var permissions []*model.Permission
+	if err := r.db.Order("name").Find(&permissions).Error; err != nil {
+		return nil, err
+	}
+	return permissions, nil
+}
+
+// CountPermissions counts how many of the given permission names exist
+func (r *roleRepository) CountPermissions(names []string) (int64, error) {
+	var count int64
+	if len(names) == 0 {
+		return 0, nil
+	}
+	err := r.db.Model(&model.Permission{}).Where("name IN ?", names).Count(&count).Error
+	return count, err
+}
+
+// GetRolePermissionNames returns the permissions granted directly to a role, without inherited ones
+func (r *roleRepository) GetRolePermissionNames(roleName string) ([]string, error) {
+	var names []string
+	err := r.db.Model(&model.RolePermission{}).
+		Where("role_name = ?", roleName).
+		Order("permission_name").
+		Pluck("permission_name", &names).Error
+	return names, err
+}
+
+func (r *roleRepository) CreateRole(role *model.Role, permissions []string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(role).Error; err != nil {
+			return err
+		}
+		return replaceRolePermissions(tx, role.Name, permissions)
+	})
+}
+
+// UpdateRole saves the description and parent of a role and replaces its permissions
+func (r *roleRepository) UpdateRole(role *model.Role, permissions []string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(role).Select("description", "parent_role", "updated_at").Updates(role).Error
+		if err != nil {
+			return err
+		}
+		return replaceRolePermissions(tx, role.Name, permissions)
+	})
+}
+
+func (r *roleRepository) DeleteRole(name string) error {
+	return r.db.Where("name = ?", name).Delete(&model.Role{}).Error
+}
+
+// CountUsersWithRole counts deleted users too, the foreign key keeps the role until they are purged
+func (r *roleRepository) CountUsersWithRole(name string) (int64, error) {
+	var count int64
+	err := r.db.Unscoped().Model(&model.User{}).Where("system_role = ?", name).Count(&count).Error
+	return count, err
+}
+
+func (r *roleRepository) CountChildRoles(name string) (int64, error) {
+	var count int64
+	err := r.db.Model(&model.Role{}).Where("parent_role = ?", name).Count(&count).Error
+	return count, err
+}
+
+func replaceRolePermissions(tx *gorm.DB, roleName string, permissions []string) error {
+	if err := tx.Where("role_name = ?", roleName).Delete(&model.RolePermission{}).Error; err != nil {
+		return err
+	}
+	if len(permissions) == 0 {
+		return nil
+	}
+
+	rolePermissions := make([]*model.RolePermission, 0, len(permissions))
+	for _, permission := range permissions {
+		rolePermissions = append(rolePermissions, &model.RolePermission{RoleName: roleName, PermissionName: permission})
+	}
+	return tx.Create(&rolePermissions).Error
+}
@@ -0,0 +1,95 @@
+package repo
+
+import (
+	"app/internal/modules/user/model"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type ISessionRepository interface {
+	GetSessionByID(id uuid.UUID) *model.UserSession
+	GetActiveSessionsByUserID(userID uuid.UUID) ([]*model.UserSession, error)
+	CreateSession(session *model.UserSession) error
+	TouchSession(id uuid.UUID, ipAddress string, seenAt time.Time) error
+	ExtendSession(id uuid.UUID, ipAddress string, expiresAt time.Time) error
+	SetSessionOrganization(id uuid.UUID, organizationID *uuid.UUID) error
+	RevokeSession(id uuid.UUID) error
+	RevokeUserSessions(userID uuid.UUID, exceptID uuid.UUID) ([]uuid.UUID, error)
+}
+
+func NewSessionRepository(db *gorm.DB) ISessionRepository {
+	return &sessionRepository{db: db}
+}
+
+type sessionRepository struct {
+	db *gorm.DB
+}
+
+func (r *sessionRepository) GetSessionByID(id uuid.UUID) *model.UserSession {
+	var session model.UserSession
+	err := r.db.First(&session, id).Error
+	if err != nil {
+		return nil
+	}
+	return &session
+}
+
+func (r *sessionRepository) GetActiveSessionsByUserID(userID uuid.UUID) ([]*model.UserSession, error) {
+	var sessions []*model.UserSession
+	err := r.db.Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Order("last_seen_at DESC").
+		Find(&sessions).Error
+	if err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+func (r *sessionRepository) CreateSession(session *model.UserSession) error {
+	return r.db.Create(session).Error
+}
+
+// TouchSession records activity on the session from the given IP
+func (r *sessionRepository) TouchSession(id uuid.UUID, ipAddress string, seenAt time.Time) error {
+	return r.db.Model(&model.UserSession{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Updates(map[string]interface{}{"last_seen_at": seenAt, "ip_address": ipAddress}).Error
+}
+
+// ExtendSession moves the expiry of the session after its refresh token was rotated
+func (r *sessionRepository) ExtendSession(id uuid.UUID, ipAddress string, expiresAt time.Time) error {
+	return r.db.Model(&model.UserSession{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Updates(map[string]interface{}{"last_seen_at": time.Now(), "ip_address": ipAddress, "expires_at": expiresAt}).Error
+}
+
+// SetSessionOrganization selects the organization of the session, nil clears it
+func (r *sessionRepository) SetSessionOrganization(id uuid.UUID, organizationID *uuid.UUID) error {
+	return r.db.Model(&model.UserSession{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("organization_id", organizationID).Error
+}
+
+func (r *sessionRepository) RevokeSession(id uuid.UUID) error {
+	return r.db.Model(&model.UserSession{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", time.Now()).Error
+}
+
+// RevokeUserSessions revokes every active session of the user but exceptID and returns the revoked ids
+func (r *sessionRepository) RevokeUserSessions(userID uuid.UUID, exceptID uuid.UUID) ([]uuid.UUID, error) {
+	var ids []uuid.UUID
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		query := tx.Model(&model.UserSession{}).Where("user_id = ? AND revoked_at IS NULL AND id <> ?", userID, exceptID)
+		if err := query.Pluck("id", &ids).Error; err != nil {
+			return err
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+		return tx.Model(&model.UserSession{}).Where("id IN ?", ids).Update("revoked_at", time.Now()).Error
+	})
+	return ids, err
+}
@@ -3,32 +3,97 @@ package repo
 import (
 	"app/internal/modules/user/dto"
 	"app/internal/modules/user/model"
+	"app/pkg/pagination"
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/google/uuid"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type IUserRepository interface {
 	GetUserByEmail(email string) *model.User
 	GetUserByUsername(username string) *model.User
 	GetUserByID(id uuid.UUID) *model.User
-	GetListUser(req dto.UserListRequestDto) ([]*model.User, int64, error)
+	GetListUser(req dto.UserListRequestDto) ([]*model.User, *int64, string, error)
+	StreamUsers(ctx context.Context, req dto.UserListRequestDto, fetchSize int, fn func(users []*model.User) error) error
+	GetExistingEmails(emails []string) ([]string, error)
+	GetExistingUsernames(usernames []string) ([]string, error)
 	CreateUser(user *model.User) (uuid.UUID, error)
 	UpdateUser(id uuid.UUID, user *model.User) (*model.User, error)
+	SetEmailVerifiedAt(id uuid.UUID, verifiedAt *time.Time) error
+	SetAvatarKey(id uuid.UUID, avatarKey string) (string, error)
+	ChangePassword(id uuid.UUID, passwordHash string, historySize int) error
+	SetTemporaryPassword(id uuid.UUID, passwordHash string, historySize int) error
+	AddPasswordHistory(id uuid.UUID, passwordHash string, historySize int) error
+	GetPasswordHistory(id uuid.UUID, limit int) ([]string, error)
+	DeleteUser(id uuid.UUID) (bool, error)
+	RestoreUser(id uuid.UUID) (bool, error)
+	GetDeletedUserByID(id uuid.UUID) *model.User
+	GetListDeletedUser(req dto.UserListRequestDto) ([]*model.User, int64, error)
+	PurgeDeletedUsers(deletedBefore time.Time, limit int) ([]uuid.UUID, error)
+	ForOrganization(organizationID uuid.UUID) IUserRepository
+	ForContext(ctx context.Context) IUserRepository
 }
 
 func NewUserRepository(db *gorm.DB) IUserRepository {
 	return &userRepository{db: db}
 }
 
+// userRepository is global unless organizationID is set, every query then only sees the members of that organization
 type userRepository struct {
-	db *gorm.DB
+	db             *gorm.DB
+	organizationID uuid.UUID
+}
+
+// ForOrganization returns the repository of a tenant: users outside the organization are not found, listed or
+// changed, and created users join it as members
+func (r *userRepository) ForOrganization(organizationID uuid.UUID) IUserRepository {
+	return &userRepository{db: r.db, organizationID: organizationID}
+}
+
+type tenantKey struct{}
+
+// WithTenant returns a context whose user queries are limited to the organization, see ForContext
+func WithTenant(ctx context.Context, organizationID uuid.UUID) context.Context {
+	return context.WithValue(ctx, tenantKey{}, organizationID)
+}
+
+// TenantFromContext returns the organization set by WithTenant
+func TenantFromContext(ctx context.Context) (uuid.UUID, bool) {
+	organizationID, ok := ctx.Value(tenantKey{}).(uuid.UUID)
+	return organizationID, ok && organizationID != uuid.Nil
+}
+
+// ForContext returns the repository of the tenant of the request context, the repository itself without one
+func (r *userRepository) ForContext(ctx context.Context) IUserRepository {
+	if organizationID, ok := TenantFromContext(ctx); ok {
+		return r.ForOrganization(organizationID)
+	}
+	return r
+}
+
+// tenantScope limits a query to the users of the organization, column holds the user id
+func (r *userRepository) tenantScope(column string) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if r.organizationID == uuid.Nil {
+			return db
+		}
+		members := db.Session(&gorm.Session{NewDB: true}).Model(&model.OrganizationMember{}).
+			Select("user_id").Where("organization_id = ?", r.organizationID)
+		return db.Where(column+" IN (?)", members)
+	}
 }
 
 func (r *userRepository) GetUserByEmail(email string) *model.User {
 	var user model.User
-	err := r.db.Where("email = ?", email).First(&user).Error
+	err := r.db.Scopes(r.tenantScope("users.id")).Where("email = ?", email).First(&user).Error
 	if err != nil {
 		return nil
 	}
@@ -37,7 +102,7 @@ func (r *userRepository) GetUserByEmail(email string) *model.User {
 
 func (r *userRepository) GetUserByUsername(username string) *model.User {
 	var user model.User
-	err := r.db.Where("username = ?", username).First(&user).Error
+	err := r.db.Scopes(r.tenantScope("users.id")).Where("username = ?", username).First(&user).Error
 	if err != nil {
 		return nil
 	}
@@ -46,58 +111,426 @@ func (r *userRepository) GetUserByUsername(username string) *model.User {
 
 func (r *userRepository) GetUserByID(id uuid.UUID) *model.User {
 	var user model.User
-	err := r.db.First(&user, id).Error
+	err := r.db.Scopes(r.tenantScope("users.id")).First(&user, id).Error
 	if err != nil {
 		return nil
 	}
 	return &user
 }
 
-func (r *userRepository) GetListUser(req dto.UserListRequestDto) ([]*model.User, int64, error) {
+// userListDefaultSort is the order of a user list without sort, newest first
+const userListDefaultSort = "-created_at"
+
+// userSortFields are the fields a user list can be sorted by, with the value of a user kept in a cursor
+var userSortFields = map[string]func(user *model.User) any{
+	"created_at":  func(u *model.User) any { return u.CreatedAt },
+	"updated_at":  func(u *model.User) any { return u.UpdatedAt },
+	"username":    func(u *model.User) any { return u.Username },
+	"email":       func(u *model.User) any { return u.Email },
+	"system_role": func(u *model.User) any { return u.SystemRole },
+}
+
+// GetListUser returns a page of users and the cursor of the next page, empty on the last page. A cursor pages
+// by keyset, after the last user of the previous page, otherwise skip pages by offset. The total is nil when
+// skipped. An unknown sort or a cursor of another sort gives pagination.ErrInvalidSort or ErrInvalidCursor.
+func (r *userRepository) GetListUser(req dto.UserListRequestDto) ([]*model.User, *int64, string, error) {
+	sort, err := parseUserSort(req.Sort)
+	if err != nil {
+		return nil, nil, "", err
+	}
+
+	query := r.db.Model(&model.User{}).Scopes(r.tenantScope("users.id"), userListFilters(req))
+
+	var total *int64
+	if !req.SkipTotal {
+		var count int64
+		if err := query.Count(&count).Error; err != nil {
+			return nil, nil, "", err
+		}
+		total = &count
+	}
+
+	if req.Cursor != "" {
+		cursor, err := pagination.DecodeCursor(req.Cursor, pagination.FormatSort(sort))
+		if err != nil {
+			return nil, nil, "", err
+		}
+		after, values, err := userKeyset(sort, cursor)
+		if err != nil {
+			return nil, nil, "", err
+		}
+		query = query.Where(after, values...)
+	} else {
+		query = query.Offset(req.Skip)
+	}
+
+	// One more user than the page tells whether there is a next page
 	var users []*model.User
-	var total int64
+	if err := query.Scopes(userListOrder(sort)).Limit(req.Limit + 1).Find(&users).Error; err != nil {
+		return nil, nil, "", err
+	}
+
+	nextCursor := ""
+	if req.Limit > 0 && len(users) > req.Limit {
+		users = users[:req.Limit]
+		nextCursor = userCursor(sort, users[len(users)-1]).Encode()
+	}
+	return users, total, nextCursor, nil
+}
+
+// userListFilters applies the filters of a user list, the pagination is left to the caller
+func userListFilters(req dto.UserListRequestDto) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if req.Email != "" {
+			db = db.Where("email ILIKE ?", "%"+escapeLike(req.Email)+"%")
+		}
+		if req.Username != "" {
+			db = db.Where("username ILIKE ?", "%"+escapeLike(req.Username)+"%")
+		}
+		roles := slices.Clone(req.SystemRoles)
+		if req.SystemRole != "" {
+			roles = append(roles, req.SystemRole)
+		}
+		if len(roles) > 0 {
+			db = db.Where("system_role IN ?", roles)
+		}
+		if req.IsActive != nil {
+			db = db.Where("is_active = ?", *req.IsActive)
+		}
+		// Timestamps are stored as local times without zone
+		if req.CreatedFrom != nil {
+			db = db.Where("created_at >= ?", req.CreatedFrom.Local())
+		}
+		if req.CreatedTo != nil {
+			db = db.Where("created_at < ?", req.CreatedTo.Local())
+		}
+		return db
+	}
+}
+
+// escapeLike makes the wildcards of a LIKE pattern match themselves
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(value)
+}
 
-	query := r.db.Model(&model.User{})
+func parseUserSort(spec string) ([]pagination.SortField, error) {
+	return pagination.ParseSort(spec, slices.Collect(maps.Keys(userSortFields)), userListDefaultSort)
+}
 
-	if req.Email != "" {
-		query = query.Where("email ILIKE ?", "%"+req.Email+"%")
+// userListOrder orders by the sort fields then by id, so users with the same values keep a stable order
+func userListOrder(sort []pagination.SortField) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		for _, field := range sort {
+			db = db.Order(clause.OrderByColumn{Column: clause.Column{Table: "users", Name: field.Field}, Desc: field.Desc})
+		}
+		return db.Order(clause.OrderByColumn{Column: clause.Column{Table: "users", Name: "id"}})
 	}
+}
 
-	if req.Username != "" {
-		query = query.Where("username", req.Username)
+// userCursor is the position of a user in a list sorted by sort, the id last
+func userCursor(sort []pagination.SortField, user *model.User) pagination.Cursor {
+	values := make([]string, 0, len(sort)+1)
+	for _, field := range sort {
+		switch value := userSortFields[field.Field](user).(type) {
+		case time.Time:
+			values = append(values, value.Format(time.RFC3339Nano))
+		case string:
+			values = append(values, value)
+		}
 	}
+	values = append(values, user.ID.String())
+	return pagination.Cursor{Sort: pagination.FormatSort(sort), Values: values}
+}
 
-	if req.SystemRole != "" {
-		query = query.Where("system_role", req.SystemRole)
+// userKeyset returns the condition matching the users after the cursor in the order of userListOrder:
+// (a > x) OR (a = x AND b > y) OR ..., with < for descending fields
+func userKeyset(sort []pagination.SortField, cursor pagination.Cursor) (string, []any, error) {
+	if len(cursor.Values) != len(sort)+1 {
+		return "", nil, pagination.ErrInvalidCursor
 	}
 
-	if err := query.Count(&total).Error; err != nil {
-		return nil, 0, err
+	fields := append(slices.Clone(sort), pagination.SortField{Field: "id"})
+	values := make([]any, len(fields))
+	for i, field := range fields {
+		var err error
+		switch field.Field {
+		case "id":
+			values[i], err = uuid.Parse(cursor.Values[i])
+		case "created_at", "updated_at":
+			values[i], err = time.Parse(time.RFC3339Nano, cursor.Values[i])
+		default:
+			values[i] = cursor.Values[i]
+		}
+		if err != nil {
+			return "", nil, pagination.ErrInvalidCursor
+		}
 	}
 
-	query = query.Limit(req.Limit).Offset(req.Skip).Order("created_at DESC")
+	var conditions []string
+	var args []any
+	for i, field := range fields {
+		parts := make([]string, 0, i+1)
+		for j := range i {
+			parts = append(parts, "users."+fields[j].Field+" = ?")
+			args = append(args, values[j])
+		}
+		operator := ">"
+		if field.Desc {
+			operator = "<"
+		}
+		parts = append(parts, "users."+field.Field+" "+operator+" ?")
+		args = append(args, values[i])
+		conditions = append(conditions, "("+strings.Join(parts, " AND ")+")")
+	}
+	return "(" + strings.Join(conditions, " OR ") + ")", args, nil
+}
 
-	if err := query.Find(&users).Error; err != nil {
-		return nil, 0, err
+// StreamUsers passes the users matching the filters of a list to fn, fetchSize users at a time, in the order
+// of GetListUser. The rows are read through a server side cursor so memory stays flat whatever the number of
+// users, the cursor lives in a transaction held until the last batch. An error of fn stops the stream.
+func (r *userRepository) StreamUsers(ctx context.Context, req dto.UserListRequestDto, fetchSize int, fn func(users []*model.User) error) error {
+	sort, err := parseUserSort(req.Sort)
+	if err != nil {
+		return err
 	}
-	return users, total, nil
+
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		query := tx.Model(&model.User{}).Scopes(r.tenantScope("users.id"), userListFilters(req), userListOrder(sort))
+		if err := tx.Exec("DECLARE user_export NO SCROLL CURSOR FOR ?", query).Error; err != nil {
+			return err
+		}
+
+		fetch := fmt.Sprintf("FETCH FORWARD %d FROM user_export", fetchSize)
+		for {
+			var users []*model.User
+			if err := tx.Raw(fetch).Scan(&users).Error; err != nil {
+				return err
+			}
+			if len(users) == 0 {
+				return nil
+			}
+			if err := fn(users); err != nil {
+				return err
+			}
+		}
+	})
+}
+
+// GetExistingEmails returns the given emails already used by a user, one query for a whole batch
+func (r *userRepository) GetExistingEmails(emails []string) ([]string, error) {
+	var existing []string
+	if len(emails) == 0 {
+		return existing, nil
+	}
+	err := r.db.Model(&model.User{}).Scopes(r.tenantScope("users.id")).Where("email IN ?", emails).Pluck("email", &existing).Error
+	return existing, err
+}
+
+// GetExistingUsernames returns the given usernames already used by a user, one query for a whole batch
+func (r *userRepository) GetExistingUsernames(usernames []string) ([]string, error) {
+	var existing []string
+	if len(usernames) == 0 {
+		return existing, nil
+	}
+	err := r.db.Model(&model.User{}).Scopes(r.tenantScope("users.id")).Where("username IN ?", usernames).Pluck("username", &existing).Error
+	return existing, err
 }
 
 func (r *userRepository) CreateUser(user *model.User) (uuid.UUID, error) {
-	err := r.db.Create(user).Error
+	if r.organizationID == uuid.Nil {
+		err := r.db.Create(user).Error
+		return user.ID, err
+	}
+
+	// The membership gets the default role of the table
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+		return tx.Create(&model.OrganizationMember{OrganizationID: r.organizationID, UserID: user.ID}).Error
+	})
 	return user.ID, err
 }
 
 func (r *userRepository) UpdateUser(id uuid.UUID, user *model.User) (*model.User, error) {
 	// run update
-	if err := r.db.Model(&model.User{}).Where("id = ?", id).Updates(user).Error; err != nil {
+	if err := r.db.Model(&model.User{}).Scopes(r.tenantScope("users.id")).Where("id = ?", id).Updates(user).Error; err != nil {
 		return nil, err
 	}
 
 	var updatedUser model.User
-	if err := r.db.First(&updatedUser, id).Error; err != nil {
+	if err := r.db.Scopes(r.tenantScope("users.id")).First(&updatedUser, id).Error; err != nil {
 		return nil, err
 	}
 
 	return &updatedUser, nil
 }
+
+// SetEmailVerifiedAt sets or clears (nil) the email verification time
+func (r *userRepository) SetEmailVerifiedAt(id uuid.UUID, verifiedAt *time.Time) error {
+	return r.db.Model(&model.User{}).Scopes(r.tenantScope("users.id")).Where("id = ?", id).Update("email_verified_at", verifiedAt).Error
+}
+
+// SetAvatarKey replaces the avatar of the user and returns the key of the previous one, empty when there was none.
+// The row is locked so concurrent uploads each get the key they replaced.
+func (r *userRepository) SetAvatarKey(id uuid.UUID, avatarKey string) (string, error) {
+	var previous string
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var user model.User
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Scopes(r.tenantScope("users.id")).
+			Select("avatar_key").Where("id = ?", id).First(&user).Error
+		if err != nil {
+			return err
+		}
+		previous = user.AvatarKey
+		return tx.Model(&model.User{}).Where("id = ?", id).Update("avatar_key", avatarKey).Error
+	})
+	return previous, err
+}
+
+// ChangePassword sets a new password hash chosen by the user, restarts the password age, clears the required
+// password change and records the hash in the history
+func (r *userRepository) ChangePassword(id uuid.UUID, passwordHash string, historySize int) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&model.User{}).Scopes(r.tenantScope("users.id")).Where("id = ?", id).Updates(map[string]interface{}{
+			"password":             passwordHash,
+			"password_changed_at":  time.Now(),
+			"must_change_password": false,
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return addPasswordHistory(tx, id, passwordHash, historySize)
+	})
+}
+
+// SetTemporaryPassword sets a password hash chosen by somebody else, restarts the password age, requires the user
+// to change the password at the next login and records the hash in the history
+func (r *userRepository) SetTemporaryPassword(id uuid.UUID, passwordHash string, historySize int) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&model.User{}).Scopes(r.tenantScope("users.id")).Where("id = ?", id).Updates(map[string]interface{}{
+			"password":             passwordHash,
+			"password_changed_at":  time.Now(),
+			"must_change_password": true,
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return addPasswordHistory(tx, id, passwordHash, historySize)
+	})
+}
+
+// AddPasswordHistory records a password hash and keeps only the last historySize hashes of the user
+func (r *userRepository) AddPasswordHistory(id uuid.UUID, passwordHash string, historySize int) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if r.organizationID != uuid.Nil {
+			var count int64
+			if err := tx.Model(&model.User{}).Scopes(r.tenantScope("users.id")).Where("id = ?", id).Count(&count).Error; err != nil {
+				return err
+			}
+			if count == 0 {
+				return gorm.ErrRecordNotFound
+			}
+		}
+		return addPasswordHistory(tx, id, passwordHash, historySize)
+	})
+}
+
+// GetPasswordHistory returns the last password hashes of a user, newest first
+func (r *userRepository) GetPasswordHistory(id uuid.UUID, limit int) ([]string, error) {
+	var hashes []string
+	err := r.db.Model(&model.PasswordHistory{}).Scopes(r.tenantScope("user_id")).
+		Where("user_id = ?", id).
+		Order("created_at DESC, id DESC").
+		Limit(limit).
+		Pluck("password_hash", &hashes).Error
+	return hashes, err
+}
+
+// DeleteUser soft deletes the user, it disappears from every query until restored or purged
+func (r *userRepository) DeleteUser(id uuid.UUID) (bool, error) {
+	result := r.db.Scopes(r.tenantScope("users.id")).Where("id = ?", id).Delete(&model.User{})
+	return result.RowsAffected == 1, result.Error
+}
+
+// RestoreUser undoes a soft delete, it fails on the unique indexes when the username or email was taken meanwhile
+func (r *userRepository) RestoreUser(id uuid.UUID) (bool, error) {
+	result := r.db.Unscoped().Model(&model.User{}).Scopes(r.tenantScope("users.id")).
+		Where("id = ? AND deleted_at IS NOT NULL", id).
+		Update("deleted_at", nil)
+	return result.RowsAffected == 1, result.Error
+}
+
+func (r *userRepository) GetDeletedUserByID(id uuid.UUID) *model.User {
+	var user model.User
+	err := r.db.Unscoped().Scopes(r.tenantScope("users.id")).Where("deleted_at IS NOT NULL").First(&user, id).Error
+	if err != nil {
+		return nil
+	}
+	return &user
+}
+
+// GetListDeletedUser lists soft deleted users, most recently deleted first
+func (r *userRepository) GetListDeletedUser(req dto.UserListRequestDto) ([]*model.User, int64, error) {
+	var users []*model.User
+	var total int64
+
+	query := r.db.Unscoped().Model(&model.User{}).Scopes(r.tenantScope("users.id")).Where("deleted_at IS NOT NULL")
+	query = query.Scopes(userListFilters(req))
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	query = query.Limit(req.Limit).Offset(req.Skip).Order("deleted_at DESC")
+
+	if err := query.Find(&users).Error; err != nil {
+		return nil, 0, err
+	}
+	return users, total, nil
+}
+
+// PurgeDeletedUsers permanently deletes up to limit users soft deleted before deletedBefore and returns their IDs.
+// Their sessions, keys, credentials and memberships go with them, audit logs and invitations are kept.
+func (r *userRepository) PurgeDeletedUsers(deletedBefore time.Time, limit int) ([]uuid.UUID, error) {
+	var ids []uuid.UUID
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Unscoped().Model(&model.User{}).Scopes(r.tenantScope("users.id")).
+			Where("deleted_at IS NOT NULL AND deleted_at < ?", deletedBefore).
+			Order("deleted_at").Limit(limit).
+			Pluck("id", &ids).Error
+		if err != nil || len(ids) == 0 {
+			return err
+		}
+		return tx.Unscoped().Where("id IN ? AND deleted_at IS NOT NULL", ids).Delete(&model.User{}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+func addPasswordHistory(tx *gorm.DB, userID uuid.UUID, passwordHash string, historySize int) error {
+	if historySize <= 0 {
+		return nil
+	}
+
+	id, err := uuid.NewV7()
+	if err != nil {
+		return err
+	}
+	entry := &model.PasswordHistory{ID: id, UserID: userID, PasswordHash: passwordHash}
+	if err := tx.Create(entry).Error; err != nil {
+		return err
+	}
+
+	keep := tx.Model(&model.PasswordHistory{}).Select("id").
+		Where("user_id = ?", userID).Order("created_at DESC, id DESC").Limit(historySize)
+	return tx.Where("user_id = ? AND id NOT IN (?)", userID, keep).Delete(&model.PasswordHistory{}).Error
+}
@@ -0,0 +1,58 @@
+package repo
+
+import (
+	"app/internal/modules/user/model"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type IWebAuthnRepository interface {
+	GetCredentialsByUserID(userID uuid.UUID) ([]*model.WebAuthnCredential, error)
+	CreateCredential(credential *model.WebAuthnCredential) error
+	UpdateCredentialUsage(userID uuid.UUID, credentialID []byte, signCount uint32, backupState bool) error
+	DeleteCredential(userID uuid.UUID, id uuid.UUID) (bool, error)
+	HasCredentials(userID uuid.UUID) bool
+}
+
+func NewWebAuthnRepository(db *gorm.DB) IWebAuthnRepository {
+	return &webAuthnRepository{db: db}
+}
+
+type webAuthnRepository struct {
+	db *gorm.DB
+}
+
+func (r *webAuthnRepository) GetCredentialsByUserID(userID uuid.UUID) ([]*model.WebAuthnCredential, error) {
+	var credentials []*model.WebAuthnCredential
+	if err := r.db.Where("user_id = ?", userID).Order("created_at").Find(&credentials).Error; err != nil {
+		return nil, err
+	}
+	return credentials, nil
+}
+
+func (r *webAuthnRepository) CreateCredential(credential *model.WebAuthnCredential) error {
+	return r.db.Create(credential).Error
+}
+
+func (r *webAuthnRepository) UpdateCredentialUsage(userID uuid.UUID, credentialID []byte, signCount uint32, backupState bool) error {
+	return r.db.Model(&model.WebAuthnCredential{}).Where("user_id = ? AND credential_id = ?", userID, credentialID).Updates(map[string]interface{}{
+		"sign_count":   signCount,
+		"backup_state": backupState,
+		"last_used_at": time.Now(),
+	}).Error
+}
+
+func (r *webAuthnRepository) DeleteCredential(userID uuid.UUID, id uuid.UUID) (bool, error) {
+	result := r.db.Where("id = ? AND user_id = ?", id, userID).Delete(&model.WebAuthnCredential{})
+	return result.RowsAffected == 1, result.Error
+}
+
+func (r *webAuthnRepository) HasCredentials(userID uuid.UUID) bool {
+	var count int64
+	if err := r.db.Model(&model.WebAuthnCredential{}).Where("user_id = ?", userID).Count(&count).Error; err != nil {
+		return false
+	}
+	return count > 0
+}
@@ -2,6 +2,7 @@ package router
 
 import (
 	"app/internal/middlewares"
+	"app/internal/modules/user/constants"
 	"app/internal/wire"
 
 	"github.com/gin-gonic/gin"
@@ -11,30 +12,160 @@ type UsersRouter struct{}
 
 func (pr *UsersRouter) InitUserRouter(Router *gin.RouterGroup) {
 	// WIRE go - get user controller with dependency injection
-	userController, _ := wire.InitUserRouterHandler()
+	userController, err := wire.InitUserRouterHandler()
+	if err != nil {
+		panic(err)
+	}
+	authMiddleware, err := wire.InitAuthMiddleware()
+	if err != nil {
+		panic(err)
+	}
 
 	// public router - no authentication required
 	usersRouterPublic := Router.Group("/user")
 	{
 		usersRouterPublic.POST("/login", userController.Login)
+		usersRouterPublic.POST("/login/mfa", userController.LoginMFA)
+		usersRouterPublic.POST("/login/mfa/enroll", userController.StartLoginMFAEnrollment)
+		usersRouterPublic.POST("/login/mfa/passkey/start", userController.StartPasskeyMFA)
+		usersRouterPublic.POST("/login/mfa/passkey", userController.LoginPasskeyMFA)
+		usersRouterPublic.POST("/login/passkey/start", userController.StartPasskeyLogin)
+		usersRouterPublic.POST("/login/passkey", userController.PasskeyLogin)
+		usersRouterPublic.POST("/login/magic", userController.StartMagicLinkLogin)
+		usersRouterPublic.POST("/login/magic/callback", userController.MagicLinkCallback)
 		usersRouterPublic.POST("/register", userController.Register)
-		usersRouterPublic.GET("/get_user/:id", userController.GetUserByID)
+		usersRouterPublic.POST("/refresh", userController.RefreshToken)
+		usersRouterPublic.POST("/forgot_password", userController.ForgotPassword)
+		usersRouterPublic.POST("/reset_password", userController.ResetPassword)
+		usersRouterPublic.POST("/verify_email", userController.VerifyEmail)
+		usersRouterPublic.POST("/resend_verification", userController.ResendVerification)
+		usersRouterPublic.GET("/oidc/providers", userController.GetOIDCProviders)
+		usersRouterPublic.POST("/oidc/:provider/authorize", userController.StartOIDCLogin)
+		usersRouterPublic.POST("/oidc/:provider/callback", userController.OIDCCallback)
+	}
+
+	// password change router - a login or the restricted token of a login that requires a password change
+	usersRouterPasswordChange := Router.Group("/user")
+	usersRouterPasswordChange.Use(authMiddleware.AuthenticatePasswordChange())
+	{
+		usersRouterPasswordChange.POST("/change_password", middlewares.RejectImpersonation(), userController.ChangePassword)
 	}
 
-	// private router - authentication required
+	// private router - authentication with a login required
 	usersRouterPrivate := Router.Group("/user")
-	usersRouterPrivate.Use(middlewares.AuthMiddleware())
+	usersRouterPrivate.Use(authMiddleware.Authenticate())
 	{
 		usersRouterPrivate.GET("/me", userController.GetCurrentUser)
-		usersRouterPrivate.POST("/create_user", userController.CreateUser)
-		usersRouterPrivate.PUT("/update_user/:id", userController.UpdateUser)
-		usersRouterPrivate.GET("/list_user", userController.GetListUser)
+		usersRouterPrivate.PUT("/me/avatar", userController.UploadAvatar)
+		usersRouterPrivate.DELETE("/me/avatar", userController.DeleteAvatar)
+		usersRouterPrivate.POST("/impersonation/stop", userController.StopImpersonation)
+	}
+
+	// user router - a login or an API key with the scope of the route required, users are limited to the
+	// organization when one is selected
+	usersRouterUser := Router.Group("/user")
+	usersRouterUser.Use(authMiddleware.AuthenticateWithAPIKey(), authMiddleware.OptionalTenant())
+	{
+		usersRouterUser.GET("/get_user/:id", middlewares.RequireScopes(constants.PermissionUserList), userController.GetUserByID)
+		usersRouterUser.POST("/create_user", authMiddleware.RequirePermission(constants.PermissionUserCreate), userController.CreateUser)
+		usersRouterUser.PUT("/update_user/:id", middlewares.RequireScopes(constants.PermissionUserUpdate), userController.UpdateUser)
+		usersRouterUser.GET("/list_user", authMiddleware.RequirePermission(constants.PermissionUserList), userController.GetListUser)
+	}
+
+	// session router - authentication with a login required, impersonation is rejected
+	usersRouterSession := Router.Group("/user")
+	usersRouterSession.Use(authMiddleware.Authenticate(), middlewares.RejectImpersonation())
+	{
+		usersRouterSession.POST("/logout", userController.Logout)
+		usersRouterSession.POST("/logout_all", userController.LogoutAll)
+		usersRouterSession.POST("/mfa/enroll", userController.EnrollMFA)
+		usersRouterSession.POST("/mfa/confirm", userController.ConfirmMFA)
+		usersRouterSession.POST("/mfa/disable", userController.DisableMFA)
+		usersRouterSession.POST("/mfa/recovery_codes", userController.RegenerateRecoveryCodes)
+		usersRouterSession.GET("/api_keys", userController.GetAPIKeys)
+		usersRouterSession.POST("/api_keys", userController.CreateAPIKey)
+		usersRouterSession.DELETE("/api_keys/:id", userController.RevokeAPIKey)
+		usersRouterSession.GET("/sessions", userController.GetSessions)
+		usersRouterSession.DELETE("/sessions/:id", userController.RevokeSession)
+		usersRouterSession.POST("/sessions/revoke_others", userController.RevokeOtherSessions)
+		usersRouterSession.POST("/oidc/:provider/link", userController.StartOIDCLink)
+		usersRouterSession.POST("/oidc/:provider/link/callback", userController.OIDCLinkCallback)
+		usersRouterSession.GET("/identities", userController.GetIdentities)
+		usersRouterSession.DELETE("/identities/:id", userController.UnlinkIdentity)
+		usersRouterSession.GET("/passkeys", userController.GetPasskeys)
+		usersRouterSession.POST("/passkeys/register/start", userController.StartPasskeyRegistration)
+		usersRouterSession.POST("/passkeys/register/finish", userController.FinishPasskeyRegistration)
+		usersRouterSession.DELETE("/passkeys/:id", userController.DeletePasskey)
+		usersRouterSession.GET("/organizations", userController.GetUserOrganizations)
+		usersRouterSession.POST("/organizations/switch", userController.SwitchOrganization)
+		usersRouterSession.POST("/organizations/invitations/accept", userController.AcceptOrganizationInvitation)
 	}
 
-	// admin router - authentication and admin role required
+	// admin router - a login or an API key, and a permission per route required
 	usersRouterAdmin := Router.Group("/admin")
-	usersRouterAdmin.Use(middlewares.AuthMiddleware(), middlewares.RoleMiddleware("ADMIN", "SUPER_ADMIN"))
+	usersRouterAdmin.Use(authMiddleware.AuthenticateWithAPIKey())
+	{
+		mfaPolicy := authMiddleware.RequirePermission(constants.PermissionMFAPolicyManage)
+		usersRouterAdmin.GET("/mfa_policies", mfaPolicy, userController.GetMFAPolicies)
+		usersRouterAdmin.PUT("/mfa_policies", mfaPolicy, userController.SetMFAPolicy)
+
+		usersRouterAdmin.POST("/unlock_user/:id", authMiddleware.RequirePermission(constants.PermissionUserUnlock), userController.UnlockUser)
+
+		invitation := authMiddleware.RequirePermission(constants.PermissionInvitationManage)
+		usersRouterAdmin.POST("/invitations", invitation, userController.CreateInvitation)
+		usersRouterAdmin.GET("/invitations", invitation, userController.GetListInvitation)
+		usersRouterAdmin.DELETE("/invitations/:id", invitation, userController.RevokeInvitation)
+
+		role := authMiddleware.RequirePermission(constants.PermissionRoleManage)
+		usersRouterAdmin.GET("/roles", role, userController.GetRoles)
+		usersRouterAdmin.POST("/roles", role, userController.CreateRole)
+		usersRouterAdmin.PUT("/roles/:name", role, userController.UpdateRole)
+		usersRouterAdmin.DELETE("/roles/:name", role, userController.DeleteRole)
+		usersRouterAdmin.GET("/permissions", role, userController.GetPermissions)
+
+		usersRouterAdmin.GET("/users/:id/sessions", authMiddleware.RequirePermission(constants.PermissionSessionView), userController.GetUserSessions)
+		usersRouterAdmin.POST("/users/:id/impersonate", middlewares.RejectAPIKey(), middlewares.RejectImpersonation(),
+			authMiddleware.RequirePermission(constants.PermissionUserImpersonate), userController.StartImpersonation)
+		userImport := authMiddleware.RequirePermission(constants.PermissionUserCreate)
+		usersRouterAdmin.POST("/users/import", userImport, userController.StartUserImport)
+		usersRouterAdmin.GET("/users/import/:id", userImport, userController.GetUserImportJob)
+		usersRouterAdmin.GET("/users/import/:id/report", userImport, userController.GetUserImportReport)
+
+		usersRouterAdmin.GET("/users/export", authMiddleware.RequirePermission(constants.PermissionUserExport), authMiddleware.OptionalTenant(),
+			userController.ExportUsers)
+
+		userDelete := authMiddleware.RequirePermission(constants.PermissionUserDelete)
+		usersRouterAdmin.GET("/users/deleted", userDelete, userController.GetListDeletedUser)
+		usersRouterAdmin.DELETE("/users/:id", middlewares.RejectImpersonation(), userDelete, userController.DeleteUser)
+		usersRouterAdmin.POST("/users/:id/restore", middlewares.RejectImpersonation(), userDelete, userController.RestoreUser)
+		usersRouterAdmin.GET("/audit_logs", authMiddleware.RequirePermission(constants.PermissionAuditLogView), userController.GetListAuditLog)
+
+		serviceAccount := authMiddleware.RequirePermission(constants.PermissionServiceAccountManage)
+		usersRouterAdmin.POST("/service_accounts", serviceAccount, userController.CreateServiceAccount)
+		usersRouterAdmin.GET("/service_accounts/:id/api_keys", serviceAccount, userController.GetServiceAccountAPIKeys)
+		usersRouterAdmin.POST("/service_accounts/:id/api_keys", serviceAccount, userController.CreateServiceAccountAPIKey)
+		usersRouterAdmin.DELETE("/service_accounts/:id/api_keys/:key_id", serviceAccount, userController.RevokeServiceAccountAPIKey)
+
+		organization := authMiddleware.RequirePermission(constants.PermissionOrganizationManage)
+		usersRouterAdmin.GET("/organizations", organization, userController.GetListOrganization)
+		usersRouterAdmin.POST("/organizations", organization, userController.CreateOrganization)
+		usersRouterAdmin.PUT("/organizations/:id", organization, userController.UpdateOrganization)
+		usersRouterAdmin.GET("/organizations/:id/members", organization, userController.GetListOrganizationMemberAdmin)
+		usersRouterAdmin.POST("/organizations/:id/members", organization, userController.AddOrganizationMemberAdmin)
+		usersRouterAdmin.PUT("/organizations/:id/members/:user_id", organization, userController.UpdateOrganizationMemberAdmin)
+		usersRouterAdmin.DELETE("/organizations/:id/members/:user_id", organization, userController.RemoveOrganizationMemberAdmin)
+	}
+
+	// organization router - a login and a membership of the organization from the token or header required
+	usersRouterOrganization := Router.Group("/organization")
+	usersRouterOrganization.Use(authMiddleware.Authenticate(), authMiddleware.Tenant())
 	{
-		// Admin-only endpoints can be added here
+		organizationAdmin := middlewares.RequireOrganizationRole(constants.OrganizationRoleOwner, constants.OrganizationRoleAdmin)
+		usersRouterOrganization.GET("", userController.GetCurrentOrganization)
+		usersRouterOrganization.GET("/users", userController.GetListOrganizationUser)
+		usersRouterOrganization.GET("/members", userController.GetListOrganizationMember)
+		usersRouterOrganization.POST("/members", organizationAdmin, userController.AddOrganizationMember)
+		usersRouterOrganization.PUT("/members/:user_id", organizationAdmin, userController.UpdateOrganizationMember)
+		usersRouterOrganization.DELETE("/members/:user_id", organizationAdmin, userController.RemoveOrganizationMember)
 	}
 }
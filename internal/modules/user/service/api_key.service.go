@@ -0,0 +1,219 @@
+package service
+
+import (
+	"app/global"
+	"app/internal/modules/user/constants"
+	"app/internal/modules/user/dto"
+	"app/internal/modules/user/model"
+	"app/internal/modules/user/repo"
+	"app/pkg/response"
+	"app/pkg/securetoken"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var ErrAPIKeyInvalid = errors.New("api key invalid, expired or revoked")
+
+// apiKeyLookupLength is the length of the public part of a key, "ak_" followed by 8 random characters
+const apiKeyLookupLength = len(constants.APIKeyPrefix) + 8
+
+// apiKeyTouchInterval limits how often the last used timestamp of a key is written
+const apiKeyTouchInterval = time.Minute
+
+// IAPIKeyService authenticates requests carrying an API key instead of a JWT
+type IAPIKeyService interface {
+	Authenticate(rawKey string) (*model.APIKey, *model.User, error)
+}
+
+type apiKeyService struct {
+	apiKeyRepo repo.IAPIKeyRepository
+	userRepo   repo.IUserRepository
+}
+
+func NewAPIKeyService(apiKeyRepo repo.IAPIKeyRepository, userRepo repo.IUserRepository) IAPIKeyService {
+	return &apiKeyService{
+		apiKeyRepo: apiKeyRepo,
+		userRepo:   userRepo,
+	}
+}
+
+// IsAPIKey reports whether a credential looks like an API key rather than a JWT
+func IsAPIKey(credential string) bool {
+	return strings.HasPrefix(credential, constants.APIKeyPrefix)
+}
+
+func (s *apiKeyService) Authenticate(rawKey string) (*model.APIKey, *model.User, error) {
+	if len(rawKey) <= apiKeyLookupLength || !IsAPIKey(rawKey) || rawKey[apiKeyLookupLength] != '_' {
+		return nil, nil, ErrAPIKeyInvalid
+	}
+
+	apiKey := s.apiKeyRepo.GetAPIKeyByPrefix(rawKey[:apiKeyLookupLength])
+	if apiKey == nil {
+		return nil, nil, ErrAPIKeyInvalid
+	}
+	if subtle.ConstantTimeCompare([]byte(apiKey.KeyHash), []byte(securetoken.Hash(rawKey))) != 1 || !apiKey.IsUsable() {
+		return nil, nil, ErrAPIKeyInvalid
+	}
+
+	user := s.userRepo.GetUserByID(apiKey.UserID)
+	if user == nil || (user.IsActive != nil && !*user.IsActive) {
+		return nil, nil, ErrAPIKeyInvalid
+	}
+
+	if err := s.apiKeyRepo.TouchAPIKey(apiKey.ID, time.Now(), apiKeyTouchInterval); err != nil {
+		global.Logger.Warn("Failed to update API key last use: " + err.Error())
+	}
+	return apiKey, user, nil
+}
+
+func (us *userService) CreateAPIKey(ownerID uuid.UUID, req dto.CreateAPIKeyRequestDto) *response.ServiceResult {
+	owner := us.userRepo.GetUserByID(ownerID)
+	if owner == nil {
+		return response.NewServiceErrorWithCode(404, response.ErrCodeUserNotFound)
+	}
+
+	return us.createAPIKey(owner, req)
+}
+
+func (us *userService) GetAPIKeys(ownerID uuid.UUID) *response.ServiceResult {
+	apiKeys, err := us.apiKeyRepo.GetAPIKeysByUserID(ownerID)
+	if err != nil {
+		global.Logger.Error("Failed to get API keys: " + err.Error())
+		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+
+	return response.NewServiceResult(apiKeys)
+}
+
+func (us *userService) RevokeAPIKey(ownerID uuid.UUID, id uuid.UUID) *response.ServiceResult {
+	revoked, err := us.apiKeyRepo.RevokeAPIKey(ownerID, id)
+	if err != nil {
+		global.Logger.Error("Failed to revoke API key: " + err.Error())
+		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+	if !revoked {
+		return response.NewServiceErrorWithCode(404, response.ErrCodeAPIKeyNotFound)
+	}
+
+	return response.NewServiceResult(nil)
+}
+
+// CreateServiceAccount creates a user that cannot log in and only authenticates with API keys
+func (us *userService) CreateServiceAccount(req dto.CreateServiceAccountRequestDto, actorRole string) *response.ServiceResult {
+	if errResult := us.checkAssignableRole(actorRole, req.SystemRole); errResult != nil {
+		return errResult
+	}
+
+	// The password is random and never shown, service accounts are rejected by Login anyway
+	password, err := securetoken.Generate(32)
+	if err != nil {
+		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+	createResult := us.createUser(dto.UserRequestDto{
+		Email:            fmt.Sprintf("%s@%s", req.Username, constants.ServiceAccountEmailDomain),
+		Username:         req.Username,
+		FullName:         req.FullName,
+		Password:         password,
+		SystemRole:       req.SystemRole,
+		IsServiceAccount: true,
+	})
+	if createResult.Error != nil {
+		return createResult
+	}
+
+	user, errResult := us.getUserFromDB(us.userRepo, createResult.Data.(uuid.UUID))
+	if errResult != nil {
+		return errResult
+	}
+	return response.NewServiceResult(user)
+}
+
+func (us *userService) CreateServiceAccountAPIKey(accountID uuid.UUID, req dto.CreateAPIKeyRequestDto, actorRole string) *response.ServiceResult {
+	account, errResult := us.getManagedServiceAccount(accountID, actorRole)
+	if errResult != nil {
+		return errResult
+	}
+
+	return us.createAPIKey(account, req)
+}
+
+func (us *userService) GetServiceAccountAPIKeys(accountID uuid.UUID, actorRole string) *response.ServiceResult {
+	if _, errResult := us.getManagedServiceAccount(accountID, actorRole); errResult != nil {
+		return errResult
+	}
+
+	return us.GetAPIKeys(accountID)
+}
+
+func (us *userService) RevokeServiceAccountAPIKey(accountID uuid.UUID, id uuid.UUID, actorRole string) *response.ServiceResult {
+	if _, errResult := us.getManagedServiceAccount(accountID, actorRole); errResult != nil {
+		return errResult
+	}
+
+	return us.RevokeAPIKey(accountID, id)
+}
+
+// getManagedServiceAccount returns the service account if the actor's role covers the account's role
+func (us *userService) getManagedServiceAccount(accountID uuid.UUID, actorRole string) (*model.User, *response.ServiceResult) {
+	account := us.userRepo.GetUserByID(accountID)
+	if account == nil || !account.IsServiceAccount {
+		return nil, response.NewServiceErrorWithCode(404, response.ErrCodeUserNotFound)
+	}
+	if us.checkAssignableRole(actorRole, account.SystemRole) != nil {
+		return nil, response.NewServiceErrorWithCode(403, response.ErrCodeUserPermissionDenied)
+	}
+	return account, nil
+}
+
+// createAPIKey issues a key for the owner, its scopes must be permissions the owner's role grants
+func (us *userService) createAPIKey(owner *model.User, req dto.CreateAPIKeyRequestDto) *response.ServiceResult {
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return response.NewServiceErrorWithCode(422, response.ErrCodeInvalidData)
+	}
+
+	scopes := slices.Compact(slices.Sorted(slices.Values(req.Scopes)))
+	allowed, err := us.permissionService.HasPermission(owner.SystemRole, scopes...)
+	if err != nil {
+		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+	if !allowed {
+		return response.NewServiceErrorWithCode(403, response.ErrCodeScopeNotAllowed)
+	}
+
+	lookup, err := securetoken.Generate(6)
+	if err != nil {
+		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+	secret, err := securetoken.Generate(32)
+	if err != nil {
+		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+	prefix := constants.APIKeyPrefix + lookup
+	rawKey := prefix + "_" + secret
+
+	id, err := uuid.NewV7()
+	if err != nil {
+		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+	apiKey := &model.APIKey{
+		ID:        id,
+		UserID:    owner.ID,
+		Name:      req.Name,
+		Prefix:    prefix,
+		KeyHash:   securetoken.Hash(rawKey),
+		Scopes:    scopes,
+		ExpiresAt: req.ExpiresAt,
+	}
+	if err := us.apiKeyRepo.CreateAPIKey(apiKey); err != nil {
+		global.Logger.Error("Failed to create API key: " + err.Error())
+		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+
+	return response.NewServiceResult(&dto.APIKeyResponseDto{APIKey: apiKey, Key: rawKey})
+}
@@ -0,0 +1,134 @@
+package service
+
+import (
+	"app/global"
+	"app/internal/modules/user/constants"
+	"app/internal/modules/user/dto"
+	"app/internal/modules/user/model"
+	"app/internal/modules/user/repo"
+	"app/pkg/jwt"
+	"app/pkg/response"
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// maxAuditPathLength is the size of the path column
+const maxAuditPathLength = 255
+
+// IAuditService records audit log entries, AuthMiddleware uses it for the writes made while impersonating
+type IAuditService interface {
+	Record(entry *model.AuditLog)
+}
+
+type auditService struct {
+	auditLogRepo repo.IAuditLogRepository
+}
+
+func NewAuditService(auditLogRepo repo.IAuditLogRepository) IAuditService {
+	return &auditService{auditLogRepo: auditLogRepo}
+}
+
+// Record stores the entry, a failure is logged and does not fail the audited action
+func (s *auditService) Record(entry *model.AuditLog) {
+	if entry.ID == uuid.Nil {
+		id, err := uuid.NewV7()
+		if err != nil {
+			global.Logger.Error("Failed to generate audit log id: " + err.Error())
+			return
+		}
+		entry.ID = id
+	}
+	if len(entry.Path) > maxAuditPathLength {
+		entry.Path = entry.Path[:maxAuditPathLength]
+	}
+	if len(entry.UserAgent) > maxUserAgentLength {
+		entry.UserAgent = entry.UserAgent[:maxUserAgentLength]
+	}
+
+	if err := s.auditLogRepo.CreateAuditLog(entry); err != nil {
+		global.Logger.Error("Failed to record audit log: " + err.Error())
+	}
+}
+
+func (us *userService) GetListAuditLog(req dto.AuditLogListRequestDto) *response.ServiceResult {
+	if req.Limit == 0 {
+		req.Limit = 10
+	}
+
+	entries, total, err := us.auditLogRepo.GetListAuditLog(req)
+	if err != nil {
+		global.Logger.Error("Failed to get audit logs from repository: " + err.Error())
+		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+
+	return response.NewServiceResult(&dto.AuditLogListResponseDto{Total: total, Data: entries})
+}
+
+// StartImpersonation issues a short-lived token acting as the target user, for users the actor's role covers
+func (us *userService) StartImpersonation(targetID uuid.UUID, actorID uuid.UUID, actorRole string, client dto.ClientInfo) *response.ServiceResult {
+	if targetID == actorID {
+		return response.NewServiceErrorWithCode(400, response.ErrCodeImpersonation)
+	}
+
+	target := us.userRepo.GetUserByID(targetID)
+	if target == nil {
+		return response.NewServiceErrorWithCode(404, response.ErrCodeUserNotFound)
+	}
+	// Service accounts only authenticate with API keys
+	if target.IsServiceAccount {
+		return response.NewServiceErrorWithCode(403, response.ErrCodeImpersonation)
+	}
+	if target.IsActive != nil && !*target.IsActive {
+		return response.NewServiceErrorWithCode(403, response.ErrCodeAccountLock)
+	}
+	if us.checkAssignableRole(actorRole, target.SystemRole) != nil {
+		return response.NewServiceErrorWithCode(403, response.ErrCodeUserPermissionDenied)
+	}
+
+	version, err := us.redisProvider.GetTokenVersion(context.Background(), target.ID.String())
+	if err != nil {
+		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+	tokenID := uuid.NewString()
+	expiry := global.Config.Auth.ImpersonationTokenExpiry
+	token, err := jwt.GenerateImpersonationToken(target.ID, target.Email, target.SystemRole, actorID, tokenID, version, global.JWTKeys, expiry)
+	if err != nil {
+		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+
+	us.auditService.Record(&model.AuditLog{
+		ActorID:   &actorID,
+		UserID:    &target.ID,
+		Action:    constants.AuditActionImpersonationStart,
+		TokenID:   tokenID,
+		IPAddress: client.IPAddress,
+		UserAgent: client.UserAgent,
+	})
+
+	return response.NewServiceResult(&dto.ImpersonationResponseDto{
+		Token:     token,
+		ExpiresAt: time.Now().Add(expiry),
+		UserID:    target.ID,
+	})
+}
+
+// StopImpersonation revokes the impersonation token of the request
+func (us *userService) StopImpersonation(userID uuid.UUID, actorID uuid.UUID, tokenID string, expiresAt time.Time, client dto.ClientInfo) *response.ServiceResult {
+	if err := us.redisProvider.DenyToken(context.Background(), tokenID, time.Until(expiresAt)); err != nil {
+		global.Logger.Error("Failed to revoke impersonation token: " + err.Error())
+		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+
+	us.auditService.Record(&model.AuditLog{
+		ActorID:   &actorID,
+		UserID:    &userID,
+		Action:    constants.AuditActionImpersonationStop,
+		TokenID:   tokenID,
+		IPAddress: client.IPAddress,
+		UserAgent: client.UserAgent,
+	})
+
+	return response.NewServiceResult(nil)
+}
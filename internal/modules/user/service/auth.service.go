@@ -0,0 +1,172 @@
+package service
+
+import (
+	"app/global"
+	"app/internal/modules/user/dto"
+	"app/internal/modules/user/model"
+	"app/internal/third_party/redis"
+	"app/pkg/jwt"
+	"app/pkg/response"
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// generateAuthTokens starts a session for the login and issues an access token and a refresh token
+// starting the session's refresh token family
+func (us *userService) generateAuthTokens(user *model.User, client dto.ClientInfo) (*dto.AuthResponseDto, error) {
+	ctx := context.Background()
+
+	familyID, err := us.startSession(user, client)
+	if err != nil {
+		return nil, err
+	}
+	tokenID := uuid.NewString()
+	if err := us.redisProvider.CreateRefreshFamily(ctx, familyID, user.ID.String(), tokenID, global.Config.JWT.RefreshExpiry); err != nil {
+		return nil, err
+	}
+
+	return us.signAuthTokens(user, familyID, tokenID)
+}
+
+func (us *userService) signAuthTokens(user *model.User, familyID string, tokenID string) (*dto.AuthResponseDto, error) {
+	version, err := us.redisProvider.GetTokenVersion(context.Background(), user.ID.String())
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := jwt.GenerateToken(user.ID, user.Email, user.SystemRole, familyID, us.sessionOrganization(familyID), version, global.JWTKeys, global.Config.JWT.TokenExpiry)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, err := jwt.GenerateRefreshToken(user.ID, familyID, tokenID, version, global.JWTKeys, global.Config.JWT.RefreshExpiry)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.AuthResponseDto{
+		Token:        token,
+		RefreshToken: refreshToken,
+	}, nil
+}
+
+// signScopedToken issues a scoped token of the flow at the user's current token version
+func (us *userService) signScopedToken(userID uuid.UUID, tokenType string, expiry time.Duration) (string, error) {
+	version, err := us.redisProvider.GetTokenVersion(context.Background(), userID.String())
+	if err != nil {
+		return "", err
+	}
+
+	return jwt.GenerateScopedToken(userID, tokenType, version, global.JWTKeys, expiry)
+}
+
+func (us *userService) RefreshToken(refreshToken string, client dto.ClientInfo) *response.ServiceResult {
+	claims, err := jwt.ValidateRefreshToken(refreshToken, global.JWTKeys)
+	if err != nil {
+		return response.NewServiceErrorWithCode(401, response.ErrInvalidToken)
+	}
+
+	ctx := context.Background()
+	user := us.userRepo.GetUserByID(claims.UserID)
+	if user == nil {
+		_ = us.endSession(claims.FamilyID)
+		return response.NewServiceErrorWithCode(401, response.ErrInvalidToken)
+	}
+	if user.IsActive != nil && !*user.IsActive {
+		_ = us.endSession(claims.FamilyID)
+		return response.NewServiceErrorWithCode(403, response.ErrCodeAccountLock)
+	}
+
+	// Tokens issued before the last logout everywhere, deactivation or password change are dead
+	version, err := us.redisProvider.GetTokenVersion(ctx, user.ID.String())
+	if err != nil {
+		global.Logger.Error("Failed to get token version: " + err.Error())
+		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+	if claims.TokenVersion != version {
+		_ = us.endSession(claims.FamilyID)
+		return response.NewServiceErrorWithCode(401, response.ErrCodeTokenRevoked)
+	}
+
+	// Rotate: the presented token becomes unusable and a new one takes its place in the family
+	newTokenID := uuid.NewString()
+	err = us.redisProvider.RotateRefreshFamily(ctx, claims.FamilyID, claims.ID, newTokenID, global.Config.JWT.RefreshExpiry)
+	if err != nil {
+		if errors.Is(err, redis.ErrRefreshTokenReused) {
+			global.Logger.Warn("Refresh token reuse detected, family revoked: " + claims.FamilyID)
+			_ = us.endSession(claims.FamilyID)
+			return response.NewServiceErrorWithCode(401, response.ErrCodeRefreshTokenReused)
+		}
+		if errors.Is(err, redis.ErrRefreshFamilyNotFound) {
+			return response.NewServiceErrorWithCode(401, response.ErrInvalidToken)
+		}
+		global.Logger.Error("Failed to rotate refresh token: " + err.Error())
+		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+
+	if sessionID, err := uuid.Parse(claims.FamilyID); err == nil {
+		expiresAt := time.Now().Add(global.Config.JWT.RefreshExpiry)
+		if err := us.sessionRepo.ExtendSession(sessionID, client.IPAddress, expiresAt); err != nil {
+			global.Logger.Warn("Failed to extend session: " + err.Error())
+		}
+	}
+
+	authResponse, err := us.signAuthTokens(user, claims.FamilyID, newTokenID)
+	if err != nil {
+		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+
+	return response.NewServiceResult(authResponse)
+}
+
+// Logout ends the session of the access token. Tokens issued before sessions existed carry no session,
+// for those the refresh token, when given, identifies the refresh token family to revoke.
+func (us *userService) Logout(userID uuid.UUID, sessionID string, tokenID string, expiresAt time.Time, refreshToken string) *response.ServiceResult {
+	ctx := context.Background()
+
+	if err := us.redisProvider.DenyToken(ctx, tokenID, time.Until(expiresAt)); err != nil {
+		global.Logger.Error("Failed to deny access token: " + err.Error())
+		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+
+	if sessionID != "" {
+		if err := us.endSession(sessionID); err != nil {
+			global.Logger.Error("Failed to revoke session: " + err.Error())
+			return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+		}
+	}
+
+	if refreshToken != "" {
+		claims, err := jwt.ValidateRefreshToken(refreshToken, global.JWTKeys)
+		if err == nil && claims.UserID == userID && claims.FamilyID != sessionID {
+			if err := us.endSession(claims.FamilyID); err != nil {
+				global.Logger.Error("Failed to revoke refresh token family: " + err.Error())
+				return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+			}
+		}
+	}
+
+	return response.NewServiceResult(nil)
+}
+
+func (us *userService) LogoutAll(userID uuid.UUID) *response.ServiceResult {
+	if err := us.revokeAllTokens(userID); err != nil {
+		global.Logger.Error("Failed to revoke user tokens: " + err.Error())
+		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+
+	return response.NewServiceResult(nil)
+}
+
+// revokeAllTokens bumps the user's token version so every access and refresh token issued so far is rejected,
+// and closes every session
+func (us *userService) revokeAllTokens(userID uuid.UUID) error {
+	if _, err := us.redisProvider.BumpTokenVersion(context.Background(), userID.String()); err != nil {
+		return err
+	}
+	_, err := us.sessionRepo.RevokeUserSessions(userID, uuid.Nil)
+	return err
+}
@@ -0,0 +1,127 @@
+package service
+
+import (
+	"app/global"
+	"app/internal/modules/user/constants"
+	"app/internal/modules/user/model"
+	"app/internal/modules/user/repo"
+	"app/pkg/policy"
+	"app/pkg/response"
+	"context"
+	"slices"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// IAuthorizationService decides with the access policy whether the subject of the context may do an action on a resource.
+// The middlewares put the subject in the request context, a context without one is anonymous.
+type IAuthorizationService interface {
+	Authorize(ctx context.Context, action string, resource policy.Attributes) (bool, error)
+}
+
+type authorizationService struct {
+	permissionService IPermissionService
+	organizationRepo  repo.IOrganizationRepository
+}
+
+func NewAuthorizationService(permissionService IPermissionService, organizationRepo repo.IOrganizationRepository) IAuthorizationService {
+	return &authorizationService{
+		permissionService: permissionService,
+		organizationRepo:  organizationRepo,
+	}
+}
+
+// Authorize completes the subject with its permissions and organizations, evaluates the policy and logs the decision
+func (as *authorizationService) Authorize(ctx context.Context, action string, resource policy.Attributes) (bool, error) {
+	subject := policy.SubjectFromContext(ctx)
+	if err := as.addSubjectAttributes(subject); err != nil {
+		return false, err
+	}
+
+	decision := global.Policy.Evaluate(policy.Request{Subject: subject, Action: action, Resource: resource})
+	global.Logger.Info("Authorization decision",
+		zap.String("action", action),
+		zap.Any("subject_id", subject["id"]),
+		zap.Any("resource_id", resource["id"]),
+		zap.Bool("allowed", decision.Allowed),
+		zap.String("rule", decision.RuleID),
+	)
+	return decision.Allowed, nil
+}
+
+func (as *authorizationService) addSubjectAttributes(subject policy.Attributes) error {
+	if role, ok := subject["role"].(string); ok {
+		permissions, err := as.permissionService.GetRolePermissions(role)
+		if err != nil {
+			return err
+		}
+		// An API key only uses the permissions within its scopes
+		if scopes, ok := subject["scopes"].([]string); ok {
+			permissions = slices.DeleteFunc(slices.Clone(permissions), func(permission string) bool {
+				return !slices.Contains(scopes, permission)
+			})
+		}
+		subject["permissions"] = permissions
+	}
+
+	if userID, ok := subject["id"].(uuid.UUID); ok {
+		memberships, err := as.organizationRepo.GetMembershipsByUserID(userID)
+		if err != nil {
+			return err
+		}
+		subject["organizations"] = activeOrganizationIDs(memberships)
+		subject["managed_organizations"] = activeOrganizationIDs(memberships, constants.OrganizationRoleOwner, constants.OrganizationRoleAdmin)
+	}
+	return nil
+}
+
+// activeOrganizationIDs returns the active organizations of the memberships, limited to the given roles when any
+func activeOrganizationIDs(memberships []*model.OrganizationMember, roles ...string) []string {
+	organizations := []string{}
+	for _, membership := range memberships {
+		if membership.Organization == nil || (membership.Organization.IsActive != nil && !*membership.Organization.IsActive) {
+			continue
+		}
+		if len(roles) > 0 && !slices.Contains(roles, membership.Role) {
+			continue
+		}
+		organizations = append(organizations, membership.OrganizationID.String())
+	}
+	return organizations
+}
+
+// authorizeUser returns an error result unless the access policy lets the subject of ctx do the action on the user
+func (us *userService) authorizeUser(ctx context.Context, action string, user *model.User) *response.ServiceResult {
+	resource, err := us.userResource(user)
+	if err != nil {
+		global.Logger.Error("Failed to get user attributes: " + err.Error())
+		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+
+	allowed, err := us.authorizationService.Authorize(ctx, action, resource)
+	if err != nil {
+		global.Logger.Error("Failed to authorize: " + err.Error())
+		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+	if !allowed {
+		return response.NewServiceErrorWithCode(403, response.ErrCodeUserPermissionDenied)
+	}
+	return nil
+}
+
+// userResource returns the policy attributes of a user
+func (us *userService) userResource(user *model.User) (policy.Attributes, error) {
+	memberships, err := us.organizationRepo.GetMembershipsByUserID(user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return policy.Attributes{
+		"id":                 user.ID.String(),
+		"role":               user.SystemRole,
+		"is_active":          user.IsActive == nil || *user.IsActive,
+		"is_service_account": user.IsServiceAccount,
+		"organizations":      activeOrganizationIDs(memberships),
+	}, nil
+}
@@ -0,0 +1,95 @@
+package service
+
+import (
+	"app/internal/modules/user/constants"
+	"app/internal/modules/user/model"
+	"app/internal/modules/user/repo"
+	"app/pkg/policy"
+	"slices"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+type fakePermissionService struct {
+	IPermissionService
+	permissions map[string][]string
+}
+
+func (f *fakePermissionService) GetRolePermissions(role string) ([]string, error) {
+	return f.permissions[role], nil
+}
+
+func (f *fakePermissionService) HasPermission(role string, permissions ...string) (bool, error) {
+	for _, permission := range permissions {
+		if !slices.Contains(f.permissions[role], permission) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+type fakeOrganizationRepo struct {
+	repo.IOrganizationRepository
+	memberships []*model.OrganizationMember
+}
+
+func (f *fakeOrganizationRepo) GetMembershipsByUserID(userID uuid.UUID) ([]*model.OrganizationMember, error) {
+	return f.memberships, nil
+}
+
+func TestAddSubjectAttributesLimitsAPIKeysToScopes(t *testing.T) {
+	permissionService := &fakePermissionService{permissions: map[string][]string{
+		"ADMIN": {"user:list", "user:update", "user:export"},
+	}}
+	as := NewAuthorizationService(permissionService, &fakeOrganizationRepo{}).(*authorizationService)
+
+	tests := []struct {
+		name        string
+		subject     policy.Attributes
+		permissions []string
+	}{
+		{"login", policy.Attributes{"role": "ADMIN"}, []string{"user:list", "user:update", "user:export"}},
+		{"api key", policy.Attributes{"role": "ADMIN", "scopes": []string{"user:list", "role:list"}}, []string{"user:list"}},
+		{"api key without scopes", policy.Attributes{"role": "ADMIN", "scopes": []string{}}, []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := as.addSubjectAttributes(tt.subject); err != nil {
+				t.Fatalf("addSubjectAttributes() error = %v", err)
+			}
+			if got := tt.subject["permissions"].([]string); !slices.Equal(got, tt.permissions) {
+				t.Errorf("permissions = %v, want %v", got, tt.permissions)
+			}
+		})
+	}
+
+	// The role's permissions must not be changed by a key's scopes
+	if got := permissionService.permissions["ADMIN"]; len(got) != 3 {
+		t.Errorf("role permissions = %v, want them untouched", got)
+	}
+}
+
+func TestAddSubjectAttributesKeepsActiveOrganizations(t *testing.T) {
+	active, inactive := true, false
+	member := uuid.New()
+	admin := uuid.New()
+	organizations := &fakeOrganizationRepo{memberships: []*model.OrganizationMember{
+		{OrganizationID: member, Role: constants.OrganizationRoleMember, Organization: &model.Organization{IsActive: &active}},
+		{OrganizationID: admin, Role: constants.OrganizationRoleAdmin, Organization: &model.Organization{IsActive: &active}},
+		{OrganizationID: uuid.New(), Role: constants.OrganizationRoleOwner, Organization: &model.Organization{IsActive: &inactive}},
+	}}
+	as := NewAuthorizationService(&fakePermissionService{}, organizations).(*authorizationService)
+
+	subject := policy.Attributes{"id": uuid.New()}
+	if err := as.addSubjectAttributes(subject); err != nil {
+		t.Fatalf("addSubjectAttributes() error = %v", err)
+	}
+	if got, want := subject["organizations"].([]string), []string{member.String(), admin.String()}; !slices.Equal(got, want) {
+		t.Errorf("organizations = %v, want %v", got, want)
+	}
+	if got, want := subject["managed_organizations"].([]string), []string{admin.String()}; !slices.Equal(got, want) {
+		t.Errorf("managed_organizations = %v, want %v", got, want)
+	}
+}
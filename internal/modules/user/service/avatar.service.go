@@ -0,0 +1,141 @@
+package service
+
+import (
+	"app/global"
+	"app/internal/modules/user/dto"
+	"app/pkg/imaging"
+	"app/pkg/response"
+	"bytes"
+	"context"
+	"fmt"
+	"image/png"
+	"io"
+	"mime/multipart"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type avatarSize struct {
+	Name   string
+	Pixels int
+}
+
+// avatarSizes are the thumbnails stored for every avatar, the uploaded image itself is not kept
+var avatarSizes = []avatarSize{{"small", 64}, {"medium", 256}, {"large", 512}}
+
+// UploadAvatar replaces the avatar of the user with thumbnails of the uploaded image, the type is sniffed from
+// the content. The thumbnails of the previous avatar are deleted.
+func (us *userService) UploadAvatar(ctx context.Context, id uuid.UUID, file *multipart.FileHeader) *response.ServiceResult {
+	config := global.Config.Auth
+	if file.Size > int64(config.AvatarMaxFileSize) {
+		return response.NewServiceErrorWithCode(422, response.ErrCodeAvatarInvalid)
+	}
+	if us.userRepo.GetUserByID(id) == nil {
+		return response.NewServiceErrorWithCode(404, response.ErrCodeUserNotFound)
+	}
+
+	src, err := file.Open()
+	if err != nil {
+		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+	defer src.Close()
+
+	// The declared size is not trusted, reading stops one byte past the limit
+	data, err := io.ReadAll(io.LimitReader(src, int64(config.AvatarMaxFileSize)+1))
+	if err != nil {
+		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+	if len(data) > config.AvatarMaxFileSize {
+		return response.NewServiceErrorWithCode(422, response.ErrCodeAvatarInvalid)
+	}
+	img, err := imaging.Decode(data, config.AvatarMaxPixels)
+	if err != nil {
+		global.Logger.Warn("Rejected avatar: " + err.Error())
+		return response.NewServiceErrorWithCode(422, response.ErrCodeAvatarInvalid)
+	}
+
+	version, err := uuid.NewV7()
+	if err != nil {
+		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+	key := fmt.Sprintf("avatars/%s/%s", id, version)
+
+	for _, size := range avatarSizes {
+		var buffer bytes.Buffer
+		if err := png.Encode(&buffer, imaging.Thumbnail(img, size.Pixels)); err != nil {
+			return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+		}
+		if err := us.s3Provider.UploadStream(ctx, avatarObjectName(key, size.Name), &buffer, int64(buffer.Len()), "image/png"); err != nil {
+			global.Logger.Error("Failed to upload avatar: " + err.Error())
+			us.deleteAvatarObjects(key)
+			return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+		}
+	}
+
+	previous, err := us.userRepo.SetAvatarKey(id, key)
+	if err != nil {
+		global.Logger.Error("Failed to set avatar: " + err.Error())
+		us.deleteAvatarObjects(key)
+		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+	us.deleteAvatarObjects(previous)
+	us.clearUserCache(id)
+
+	return response.NewServiceResult(us.avatarURLs(ctx, key))
+}
+
+// DeleteAvatar removes the avatar of the user, removing a missing avatar succeeds
+func (us *userService) DeleteAvatar(id uuid.UUID) *response.ServiceResult {
+	previous, err := us.userRepo.SetAvatarKey(id, "")
+	if err != nil {
+		global.Logger.Error("Failed to delete avatar: " + err.Error())
+		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+	us.deleteAvatarObjects(previous)
+	us.clearUserCache(id)
+
+	return response.NewServiceResult(nil)
+}
+
+// avatarURLs presigns the thumbnails of an avatar, nil without avatar. A failure is logged and gives nil so the
+// user is still returned.
+func (us *userService) avatarURLs(ctx context.Context, key string) *dto.AvatarDto {
+	if key == "" {
+		return nil
+	}
+
+	expiry := global.Config.Auth.AvatarURLExpiry
+	urls := make(map[string]string, len(avatarSizes))
+	for _, size := range avatarSizes {
+		url, err := us.s3Provider.GetPresignedURL(ctx, avatarObjectName(key, size.Name), expiry)
+		if err != nil {
+			global.Logger.Warn("Failed to presign avatar URL: " + err.Error())
+			return nil
+		}
+		urls[size.Name] = url
+	}
+
+	return &dto.AvatarDto{
+		Small:     urls["small"],
+		Medium:    urls["medium"],
+		Large:     urls["large"],
+		ExpiresAt: time.Now().Add(expiry),
+	}
+}
+
+// deleteAvatarObjects deletes the thumbnails of an avatar, a failure only leaves orphan objects behind
+func (us *userService) deleteAvatarObjects(key string) {
+	if key == "" {
+		return
+	}
+	for _, size := range avatarSizes {
+		if err := us.s3Provider.DeleteFile(context.Background(), avatarObjectName(key, size.Name)); err != nil {
+			global.Logger.Warn("Failed to delete avatar: " + err.Error())
+		}
+	}
+}
+
+func avatarObjectName(key string, size string) string {
+	return fmt.Sprintf("%s/%s.png", key, size)
+}
@@ -0,0 +1,137 @@
+package service
+
+import (
+	"app/global"
+	"app/internal/modules/user/constants"
+	"app/internal/modules/user/dto"
+	"app/internal/modules/user/model"
+	"app/internal/third_party/mail"
+	"app/pkg/jwt"
+	"app/pkg/response"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// resolveRegistration applies the registration policy and returns the role of the new account
+// and the invitation it was registered with, if any
+func (us *userService) resolveRegistration(registerDto dto.RegisterRequestDto) (string, *model.Invitation, *response.ServiceResult) {
+	if registerDto.InvitationToken != "" {
+		invitation, errResult := us.resolveInvitation(registerDto.InvitationToken, registerDto.Email)
+		if errResult != nil {
+			return "", nil, errResult
+		}
+		return invitation.SystemRole, invitation, nil
+	}
+
+	if global.Config.Auth.RegistrationMode != constants.RegistrationOpen {
+		return "", nil, response.NewServiceErrorWithCode(403, response.ErrCodeRegistrationClosed)
+	}
+
+	role := registerDto.Role
+	if role == "" {
+		role = constants.User
+	}
+	if !slices.Contains(global.Config.Auth.SelfRegistrationRoles, role) {
+		return "", nil, response.NewServiceErrorWithCode(403, response.ErrCodeRoleNotAllowed)
+	}
+	return role, nil, nil
+}
+
+// resolveInvitation checks the signed invitation token and that it was issued to this email
+func (us *userService) resolveInvitation(token string, email string) (*model.Invitation, *response.ServiceResult) {
+	if global.Config.Auth.RegistrationMode == constants.RegistrationClosed {
+		return nil, response.NewServiceErrorWithCode(403, response.ErrCodeRegistrationClosed)
+	}
+
+	claims, err := jwt.ValidateScopedToken(token, jwt.TokenTypeInvitation, global.JWTKeys)
+	if err != nil {
+		return nil, response.NewServiceErrorWithCode(400, response.ErrCodeInvitationInvalid)
+	}
+	invitationID, err := uuid.Parse(claims.ID)
+	if err != nil {
+		return nil, response.NewServiceErrorWithCode(400, response.ErrCodeInvitationInvalid)
+	}
+
+	invitation := us.invitationRepo.GetInvitationByID(invitationID)
+	if invitation == nil || !invitation.IsUsable() || !strings.EqualFold(invitation.Email, email) {
+		return nil, response.NewServiceErrorWithCode(400, response.ErrCodeInvitationInvalid)
+	}
+	return invitation, nil
+}
+
+func (us *userService) CreateInvitation(req dto.CreateInvitationRequestDto, inviterID uuid.UUID, inviterRole string) *response.ServiceResult {
+	if errResult := us.checkAssignableRole(inviterRole, req.SystemRole); errResult != nil {
+		return errResult
+	}
+	if global.Config.Auth.RegistrationMode == constants.RegistrationClosed {
+		return response.NewServiceErrorWithCode(403, response.ErrCodeRegistrationClosed)
+	}
+	if us.userRepo.GetUserByEmail(req.Email) != nil {
+		return response.NewServiceErrorWithCode(409, response.ErrCodeUserHasExists)
+	}
+
+	invitationID, err := uuid.NewV7()
+	if err != nil {
+		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+
+	expiry := global.Config.Auth.InvitationExpiry
+	invitation := &model.Invitation{
+		ID:         invitationID,
+		Email:      req.Email,
+		SystemRole: req.SystemRole,
+		InvitedBy:  &inviterID,
+		ExpiresAt:  time.Now().Add(expiry),
+	}
+	if err := us.invitationRepo.CreateInvitation(invitation); err != nil {
+		global.Logger.Error("Failed to create invitation: " + err.Error())
+		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+
+	token, err := jwt.GenerateInvitationToken(invitation.ID, invitation.Email, invitation.SystemRole, global.JWTKeys, expiry)
+	if err != nil {
+		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+
+	link := fmt.Sprintf("%s/register?invitation_token=%s", global.Config.System.AppBaseURL, url.QueryEscape(token))
+	us.sendMailAsync(mail.Message{
+		To:      invitation.Email,
+		Subject: "You have been invited",
+		Body: fmt.Sprintf("Hello,\n\nYou have been invited to create an account. The link below expires in %s.\n\n%s\n",
+			expiry, link),
+	})
+
+	return response.NewServiceResult(&dto.InvitationResponseDto{Invitation: invitation, Link: link})
+}
+
+func (us *userService) GetListInvitation(req dto.InvitationListRequestDto) *response.ServiceResult {
+	if req.Limit == 0 {
+		req.Limit = 10
+	}
+
+	invitations, total, err := us.invitationRepo.GetListInvitation(req.Skip, req.Limit)
+	if err != nil {
+		global.Logger.Error("Failed to get invitations from repository: " + err.Error())
+		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+
+	return response.NewServiceResult(&dto.InvitationListResponseDto{Total: total, Data: invitations})
+}
+
+func (us *userService) RevokeInvitation(id uuid.UUID) *response.ServiceResult {
+	revoked, err := us.invitationRepo.RevokeInvitation(id)
+	if err != nil {
+		global.Logger.Error("Failed to revoke invitation: " + err.Error())
+		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+	if !revoked {
+		return response.NewServiceErrorWithCode(404, response.ErrCodeInvitationInvalid)
+	}
+
+	return response.NewServiceResult(nil)
+}
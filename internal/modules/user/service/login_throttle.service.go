@@ -0,0 +1,122 @@
+package service
+
+import (
+	"app/global"
+	"app/pkg/response"
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Failed logins are tracked for the username and for the client IP separately
+const (
+	loginSubjectUser = "user"
+	loginSubjectIP   = "ip"
+)
+
+type loginSubject struct {
+	scope       string
+	value       string
+	maxAttempts int
+}
+
+func loginSubjects(username string, clientIP string) []loginSubject {
+	return []loginSubject{
+		{scope: loginSubjectUser, value: strings.ToLower(username), maxAttempts: global.Config.Auth.LoginMaxAttempts},
+		{scope: loginSubjectIP, value: clientIP, maxAttempts: global.Config.Auth.LoginMaxAttemptsPerIP},
+	}
+}
+
+func loginFailuresKey(s loginSubject) string {
+	return fmt.Sprintf("login_failures:%s:%s", s.scope, s.value)
+}
+
+func loginDelayKey(s loginSubject) string {
+	return fmt.Sprintf("login_delay:%s:%s", s.scope, s.value)
+}
+
+func loginLockKey(s loginSubject) string {
+	return fmt.Sprintf("login_lock:%s:%s", s.scope, s.value)
+}
+
+// checkLoginThrottle refuses the attempt while the username or the IP is locked or must still wait
+func (us *userService) checkLoginThrottle(username string, clientIP string) *response.ServiceResult {
+	ctx := context.Background()
+	for _, subject := range loginSubjects(username, clientIP) {
+		if ttl, err := us.redisProvider.TTL(ctx, loginLockKey(subject)); err == nil && ttl > 0 {
+			return loginThrottled(response.ErrCodeLoginLocked, ttl)
+		}
+		if ttl, err := us.redisProvider.TTL(ctx, loginDelayKey(subject)); err == nil && ttl > 0 {
+			return loginThrottled(response.ErrCodeTooManyRequests, ttl)
+		}
+	}
+	return nil
+}
+
+// recordLoginFailure counts the failure and applies the progressive delay or the lockout
+func (us *userService) recordLoginFailure(username string, clientIP string) {
+	ctx := context.Background()
+	config := global.Config.Auth
+
+	for _, subject := range loginSubjects(username, clientIP) {
+		failures, err := us.redisProvider.Incr(ctx, loginFailuresKey(subject), config.LoginAttemptWindow)
+		if err != nil {
+			global.Logger.Error("Failed to count login failure: " + err.Error())
+			continue
+		}
+
+		if subject.maxAttempts > 0 && failures >= int64(subject.maxAttempts) {
+			global.Logger.Warn(fmt.Sprintf("Login locked for %s %s after %d failures", subject.scope, subject.value, failures))
+			_ = us.redisProvider.Set(ctx, loginLockKey(subject), 1, config.LoginLockoutDuration)
+			_ = us.redisProvider.Del(ctx, loginFailuresKey(subject))
+			continue
+		}
+
+		if failures >= int64(config.LoginDelayAfter) {
+			_ = us.redisProvider.Set(ctx, loginDelayKey(subject), 1, loginDelay(failures-int64(config.LoginDelayAfter)))
+		}
+	}
+}
+
+// resetLoginFailures clears the username counters after a successful login.
+// The IP counters are kept, one valid account must not reset a password spraying IP.
+func (us *userService) resetLoginFailures(username string) {
+	subject := loginSubject{scope: loginSubjectUser, value: strings.ToLower(username)}
+	_ = us.redisProvider.Del(context.Background(), loginFailuresKey(subject), loginDelayKey(subject))
+}
+
+func (us *userService) UnlockUser(id uuid.UUID) *response.ServiceResult {
+	user := us.userRepo.GetUserByID(id)
+	if user == nil {
+		return response.NewServiceErrorWithCode(404, response.ErrCodeUserNotFound)
+	}
+
+	subject := loginSubject{scope: loginSubjectUser, value: strings.ToLower(user.Username)}
+	err := us.redisProvider.Del(context.Background(), loginFailuresKey(subject), loginDelayKey(subject), loginLockKey(subject))
+	if err != nil {
+		global.Logger.Error("Failed to unlock user: " + err.Error())
+		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+
+	return response.NewServiceResult(nil)
+}
+
+// loginDelay doubles the wait for every failure past the delay threshold, up to the configured maximum
+func loginDelay(extraFailures int64) time.Duration {
+	config := global.Config.Auth
+	delay := float64(config.LoginBaseDelay) * math.Pow(2, float64(extraFailures))
+	if delay > float64(config.LoginMaxDelay) {
+		return config.LoginMaxDelay
+	}
+	return time.Duration(delay)
+}
+
+func loginThrottled(errorCode int, retryAfter time.Duration) *response.ServiceResult {
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	return response.NewServiceErrorWithCode(429, errorCode).WithHeader("Retry-After", strconv.Itoa(seconds))
+}
@@ -0,0 +1,115 @@
+package service
+
+import (
+	"app/global"
+	"app/internal/modules/user/constants"
+	"app/internal/modules/user/dto"
+	"app/internal/modules/user/model"
+	"app/internal/third_party/mail"
+	"app/internal/third_party/redis"
+	"app/pkg/response"
+	"app/pkg/securetoken"
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func (us *userService) StartMagicLinkLogin(email string) *response.ServiceResult {
+	if !global.Config.Auth.MagicLinkEnabled {
+		return response.NewServiceErrorWithCode(403, response.ErrCodeMagicLinkDisabled)
+	}
+
+	user, errResult := us.emailRecipient("magic_link_request", email, global.Config.Auth.MagicLinkResendPeriod, func(user *model.User) bool {
+		return !user.IsServiceAccount && (user.IsActive == nil || *user.IsActive)
+	})
+	if errResult != nil {
+		return errResult
+	}
+	if user == nil {
+		return response.NewServiceResult(nil)
+	}
+
+	token, err := securetoken.Generate(32)
+	if err != nil {
+		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+
+	// The token is bound to the current email so changing the address invalidates pending links
+	expiry := global.Config.Auth.MagicLinkExpiry
+	value := user.ID.String() + "|" + user.Email
+	err = us.redisProvider.SetOneTimeToken(context.Background(), constants.TokenPurposeMagicLink, user.ID.String(), securetoken.Hash(token), value, expiry)
+	if err != nil {
+		global.Logger.Error("Failed to store magic link token: " + err.Error())
+		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+
+	link := fmt.Sprintf("%s/magic-login?token=%s", global.Config.System.AppBaseURL, url.QueryEscape(token))
+	us.sendMailAsync(mail.Message{
+		To:      user.Email,
+		Subject: "Your sign-in link",
+		Body: fmt.Sprintf("Hello %s,\n\nUse the link below to sign in. It expires in %s and can only be used once.\n\n%s\n\nIf you did not ask to sign in, you can ignore this email.\n",
+			user.Username, expiry, link),
+	})
+
+	return response.NewServiceResult(nil)
+}
+
+func (us *userService) MagicLinkCallback(token string, client dto.ClientInfo) *response.ServiceResult {
+	if !global.Config.Auth.MagicLinkEnabled {
+		return response.NewServiceErrorWithCode(403, response.ErrCodeMagicLinkDisabled)
+	}
+
+	value, err := us.redisProvider.ConsumeOneTimeToken(context.Background(), constants.TokenPurposeMagicLink, securetoken.Hash(token))
+	if err != nil {
+		if errors.Is(err, redis.ErrOneTimeTokenNotFound) {
+			return response.NewServiceErrorWithCode(400, response.ErrCodeMagicLinkInvalid)
+		}
+		global.Logger.Error("Failed to consume magic link token: " + err.Error())
+		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+
+	rawID, email, found := strings.Cut(value, "|")
+	if !found {
+		return response.NewServiceErrorWithCode(400, response.ErrCodeMagicLinkInvalid)
+	}
+	userID, err := uuid.Parse(rawID)
+	if err != nil {
+		return response.NewServiceErrorWithCode(400, response.ErrCodeMagicLinkInvalid)
+	}
+	user := us.userRepo.GetUserByID(userID)
+	if user == nil || user.Email != email || user.IsServiceAccount {
+		return response.NewServiceErrorWithCode(400, response.ErrCodeMagicLinkInvalid)
+	}
+	if user.IsActive != nil && !*user.IsActive {
+		return response.NewServiceErrorWithCode(403, response.ErrCodeAccountLock)
+	}
+
+	// Opening the link proves the user controls the address
+	if user.EmailVerifiedAt == nil {
+		now := time.Now()
+		if err := us.userRepo.SetEmailVerifiedAt(user.ID, &now); err != nil {
+			global.Logger.Error("Failed to mark email verified: " + err.Error())
+			return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+		}
+		user.EmailVerifiedAt = &now
+	}
+
+	if user.MustChangePassword || global.PasswordPolicy.IsExpired(user.PasswordChangedAt, time.Now()) {
+		return us.startPasswordChange(user)
+	}
+	if mfaResult := us.startMFALogin(user); mfaResult != nil {
+		return mfaResult
+	}
+
+	authResponse, err := us.generateAuthTokens(user, client)
+	if err != nil {
+		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+
+	return response.NewServiceResult(authResponse)
+}
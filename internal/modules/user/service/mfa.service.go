@@ -0,0 +1,364 @@
+package service
+
+import (
+	"app/global"
+	"app/internal/modules/user/constants"
+	"app/internal/modules/user/dto"
+	"app/internal/modules/user/model"
+	"app/pkg/jwt"
+	"app/pkg/response"
+	"app/pkg/secretbox"
+	"app/pkg/securetoken"
+	"app/pkg/totp"
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	recoveryCodeCount  = 10
+	maxMFALoginAttempt = 5
+)
+
+// startMFALogin returns the MFA step of login for users who enabled MFA or whose role requires it,
+// nil when the user can get tokens right away
+func (us *userService) startMFALogin(user *model.User) *response.ServiceResult {
+	// A registered passkey is a second factor on its own
+	var methods []string
+	if mfa := us.mfaRepo.GetUserMFA(user.ID); mfa != nil && mfa.EnabledAt != nil {
+		methods = append(methods, constants.MFAMethodTOTP)
+	}
+	if us.webAuthnRepo.HasCredentials(user.ID) {
+		methods = append(methods, constants.MFAMethodPasskey)
+	}
+	enabled := len(methods) > 0
+	if !enabled && !us.mfaRepo.IsMFARequiredForRole(user.SystemRole) {
+		return nil
+	}
+
+	mfaToken, err := us.signScopedToken(user.ID, jwt.TokenTypeMFAPending, global.Config.Auth.MFATokenExpiry)
+	if err != nil {
+		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+
+	return response.NewServiceResult(&dto.AuthResponseDto{
+		MFARequired:           true,
+		MFAEnrollmentRequired: !enabled,
+		MFAToken:              mfaToken,
+		MFAMethods:            methods,
+	})
+}
+
+// pendingMFAUser resolves the user of an mfa pending token, limiting the number of codes tried with it
+func (us *userService) pendingMFAUser(mfaToken string) (*jwt.JWTClaims, *model.User, *response.ServiceResult) {
+	claims, err := jwt.ValidateScopedToken(mfaToken, jwt.TokenTypeMFAPending, global.JWTKeys)
+	if err != nil {
+		return nil, nil, response.NewServiceErrorWithCode(401, response.ErrInvalidToken)
+	}
+
+	ctx := context.Background()
+	if denied, err := us.redisProvider.IsTokenDenied(ctx, claims.ID); err != nil || denied {
+		return nil, nil, response.NewServiceErrorWithCode(401, response.ErrInvalidToken)
+	}
+	// A logout everywhere, deactivation or password change since the login also ends its MFA step
+	version, err := us.redisProvider.GetTokenVersion(ctx, claims.UserID.String())
+	if err != nil {
+		global.Logger.Error("Failed to get token version: " + err.Error())
+		return nil, nil, response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+	if claims.TokenVersion != version {
+		return nil, nil, response.NewServiceErrorWithCode(401, response.ErrCodeTokenRevoked)
+	}
+	attempts, err := us.redisProvider.Incr(ctx, fmt.Sprintf("mfa_attempts:%s", claims.ID), global.Config.Auth.MFATokenExpiry)
+	if err != nil {
+		global.Logger.Error("Failed to count MFA attempts: " + err.Error())
+		return nil, nil, response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+	if attempts > maxMFALoginAttempt {
+		return nil, nil, response.NewServiceErrorWithCode(429, response.ErrCodeTooManyRequests)
+	}
+
+	user := us.userRepo.GetUserByID(claims.UserID)
+	if user == nil {
+		return nil, nil, response.NewServiceErrorWithCode(401, response.ErrInvalidToken)
+	}
+	if user.IsActive != nil && !*user.IsActive {
+		return nil, nil, response.NewServiceErrorWithCode(403, response.ErrCodeAccountLock)
+	}
+
+	return claims, user, nil
+}
+
+func (us *userService) LoginMFA(mfaToken string, code string, recoveryCode string, client dto.ClientInfo) *response.ServiceResult {
+	claims, user, errResult := us.pendingMFAUser(mfaToken)
+	if errResult != nil {
+		return errResult
+	}
+
+	mfa := us.mfaRepo.GetUserMFA(user.ID)
+	if mfa == nil {
+		return response.NewServiceErrorWithCode(400, response.ErrCodeMFANotEnabled)
+	}
+
+	var recoveryCodes []string
+	if mfa.EnabledAt != nil {
+		ok, err := us.verifyMFA(mfa, code, recoveryCode)
+		if err != nil {
+			return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+		}
+		if !ok {
+			return response.NewServiceErrorWithCode(401, response.ErrCodeMFACodeInvalid)
+		}
+	} else {
+		// Enrollment required by the role policy, the first valid code completes it
+		if !us.mfaEnrollmentRequired(user) {
+			return response.NewServiceErrorWithCode(400, response.ErrCodeMFANotEnabled)
+		}
+		var errResult *response.ServiceResult
+		recoveryCodes, errResult = us.enableMFA(mfa, code)
+		if errResult != nil {
+			return errResult
+		}
+	}
+
+	// The mfa token is single use
+	_ = us.redisProvider.DenyToken(context.Background(), claims.ID, time.Until(claims.ExpiresAt.Time))
+
+	authResponse, err := us.generateAuthTokens(user, client)
+	if err != nil {
+		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+	authResponse.RecoveryCodes = recoveryCodes
+
+	return response.NewServiceResult(authResponse)
+}
+
+func (us *userService) StartLoginMFAEnrollment(mfaToken string) *response.ServiceResult {
+	_, user, errResult := us.pendingMFAUser(mfaToken)
+	if errResult != nil {
+		return errResult
+	}
+	if !us.mfaEnrollmentRequired(user) {
+		return response.NewServiceErrorWithCode(400, response.ErrCodeMFANotEnabled)
+	}
+
+	return us.EnrollMFA(user.ID)
+}
+
+// mfaEnrollmentRequired tells whether a login must enroll a second factor: the role requires one and the user has
+// none. A user with a factor must prove it, enrolling a new one during login would skip it.
+func (us *userService) mfaEnrollmentRequired(user *model.User) bool {
+	if mfa := us.mfaRepo.GetUserMFA(user.ID); mfa != nil && mfa.EnabledAt != nil {
+		return false
+	}
+	return !us.webAuthnRepo.HasCredentials(user.ID) && us.mfaRepo.IsMFARequiredForRole(user.SystemRole)
+}
+
+func (us *userService) EnrollMFA(userID uuid.UUID) *response.ServiceResult {
+	user := us.userRepo.GetUserByID(userID)
+	if user == nil {
+		return response.NewServiceErrorWithCode(404, response.ErrCodeUserNotFound)
+	}
+
+	if mfa := us.mfaRepo.GetUserMFA(userID); mfa != nil && mfa.EnabledAt != nil {
+		return response.NewServiceErrorWithCode(409, response.ErrCodeMFAAlreadyEnabled)
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+	encryptedSecret, err := secretbox.Seal(global.Config.Auth.MFASecretKey, secret)
+	if err != nil {
+		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+
+	// Enrollment stays pending until ConfirmMFA receives a valid code
+	if err := us.mfaRepo.SaveUserMFA(&model.UserMFA{UserID: userID, Secret: encryptedSecret}); err != nil {
+		global.Logger.Error("Failed to save MFA secret: " + err.Error())
+		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+
+	return response.NewServiceResult(&dto.MFAEnrollResponseDto{
+		Secret:          secret,
+		ProvisioningURI: totp.ProvisioningURI(global.Config.Auth.MFAIssuer, user.Email, secret),
+	})
+}
+
+func (us *userService) ConfirmMFA(userID uuid.UUID, code string) *response.ServiceResult {
+	mfa := us.mfaRepo.GetUserMFA(userID)
+	if mfa == nil {
+		return response.NewServiceErrorWithCode(400, response.ErrCodeMFANotEnabled)
+	}
+	if mfa.EnabledAt != nil {
+		return response.NewServiceErrorWithCode(409, response.ErrCodeMFAAlreadyEnabled)
+	}
+
+	recoveryCodes, errResult := us.enableMFA(mfa, code)
+	if errResult != nil {
+		return errResult
+	}
+
+	return response.NewServiceResult(&dto.MFARecoveryCodesResponseDto{RecoveryCodes: recoveryCodes})
+}
+
+func (us *userService) DisableMFA(userID uuid.UUID, code string) *response.ServiceResult {
+	user := us.userRepo.GetUserByID(userID)
+	if user == nil {
+		return response.NewServiceErrorWithCode(404, response.ErrCodeUserNotFound)
+	}
+	if us.mfaRepo.IsMFARequiredForRole(user.SystemRole) {
+		return response.NewServiceErrorWithCode(403, response.ErrCodeMFARequired)
+	}
+
+	mfa := us.mfaRepo.GetUserMFA(userID)
+	if mfa == nil || mfa.EnabledAt == nil {
+		return response.NewServiceErrorWithCode(400, response.ErrCodeMFANotEnabled)
+	}
+
+	// Either a TOTP code or a recovery code proves possession
+	ok, err := us.verifyMFA(mfa, code, code)
+	if err != nil {
+		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+	if !ok {
+		return response.NewServiceErrorWithCode(401, response.ErrCodeMFACodeInvalid)
+	}
+
+	if err := us.mfaRepo.DeleteUserMFA(userID); err != nil {
+		global.Logger.Error("Failed to disable MFA: " + err.Error())
+		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+
+	return response.NewServiceResult(nil)
+}
+
+func (us *userService) RegenerateRecoveryCodes(userID uuid.UUID, code string) *response.ServiceResult {
+	mfa := us.mfaRepo.GetUserMFA(userID)
+	if mfa == nil || mfa.EnabledAt == nil {
+		return response.NewServiceErrorWithCode(400, response.ErrCodeMFANotEnabled)
+	}
+
+	ok, err := us.verifyMFA(mfa, code, "")
+	if err != nil {
+		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+	if !ok {
+		return response.NewServiceErrorWithCode(401, response.ErrCodeMFACodeInvalid)
+	}
+
+	recoveryCodes, err := us.newRecoveryCodes(userID)
+	if err != nil {
+		global.Logger.Error("Failed to generate recovery codes: " + err.Error())
+		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+
+	return response.NewServiceResult(&dto.MFARecoveryCodesResponseDto{RecoveryCodes: recoveryCodes})
+}
+
+func (us *userService) GetMFAPolicies() *response.ServiceResult {
+	policies, err := us.mfaRepo.GetRoleMFAPolicies()
+	if err != nil {
+		global.Logger.Error("Failed to get MFA policies: " + err.Error())
+		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+
+	return response.NewServiceResult(policies)
+}
+
+func (us *userService) SetMFAPolicy(role string, required bool) *response.ServiceResult {
+	if us.roleRepo.GetRoleByName(role) == nil {
+		return response.NewServiceErrorWithCode(404, response.ErrCodeRoleNotFound)
+	}
+
+	policy := &model.RoleMFAPolicy{SystemRole: role, MFARequired: required}
+	if err := us.mfaRepo.SaveRoleMFAPolicy(policy); err != nil {
+		global.Logger.Error("Failed to save MFA policy: " + err.Error())
+		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+
+	return response.NewServiceResult(policy)
+}
+
+// enableMFA confirms a pending enrollment with its first code and returns fresh recovery codes
+func (us *userService) enableMFA(mfa *model.UserMFA, code string) ([]string, *response.ServiceResult) {
+	ok, err := us.verifyTOTP(mfa, code)
+	if err != nil {
+		return nil, response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+	if !ok {
+		return nil, response.NewServiceErrorWithCode(401, response.ErrCodeMFACodeInvalid)
+	}
+
+	now := time.Now()
+	mfa.EnabledAt = &now
+	if err := us.mfaRepo.SaveUserMFA(mfa); err != nil {
+		global.Logger.Error("Failed to enable MFA: " + err.Error())
+		return nil, response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+
+	recoveryCodes, err := us.newRecoveryCodes(mfa.UserID)
+	if err != nil {
+		global.Logger.Error("Failed to generate recovery codes: " + err.Error())
+		return nil, response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+	return recoveryCodes, nil
+}
+
+// verifyMFA accepts either a TOTP code or an unused recovery code
+func (us *userService) verifyMFA(mfa *model.UserMFA, code string, recoveryCode string) (bool, error) {
+	if code != "" {
+		if ok, err := us.verifyTOTP(mfa, code); err != nil || ok {
+			return ok, err
+		}
+	}
+	if recoveryCode != "" {
+		return us.mfaRepo.UseRecoveryCode(mfa.UserID, securetoken.Hash(normalizeRecoveryCode(recoveryCode)))
+	}
+	return false, nil
+}
+
+func (us *userService) verifyTOTP(mfa *model.UserMFA, code string) (bool, error) {
+	secret, err := secretbox.Open(global.Config.Auth.MFASecretKey, mfa.Secret)
+	if err != nil {
+		global.Logger.Error("Failed to decrypt MFA secret: " + err.Error())
+		return false, err
+	}
+
+	step, ok := totp.Validate(code, secret, time.Now())
+	if !ok {
+		return false, nil
+	}
+	// A code is accepted only once, even inside its validity window
+	return us.mfaRepo.UseTimeStep(mfa.UserID, int64(step))
+}
+
+func (us *userService) newRecoveryCodes(userID uuid.UUID) ([]string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+	encoding := base32.StdEncoding.WithPadding(base32.NoPadding)
+
+	for i := 0; i < recoveryCodeCount; i++ {
+		b := make([]byte, 5)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		raw := strings.ToLower(encoding.EncodeToString(b))
+		codes = append(codes, raw[:4]+"-"+raw[4:])
+		hashes = append(hashes, securetoken.Hash(raw))
+	}
+
+	if err := us.mfaRepo.ReplaceRecoveryCodes(userID, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+}
@@ -0,0 +1,77 @@
+package service
+
+import (
+	"app/internal/modules/user/dto"
+	"app/internal/modules/user/model"
+	"app/pkg/response"
+	"app/pkg/totp"
+	"testing"
+	"time"
+)
+
+// mfaToken starts the second factor step of a login of the user
+func mfaToken(t *testing.T, us *userService, user *model.User) string {
+	t.Helper()
+	result := us.startMFALogin(user)
+	if result == nil || result.Error != nil {
+		t.Fatalf("startMFALogin() = %+v, want an mfa token", result)
+	}
+	return result.Data.(*dto.AuthResponseDto).MFAToken
+}
+
+// startLoginEnrollment enrolls TOTP with the mfa token and returns a valid code of the new secret
+func startLoginEnrollment(t *testing.T, us *userService, token string) string {
+	t.Helper()
+	result := us.StartLoginMFAEnrollment(token)
+	if result.Error != nil {
+		t.Fatalf("StartLoginMFAEnrollment() error = %v", result.Error)
+	}
+	code, err := totp.GenerateCode(result.Data.(*dto.MFAEnrollResponseDto).Secret, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return code
+}
+
+func newMFATest(t *testing.T) (*userService, *fakeMFARepo, *model.User) {
+	t.Helper()
+	setupTestGlobals(t)
+	users := &fakeUserRepo{}
+	user := newTestUser(users)
+	user.SystemRole = "ADMIN"
+	us := newTestUserService(users)
+	mfaRepo := us.mfaRepo.(*fakeMFARepo)
+	mfaRepo.requiredRoles = []string{"ADMIN"}
+	return us, mfaRepo, user
+}
+
+func TestLoginMFAEnrollsWhenRoleRequiresIt(t *testing.T) {
+	us, mfaRepo, user := newMFATest(t)
+
+	token := mfaToken(t, us, user)
+	code := startLoginEnrollment(t, us, token)
+	result := us.LoginMFA(token, code, "", dto.ClientInfo{})
+	if result.Error != nil {
+		t.Fatalf("LoginMFA() error = %v", result.Error)
+	}
+	if auth := result.Data.(*dto.AuthResponseDto); auth.Token == "" || len(auth.RecoveryCodes) != recoveryCodeCount {
+		t.Errorf("LoginMFA() = %+v, want tokens and recovery codes", auth)
+	}
+	if mfa := mfaRepo.GetUserMFA(user.ID); mfa == nil || mfa.EnabledAt == nil {
+		t.Error("TOTP was not enabled")
+	}
+}
+
+func TestLoginMFARejectsEnrollmentNoLongerRequired(t *testing.T) {
+	us, mfaRepo, user := newMFATest(t)
+
+	token := mfaToken(t, us, user)
+	code := startLoginEnrollment(t, us, token)
+	mfaRepo.requiredRoles = nil
+
+	assertServiceError(t, "StartLoginMFAEnrollment()", us.StartLoginMFAEnrollment(token), 400, response.ErrCodeMFANotEnabled)
+	assertServiceError(t, "LoginMFA()", us.LoginMFA(token, code, "", dto.ClientInfo{}), 400, response.ErrCodeMFANotEnabled)
+	if mfa := mfaRepo.GetUserMFA(user.ID); mfa == nil || mfa.EnabledAt != nil {
+		t.Error("pending TOTP enrollment was enabled")
+	}
+}
@@ -0,0 +1,341 @@
+package service
+
+import (
+	"app/global"
+	"app/internal/modules/user/constants"
+	"app/internal/modules/user/dto"
+	"app/internal/modules/user/model"
+	"app/internal/third_party/oidc"
+	"app/internal/third_party/redis"
+	"app/pkg/response"
+	"app/pkg/securetoken"
+	"app/pkg/setting"
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/google/uuid"
+)
+
+// maxOIDCUsernameLength leaves room for the suffix added when the username is taken
+const maxOIDCUsernameLength = 80
+
+// oidcState is kept server side under the hash of the state parameter until the provider redirects back.
+// LinkUserID is set when a signed in user links an identity instead of logging in.
+type oidcState struct {
+	Provider     string    `json:"provider"`
+	Nonce        string    `json:"nonce"`
+	CodeVerifier string    `json:"code_verifier"`
+	LinkUserID   uuid.UUID `json:"link_user_id"`
+}
+
+func (us *userService) GetOIDCProviders() *response.ServiceResult {
+	return response.NewServiceResult(us.oidcProvider.Names())
+}
+
+func (us *userService) StartOIDCLogin(provider string) *response.ServiceResult {
+	return us.startOIDCFlow(provider, uuid.Nil)
+}
+
+func (us *userService) StartOIDCLink(provider string, userID uuid.UUID) *response.ServiceResult {
+	return us.startOIDCFlow(provider, userID)
+}
+
+// OIDCCallback logs in with the identity, linking or provisioning the local account when allowed
+func (us *userService) OIDCCallback(provider string, req dto.OIDCCallbackRequestDto, client dto.ClientInfo) *response.ServiceResult {
+	state, claims, errResult := us.completeOIDCFlow(provider, req)
+	if errResult != nil {
+		return errResult
+	}
+	if state.LinkUserID != uuid.Nil {
+		return response.NewServiceErrorWithCode(400, response.ErrCodeOIDCStateInvalid)
+	}
+
+	user, errResult := us.resolveOIDCUser(provider, claims)
+	if errResult != nil {
+		return errResult
+	}
+
+	if user.IsActive != nil && !*user.IsActive {
+		return response.NewServiceErrorWithCode(403, response.ErrCodeAccountLock)
+	}
+	if global.Config.Auth.RequireEmailVerification && user.EmailVerifiedAt == nil {
+		return response.NewServiceErrorWithCode(403, response.ErrCodeEmailNotVerified)
+	}
+	if user.MustChangePassword || global.PasswordPolicy.IsExpired(user.PasswordChangedAt, time.Now()) {
+		return us.startPasswordChange(user)
+	}
+	if mfaResult := us.startMFALogin(user); mfaResult != nil {
+		return mfaResult
+	}
+
+	authResponse, err := us.generateAuthTokens(user, client)
+	if err != nil {
+		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+	return response.NewServiceResult(authResponse)
+}
+
+// OIDCLinkCallback links the identity to the signed in user who started the flow
+func (us *userService) OIDCLinkCallback(provider string, req dto.OIDCCallbackRequestDto, userID uuid.UUID) *response.ServiceResult {
+	state, claims, errResult := us.completeOIDCFlow(provider, req)
+	if errResult != nil {
+		return errResult
+	}
+	if state.LinkUserID != userID {
+		return response.NewServiceErrorWithCode(400, response.ErrCodeOIDCStateInvalid)
+	}
+
+	if identity := us.identityRepo.GetIdentity(provider, claims.Subject); identity != nil {
+		if identity.UserID != userID {
+			return response.NewServiceErrorWithCode(409, response.ErrCodeIdentityLinked)
+		}
+		return response.NewServiceResult(identity)
+	}
+
+	identity, err := us.linkIdentity(userID, provider, claims)
+	if err != nil {
+		global.Logger.Error("Failed to link identity: " + err.Error())
+		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+	return response.NewServiceResult(identity)
+}
+
+func (us *userService) GetIdentities(userID uuid.UUID) *response.ServiceResult {
+	identities, err := us.identityRepo.GetIdentitiesByUserID(userID)
+	if err != nil {
+		global.Logger.Error("Failed to get identities: " + err.Error())
+		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+
+	return response.NewServiceResult(identities)
+}
+
+func (us *userService) UnlinkIdentity(userID uuid.UUID, id uuid.UUID) *response.ServiceResult {
+	deleted, err := us.identityRepo.DeleteIdentity(userID, id)
+	if err != nil {
+		global.Logger.Error("Failed to unlink identity: " + err.Error())
+		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+	if !deleted {
+		return response.NewServiceErrorWithCode(404, response.ErrCodeIdentityNotFound)
+	}
+
+	return response.NewServiceResult(nil)
+}
+
+// startOIDCFlow stores a single-use state with the nonce and PKCE verifier and returns the authorization URL
+func (us *userService) startOIDCFlow(provider string, linkUserID uuid.UUID) *response.ServiceResult {
+	if _, ok := us.oidcProvider.Setting(provider); !ok {
+		return response.NewServiceErrorWithCode(404, response.ErrCodeOIDCProviderNotFound)
+	}
+
+	state, err := securetoken.Generate(32)
+	if err != nil {
+		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+	nonce, err := securetoken.Generate(16)
+	if err != nil {
+		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+	flow := oidcState{
+		Provider:     provider,
+		Nonce:        nonce,
+		CodeVerifier: oidc.GenerateCodeVerifier(),
+		LinkUserID:   linkUserID,
+	}
+	value, err := json.Marshal(flow)
+	if err != nil {
+		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+
+	ctx := context.Background()
+	stateHash := securetoken.Hash(state)
+	err = us.redisProvider.SetOneTimeToken(ctx, constants.TokenPurposeOIDCState, stateHash, stateHash, string(value), global.Config.OIDC.StateExpiry)
+	if err != nil {
+		global.Logger.Error("Failed to store OIDC state: " + err.Error())
+		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+
+	authorizationURL, err := us.oidcProvider.AuthCodeURL(ctx, provider, state, flow.Nonce, flow.CodeVerifier)
+	if err != nil {
+		global.Logger.Error("Failed to build OIDC authorization URL: " + err.Error())
+		return response.NewServiceErrorWithCode(502, response.ErrCodeOIDCLoginFailed)
+	}
+	return response.NewServiceResult(&dto.OIDCAuthorizeResponseDto{AuthorizationURL: authorizationURL})
+}
+
+// completeOIDCFlow consumes the state and exchanges the code for the verified ID token claims
+func (us *userService) completeOIDCFlow(provider string, req dto.OIDCCallbackRequestDto) (*oidcState, *oidc.Claims, *response.ServiceResult) {
+	ctx := context.Background()
+
+	value, err := us.redisProvider.ConsumeOneTimeToken(ctx, constants.TokenPurposeOIDCState, securetoken.Hash(req.State))
+	if err != nil {
+		if errors.Is(err, redis.ErrOneTimeTokenNotFound) {
+			return nil, nil, response.NewServiceErrorWithCode(400, response.ErrCodeOIDCStateInvalid)
+		}
+		return nil, nil, response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+
+	var state oidcState
+	if err := json.Unmarshal([]byte(value), &state); err != nil || state.Provider != provider {
+		return nil, nil, response.NewServiceErrorWithCode(400, response.ErrCodeOIDCStateInvalid)
+	}
+
+	claims, err := us.oidcProvider.Exchange(ctx, provider, req.Code, state.CodeVerifier, state.Nonce)
+	if err != nil {
+		global.Logger.Warn("OIDC login failed: " + err.Error())
+		return nil, nil, response.NewServiceErrorWithCode(401, response.ErrCodeOIDCLoginFailed)
+	}
+	return &state, claims, nil
+}
+
+// resolveOIDCUser finds the user linked to the identity. A new identity is linked to the account with the same
+// verified email when the provider is trusted to, or gets a new account when the provider provisions them.
+func (us *userService) resolveOIDCUser(provider string, claims *oidc.Claims) (*model.User, *response.ServiceResult) {
+	if identity := us.identityRepo.GetIdentity(provider, claims.Subject); identity != nil {
+		user := us.userRepo.GetUserByID(identity.UserID)
+		if user == nil {
+			return nil, response.NewServiceErrorWithCode(401, response.ErrCodeOIDCLoginFailed)
+		}
+		if err := us.identityRepo.TouchIdentity(identity.ID); err != nil {
+			global.Logger.Warn("Failed to update identity last login: " + err.Error())
+		}
+		return user, nil
+	}
+
+	config, _ := us.oidcProvider.Setting(provider)
+	if claims.Email == "" || !claims.EmailVerified || !emailDomainAllowed(config, claims.Email) {
+		return nil, response.NewServiceErrorWithCode(403, response.ErrCodeOIDCAccountNotLinked)
+	}
+
+	user := us.userRepo.GetUserByEmail(claims.Email)
+	if user != nil {
+		if !config.LinkByEmail || user.IsServiceAccount {
+			return nil, response.NewServiceErrorWithCode(403, response.ErrCodeOIDCAccountNotLinked)
+		}
+	} else {
+		if !config.AutoProvision {
+			return nil, response.NewServiceErrorWithCode(403, response.ErrCodeOIDCAccountNotLinked)
+		}
+		var errResult *response.ServiceResult
+		if user, errResult = us.provisionOIDCUser(config, claims); errResult != nil {
+			return nil, errResult
+		}
+	}
+
+	if _, err := us.linkIdentity(user.ID, provider, claims); err != nil {
+		global.Logger.Error("Failed to link identity: " + err.Error())
+		return nil, response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+	return user, nil
+}
+
+// provisionOIDCUser creates the account just in time, with the provider's default role and a verified email
+func (us *userService) provisionOIDCUser(config setting.OIDCProviderSetting, claims *oidc.Claims) (*model.User, *response.ServiceResult) {
+	username, err := us.availableUsername(oidcUsername(claims))
+	if err != nil {
+		return nil, response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+	// The password is random and never shown, the user can set one through the password reset flow
+	password, err := securetoken.Generate(32)
+	if err != nil {
+		return nil, response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+
+	createResult := us.createUser(dto.UserRequestDto{
+		Email:      claims.Email,
+		Username:   username,
+		FullName:   claims.Name,
+		Password:   password,
+		SystemRole: config.DefaultRole,
+	})
+	if createResult.Error != nil {
+		return nil, createResult
+	}
+
+	userID := createResult.Data.(uuid.UUID)
+	now := time.Now()
+	if err := us.userRepo.SetEmailVerifiedAt(userID, &now); err != nil {
+		global.Logger.Error("Failed to mark email verified: " + err.Error())
+	}
+
+	user := us.userRepo.GetUserByID(userID)
+	if user == nil {
+		return nil, response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+	return user, nil
+}
+
+func (us *userService) linkIdentity(userID uuid.UUID, provider string, claims *oidc.Claims) (*model.UserIdentity, error) {
+	id, err := uuid.NewV7()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	identity := &model.UserIdentity{
+		ID:          id,
+		UserID:      userID,
+		Provider:    provider,
+		Subject:     claims.Subject,
+		Email:       claims.Email,
+		LastLoginAt: &now,
+	}
+	if err := us.identityRepo.CreateIdentity(identity); err != nil {
+		return nil, err
+	}
+	return identity, nil
+}
+
+// availableUsername returns base, or base with a random suffix when it is taken
+func (us *userService) availableUsername(base string) (string, error) {
+	username := base
+	for range 5 {
+		if us.userRepo.GetUserByUsername(username) == nil {
+			return username, nil
+		}
+		suffix, err := securetoken.Generate(4)
+		if err != nil {
+			return "", err
+		}
+		username = base + "-" + strings.ToLower(suffix)
+	}
+	return "", errors.New("no available username for " + base)
+}
+
+// oidcUsername derives a username from the preferred username or the local part of the email
+func oidcUsername(claims *oidc.Claims) string {
+	base := claims.PreferredUsername
+	if base == "" {
+		base, _, _ = strings.Cut(claims.Email, "@")
+	}
+
+	base = strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '.' || r == '_' || r == '-' {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, base)
+	if len(base) > maxOIDCUsernameLength {
+		base = base[:maxOIDCUsernameLength]
+	}
+	if base == "" {
+		base = "user"
+	}
+	return base
+}
+
+func emailDomainAllowed(config setting.OIDCProviderSetting, email string) bool {
+	if len(config.AllowedDomains) == 0 {
+		return true
+	}
+	_, domain, _ := strings.Cut(email, "@")
+	return slices.ContainsFunc(config.AllowedDomains, func(allowed string) bool {
+		return strings.EqualFold(allowed, domain)
+	})
+}
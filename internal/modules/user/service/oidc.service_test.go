@@ -0,0 +1,209 @@
+package service
+
+import (
+	"app/global"
+	"app/internal/modules/user/dto"
+	"app/internal/modules/user/model"
+	"app/internal/third_party/oidc"
+	"app/internal/third_party/oidc/oidctest"
+	"app/pkg/response"
+	"app/pkg/setting"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const testOIDCProvider = "test"
+
+type oidcTest struct {
+	us         *userService
+	issuer     *oidctest.Issuer
+	users      *fakeUserRepo
+	identities *fakeIdentityRepo
+}
+
+func newOIDCTest(t *testing.T, provider setting.OIDCProviderSetting) *oidcTest {
+	t.Helper()
+	setupTestGlobals(t)
+	issuer, err := oidctest.NewIssuer("app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(issuer.Close)
+
+	provider.Name = testOIDCProvider
+	provider.Issuer = issuer.URL
+	provider.ClientID = "app"
+	provider.Scopes = []string{"openid", "email"}
+	global.Config.OIDC = setting.OIDCSetting{
+		RedirectURL: "https://app.example.test/oidc/callback",
+		StateExpiry: 5 * time.Minute,
+		Providers:   []setting.OIDCProviderSetting{provider},
+	}
+
+	users := &fakeUserRepo{}
+	identities := &fakeIdentityRepo{}
+	us := newTestUserService(users)
+	us.identityRepo = identities
+	us.oidcProvider = oidc.NewOIDCProvider()
+	return &oidcTest{us: us, issuer: issuer, users: users, identities: identities}
+}
+
+// callback answers the authorization URL of a started flow as the identity of the claims
+func (o *oidcTest) callback(t *testing.T, started *response.ServiceResult, claims map[string]any) dto.OIDCCallbackRequestDto {
+	t.Helper()
+	if started.Error != nil {
+		t.Fatalf("start OIDC flow error = %v", started.Error)
+	}
+	code, state, err := o.issuer.Authorize(started.Data.(*dto.OIDCAuthorizeResponseDto).AuthorizationURL, claims)
+	if err != nil {
+		t.Fatalf("Authorize() error = %v", err)
+	}
+	return dto.OIDCCallbackRequestDto{Code: code, State: state}
+}
+
+func (o *oidcTest) linkIdentity(user *model.User, subject string) {
+	o.identities.identities = append(o.identities.identities, &model.UserIdentity{
+		ID: uuid.New(), UserID: user.ID, Provider: testOIDCProvider, Subject: subject, Email: user.Email,
+	})
+}
+
+func assertServiceError(t *testing.T, name string, result *response.ServiceResult, statusCode int, errorCode int) {
+	t.Helper()
+	if result.StatusCode != statusCode || result.ErrorCode != errorCode {
+		t.Errorf("%s = %d/%d, want %d/%d", name, result.StatusCode, result.ErrorCode, statusCode, errorCode)
+	}
+}
+
+func TestOIDCCallbackRejectsReplayedState(t *testing.T) {
+	o := newOIDCTest(t, setting.OIDCProviderSetting{})
+	user := newTestUser(o.users)
+	o.linkIdentity(user, "alice")
+
+	req := o.callback(t, o.us.StartOIDCLogin(testOIDCProvider), map[string]any{"sub": "alice"})
+	result := o.us.OIDCCallback(testOIDCProvider, req, dto.ClientInfo{})
+	if result.Error != nil {
+		t.Fatalf("OIDCCallback() error = %v", result.Error)
+	}
+	if result.Data.(*dto.AuthResponseDto).Token == "" {
+		t.Error("OIDCCallback() returned no access token")
+	}
+
+	assertServiceError(t, "replayed OIDCCallback()", o.us.OIDCCallback(testOIDCProvider, req, dto.ClientInfo{}), 400, response.ErrCodeOIDCStateInvalid)
+}
+
+func TestOIDCCallbackRejectsNonceMismatch(t *testing.T) {
+	o := newOIDCTest(t, setting.OIDCProviderSetting{})
+	user := newTestUser(o.users)
+	o.linkIdentity(user, "alice")
+
+	req := o.callback(t, o.us.StartOIDCLogin(testOIDCProvider), map[string]any{"sub": "alice", "nonce": "other"})
+	assertServiceError(t, "OIDCCallback()", o.us.OIDCCallback(testOIDCProvider, req, dto.ClientInfo{}), 401, response.ErrCodeOIDCLoginFailed)
+}
+
+func TestOIDCCallbackRejectsStateOfLinkFlow(t *testing.T) {
+	o := newOIDCTest(t, setting.OIDCProviderSetting{})
+	user := newTestUser(o.users)
+	o.linkIdentity(user, "alice")
+
+	req := o.callback(t, o.us.StartOIDCLink(testOIDCProvider, user.ID), map[string]any{"sub": "alice"})
+	assertServiceError(t, "OIDCCallback()", o.us.OIDCCallback(testOIDCProvider, req, dto.ClientInfo{}), 400, response.ErrCodeOIDCStateInvalid)
+}
+
+func TestOIDCLinkCallbackRejectsIdentityOfAnotherUser(t *testing.T) {
+	o := newOIDCTest(t, setting.OIDCProviderSetting{})
+	owner := newTestUser(o.users)
+	o.linkIdentity(owner, "alice")
+	other := newTestUser(o.users)
+
+	req := o.callback(t, o.us.StartOIDCLink(testOIDCProvider, other.ID), map[string]any{"sub": "alice"})
+	result := o.us.OIDCLinkCallback(testOIDCProvider, req, other.ID)
+	assertServiceError(t, "OIDCLinkCallback()", result, 409, response.ErrCodeIdentityLinked)
+	if len(o.identities.identities) != 1 || o.identities.identities[0].UserID != owner.ID {
+		t.Errorf("identities = %+v, want only the one of the owner", o.identities.identities)
+	}
+
+	// A link flow is bound to the user who started it
+	req = o.callback(t, o.us.StartOIDCLink(testOIDCProvider, other.ID), map[string]any{"sub": "bob"})
+	assertServiceError(t, "OIDCLinkCallback() of another user", o.us.OIDCLinkCallback(testOIDCProvider, req, owner.ID), 400, response.ErrCodeOIDCStateInvalid)
+}
+
+func TestOIDCCallbackWithExistingEmail(t *testing.T) {
+	tests := []struct {
+		name           string
+		provider       setting.OIDCProviderSetting
+		serviceAccount bool
+		emailVerified  bool
+		linked         bool
+	}{
+		{"provisioning does not take over the account", setting.OIDCProviderSetting{AutoProvision: true, DefaultRole: "USER"}, false, true, false},
+		{"link by email", setting.OIDCProviderSetting{LinkByEmail: true, AutoProvision: true}, false, true, true},
+		{"link by email needs a verified email", setting.OIDCProviderSetting{LinkByEmail: true, AutoProvision: true}, false, false, false},
+		{"service accounts are never linked", setting.OIDCProviderSetting{LinkByEmail: true, AutoProvision: true}, true, true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := newOIDCTest(t, tt.provider)
+			user := newTestUser(o.users)
+			user.IsServiceAccount = tt.serviceAccount
+
+			claims := map[string]any{"sub": "alice", "email": user.Email, "email_verified": tt.emailVerified}
+			req := o.callback(t, o.us.StartOIDCLogin(testOIDCProvider), claims)
+			result := o.us.OIDCCallback(testOIDCProvider, req, dto.ClientInfo{})
+
+			if len(o.users.users) != 1 {
+				t.Fatalf("users = %d, want no provisioned account", len(o.users.users))
+			}
+			if !tt.linked {
+				assertServiceError(t, "OIDCCallback()", result, 403, response.ErrCodeOIDCAccountNotLinked)
+				if len(o.identities.identities) != 0 {
+					t.Errorf("identities = %+v, want none", o.identities.identities)
+				}
+				return
+			}
+			if result.Error != nil {
+				t.Fatalf("OIDCCallback() error = %v", result.Error)
+			}
+			if identity := o.identities.GetIdentity(testOIDCProvider, "alice"); identity == nil || identity.UserID != user.ID {
+				t.Errorf("identity = %+v, want it linked to the existing account", identity)
+			}
+		})
+	}
+}
+
+func TestOIDCCallbackRequiresPasswordChange(t *testing.T) {
+	expired := time.Now().Add(-100 * 24 * time.Hour)
+	tests := []struct {
+		name     string
+		update   func(user *model.User)
+		identity bool
+	}{
+		{"linked identity, must change password", func(user *model.User) { user.MustChangePassword = true }, true},
+		{"linked identity, expired password", func(user *model.User) { user.PasswordChangedAt = &expired }, true},
+		{"link by email, must change password", func(user *model.User) { user.MustChangePassword = true }, false},
+		{"link by email, expired password", func(user *model.User) { user.PasswordChangedAt = &expired }, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := newOIDCTest(t, setting.OIDCProviderSetting{LinkByEmail: true})
+			user := newTestUser(o.users)
+			tt.update(user)
+			if tt.identity {
+				o.linkIdentity(user, "alice")
+			}
+
+			claims := map[string]any{"sub": "alice", "email": user.Email, "email_verified": true}
+			req := o.callback(t, o.us.StartOIDCLogin(testOIDCProvider), claims)
+			result := o.us.OIDCCallback(testOIDCProvider, req, dto.ClientInfo{})
+			if result.Error != nil {
+				t.Fatalf("OIDCCallback() error = %v", result.Error)
+			}
+			if auth := result.Data.(*dto.AuthResponseDto); !auth.PasswordChangeRequired || auth.Token != "" {
+				t.Errorf("OIDCCallback() = %+v, want only a password change token", auth)
+			}
+		})
+	}
+}
@@ -0,0 +1,366 @@
+package service
+
+import (
+	"app/global"
+	"app/internal/modules/user/constants"
+	"app/internal/modules/user/dto"
+	"app/internal/modules/user/model"
+	"app/internal/modules/user/repo"
+	"app/internal/third_party/mail"
+	"app/internal/third_party/redis"
+	"app/pkg/jwt"
+	"app/pkg/response"
+	"app/pkg/securetoken"
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// organizationSlugPattern lowercase words separated by single dashes, e.g. "acme-corp"
+var organizationSlugPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// IMembershipService resolves the tenant of a request, the Tenant middleware uses it for every organization request
+type IMembershipService interface {
+	GetMembership(organizationID uuid.UUID, userID uuid.UUID) (*model.OrganizationMember, error)
+}
+
+type membershipService struct {
+	organizationRepo repo.IOrganizationRepository
+}
+
+func NewMembershipService(organizationRepo repo.IOrganizationRepository) IMembershipService {
+	return &membershipService{organizationRepo: organizationRepo}
+}
+
+// GetMembership returns nil when the user is not a member or the organization is deactivated
+func (ms *membershipService) GetMembership(organizationID uuid.UUID, userID uuid.UUID) (*model.OrganizationMember, error) {
+	return ms.organizationRepo.GetActiveMember(organizationID, userID)
+}
+
+func (us *userService) CreateOrganization(req dto.CreateOrganizationRequestDto) *response.ServiceResult {
+	slug := strings.ToLower(strings.TrimSpace(req.Slug))
+	if !organizationSlugPattern.MatchString(slug) {
+		return response.NewServiceErrorWithCode(422, response.ErrCodeInvalidData)
+	}
+	if us.organizationRepo.GetOrganizationBySlug(slug) != nil {
+		return response.NewServiceErrorWithCode(409, response.ErrCodeOrgHasExists)
+	}
+
+	var owner *model.OrganizationMember
+	if req.OwnerID != nil {
+		if us.userRepo.GetUserByID(*req.OwnerID) == nil {
+			return response.NewServiceErrorWithCode(404, response.ErrCodeUserNotFound)
+		}
+		owner = &model.OrganizationMember{UserID: *req.OwnerID, Role: constants.OrganizationRoleOwner}
+	}
+
+	organization := &model.Organization{ID: uuid.New(), Name: strings.TrimSpace(req.Name), Slug: slug}
+	if err := us.organizationRepo.CreateOrganization(organization, owner); err != nil {
+		global.Logger.Error("Failed to create organization: " + err.Error())
+		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+
+	return response.NewServiceResult(organization)
+}
+
+func (us *userService) GetListOrganization(req dto.OrganizationListRequestDto) *response.ServiceResult {
+	if req.Limit == 0 {
+		req.Limit = 10
+	}
+
+	organizations, total, err := us.organizationRepo.GetListOrganization(req)
+	if err != nil {
+		global.Logger.Error("Failed to get organizations from repository: " + err.Error())
+		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+
+	return response.NewServiceResult(&dto.OrganizationListResponseDto{Total: total, Data: organizations})
+}
+
+func (us *userService) GetOrganization(id uuid.UUID) *response.ServiceResult {
+	organization := us.organizationRepo.GetOrganizationByID(id)
+	if organization == nil {
+		return response.NewServiceErrorWithCode(404, response.ErrCodeOrgNotFound)
+	}
+
+	return response.NewServiceResult(organization)
+}
+
+func (us *userService) UpdateOrganization(id uuid.UUID, req dto.UpdateOrganizationRequestDto) *response.ServiceResult {
+	if us.organizationRepo.GetOrganizationByID(id) == nil {
+		return response.NewServiceErrorWithCode(404, response.ErrCodeOrgNotFound)
+	}
+
+	organization, err := us.organizationRepo.UpdateOrganization(id, &model.Organization{
+		Name:     strings.TrimSpace(req.Name),
+		IsActive: req.IsActive,
+	})
+	if err != nil {
+		global.Logger.Error("Failed to update organization: " + err.Error())
+		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+
+	return response.NewServiceResult(organization)
+}
+
+func (us *userService) GetListOrganizationMember(organizationID uuid.UUID, req dto.OrganizationMemberListRequestDto) *response.ServiceResult {
+	if req.Limit == 0 {
+		req.Limit = 10
+	}
+
+	members, total, err := us.organizationRepo.GetListMember(organizationID, req)
+	if err != nil {
+		global.Logger.Error("Failed to get organization members from repository: " + err.Error())
+		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+
+	return response.NewServiceResult(&dto.OrganizationMemberListResponseDto{Total: total, Data: members})
+}
+
+// GetListOrganizationUser lists users through the tenant scoped repository, only members are visible
+func (us *userService) GetListOrganizationUser(organizationID uuid.UUID, req dto.UserListRequestDto) *response.ServiceResult {
+	return us.getListUser(us.userRepo.ForOrganization(organizationID), req)
+}
+
+// AddOrganizationMember adds an existing user, only for the platform API which acts as an owner.
+// Organization admins invite with InviteOrganizationMember.
+func (us *userService) AddOrganizationMember(organizationID uuid.UUID, req dto.AddOrganizationMemberRequestDto, actorRole string) *response.ServiceResult {
+	if !canManageOrganizationRole(actorRole, req.Role) {
+		return response.NewServiceErrorWithCode(403, response.ErrCodeOrgAccessDenied)
+	}
+	if us.organizationRepo.GetOrganizationByID(organizationID) == nil {
+		return response.NewServiceErrorWithCode(404, response.ErrCodeOrgNotFound)
+	}
+
+	user := us.userRepo.GetUserByEmail(req.Email)
+	if user == nil {
+		return response.NewServiceErrorWithCode(404, response.ErrCodeUserNotFound)
+	}
+	if us.organizationRepo.GetMember(organizationID, user.ID) != nil {
+		return response.NewServiceErrorWithCode(409, response.ErrCodeOrgMemberHasExists)
+	}
+
+	member := &model.OrganizationMember{OrganizationID: organizationID, UserID: user.ID, Role: req.Role}
+	if err := us.organizationRepo.AddMember(member); err != nil {
+		global.Logger.Error("Failed to add organization member: " + err.Error())
+		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+
+	return response.NewServiceResult(member)
+}
+
+// InviteOrganizationMember emails an invitation to join the organization, the user joins by accepting it.
+// actorRole is the organization role of the actor.
+func (us *userService) InviteOrganizationMember(organizationID uuid.UUID, req dto.AddOrganizationMemberRequestDto, actorRole string) *response.ServiceResult {
+	if !canManageOrganizationRole(actorRole, req.Role) {
+		return response.NewServiceErrorWithCode(403, response.ErrCodeOrgAccessDenied)
+	}
+	organization := us.organizationRepo.GetOrganizationByID(organizationID)
+	if organization == nil {
+		return response.NewServiceErrorWithCode(404, response.ErrCodeOrgNotFound)
+	}
+
+	user, errResult := us.emailRecipient("organization_invitation", req.Email, 0, func(user *model.User) bool {
+		return !user.IsServiceAccount && (user.IsActive == nil || *user.IsActive) &&
+			us.organizationRepo.GetMember(organizationID, user.ID) == nil
+	})
+	if errResult != nil {
+		return errResult
+	}
+	if user == nil {
+		return response.NewServiceResult(nil)
+	}
+
+	token, err := securetoken.Generate(32)
+	if err != nil {
+		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+
+	// A new invitation to the same organization replaces the previous one, the email binds it like a magic link
+	expiry := global.Config.Auth.InvitationExpiry
+	subject := organizationID.String() + ":" + user.ID.String()
+	value := strings.Join([]string{organizationID.String(), user.ID.String(), req.Role, user.Email}, "|")
+	err = us.redisProvider.SetOneTimeToken(context.Background(), constants.TokenPurposeOrgInvitation, subject, securetoken.Hash(token), value, expiry)
+	if err != nil {
+		global.Logger.Error("Failed to store organization invitation: " + err.Error())
+		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+
+	link := fmt.Sprintf("%s/organization-invitation?token=%s", global.Config.System.AppBaseURL, url.QueryEscape(token))
+	us.sendMailAsync(mail.Message{
+		To:      user.Email,
+		Subject: "You have been invited to " + organization.Name,
+		Body: fmt.Sprintf("Hello %s,\n\nYou have been invited to join %s as %s. Sign in and open the link below to accept, it expires in %s.\n\n%s\n\nIf you do not want to join, you can ignore this email.\n",
+			user.Username, organization.Name, req.Role, expiry, link),
+	})
+
+	return response.NewServiceResult(nil)
+}
+
+// AcceptOrganizationInvitation adds the signed in user to the organization of an invitation issued to them
+func (us *userService) AcceptOrganizationInvitation(userID uuid.UUID, token string) *response.ServiceResult {
+	value, err := us.redisProvider.ConsumeOneTimeToken(context.Background(), constants.TokenPurposeOrgInvitation, securetoken.Hash(token))
+	if err != nil {
+		if errors.Is(err, redis.ErrOneTimeTokenNotFound) {
+			return response.NewServiceErrorWithCode(400, response.ErrCodeInvitationInvalid)
+		}
+		global.Logger.Error("Failed to consume organization invitation: " + err.Error())
+		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+
+	parts := strings.SplitN(value, "|", 4)
+	if len(parts) != 4 || parts[1] != userID.String() {
+		return response.NewServiceErrorWithCode(400, response.ErrCodeInvitationInvalid)
+	}
+	organizationID, err := uuid.Parse(parts[0])
+	if err != nil {
+		return response.NewServiceErrorWithCode(400, response.ErrCodeInvitationInvalid)
+	}
+	user := us.userRepo.GetUserByID(userID)
+	if user == nil || user.Email != parts[3] {
+		return response.NewServiceErrorWithCode(400, response.ErrCodeInvitationInvalid)
+	}
+	organization := us.organizationRepo.GetOrganizationByID(organizationID)
+	if organization == nil || (organization.IsActive != nil && !*organization.IsActive) {
+		return response.NewServiceErrorWithCode(404, response.ErrCodeOrgNotFound)
+	}
+	if us.organizationRepo.GetMember(organizationID, userID) != nil {
+		return response.NewServiceErrorWithCode(409, response.ErrCodeOrgMemberHasExists)
+	}
+
+	member := &model.OrganizationMember{OrganizationID: organizationID, UserID: userID, Role: parts[2]}
+	if err := us.organizationRepo.AddMember(member); err != nil {
+		global.Logger.Error("Failed to add organization member: " + err.Error())
+		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+
+	return response.NewServiceResult(member)
+}
+
+func (us *userService) UpdateOrganizationMember(organizationID uuid.UUID, userID uuid.UUID, req dto.UpdateOrganizationMemberRequestDto, actorRole string) *response.ServiceResult {
+	member := us.organizationRepo.GetMember(organizationID, userID)
+	if member == nil {
+		return response.NewServiceErrorWithCode(404, response.ErrCodeOrgMemberNotFound)
+	}
+	if !canManageOrganizationRole(actorRole, member.Role) || !canManageOrganizationRole(actorRole, req.Role) {
+		return response.NewServiceErrorWithCode(403, response.ErrCodeOrgAccessDenied)
+	}
+	if member.Role == constants.OrganizationRoleOwner && req.Role != constants.OrganizationRoleOwner {
+		if errResult := us.checkNotLastOwner(organizationID); errResult != nil {
+			return errResult
+		}
+	}
+
+	if _, err := us.organizationRepo.UpdateMemberRole(organizationID, userID, req.Role); err != nil {
+		global.Logger.Error("Failed to update organization member: " + err.Error())
+		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+	member.Role = req.Role
+
+	return response.NewServiceResult(member)
+}
+
+func (us *userService) RemoveOrganizationMember(organizationID uuid.UUID, userID uuid.UUID, actorRole string) *response.ServiceResult {
+	member := us.organizationRepo.GetMember(organizationID, userID)
+	if member == nil {
+		return response.NewServiceErrorWithCode(404, response.ErrCodeOrgMemberNotFound)
+	}
+	if !canManageOrganizationRole(actorRole, member.Role) {
+		return response.NewServiceErrorWithCode(403, response.ErrCodeOrgAccessDenied)
+	}
+	if member.Role == constants.OrganizationRoleOwner {
+		if errResult := us.checkNotLastOwner(organizationID); errResult != nil {
+			return errResult
+		}
+	}
+
+	if _, err := us.organizationRepo.RemoveMember(organizationID, userID); err != nil {
+		global.Logger.Error("Failed to remove organization member: " + err.Error())
+		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+
+	return response.NewServiceResult(nil)
+}
+
+func (us *userService) GetUserOrganizations(userID uuid.UUID) *response.ServiceResult {
+	memberships, err := us.organizationRepo.GetMembershipsByUserID(userID)
+	if err != nil {
+		global.Logger.Error("Failed to get organizations of user: " + err.Error())
+		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+
+	return response.NewServiceResult(memberships)
+}
+
+// SwitchOrganization selects the organization of the session and returns an access token carrying it.
+// The presented access token is revoked, refreshed tokens keep the selection.
+func (us *userService) SwitchOrganization(userID uuid.UUID, sessionID string, tokenID string, expiresAt time.Time, organizationID *uuid.UUID) *response.ServiceResult {
+	id, err := uuid.Parse(sessionID)
+	if err != nil {
+		return response.NewServiceErrorWithCode(404, response.ErrCodeSessionNotFound)
+	}
+	user := us.userRepo.GetUserByID(userID)
+	if user == nil {
+		return response.NewServiceErrorWithCode(404, response.ErrCodeUserNotFound)
+	}
+
+	if organizationID != nil {
+		member, err := us.organizationRepo.GetActiveMember(*organizationID, userID)
+		if err != nil {
+			global.Logger.Error("Failed to get organization membership: " + err.Error())
+			return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+		}
+		if member == nil {
+			return response.NewServiceErrorWithCode(403, response.ErrCodeOrgAccessDenied)
+		}
+	}
+
+	if err := us.sessionRepo.SetSessionOrganization(id, organizationID); err != nil {
+		global.Logger.Error("Failed to set session organization: " + err.Error())
+		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+
+	ctx := context.Background()
+	version, err := us.redisProvider.GetTokenVersion(ctx, userID.String())
+	if err != nil {
+		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+	token, err := jwt.GenerateToken(user.ID, user.Email, user.SystemRole, sessionID, organizationID, version, global.JWTKeys, global.Config.JWT.TokenExpiry)
+	if err != nil {
+		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+	_ = us.redisProvider.DenyToken(ctx, tokenID, time.Until(expiresAt))
+
+	return response.NewServiceResult(&dto.AuthResponseDto{Token: token})
+}
+
+// checkNotLastOwner keeps at least one owner in an organization
+func (us *userService) checkNotLastOwner(organizationID uuid.UUID) *response.ServiceResult {
+	owners, err := us.organizationRepo.CountMembersWithRole(organizationID, constants.OrganizationRoleOwner)
+	if err != nil {
+		global.Logger.Error("Failed to count organization owners: " + err.Error())
+		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+	if owners <= 1 {
+		return response.NewServiceErrorWithCode(409, response.ErrCodeOrgLastOwner)
+	}
+	return nil
+}
+
+// canManageOrganizationRole owners manage every member, admins only plain members
+func canManageOrganizationRole(actorRole string, role string) bool {
+	switch actorRole {
+	case constants.OrganizationRoleOwner:
+		return true
+	case constants.OrganizationRoleAdmin:
+		return role == constants.OrganizationRoleMember
+	default:
+		return false
+	}
+}
@@ -0,0 +1,302 @@
+package service
+
+import (
+	"app/global"
+	"app/internal/modules/user/constants"
+	"app/internal/modules/user/dto"
+	"app/internal/modules/user/model"
+	"app/internal/third_party/mail"
+	"app/internal/third_party/redis"
+	"app/pkg/jwt"
+	"app/pkg/response"
+	"app/pkg/securetoken"
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func (us *userService) ForgotPassword(email string) *response.ServiceResult {
+	user, errResult := us.emailRecipient("password_reset_request", email, global.Config.Auth.PasswordResetRequestPeriod, func(user *model.User) bool {
+		return user.IsActive == nil || *user.IsActive
+	})
+	if errResult != nil {
+		return errResult
+	}
+	if user == nil {
+		return response.NewServiceResult(nil)
+	}
+
+	token, err := securetoken.Generate(32)
+	if err != nil {
+		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+
+	expiry := global.Config.Auth.PasswordResetExpiry
+	err = us.redisProvider.SetOneTimeToken(context.Background(), constants.TokenPurposePasswordReset, user.ID.String(), securetoken.Hash(token), user.ID.String(), expiry)
+	if err != nil {
+		global.Logger.Error("Failed to store password reset token: " + err.Error())
+		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+
+	link := fmt.Sprintf("%s/reset-password?token=%s", global.Config.System.AppBaseURL, url.QueryEscape(token))
+	us.sendMailAsync(mail.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hello %s,\n\nUse the link below to choose a new password. It expires in %s and can only be used once.\n\n%s\n\nIf you did not ask for a password reset, you can ignore this email.\n",
+			user.Username, expiry, link),
+	})
+
+	return response.NewServiceResult(nil)
+}
+
+func (us *userService) ResetPassword(token string, newPassword string) *response.ServiceResult {
+	ctx := context.Background()
+	tokenHash := securetoken.Hash(token)
+
+	// The token is used up only once the new password passes the policy, a rejected password keeps the link valid
+	value, err := us.redisProvider.GetOneTimeToken(ctx, constants.TokenPurposePasswordReset, tokenHash)
+	if err != nil {
+		if errors.Is(err, redis.ErrOneTimeTokenNotFound) {
+			return response.NewServiceErrorWithCode(400, response.ErrCodeResetTokenInvalid)
+		}
+		global.Logger.Error("Failed to get password reset token: " + err.Error())
+		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+
+	userID, err := uuid.Parse(value)
+	if err != nil {
+		return response.NewServiceErrorWithCode(400, response.ErrCodeResetTokenInvalid)
+	}
+	user := us.userRepo.GetUserByID(userID)
+	if user == nil {
+		return response.NewServiceErrorWithCode(400, response.ErrCodeResetTokenInvalid)
+	}
+	if errResult := us.checkPasswordPolicy(newPassword, user); errResult != nil {
+		return errResult
+	}
+
+	if _, err := us.redisProvider.ConsumeOneTimeToken(ctx, constants.TokenPurposePasswordReset, tokenHash); err != nil {
+		if errors.Is(err, redis.ErrOneTimeTokenNotFound) {
+			return response.NewServiceErrorWithCode(400, response.ErrCodeResetTokenInvalid)
+		}
+		global.Logger.Error("Failed to consume password reset token: " + err.Error())
+		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+
+	hashedPassword, err := global.PasswordHasher.Hash(newPassword)
+	if err != nil {
+		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+	if err := us.userRepo.ChangePassword(userID, hashedPassword, global.PasswordPolicy.HistorySize()); err != nil {
+		global.Logger.Error("Failed to reset password: " + err.Error())
+		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+
+	// Whoever had access to the account before the reset is logged out
+	if err := us.revokeAllTokens(userID); err != nil {
+		global.Logger.Error("Failed to revoke user tokens: " + err.Error())
+	}
+
+	return response.NewServiceResult(nil)
+}
+
+// rehashPassword replaces a hash made with an outdated algorithm or work factor once the password is known,
+// a failure only leaves the old hash in place
+func (us *userService) rehashPassword(user *model.User, password string) {
+	if !global.PasswordHasher.NeedsRehash(user.Password) {
+		return
+	}
+
+	hashedPassword, err := global.PasswordHasher.Hash(password)
+	if err != nil {
+		global.Logger.Error("Failed to rehash password: " + err.Error())
+		return
+	}
+	if _, err := us.userRepo.UpdateUser(user.ID, &model.User{Password: hashedPassword}); err != nil {
+		global.Logger.Error("Failed to save rehashed password: " + err.Error())
+		return
+	}
+	user.Password = hashedPassword
+}
+
+// checkPasswordPolicy returns a 422 listing every rule the new password of user violates.
+// A user without an ID is being created and has no previous passwords.
+func (us *userService) checkPasswordPolicy(password string, user *model.User) *response.ServiceResult {
+	violations := global.PasswordPolicy.Validate(password, user.Username, user.Email)
+
+	if user.ID != uuid.Nil {
+		reused, err := us.isPasswordReused(user, password)
+		if err != nil {
+			global.Logger.Error("Failed to check password history: " + err.Error())
+			return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+		}
+		if reused {
+			violations = append(violations, global.PasswordPolicy.HistoryViolation())
+		}
+	}
+
+	if len(violations) > 0 {
+		return response.NewServiceErrorWithCode(422, response.ErrCodePasswordPolicy).WithData(violations)
+	}
+	return nil
+}
+
+// isPasswordReused compares the password with the current one and the last HistorySize ones
+func (us *userService) isPasswordReused(user *model.User, password string) (bool, error) {
+	var hashes []string
+	if global.PasswordPolicy.HistorySize() > 0 {
+		var err error
+		if hashes, err = us.userRepo.GetPasswordHistory(user.ID, global.PasswordPolicy.HistorySize()); err != nil {
+			return false, err
+		}
+	}
+	// Accounts created before the history existed only have their current hash
+	if user.Password != "" && !slices.Contains(hashes, user.Password) {
+		hashes = append(hashes, user.Password)
+	}
+
+	for _, hash := range hashes {
+		match, err := global.PasswordHasher.Verify(password, hash)
+		if err != nil {
+			global.Logger.Warn("Skipping unreadable password hash: " + err.Error())
+			continue
+		}
+		if match {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// startPasswordChange returns the restricted token login issues instead of tokens while the password must be changed
+func (us *userService) startPasswordChange(user *model.User) *response.ServiceResult {
+	token, err := us.signScopedToken(user.ID, jwt.TokenTypePasswordChange, global.Config.Auth.PasswordChangeTokenExpiry)
+	if err != nil {
+		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+
+	return response.NewServiceResult(&dto.AuthResponseDto{
+		PasswordChangeRequired: true,
+		PasswordChangeToken:    token,
+	})
+}
+
+// ChangePassword changes the password of a signed in user. Every token issued before is revoked, the other sessions
+// are signed out and the current session continues with new tokens.
+func (us *userService) ChangePassword(userID uuid.UUID, req dto.ChangePasswordRequestDto, sessionID string, client dto.ClientInfo) *response.ServiceResult {
+	user, errResult := us.changePassword(userID, req, client)
+	if errResult != nil {
+		return errResult
+	}
+
+	ctx := context.Background()
+	if _, err := us.redisProvider.BumpTokenVersion(ctx, userID.String()); err != nil {
+		global.Logger.Error("Failed to revoke user tokens: " + err.Error())
+		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+	if result := us.RevokeOtherSessions(userID, sessionID); result.Error != nil {
+		return result
+	}
+
+	// Tokens issued before sessions existed carry no session, their holder signs in again like one of an ended session
+	if sessionID == "" {
+		return response.NewServiceResult(nil)
+	}
+	alive, err := us.redisProvider.RefreshFamilyExists(ctx, sessionID)
+	if err != nil {
+		global.Logger.Error("Failed to check refresh token family: " + err.Error())
+		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+	if !alive {
+		return response.NewServiceResult(nil)
+	}
+
+	// The refresh token of the session is replaced, the old one would now be seen as reused
+	tokenID := uuid.NewString()
+	if err := us.redisProvider.CreateRefreshFamily(ctx, sessionID, userID.String(), tokenID, global.Config.JWT.RefreshExpiry); err != nil {
+		global.Logger.Error("Failed to renew refresh token family: " + err.Error())
+		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+	authResponse, err := us.signAuthTokens(user, sessionID, tokenID)
+	if err != nil {
+		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+	return response.NewServiceResult(authResponse)
+}
+
+// CompletePasswordChange changes the password with the restricted token of login, then continues the login
+func (us *userService) CompletePasswordChange(userID uuid.UUID, req dto.ChangePasswordRequestDto, tokenID string, tokenExpiresAt time.Time, client dto.ClientInfo) *response.ServiceResult {
+	// The restricted token is single use, it is given back when the password could not be changed
+	ctx := context.Background()
+	consumed, err := us.redisProvider.ConsumeToken(ctx, tokenID, time.Until(tokenExpiresAt))
+	if err != nil {
+		global.Logger.Error("Failed to consume password change token: " + err.Error())
+		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+	if !consumed {
+		return response.NewServiceErrorWithCode(401, response.ErrCodeTokenRevoked)
+	}
+
+	user, errResult := us.changePassword(userID, req, client)
+	if errResult != nil {
+		if err := us.redisProvider.ReleaseToken(ctx, tokenID); err != nil {
+			global.Logger.Error("Failed to release password change token: " + err.Error())
+		}
+		return errResult
+	}
+
+	if mfaResult := us.startMFALogin(user); mfaResult != nil {
+		return mfaResult
+	}
+	authResponse, err := us.generateAuthTokens(user, client)
+	if err != nil {
+		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+	return response.NewServiceResult(authResponse)
+}
+
+// changePassword checks the current password, with the same throttling as login, and sets the new one
+func (us *userService) changePassword(userID uuid.UUID, req dto.ChangePasswordRequestDto, client dto.ClientInfo) (*model.User, *response.ServiceResult) {
+	user := us.userRepo.GetUserByID(userID)
+	if user == nil {
+		return nil, response.NewServiceErrorWithCode(404, response.ErrCodeUserNotFound)
+	}
+	if user.IsActive != nil && !*user.IsActive {
+		return nil, response.NewServiceErrorWithCode(403, response.ErrCodeAccountLock)
+	}
+
+	if throttled := us.checkLoginThrottle(user.Username, client.IPAddress); throttled != nil {
+		return nil, throttled
+	}
+	match, err := global.PasswordHasher.Verify(req.CurrentPassword, user.Password)
+	if err != nil {
+		global.Logger.Error("Failed to verify password hash: " + err.Error())
+	}
+	if !match {
+		us.recordLoginFailure(user.Username, client.IPAddress)
+		return nil, response.NewServiceErrorWithCode(401, response.ErrCodeInvalidLogin)
+	}
+	us.resetLoginFailures(user.Username)
+
+	if errResult := us.checkPasswordPolicy(req.NewPassword, user); errResult != nil {
+		return nil, errResult
+	}
+
+	hashedPassword, err := global.PasswordHasher.Hash(req.NewPassword)
+	if err != nil {
+		return nil, response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+	if err := us.userRepo.ChangePassword(userID, hashedPassword, global.PasswordPolicy.HistorySize()); err != nil {
+		global.Logger.Error("Failed to change password: " + err.Error())
+		return nil, response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+
+	user.Password = hashedPassword
+	user.MustChangePassword = false
+	return user, nil
+}
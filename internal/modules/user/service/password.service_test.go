@@ -0,0 +1,94 @@
+package service
+
+import (
+	"app/global"
+	"app/internal/modules/user/constants"
+	"app/internal/modules/user/dto"
+	"app/pkg/jwt"
+	"app/pkg/response"
+	"app/pkg/securetoken"
+	"context"
+	"testing"
+	"time"
+)
+
+func TestForgotPasswordThrottlesEmail(t *testing.T) {
+	setupTestGlobals(t)
+	global.Config.Auth.PasswordResetRequestPeriod = time.Minute
+	us := newTestUserService(&fakeUserRepo{})
+
+	// The throttle applies whether or not the email is registered
+	if result := us.ForgotPassword("nobody@example.test"); result.Error != nil {
+		t.Fatalf("ForgotPassword() error = %v", result.Error)
+	}
+	assertServiceError(t, "second ForgotPassword()", us.ForgotPassword("Nobody@example.test"), 429, response.ErrCodeTooManyRequests)
+	if result := us.ForgotPassword("other@example.test"); result.Error != nil {
+		t.Errorf("ForgotPassword() of another email error = %v", result.Error)
+	}
+}
+
+func TestResetPasswordKeepsTokenOnPolicyViolation(t *testing.T) {
+	setupTestGlobals(t)
+	users := &fakeUserRepo{}
+	user := newTestUser(users)
+	us := newTestUserService(users)
+
+	token := "reset-token"
+	err := us.redisProvider.SetOneTimeToken(context.Background(), constants.TokenPurposePasswordReset, user.ID.String(), securetoken.Hash(token), user.ID.String(), time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertServiceError(t, "ResetPassword() with a short password", us.ResetPassword(token, "short"), 422, response.ErrCodePasswordPolicy)
+	if result := us.ResetPassword(token, "a long enough password"); result.Error != nil {
+		t.Fatalf("ResetPassword() after a rejected password error = %v", result.Error)
+	}
+	if ok, _ := global.PasswordHasher.Verify("a long enough password", user.Password); !ok {
+		t.Error("password was not changed")
+	}
+	assertServiceError(t, "replayed ResetPassword()", us.ResetPassword(token, "another long password"), 400, response.ErrCodeResetTokenInvalid)
+}
+
+func TestChangePasswordRevokesTokensAndRenewsSession(t *testing.T) {
+	setupTestGlobals(t)
+	users := &fakeUserRepo{}
+	user := newTestUser(users)
+	user.Password, _ = global.PasswordHasher.Hash("the old password")
+	us := newTestUserService(users)
+
+	current, err := us.generateAuthTokens(user, dto.ClientInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := us.generateAuthTokens(user, dto.ClientInfo{}); err != nil {
+		t.Fatal(err)
+	}
+	sessions := us.sessionRepo.(*fakeSessionRepo).sessions
+	currentSession, otherSession := sessions[0].ID.String(), sessions[1].ID.String()
+
+	req := dto.ChangePasswordRequestDto{CurrentPassword: "the old password", NewPassword: "a new long password"}
+	result := us.ChangePassword(user.ID, req, currentSession, dto.ClientInfo{})
+	if result.Error != nil {
+		t.Fatalf("ChangePassword() error = %v", result.Error)
+	}
+	renewed := result.Data.(*dto.AuthResponseDto)
+
+	// Access tokens of before the change carry the old token version
+	oldClaims, _ := jwt.ValidateToken(current.Token, global.JWTKeys)
+	newClaims, err := jwt.ValidateToken(renewed.Token, global.JWTKeys)
+	if err != nil {
+		t.Fatalf("ValidateToken() of the new token error = %v", err)
+	}
+	version, _ := us.redisProvider.GetTokenVersion(context.Background(), user.ID.String())
+	if oldClaims.TokenVersion == version || newClaims.TokenVersion != version || newClaims.FamilyID != currentSession {
+		t.Errorf("token versions old %d new %d, current %d", oldClaims.TokenVersion, newClaims.TokenVersion, version)
+	}
+
+	if alive, _ := us.redisProvider.RefreshFamilyExists(context.Background(), otherSession); alive {
+		t.Error("the other session was not signed out")
+	}
+	if result := us.RefreshToken(renewed.RefreshToken, dto.ClientInfo{}); result.Error != nil {
+		t.Errorf("RefreshToken() with the new refresh token error = %v", result.Error)
+	}
+	assertServiceError(t, "RefreshToken() with the old refresh token", us.RefreshToken(current.RefreshToken, dto.ClientInfo{}), 401, response.ErrCodeTokenRevoked)
+}
@@ -0,0 +1,111 @@
+package service
+
+import (
+	"app/global"
+	"app/internal/modules/user/repo"
+	"app/internal/third_party/redis"
+	"context"
+	"slices"
+	"sort"
+)
+
+// IPermissionService resolves the effective permissions of a role, its own plus those inherited from its parents
+type IPermissionService interface {
+	GetRolePermissions(role string) ([]string, error)
+	HasPermission(role string, permissions ...string) (bool, error)
+	InvalidateCache(roles ...string) error
+}
+
+type permissionService struct {
+	roleRepo      repo.IRoleRepository
+	redisProvider *redis.RedisProvider
+}
+
+func NewPermissionService(roleRepo repo.IRoleRepository, redisProvider *redis.RedisProvider) IPermissionService {
+	return &permissionService{
+		roleRepo:      roleRepo,
+		redisProvider: redisProvider,
+	}
+}
+
+func (ps *permissionService) GetRolePermissions(role string) ([]string, error) {
+	ctx := context.Background()
+
+	permissions, found, err := ps.redisProvider.GetRolePermissions(ctx, role)
+	if err != nil {
+		global.Logger.Warn("Failed to read cached role permissions: " + err.Error())
+	}
+	if found {
+		return permissions, nil
+	}
+
+	permissions, err = ps.resolveRolePermissions(role)
+	if err != nil {
+		return nil, err
+	}
+	if err := ps.redisProvider.SetRolePermissions(ctx, role, permissions, global.Config.Auth.PermissionCacheTTL); err != nil {
+		global.Logger.Warn("Failed to cache role permissions: " + err.Error())
+	}
+	return permissions, nil
+}
+
+// HasPermission reports whether the role has every one of the given permissions
+func (ps *permissionService) HasPermission(role string, permissions ...string) (bool, error) {
+	granted, err := ps.GetRolePermissions(role)
+	if err != nil {
+		return false, err
+	}
+	for _, permission := range permissions {
+		if !slices.Contains(granted, permission) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// InvalidateCache drops the cached permissions of every role, plus the given ones that may no longer exist.
+// A change to one role affects every role inheriting from it so the whole cache goes.
+func (ps *permissionService) InvalidateCache(roles ...string) error {
+	existing, err := ps.roleRepo.GetRoles()
+	if err != nil {
+		return err
+	}
+	for _, role := range existing {
+		roles = append(roles, role.Name)
+	}
+	return ps.redisProvider.DeleteRolePermissions(context.Background(), roles...)
+}
+
+// resolveRolePermissions walks up the role hierarchy, an unknown role has no permissions
+func (ps *permissionService) resolveRolePermissions(role string) ([]string, error) {
+	set := make(map[string]struct{})
+	visited := make(map[string]bool)
+
+	for name := role; name != "" && !visited[name]; {
+		visited[name] = true
+
+		current := ps.roleRepo.GetRoleByName(name)
+		if current == nil {
+			break
+		}
+		names, err := ps.roleRepo.GetRolePermissionNames(name)
+		if err != nil {
+			return nil, err
+		}
+		for _, permission := range names {
+			set[permission] = struct{}{}
+		}
+
+		name = ""
+		if current.ParentRole != nil {
+			name = *current.ParentRole
+		}
+	}
+
+	permissions := make([]string, 0, len(set))
+	for permission := range set {
+		permissions = append(permissions, permission)
+	}
+	sort.Strings(permissions)
+	return permissions, nil
+}
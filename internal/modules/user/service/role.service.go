@@ -0,0 +1,229 @@
+package service
+
+import (
+	"app/global"
+	"app/internal/modules/user/constants"
+	"app/internal/modules/user/dto"
+	"app/internal/modules/user/model"
+	"app/pkg/response"
+	"slices"
+)
+
+func (us *userService) GetRoles() *response.ServiceResult {
+	roles, err := us.roleRepo.GetRoles()
+	if err != nil {
+		global.Logger.Error("Failed to get roles: " + err.Error())
+		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+
+	result := make([]*dto.RoleResponseDto, 0, len(roles))
+	for _, role := range roles {
+		roleResponse, err := us.toRoleResponse(role)
+		if err != nil {
+			global.Logger.Error("Failed to get role permissions: " + err.Error())
+			return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+		}
+		result = append(result, roleResponse)
+	}
+
+	return response.NewServiceResult(result)
+}
+
+func (us *userService) GetPermissions() *response.ServiceResult {
+	permissions, err := us.roleRepo.GetPermissions()
+	if err != nil {
+		global.Logger.Error("Failed to get permissions: " + err.Error())
+		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+
+	return response.NewServiceResult(permissions)
+}
+
+func (us *userService) CreateRole(req dto.CreateRoleRequestDto, actorRole string) *response.ServiceResult {
+	if us.roleRepo.GetRoleByName(req.Name) != nil {
+		return response.NewServiceErrorWithCode(409, response.ErrCodeRoleHasExists)
+	}
+
+	role := &model.Role{Name: req.Name, Description: req.Description}
+	if errResult := us.applyRoleChanges(role, req.ParentRole, &req.Permissions, actorRole); errResult != nil {
+		return errResult
+	}
+	if err := us.roleRepo.CreateRole(role, req.Permissions); err != nil {
+		global.Logger.Error("Failed to create role: " + err.Error())
+		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+
+	return us.roleChanged(role)
+}
+
+func (us *userService) UpdateRole(name string, req dto.UpdateRoleRequestDto, actorRole string) *response.ServiceResult {
+	role := us.roleRepo.GetRoleByName(name)
+	if role == nil {
+		return response.NewServiceErrorWithCode(404, response.ErrCodeRoleNotFound)
+	}
+
+	role.Description = req.Description
+	if errResult := us.applyRoleChanges(role, req.ParentRole, &req.Permissions, actorRole); errResult != nil {
+		return errResult
+	}
+	if err := us.roleRepo.UpdateRole(role, req.Permissions); err != nil {
+		global.Logger.Error("Failed to update role: " + err.Error())
+		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+
+	return us.roleChanged(role)
+}
+
+// DeleteRole only removes custom roles that are neither assigned to a user nor a parent of another role
+func (us *userService) DeleteRole(name string) *response.ServiceResult {
+	role := us.roleRepo.GetRoleByName(name)
+	if role == nil {
+		return response.NewServiceErrorWithCode(404, response.ErrCodeRoleNotFound)
+	}
+	if role.IsSystem {
+		return response.NewServiceErrorWithCode(409, response.ErrCodeRoleInUse)
+	}
+
+	users, err := us.roleRepo.CountUsersWithRole(name)
+	if err != nil {
+		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+	children, err := us.roleRepo.CountChildRoles(name)
+	if err != nil {
+		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+	if users > 0 || children > 0 {
+		return response.NewServiceErrorWithCode(409, response.ErrCodeRoleInUse)
+	}
+
+	if err := us.roleRepo.DeleteRole(name); err != nil {
+		global.Logger.Error("Failed to delete role: " + err.Error())
+		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+	if err := us.permissionService.InvalidateCache(name); err != nil {
+		global.Logger.Error("Failed to invalidate permission cache: " + err.Error())
+	}
+
+	return response.NewServiceResult(nil)
+}
+
+// applyRoleChanges validates the parent role and the permissions and sets them on the role, the actor must hold
+// whatever the role gains
+func (us *userService) applyRoleChanges(role *model.Role, parentRole string, permissions *[]string, actorRole string) *response.ServiceResult {
+	previousParent := role.ParentRole
+	role.ParentRole = nil
+	if parentRole != "" {
+		// Walk up from the new parent, meeting the role itself means the hierarchy would loop
+		visited := map[string]bool{}
+		for name := parentRole; name != ""; {
+			if name == role.Name || visited[name] {
+				return response.NewServiceErrorWithCode(400, response.ErrCodeRoleHierarchyInvalid)
+			}
+			visited[name] = true
+
+			parent := us.roleRepo.GetRoleByName(name)
+			if parent == nil {
+				return response.NewServiceErrorWithCode(400, response.ErrCodeRoleHierarchyInvalid)
+			}
+			name = ""
+			if parent.ParentRole != nil {
+				name = *parent.ParentRole
+			}
+		}
+		role.ParentRole = &parentRole
+	}
+
+	slices.Sort(*permissions)
+	*permissions = slices.Compact(*permissions)
+	count, err := us.roleRepo.CountPermissions(*permissions)
+	if err != nil {
+		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+	if count != int64(len(*permissions)) {
+		return response.NewServiceErrorWithCode(400, response.ErrCodePermissionNotFound)
+	}
+
+	if parentRole != "" && (previousParent == nil || *previousParent != parentRole) {
+		if errResult := us.checkAssignableRole(actorRole, parentRole); errResult != nil {
+			return errResult
+		}
+	}
+	return us.checkGrantablePermissions(actorRole, role.Name, *permissions)
+}
+
+// checkGrantablePermissions makes sure the actor's role holds every permission the role does not have yet,
+// role:manage alone does not hand out more privileges than the actor holds. SUPER_ADMIN grants any permission.
+func (us *userService) checkGrantablePermissions(actorRole string, roleName string, permissions []string) *response.ServiceResult {
+	if actorRole == constants.SuperAdmin {
+		return nil
+	}
+
+	current, err := us.roleRepo.GetRolePermissionNames(roleName)
+	if err != nil {
+		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+	var granted []string
+	for _, permission := range permissions {
+		if !slices.Contains(current, permission) {
+			granted = append(granted, permission)
+		}
+	}
+	if len(granted) == 0 {
+		return nil
+	}
+
+	allowed, err := us.permissionService.HasPermission(actorRole, granted...)
+	if err != nil {
+		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+	if !allowed {
+		return response.NewServiceErrorWithCode(403, response.ErrCodePermissionNotAllowed)
+	}
+	return nil
+}
+
+// roleChanged clears the permission cache and returns the saved role
+func (us *userService) roleChanged(role *model.Role) *response.ServiceResult {
+	if err := us.permissionService.InvalidateCache(); err != nil {
+		global.Logger.Error("Failed to invalidate permission cache: " + err.Error())
+	}
+
+	roleResponse, err := us.toRoleResponse(role)
+	if err != nil {
+		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+	return response.NewServiceResult(roleResponse)
+}
+
+func (us *userService) toRoleResponse(role *model.Role) (*dto.RoleResponseDto, error) {
+	permissions, err := us.roleRepo.GetRolePermissionNames(role.Name)
+	if err != nil {
+		return nil, err
+	}
+	effective, err := us.permissionService.GetRolePermissions(role.Name)
+	if err != nil {
+		return nil, err
+	}
+	return &dto.RoleResponseDto{Role: role, Permissions: permissions, EffectivePermissions: effective}, nil
+}
+
+// checkAssignableRole makes sure the role exists and grants nothing the actor's own role does not,
+// so nobody can hand out more privileges than they hold
+func (us *userService) checkAssignableRole(actorRole string, role string) *response.ServiceResult {
+	if us.roleRepo.GetRoleByName(role) == nil {
+		return response.NewServiceErrorWithCode(400, response.ErrCodeRoleNotFound)
+	}
+
+	required, err := us.permissionService.GetRolePermissions(role)
+	if err != nil {
+		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+	allowed, err := us.permissionService.HasPermission(actorRole, required...)
+	if err != nil {
+		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+	if !allowed {
+		return response.NewServiceErrorWithCode(403, response.ErrCodeRoleNotAllowed)
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package service
+
+import (
+	"app/internal/modules/user/dto"
+	"app/internal/modules/user/model"
+	"app/pkg/response"
+	"slices"
+	"testing"
+)
+
+func (f *fakeRoleRepo) CountPermissions(names []string) (int64, error) {
+	return int64(len(names)), nil
+}
+
+func (f *fakeRoleRepo) GetRolePermissionNames(roleName string) ([]string, error) {
+	return f.permissions[roleName], nil
+}
+
+func (f *fakeRoleRepo) CreateRole(role *model.Role, permissions []string) error {
+	f.roles = append(f.roles, role)
+	f.permissions[role.Name] = permissions
+	return nil
+}
+
+func (f *fakeRoleRepo) UpdateRole(role *model.Role, permissions []string) error {
+	f.permissions[role.Name] = permissions
+	return nil
+}
+
+func (f *fakePermissionService) InvalidateCache(roles ...string) error {
+	return nil
+}
+
+func TestRoleGrantsOnlyPermissionsOfActor(t *testing.T) {
+	setupTestGlobals(t)
+	us := newTestUserService(&fakeUserRepo{})
+	roles := &fakeRoleRepo{
+		roles:       []*model.Role{{Name: "SUPER_ADMIN"}, {Name: "ADMIN"}},
+		permissions: map[string][]string{"ADMIN": {"role:manage", "user:list"}},
+	}
+	us.roleRepo = roles
+	us.permissionService = &fakePermissionService{permissions: map[string][]string{
+		"SUPER_ADMIN": {"role:manage", "user:delete", "user:list"},
+		"ADMIN":       {"role:manage", "user:list"},
+	}}
+
+	assertServiceError(t, "CreateRole() with a permission the actor lacks",
+		us.CreateRole(dto.CreateRoleRequestDto{Name: "CLEANER", Permissions: []string{"user:delete"}}, "ADMIN"),
+		403, response.ErrCodePermissionNotAllowed)
+	assertServiceError(t, "CreateRole() under a parent granting more than the actor",
+		us.CreateRole(dto.CreateRoleRequestDto{Name: "CLEANER", ParentRole: "SUPER_ADMIN"}, "ADMIN"),
+		403, response.ErrCodeRoleNotAllowed)
+	if result := us.CreateRole(dto.CreateRoleRequestDto{Name: "VIEWER", Permissions: []string{"user:list"}}, "ADMIN"); result.Error != nil {
+		t.Fatalf("CreateRole() with a permission of the actor error = %v", result.Error)
+	}
+	if result := us.CreateRole(dto.CreateRoleRequestDto{Name: "CLEANER", Permissions: []string{"user:delete"}}, "SUPER_ADMIN"); result.Error != nil {
+		t.Fatalf("CreateRole() by SUPER_ADMIN error = %v", result.Error)
+	}
+
+	// A permission the role already has is kept, only the new ones must be held by the actor
+	update := dto.UpdateRoleRequestDto{Permissions: []string{"user:delete", "user:list"}}
+	if result := us.UpdateRole("CLEANER", update, "ADMIN"); result.Error != nil {
+		t.Fatalf("UpdateRole() keeping a permission the actor lacks error = %v", result.Error)
+	}
+	update = dto.UpdateRoleRequestDto{Permissions: []string{"user:delete", "user:list"}}
+	assertServiceError(t, "UpdateRole() with a permission the actor lacks", us.UpdateRole("VIEWER", update, "ADMIN"), 403, response.ErrCodePermissionNotAllowed)
+	if got := roles.permissions["VIEWER"]; !slices.Equal(got, []string{"user:list"}) {
+		t.Errorf("VIEWER permissions = %v, want them unchanged", got)
+	}
+}
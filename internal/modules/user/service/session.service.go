@@ -0,0 +1,171 @@
+package service
+
+import (
+	"app/global"
+	"app/internal/modules/user/dto"
+	"app/internal/modules/user/model"
+	"app/internal/modules/user/repo"
+	"app/internal/third_party/redis"
+	"app/pkg/response"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// sessionTouchInterval limits how often the last seen timestamp of a session is written
+const sessionTouchInterval = time.Minute
+
+// maxUserAgentLength is the size of the user_agent column
+const maxUserAgentLength = 255
+
+// ISessionService is used by AuthMiddleware to check and track the session of an access token
+type ISessionService interface {
+	IsSessionActive(sessionID string) (bool, error)
+	TouchSession(sessionID string, ipAddress string)
+}
+
+type sessionService struct {
+	sessionRepo   repo.ISessionRepository
+	redisProvider *redis.RedisProvider
+}
+
+func NewSessionService(sessionRepo repo.ISessionRepository, redisProvider *redis.RedisProvider) ISessionService {
+	return &sessionService{
+		sessionRepo:   sessionRepo,
+		redisProvider: redisProvider,
+	}
+}
+
+// IsSessionActive a session lives as long as its refresh token family, revoking the session deletes the family
+func (s *sessionService) IsSessionActive(sessionID string) (bool, error) {
+	return s.redisProvider.RefreshFamilyExists(context.Background(), sessionID)
+}
+
+// TouchSession updates the last seen timestamp, at most once per interval
+func (s *sessionService) TouchSession(sessionID string, ipAddress string) {
+	id, err := uuid.Parse(sessionID)
+	if err != nil {
+		return
+	}
+
+	key := fmt.Sprintf("session_seen:%s", sessionID)
+	first, err := s.redisProvider.SetNX(context.Background(), key, 1, sessionTouchInterval)
+	if err != nil || !first {
+		return
+	}
+	if err := s.sessionRepo.TouchSession(id, ipAddress, time.Now()); err != nil {
+		global.Logger.Warn("Failed to update session last seen: " + err.Error())
+	}
+}
+
+func (us *userService) GetSessions(userID uuid.UUID, currentSessionID string) *response.ServiceResult {
+	sessions, err := us.sessionRepo.GetActiveSessionsByUserID(userID)
+	if err != nil {
+		global.Logger.Error("Failed to get sessions: " + err.Error())
+		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+
+	result := make([]*dto.SessionResponseDto, 0, len(sessions))
+	for _, session := range sessions {
+		result = append(result, &dto.SessionResponseDto{UserSession: session, Current: session.ID.String() == currentSessionID})
+	}
+	return response.NewServiceResult(result)
+}
+
+func (us *userService) RevokeSession(userID uuid.UUID, sessionID uuid.UUID) *response.ServiceResult {
+	session := us.sessionRepo.GetSessionByID(sessionID)
+	if session == nil || session.UserID != userID || session.RevokedAt != nil {
+		return response.NewServiceErrorWithCode(404, response.ErrCodeSessionNotFound)
+	}
+
+	if err := us.endSession(sessionID.String()); err != nil {
+		global.Logger.Error("Failed to revoke session: " + err.Error())
+		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+	return response.NewServiceResult(nil)
+}
+
+// RevokeOtherSessions signs the user out everywhere but on the current session
+func (us *userService) RevokeOtherSessions(userID uuid.UUID, currentSessionID string) *response.ServiceResult {
+	currentID, _ := uuid.Parse(currentSessionID)
+
+	ids, err := us.sessionRepo.RevokeUserSessions(userID, currentID)
+	if err != nil {
+		global.Logger.Error("Failed to revoke sessions: " + err.Error())
+		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+	for _, id := range ids {
+		if err := us.redisProvider.RevokeRefreshFamily(context.Background(), id.String()); err != nil {
+			global.Logger.Error("Failed to revoke refresh token family: " + err.Error())
+			return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+		}
+	}
+
+	return response.NewServiceResult(nil)
+}
+
+// GetUserSessions lists the active sessions of any user the actor's role covers
+func (us *userService) GetUserSessions(targetID uuid.UUID, actorRole string) *response.ServiceResult {
+	target := us.userRepo.GetUserByID(targetID)
+	if target == nil {
+		return response.NewServiceErrorWithCode(404, response.ErrCodeUserNotFound)
+	}
+	if us.checkAssignableRole(actorRole, target.SystemRole) != nil {
+		return response.NewServiceErrorWithCode(403, response.ErrCodeUserPermissionDenied)
+	}
+
+	return us.GetSessions(targetID, "")
+}
+
+// startSession records a new session for the login and returns its id, also the refresh token family id
+func (us *userService) startSession(user *model.User, client dto.ClientInfo) (string, error) {
+	id, err := uuid.NewV7()
+	if err != nil {
+		return "", err
+	}
+
+	userAgent := client.UserAgent
+	if len(userAgent) > maxUserAgentLength {
+		userAgent = userAgent[:maxUserAgentLength]
+	}
+	now := time.Now()
+	session := &model.UserSession{
+		ID:         id,
+		UserID:     user.ID,
+		UserAgent:  userAgent,
+		IPAddress:  client.IPAddress,
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(global.Config.JWT.RefreshExpiry),
+	}
+	if err := us.sessionRepo.CreateSession(session); err != nil {
+		return "", err
+	}
+	return id.String(), nil
+}
+
+// sessionOrganization returns the organization selected in the session, carried by its access tokens
+func (us *userService) sessionOrganization(sessionID string) *uuid.UUID {
+	id, err := uuid.Parse(sessionID)
+	if err != nil {
+		return nil
+	}
+	session := us.sessionRepo.GetSessionByID(id)
+	if session == nil {
+		return nil
+	}
+	return session.OrganizationID
+}
+
+// endSession revokes the session and its refresh token family, access tokens of the session die with it
+func (us *userService) endSession(sessionID string) error {
+	if err := us.redisProvider.RevokeRefreshFamily(context.Background(), sessionID); err != nil {
+		return err
+	}
+	id, err := uuid.Parse(sessionID)
+	if err != nil {
+		return nil
+	}
+	return us.sessionRepo.RevokeSession(id)
+}
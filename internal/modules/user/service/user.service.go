@@ -6,43 +6,180 @@ import (
 	"app/internal/modules/user/dto"
 	"app/internal/modules/user/model"
 	"app/internal/modules/user/repo"
+	"app/internal/third_party/mail"
+	"app/internal/third_party/oidc"
 	"app/internal/third_party/redis"
-	"app/pkg/jwt"
+	"app/internal/third_party/s3"
+	"app/internal/third_party/webauthn"
+	"app/pkg/pagination"
 	"app/pkg/response"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"mime/multipart"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
-	"golang.org/x/crypto/bcrypt"
 )
 
 type IUserService interface {
-	GetUserByID(id uuid.UUID) *response.ServiceResult
-	GetListUser(req dto.UserListRequestDto, userRole string) *response.ServiceResult
-	CreateUser(userDto dto.UserRequestDto) *response.ServiceResult
-	UpdateUser(id uuid.UUID, updateDto dto.UserUpdateRequestDto, userRole string, userID uuid.UUID) *response.ServiceResult
-	Login(username string, password string) *response.ServiceResult
-	Register(registerDto dto.RegisterRequestDto) *response.ServiceResult
+	GetUserByID(ctx context.Context, id uuid.UUID) *response.ServiceResult
+	GetListUser(ctx context.Context, req dto.UserListRequestDto) *response.ServiceResult
+	CreateUser(userDto dto.UserRequestDto, actorRole string) *response.ServiceResult
+	UpdateUser(ctx context.Context, id uuid.UUID, updateDto dto.UserUpdateRequestDto, userRole string, userID uuid.UUID) *response.ServiceResult
+	Login(username string, password string, client dto.ClientInfo) *response.ServiceResult
+	Register(registerDto dto.RegisterRequestDto, client dto.ClientInfo) *response.ServiceResult
+	RefreshToken(refreshToken string, client dto.ClientInfo) *response.ServiceResult
+	Logout(userID uuid.UUID, sessionID string, tokenID string, expiresAt time.Time, refreshToken string) *response.ServiceResult
+	LogoutAll(userID uuid.UUID) *response.ServiceResult
+	ForgotPassword(email string) *response.ServiceResult
+	ResetPassword(token string, newPassword string) *response.ServiceResult
+	VerifyEmail(token string) *response.ServiceResult
+	ResendVerification(email string) *response.ServiceResult
+	StartMagicLinkLogin(email string) *response.ServiceResult
+	MagicLinkCallback(token string, client dto.ClientInfo) *response.ServiceResult
+	LoginMFA(mfaToken string, code string, recoveryCode string, client dto.ClientInfo) *response.ServiceResult
+	StartLoginMFAEnrollment(mfaToken string) *response.ServiceResult
+	EnrollMFA(userID uuid.UUID) *response.ServiceResult
+	ConfirmMFA(userID uuid.UUID, code string) *response.ServiceResult
+	DisableMFA(userID uuid.UUID, code string) *response.ServiceResult
+	RegenerateRecoveryCodes(userID uuid.UUID, code string) *response.ServiceResult
+	GetMFAPolicies() *response.ServiceResult
+	SetMFAPolicy(role string, required bool) *response.ServiceResult
+	UnlockUser(id uuid.UUID) *response.ServiceResult
+	CreateInvitation(req dto.CreateInvitationRequestDto, inviterID uuid.UUID, inviterRole string) *response.ServiceResult
+	GetListInvitation(req dto.InvitationListRequestDto) *response.ServiceResult
+	RevokeInvitation(id uuid.UUID) *response.ServiceResult
+	GetRoles() *response.ServiceResult
+	GetPermissions() *response.ServiceResult
+	CreateRole(req dto.CreateRoleRequestDto, actorRole string) *response.ServiceResult
+	UpdateRole(name string, req dto.UpdateRoleRequestDto, actorRole string) *response.ServiceResult
+	DeleteRole(name string) *response.ServiceResult
+	CreateAPIKey(ownerID uuid.UUID, req dto.CreateAPIKeyRequestDto) *response.ServiceResult
+	GetAPIKeys(ownerID uuid.UUID) *response.ServiceResult
+	RevokeAPIKey(ownerID uuid.UUID, id uuid.UUID) *response.ServiceResult
+	CreateServiceAccount(req dto.CreateServiceAccountRequestDto, actorRole string) *response.ServiceResult
+	CreateServiceAccountAPIKey(accountID uuid.UUID, req dto.CreateAPIKeyRequestDto, actorRole string) *response.ServiceResult
+	GetServiceAccountAPIKeys(accountID uuid.UUID, actorRole string) *response.ServiceResult
+	RevokeServiceAccountAPIKey(accountID uuid.UUID, id uuid.UUID, actorRole string) *response.ServiceResult
+	GetSessions(userID uuid.UUID, currentSessionID string) *response.ServiceResult
+	RevokeSession(userID uuid.UUID, sessionID uuid.UUID) *response.ServiceResult
+	RevokeOtherSessions(userID uuid.UUID, currentSessionID string) *response.ServiceResult
+	GetUserSessions(targetID uuid.UUID, actorRole string) *response.ServiceResult
+	ChangePassword(userID uuid.UUID, req dto.ChangePasswordRequestDto, sessionID string, client dto.ClientInfo) *response.ServiceResult
+	CompletePasswordChange(userID uuid.UUID, req dto.ChangePasswordRequestDto, tokenID string, tokenExpiresAt time.Time, client dto.ClientInfo) *response.ServiceResult
+	StartImpersonation(targetID uuid.UUID, actorID uuid.UUID, actorRole string, client dto.ClientInfo) *response.ServiceResult
+	StopImpersonation(userID uuid.UUID, actorID uuid.UUID, tokenID string, expiresAt time.Time, client dto.ClientInfo) *response.ServiceResult
+	GetListAuditLog(req dto.AuditLogListRequestDto) *response.ServiceResult
+	DeleteUser(id uuid.UUID, actorID uuid.UUID, actorRole string, client dto.ClientInfo) *response.ServiceResult
+	RestoreUser(id uuid.UUID, actorID uuid.UUID, actorRole string, client dto.ClientInfo) *response.ServiceResult
+	GetListDeletedUser(req dto.UserListRequestDto) *response.ServiceResult
+	StartUserImport(file *multipart.FileHeader, dryRun bool, actorID uuid.UUID, actorRole string) *response.ServiceResult
+	GetUserImportJob(id uuid.UUID) *response.ServiceResult
+	GetUserImportReport(id uuid.UUID) *response.ServiceResult
+	ExportUsers(ctx context.Context, req dto.UserExportRequestDto, actorID uuid.UUID, client dto.ClientInfo) *response.ServiceResult
+	StoreUserExport(ctx context.Context, req dto.UserExportRequestDto, actorID uuid.UUID, client dto.ClientInfo) *response.ServiceResult
+	UploadAvatar(ctx context.Context, id uuid.UUID, file *multipart.FileHeader) *response.ServiceResult
+	DeleteAvatar(id uuid.UUID) *response.ServiceResult
+	GetOIDCProviders() *response.ServiceResult
+	StartOIDCLogin(provider string) *response.ServiceResult
+	OIDCCallback(provider string, req dto.OIDCCallbackRequestDto, client dto.ClientInfo) *response.ServiceResult
+	StartOIDCLink(provider string, userID uuid.UUID) *response.ServiceResult
+	OIDCLinkCallback(provider string, req dto.OIDCCallbackRequestDto, userID uuid.UUID) *response.ServiceResult
+	GetIdentities(userID uuid.UUID) *response.ServiceResult
+	UnlinkIdentity(userID uuid.UUID, id uuid.UUID) *response.ServiceResult
+	StartPasskeyRegistration(userID uuid.UUID) *response.ServiceResult
+	FinishPasskeyRegistration(userID uuid.UUID, req dto.PasskeyRegistrationRequestDto) *response.ServiceResult
+	GetPasskeys(userID uuid.UUID) *response.ServiceResult
+	DeletePasskey(userID uuid.UUID, id uuid.UUID) *response.ServiceResult
+	StartPasskeyLogin() *response.ServiceResult
+	PasskeyLogin(req dto.PasskeyLoginRequestDto, client dto.ClientInfo) *response.ServiceResult
+	StartPasskeyMFA(mfaToken string) *response.ServiceResult
+	LoginPasskeyMFA(req dto.PasskeyMFARequestDto, client dto.ClientInfo) *response.ServiceResult
+	CreateOrganization(req dto.CreateOrganizationRequestDto) *response.ServiceResult
+	GetListOrganization(req dto.OrganizationListRequestDto) *response.ServiceResult
+	GetOrganization(id uuid.UUID) *response.ServiceResult
+	UpdateOrganization(id uuid.UUID, req dto.UpdateOrganizationRequestDto) *response.ServiceResult
+	GetListOrganizationMember(organizationID uuid.UUID, req dto.OrganizationMemberListRequestDto) *response.ServiceResult
+	GetListOrganizationUser(organizationID uuid.UUID, req dto.UserListRequestDto) *response.ServiceResult
+	AddOrganizationMember(organizationID uuid.UUID, req dto.AddOrganizationMemberRequestDto, actorRole string) *response.ServiceResult
+	InviteOrganizationMember(organizationID uuid.UUID, req dto.AddOrganizationMemberRequestDto, actorRole string) *response.ServiceResult
+	AcceptOrganizationInvitation(userID uuid.UUID, token string) *response.ServiceResult
+	UpdateOrganizationMember(organizationID uuid.UUID, userID uuid.UUID, req dto.UpdateOrganizationMemberRequestDto, actorRole string) *response.ServiceResult
+	RemoveOrganizationMember(organizationID uuid.UUID, userID uuid.UUID, actorRole string) *response.ServiceResult
+	GetUserOrganizations(userID uuid.UUID) *response.ServiceResult
+	SwitchOrganization(userID uuid.UUID, sessionID string, tokenID string, expiresAt time.Time, organizationID *uuid.UUID) *response.ServiceResult
 	ReceiveMessages(msg []byte) error
 }
 
 type userService struct {
-	userRepo      repo.IUserRepository
-	redisProvider *redis.RedisProvider
+	userRepo             repo.IUserRepository
+	mfaRepo              repo.IMFARepository
+	invitationRepo       repo.IInvitationRepository
+	roleRepo             repo.IRoleRepository
+	apiKeyRepo           repo.IAPIKeyRepository
+	sessionRepo          repo.ISessionRepository
+	identityRepo         repo.IIdentityRepository
+	auditLogRepo         repo.IAuditLogRepository
+	webAuthnRepo         repo.IWebAuthnRepository
+	organizationRepo     repo.IOrganizationRepository
+	permissionService    IPermissionService
+	authorizationService IAuthorizationService
+	auditService         IAuditService
+	redisProvider        *redis.RedisProvider
+	oidcProvider         *oidc.OIDCProvider
+	webAuthnProvider     *webauthn.WebAuthnProvider
+	s3Provider           *s3.S3Provider
+	mailer               mail.Mailer
 }
 
-func NewUserService(userRepo repo.IUserRepository, redisProvider *redis.RedisProvider) IUserService {
+func NewUserService(
+	userRepo repo.IUserRepository,
+	mfaRepo repo.IMFARepository,
+	invitationRepo repo.IInvitationRepository,
+	roleRepo repo.IRoleRepository,
+	apiKeyRepo repo.IAPIKeyRepository,
+	sessionRepo repo.ISessionRepository,
+	identityRepo repo.IIdentityRepository,
+	auditLogRepo repo.IAuditLogRepository,
+	webAuthnRepo repo.IWebAuthnRepository,
+	organizationRepo repo.IOrganizationRepository,
+	permissionService IPermissionService,
+	authorizationService IAuthorizationService,
+	auditService IAuditService,
+	redisProvider *redis.RedisProvider,
+	oidcProvider *oidc.OIDCProvider,
+	webAuthnProvider *webauthn.WebAuthnProvider,
+	s3Provider *s3.S3Provider,
+	mailer mail.Mailer,
+) IUserService {
 	return &userService{
-		userRepo:      userRepo,
-		redisProvider: redisProvider,
+		userRepo:             userRepo,
+		mfaRepo:              mfaRepo,
+		invitationRepo:       invitationRepo,
+		roleRepo:             roleRepo,
+		apiKeyRepo:           apiKeyRepo,
+		sessionRepo:          sessionRepo,
+		identityRepo:         identityRepo,
+		auditLogRepo:         auditLogRepo,
+		webAuthnRepo:         webAuthnRepo,
+		organizationRepo:     organizationRepo,
+		permissionService:    permissionService,
+		authorizationService: authorizationService,
+		auditService:         auditService,
+		redisProvider:        redisProvider,
+		oidcProvider:         oidcProvider,
+		webAuthnProvider:     webAuthnProvider,
+		s3Provider:           s3Provider,
+		mailer:               mailer,
 	}
 }
 
 func (us *userService) getUserFromCache(id uuid.UUID) (*dto.UserResponseDto, bool) {
 	ctx := context.Background()
-	key := fmt.Sprintf("user:%s", id.String())
+	key := userCacheKey(id)
 
 	data, err := us.redisProvider.Get(ctx, key)
 	if err != nil || data == "" {
@@ -59,75 +196,156 @@ func (us *userService) getUserFromCache(id uuid.UUID) (*dto.UserResponseDto, boo
 
 func (us *userService) saveUserToCache(id uuid.UUID, user *dto.UserResponseDto) {
 	ctx := context.Background()
-	key := fmt.Sprintf("user:%s", id.String())
+	key := userCacheKey(id)
 
 	if data, err := json.Marshal(user); err == nil {
 		_ = us.redisProvider.Set(ctx, key, data, 10*time.Second)
 	}
 }
 
-func (us *userService) GetUserByID(id uuid.UUID) *response.ServiceResult {
-	// 1. Get from cache
-	if user, ok := us.getUserFromCache(id); ok {
+// sendMailAsync delivers the mail in the background so response time does not reveal whether an account exists
+func (us *userService) sendMailAsync(msg mail.Message) {
+	go func() {
+		if err := us.mailer.Send(context.Background(), msg); err != nil {
+			global.Logger.Error("Failed to send mail to " + msg.To + ": " + err.Error())
+		}
+	}()
+}
+
+// emailRecipient returns the account an email requested for an address should go to, nil when there is none
+// or eligible rejects it. A positive period throttles the address whether or not it belongs to an account.
+// Callers answer a nil account like a sent email, so these endpoints cannot be used to find registered emails.
+func (us *userService) emailRecipient(kind string, email string, period time.Duration, eligible func(user *model.User) bool) (*model.User, *response.ServiceResult) {
+	if period > 0 {
+		throttleKey := fmt.Sprintf("%s:%s", kind, strings.ToLower(email))
+		allowed, err := us.redisProvider.SetNX(context.Background(), throttleKey, 1, period)
+		if err != nil {
+			global.Logger.Error("Failed to throttle " + kind + " email: " + err.Error())
+			return nil, response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+		}
+		if !allowed {
+			return nil, response.NewServiceErrorWithCode(429, response.ErrCodeTooManyRequests)
+		}
+	}
+
+	user := us.userRepo.GetUserByEmail(email)
+	if user == nil || !eligible(user) {
+		return nil, nil
+	}
+	return user, nil
+}
+
+// GetUserByID returns the user when the access policy lets the subject of ctx read it, only a member when
+// ctx has a tenant
+func (us *userService) GetUserByID(ctx context.Context, id uuid.UUID) *response.ServiceResult {
+	// 1. Get from cache, shared by every tenant so a tenant scoped read always goes to the DB
+	var user *dto.UserResponseDto
+	ok := false
+	if _, scoped := repo.TenantFromContext(ctx); !scoped {
+		user, ok = us.getUserFromCache(id)
+	}
+	if ok {
 		global.Logger.Info("Cache hit for user: " + id.String())
-		return response.NewServiceResult(user)
+	} else {
+		// 2. Get from DB
+		var errResult *response.ServiceResult
+		user, errResult = us.getUserFromDB(us.userRepo.ForContext(ctx), id)
+		if errResult != nil {
+			return errResult
+		}
+
+		// 3. Save to cache
+		us.saveUserToCache(id, user)
 	}
 
-	// 2. Get from DB
-	user, errResult := us.getUserFromDB(id)
-	if errResult != nil {
+	resource := &model.User{ID: user.Id, SystemRole: user.SystemRole, IsActive: &user.IsActive, IsServiceAccount: user.IsServiceAccount}
+	if errResult := us.authorizeUser(ctx, constants.ActionUserRead, resource); errResult != nil {
 		return errResult
 	}
 
-	// 3. Save to cache
-	us.saveUserToCache(id, user)
-
 	return response.NewServiceResult(user)
 }
 
-func (us *userService) getUserFromDB(id uuid.UUID) (*dto.UserResponseDto, *response.ServiceResult) {
-	result := us.userRepo.GetUserByID(id)
+func (us *userService) getUserFromDB(userRepo repo.IUserRepository, id uuid.UUID) (*dto.UserResponseDto, *response.ServiceResult) {
+	result := userRepo.GetUserByID(id)
 	if result == nil {
 		return nil, response.NewServiceErrorWithCode(422, response.ErrCodeUserNotFound)
 	}
 
 	user := &dto.UserResponseDto{
-		Id:          result.ID,
-		Email:       result.Email,
-		Username:    result.Username,
-		FullName:    result.FullName,
-		PhoneNumber: result.PhoneNumber,
-		Gender:      result.Gender,
-		Address:     result.Address,
-		SystemRole:  result.SystemRole,
-		IsActive:    *result.IsActive,
-		CreatedAt:   result.CreatedAt,
-		UpdatedAt:   result.UpdatedAt,
+		Id:               result.ID,
+		Email:            result.Email,
+		Username:         result.Username,
+		FullName:         result.FullName,
+		PhoneNumber:      result.PhoneNumber,
+		Gender:           result.Gender,
+		Address:          result.Address,
+		SystemRole:       result.SystemRole,
+		IsActive:         *result.IsActive,
+		EmailVerifiedAt:  result.EmailVerifiedAt,
+		IsServiceAccount: result.IsServiceAccount,
+		Avatar:           us.avatarURLs(context.Background(), result.AvatarKey),
+		CreatedAt:        result.CreatedAt,
+		UpdatedAt:        result.UpdatedAt,
 	}
 
 	return user, nil
 }
 
-func (us *userService) GetListUser(req dto.UserListRequestDto, userRole string) *response.ServiceResult {
-	// Check authorization - only ADMIN can get user list
-	if userRole != constants.Admin {
-		return response.NewServiceErrorWithCode(403, response.ErrCodeAccessDenied)
+// GetListUser the route requires the user:list permission, only members are listed when ctx has a tenant
+func (us *userService) GetListUser(ctx context.Context, req dto.UserListRequestDto) *response.ServiceResult {
+	return us.getListUser(us.userRepo.ForContext(ctx), req)
+}
+
+func (us *userService) getListUser(userRepo repo.IUserRepository, req dto.UserListRequestDto) *response.ServiceResult {
+	if req.Limit == 0 {
+		req.Limit = 10
 	}
 
-	users, total, err := us.userRepo.GetListUser(req)
+	users, total, nextCursor, err := userRepo.GetListUser(req)
+	if errors.Is(err, pagination.ErrInvalidSort) {
+		return response.NewServiceErrorWithCode(422, response.ErrCodeInvalidSort)
+	}
+	if errors.Is(err, pagination.ErrInvalidCursor) {
+		return response.NewServiceErrorWithCode(422, response.ErrCodeInvalidCursor)
+	}
 	if err != nil {
 		global.Logger.Error("Failed to get users from repository: " + err.Error())
 		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
 	}
 
-	result := map[string]interface{}{
-		"total": total,
-		"data":  users,
+	return response.NewServiceResult(&dto.UserListResponseDto{
+		Total:      total,
+		NextCursor: nextCursor,
+		Data:       users,
+	})
+}
+
+// CreateUser creates a user on behalf of an actor, who can only assign roles within their own permissions.
+// The user gets a random temporary password, returned only here, and must change it at the first login.
+func (us *userService) CreateUser(userDto dto.UserRequestDto, actorRole string) *response.ServiceResult {
+	if errResult := us.checkAssignableRole(actorRole, userDto.SystemRole); errResult != nil {
+		return errResult
+	}
+
+	temporaryPassword, err := global.PasswordPolicy.GeneratePassword()
+	if err != nil {
+		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+	userDto.Password = temporaryPassword
+	userDto.MustChangePassword = true
+
+	createResult := us.createUser(userDto)
+	if createResult.Error != nil {
+		return createResult
 	}
-	return response.NewServiceResult(result)
+	return response.NewServiceResult(&dto.CreateUserResponseDto{
+		ID:                createResult.Data.(uuid.UUID),
+		TemporaryPassword: temporaryPassword,
+	})
 }
 
-func (us *userService) CreateUser(userDto dto.UserRequestDto) *response.ServiceResult {
+func (us *userService) createUser(userDto dto.UserRequestDto) *response.ServiceResult {
 
 	existingEmail := us.userRepo.GetUserByEmail(userDto.Email)
 	if existingEmail != nil {
@@ -139,48 +357,91 @@ func (us *userService) CreateUser(userDto dto.UserRequestDto) *response.ServiceR
 		return response.NewServiceErrorWithCode(409, response.ErrCodeUserHasExists)
 	}
 
+	return us.insertUser(userDto)
+}
+
+// insertUser creates the user without checking that the email and username are free, the unique indexes still do
+func (us *userService) insertUser(userDto dto.UserRequestDto) *response.ServiceResult {
 	// Hash the password
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(userDto.Password), bcrypt.DefaultCost)
+	hashedPassword, err := global.PasswordHasher.Hash(userDto.Password)
 	if err != nil {
 		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
 	}
 
 	active := true
+	now := time.Now()
 	userID, err := uuid.NewV7()
 	if err != nil {
 		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
 	}
 
 	user := &model.User{
-		ID:          userID,
-		Email:       userDto.Email,
-		Username:    userDto.Username,
-		FullName:    userDto.FullName,
-		Password:    string(hashedPassword),
-		PhoneNumber: userDto.PhoneNumber,
-		Gender:      userDto.Gender,
-		Address:     userDto.Address,
-		SystemRole:  userDto.SystemRole,
-		IsActive:    &active,
+		ID:                 userID,
+		Email:              userDto.Email,
+		Username:           userDto.Username,
+		FullName:           userDto.FullName,
+		Password:           hashedPassword,
+		PasswordChangedAt:  &now,
+		PhoneNumber:        userDto.PhoneNumber,
+		Gender:             userDto.Gender,
+		Address:            userDto.Address,
+		SystemRole:         userDto.SystemRole,
+		IsActive:           &active,
+		IsServiceAccount:   userDto.IsServiceAccount,
+		MustChangePassword: userDto.MustChangePassword,
 	}
 
 	_, err = us.userRepo.CreateUser(user)
 	if err != nil {
 		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
 	}
+	if err := us.userRepo.AddPasswordHistory(userID, hashedPassword, global.PasswordPolicy.HistorySize()); err != nil {
+		global.Logger.Error("Failed to record password history: " + err.Error())
+	}
 	return response.NewServiceResult(userID)
 }
 
-func (us *userService) UpdateUser(id uuid.UUID, updateDto dto.UserUpdateRequestDto, userRole string, userID uuid.UUID) *response.ServiceResult {
-
-	existingUser := us.userRepo.GetUserByID(id)
+// UpdateUser who may update whom is decided by the access policy, role and activation changes are
+// authorized separately as they are administrative, even on one's own account
+func (us *userService) UpdateUser(ctx context.Context, id uuid.UUID, updateDto dto.UserUpdateRequestDto, userRole string, userID uuid.UUID) *response.ServiceResult {
+	userRepo := us.userRepo.ForContext(ctx)
+	existingUser := userRepo.GetUserByID(id)
 	if existingUser == nil {
 		return response.NewServiceErrorWithCode(404, response.ErrCodeUserNotFound)
 	}
+	// The own password is changed with the current one, which also keeps the session
+	if updateDto.Password != "" && userID == id {
+		return response.NewServiceErrorWithCode(403, response.ErrCodeUserPasswordSelf)
+	}
 
-	if userRole != constants.Admin && userID != id {
+	if errResult := us.authorizeUser(ctx, constants.ActionUserUpdate, existingUser); errResult != nil {
+		return errResult
+	}
+	if updateDto.SystemRole != "" || updateDto.IsActive != nil {
+		if errResult := us.authorizeUser(ctx, constants.ActionUserManage, existingUser); errResult != nil {
+			return errResult
+		}
+	}
+	// Nobody manages a user holding more privileges than themselves
+	if userID != id && us.checkAssignableRole(userRole, existingUser.SystemRole) != nil {
 		return response.NewServiceErrorWithCode(403, response.ErrCodeUserPermissionDenied)
 	}
+	if updateDto.SystemRole != "" {
+		if errResult := us.checkAssignableRole(userRole, updateDto.SystemRole); errResult != nil {
+			return errResult
+		}
+	}
+
+	if updateDto.Password != "" {
+		// The policy is checked against the email the account will have after the update
+		policyUser := *existingUser
+		if updateDto.Email != "" {
+			policyUser.Email = updateDto.Email
+		}
+		if errResult := us.checkPasswordPolicy(updateDto.Password, &policyUser); errResult != nil {
+			return errResult
+		}
+	}
 
 	updateUser := &model.User{}
 	if updateDto.Email != "" {
@@ -210,80 +471,144 @@ func (us *userService) UpdateUser(id uuid.UUID, updateDto dto.UserUpdateRequestD
 		updateUser.IsActive = updateDto.IsActive
 	}
 
+	var hashedPassword string
 	if updateDto.Password != "" {
-		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(updateDto.Password), bcrypt.DefaultCost)
-		if err != nil {
+		var err error
+		if hashedPassword, err = global.PasswordHasher.Hash(updateDto.Password); err != nil {
 			return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
 		}
-		updateUser.Password = string(hashedPassword)
 	}
 
-	updatedUser, err := us.userRepo.UpdateUser(id, updateUser)
+	updatedUser, err := userRepo.UpdateUser(id, updateUser)
 	if err != nil {
 		return response.NewServiceErrorWithCode(400, response.ErrCodeUserHasExists)
 	}
+	// A password set by somebody else is temporary
+	if hashedPassword != "" {
+		if err := userRepo.SetTemporaryPassword(id, hashedPassword, global.PasswordPolicy.HistorySize()); err != nil {
+			global.Logger.Error("Failed to set password: " + err.Error())
+			return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+		}
+	}
+
+	// A new email address has to be verified again
+	if updateUser.Email != "" && updateUser.Email != existingUser.Email {
+		if err := us.userRepo.SetEmailVerifiedAt(id, nil); err != nil {
+			global.Logger.Error("Failed to reset email verification: " + err.Error())
+			return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+		}
+		updatedUser.EmailVerifiedAt = nil
+		if err := us.sendVerificationEmail(updatedUser); err != nil {
+			global.Logger.Error("Failed to send verification email: " + err.Error())
+		}
+	}
+
+	// Deactivation, password change and role change must kick out every existing session, permissions are
+	// authorized from the role claim of the access token
+	roleChanged := updateUser.SystemRole != "" && updateUser.SystemRole != existingUser.SystemRole
+	if hashedPassword != "" || (updateUser.IsActive != nil && !*updateUser.IsActive) || roleChanged {
+		if err := us.revokeAllTokens(id); err != nil {
+			global.Logger.Error("Failed to revoke user tokens: " + err.Error())
+		}
+	}
 
 	userResponse := dto.UserResponseDto{
-		Id:          updatedUser.ID,
-		Email:       updatedUser.Email,
-		Username:    updatedUser.Username,
-		FullName:    updatedUser.FullName,
-		PhoneNumber: updatedUser.PhoneNumber,
-		Gender:      updatedUser.Gender,
-		Address:     updatedUser.Address,
-		SystemRole:  updatedUser.SystemRole,
-		IsActive:    *updatedUser.IsActive,
-		CreatedAt:   updatedUser.CreatedAt,
-		UpdatedAt:   updatedUser.UpdatedAt,
+		Id:              updatedUser.ID,
+		Email:           updatedUser.Email,
+		Username:        updatedUser.Username,
+		FullName:        updatedUser.FullName,
+		PhoneNumber:     updatedUser.PhoneNumber,
+		Gender:          updatedUser.Gender,
+		Address:         updatedUser.Address,
+		SystemRole:      updatedUser.SystemRole,
+		IsActive:        *updatedUser.IsActive,
+		EmailVerifiedAt: updatedUser.EmailVerifiedAt,
+		Avatar:          us.avatarURLs(ctx, updatedUser.AvatarKey),
+		CreatedAt:       updatedUser.CreatedAt,
+		UpdatedAt:       updatedUser.UpdatedAt,
 	}
 
 	return response.NewServiceResult(&userResponse)
 }
 
-func (us *userService) Login(username string, password string) *response.ServiceResult {
+func (us *userService) Login(username string, password string, client dto.ClientInfo) *response.ServiceResult {
+	if throttled := us.checkLoginThrottle(username, client.IPAddress); throttled != nil {
+		return throttled
+	}
+
+	// Service accounts only authenticate with API keys
 	user := us.userRepo.GetUserByUsername(username)
-	if user == nil {
+	if user == nil || user.IsServiceAccount {
+		us.recordLoginFailure(username, client.IPAddress)
 		return response.NewServiceErrorWithCode(401, response.ErrCodeInvalidLogin)
 	}
 
 	// Compare password hash
-	err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password))
+	match, err := global.PasswordHasher.Verify(password, user.Password)
 	if err != nil {
+		global.Logger.Error("Failed to verify password hash: " + err.Error())
+	}
+	if !match {
+		us.recordLoginFailure(username, client.IPAddress)
 		return response.NewServiceErrorWithCode(401, response.ErrCodeInvalidLogin)
 	}
+	us.resetLoginFailures(username)
+	us.rehashPassword(user, password)
+
 	if user.IsActive != nil && !*user.IsActive {
 		return response.NewServiceErrorWithCode(403, response.ErrCodeAccountLock)
 	}
-
-	// Generate JWT token
-	token, err := jwt.GenerateToken(user.ID, user.Email, user.SystemRole, global.Config.JWT.SecretKey, global.Config.JWT.TokenExpiry)
-	if err != nil {
-		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	if global.Config.Auth.RequireEmailVerification && user.EmailVerifiedAt == nil {
+		return response.NewServiceErrorWithCode(403, response.ErrCodeEmailNotVerified)
+	}
+	if user.MustChangePassword || global.PasswordPolicy.IsExpired(user.PasswordChangedAt, time.Now()) {
+		return us.startPasswordChange(user)
+	}
+	if mfaResult := us.startMFALogin(user); mfaResult != nil {
+		return mfaResult
 	}
 
-	// Generate refresh token with longer expiry
-	refreshToken, err := jwt.GenerateToken(user.ID, user.Email, user.SystemRole, global.Config.JWT.SecretKey, global.Config.JWT.RefreshExpiry)
+	// Generate access and refresh tokens
+	authResponse, err := us.generateAuthTokens(user, client)
 	if err != nil {
 		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
 	}
 
-	authResponse := &dto.AuthResponseDto{
-		Token:        token,
-		RefreshToken: refreshToken,
-	}
-
 	return response.NewServiceResult(authResponse)
 }
 
-func (us *userService) Register(registerDto dto.RegisterRequestDto) *response.ServiceResult {
+func (us *userService) Register(registerDto dto.RegisterRequestDto, client dto.ClientInfo) *response.ServiceResult {
+	role, invitation, errResult := us.resolveRegistration(registerDto)
+	if errResult != nil {
+		return errResult
+	}
+	newUser := &model.User{Username: registerDto.Username, Email: registerDto.Email}
+	if errResult := us.checkPasswordPolicy(registerDto.Password, newUser); errResult != nil {
+		return errResult
+	}
+
+	// Claim the invitation first so it cannot be used by two registrations at once
+	if invitation != nil {
+		accepted, err := us.invitationRepo.AcceptInvitation(invitation.ID)
+		if err != nil {
+			return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+		}
+		if !accepted {
+			return response.NewServiceErrorWithCode(400, response.ErrCodeInvitationInvalid)
+		}
+	}
+
 	userDto := dto.UserRequestDto{
 		Email:      registerDto.Email,
 		Username:   registerDto.Username,
 		Password:   registerDto.Password,
-		SystemRole: registerDto.Role,
+		SystemRole: role,
 	}
-	createResult := us.CreateUser(userDto)
+	createResult := us.createUser(userDto)
 	if createResult.Error != nil {
+		if invitation != nil {
+			_ = us.invitationRepo.ReleaseInvitation(invitation.ID)
+		}
 		return createResult // Return CreateUser
 	}
 
@@ -293,23 +618,30 @@ func (us *userService) Register(registerDto dto.RegisterRequestDto) *response.Se
 		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
 	}
 
-	// Generate JWT token
-	token, err := jwt.GenerateToken(user.ID, user.Email, user.SystemRole, global.Config.JWT.SecretKey, global.Config.JWT.TokenExpiry)
-	if err != nil {
-		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	if invitation != nil {
+		// The invitation was delivered to this address, which proves ownership
+		now := time.Now()
+		if err := us.userRepo.SetEmailVerifiedAt(userID, &now); err != nil {
+			global.Logger.Error("Failed to mark email verified: " + err.Error())
+		} else {
+			user.EmailVerifiedAt = &now
+		}
+	} else if err := us.sendVerificationEmail(user); err != nil {
+		global.Logger.Error("Failed to send verification email: " + err.Error())
+	}
+	if global.Config.Auth.RequireEmailVerification && user.EmailVerifiedAt == nil {
+		return response.NewServiceResult(&dto.AuthResponseDto{EmailVerificationRequired: true})
+	}
+	if mfaResult := us.startMFALogin(user); mfaResult != nil {
+		return mfaResult
 	}
 
-	// Generate refresh token with longer expiry
-	refreshToken, err := jwt.GenerateToken(user.ID, user.Email, user.SystemRole, global.Config.JWT.SecretKey, global.Config.JWT.RefreshExpiry)
+	// Generate access and refresh tokens
+	authResponse, err := us.generateAuthTokens(user, client)
 	if err != nil {
 		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
 	}
 
-	authResponse := &dto.AuthResponseDto{
-		Token:        token,
-		RefreshToken: refreshToken,
-	}
-
 	return response.NewServiceResult(authResponse)
 }
 
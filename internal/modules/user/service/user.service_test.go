@@ -0,0 +1,322 @@
+package service
+
+import (
+	"app/global"
+	"app/internal/modules/user/dto"
+	"app/internal/modules/user/model"
+	"app/internal/modules/user/repo"
+	"app/internal/third_party/redis"
+	"app/internal/third_party/webauthn"
+	"app/pkg/jwt"
+	"app/pkg/logger"
+	"app/pkg/password"
+	"app/pkg/policy"
+	"app/pkg/response"
+	"app/pkg/setting"
+	"bytes"
+	"context"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/google/uuid"
+	goredis "github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+const testOrigin = "https://app.example.test"
+
+type fakeUserRepo struct {
+	repo.IUserRepository
+	users []*model.User
+}
+
+func (f *fakeUserRepo) GetUserByID(id uuid.UUID) *model.User {
+	for _, user := range f.users {
+		if user.ID == id {
+			return user
+		}
+	}
+	return nil
+}
+
+func (f *fakeUserRepo) GetUserByEmail(email string) *model.User {
+	for _, user := range f.users {
+		if strings.EqualFold(user.Email, email) {
+			return user
+		}
+	}
+	return nil
+}
+
+func (f *fakeUserRepo) ChangePassword(id uuid.UUID, passwordHash string, historySize int) error {
+	if user := f.GetUserByID(id); user != nil {
+		now := time.Now()
+		user.Password = passwordHash
+		user.PasswordChangedAt = &now
+		user.MustChangePassword = false
+	}
+	return nil
+}
+
+func (f *fakeUserRepo) ForContext(ctx context.Context) repo.IUserRepository {
+	return f
+}
+
+func (f *fakeUserRepo) UpdateUser(id uuid.UUID, user *model.User) (*model.User, error) {
+	return f.GetUserByID(id), nil
+}
+
+func (f *fakeUserRepo) SetTemporaryPassword(id uuid.UUID, passwordHash string, historySize int) error {
+	if user := f.GetUserByID(id); user != nil {
+		now := time.Now()
+		user.Password = passwordHash
+		user.PasswordChangedAt = &now
+		user.MustChangePassword = true
+	}
+	return nil
+}
+
+type fakeRoleRepo struct {
+	repo.IRoleRepository
+	roles       []*model.Role
+	permissions map[string][]string
+}
+
+func (f *fakeRoleRepo) GetRoleByName(name string) *model.Role {
+	for _, role := range f.roles {
+		if role.Name == name {
+			return role
+		}
+	}
+	return nil
+}
+
+// fakeAuthorizationService allows every action, the access policy has its own tests
+type fakeAuthorizationService struct{}
+
+func (f *fakeAuthorizationService) Authorize(ctx context.Context, action string, resource policy.Attributes) (bool, error) {
+	return true, nil
+}
+
+type fakeIdentityRepo struct {
+	repo.IIdentityRepository
+	identities []*model.UserIdentity
+}
+
+func (f *fakeIdentityRepo) GetIdentity(provider string, subject string) *model.UserIdentity {
+	for _, identity := range f.identities {
+		if identity.Provider == provider && identity.Subject == subject {
+			return identity
+		}
+	}
+	return nil
+}
+
+func (f *fakeIdentityRepo) CreateIdentity(identity *model.UserIdentity) error {
+	f.identities = append(f.identities, identity)
+	return nil
+}
+
+func (f *fakeIdentityRepo) TouchIdentity(id uuid.UUID) error {
+	return nil
+}
+
+type fakeMFARepo struct {
+	repo.IMFARepository
+	mfa           map[uuid.UUID]*model.UserMFA
+	requiredRoles []string
+}
+
+func (f *fakeMFARepo) GetUserMFA(userID uuid.UUID) *model.UserMFA {
+	return f.mfa[userID]
+}
+
+func (f *fakeMFARepo) SaveUserMFA(mfa *model.UserMFA) error {
+	if f.mfa == nil {
+		f.mfa = make(map[uuid.UUID]*model.UserMFA)
+	}
+	f.mfa[mfa.UserID] = mfa
+	return nil
+}
+
+func (f *fakeMFARepo) UseTimeStep(userID uuid.UUID, step int64) (bool, error) {
+	return true, nil
+}
+
+func (f *fakeMFARepo) ReplaceRecoveryCodes(userID uuid.UUID, codeHashes []string) error {
+	return nil
+}
+
+func (f *fakeMFARepo) IsMFARequiredForRole(role string) bool {
+	return slices.Contains(f.requiredRoles, role)
+}
+
+type fakeSessionRepo struct {
+	repo.ISessionRepository
+	sessions []*model.UserSession
+}
+
+func (f *fakeSessionRepo) CreateSession(session *model.UserSession) error {
+	f.sessions = append(f.sessions, session)
+	return nil
+}
+
+func (f *fakeSessionRepo) RevokeUserSessions(userID uuid.UUID, exceptID uuid.UUID) ([]uuid.UUID, error) {
+	var revoked []uuid.UUID
+	for _, session := range f.sessions {
+		if session.UserID == userID && session.ID != exceptID && session.RevokedAt == nil {
+			now := time.Now()
+			session.RevokedAt = &now
+			revoked = append(revoked, session.ID)
+		}
+	}
+	return revoked, nil
+}
+
+func (f *fakeSessionRepo) RevokeSession(id uuid.UUID) error {
+	if session := f.GetSessionByID(id); session != nil && session.RevokedAt == nil {
+		now := time.Now()
+		session.RevokedAt = &now
+	}
+	return nil
+}
+
+func (f *fakeSessionRepo) ExtendSession(id uuid.UUID, ipAddress string, expiresAt time.Time) error {
+	return nil
+}
+
+func (f *fakeSessionRepo) GetSessionByID(id uuid.UUID) *model.UserSession {
+	for _, session := range f.sessions {
+		if session.ID == id {
+			return session
+		}
+	}
+	return nil
+}
+
+type fakeWebAuthnRepo struct {
+	repo.IWebAuthnRepository
+	credentials []*model.WebAuthnCredential
+}
+
+func (f *fakeWebAuthnRepo) GetCredentialsByUserID(userID uuid.UUID) ([]*model.WebAuthnCredential, error) {
+	var credentials []*model.WebAuthnCredential
+	for _, credential := range f.credentials {
+		if credential.UserID == userID {
+			credentials = append(credentials, credential)
+		}
+	}
+	return credentials, nil
+}
+
+func (f *fakeWebAuthnRepo) CreateCredential(credential *model.WebAuthnCredential) error {
+	f.credentials = append(f.credentials, credential)
+	return nil
+}
+
+func (f *fakeWebAuthnRepo) UpdateCredentialUsage(userID uuid.UUID, credentialID []byte, signCount uint32, backupState bool) error {
+	for _, credential := range f.credentials {
+		if credential.UserID == userID && bytes.Equal(credential.CredentialID, credentialID) {
+			credential.SignCount = signCount
+			credential.BackupState = backupState
+		}
+	}
+	return nil
+}
+
+func (f *fakeWebAuthnRepo) HasCredentials(userID uuid.UUID) bool {
+	credentials, _ := f.GetCredentialsByUserID(userID)
+	return len(credentials) > 0
+}
+
+// setupTestGlobals points the globals used by the service at an in-memory redis and a test configuration
+func setupTestGlobals(t *testing.T) {
+	t.Helper()
+	server := miniredis.RunT(t)
+	client := goredis.NewClient(&goredis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+
+	keys, err := jwt.NewKeySet(setting.JWTSetting{SigningAlgorithm: "HS256", SecretKey: "test-secret"})
+	if err != nil {
+		t.Fatalf("NewKeySet() error = %v", err)
+	}
+
+	passwordSetting := setting.PasswordSetting{HashAlgorithm: password.AlgorithmBcrypt, BcryptCost: 4, MinLength: 12, MaxAge: 90 * 24 * time.Hour}
+	hasher, err := password.NewHasher(passwordSetting)
+	if err != nil {
+		t.Fatalf("NewHasher() error = %v", err)
+	}
+	policy, err := password.NewPolicy(passwordSetting)
+	if err != nil {
+		t.Fatalf("NewPolicy() error = %v", err)
+	}
+
+	global.Redis = client
+	global.JWTKeys = keys
+	global.PasswordHasher = hasher
+	global.PasswordPolicy = policy
+	global.Logger = &logger.LogZap{Logger: zap.NewNop()}
+	global.Config.JWT.TokenExpiry = 15 * time.Minute
+	global.Config.JWT.RefreshExpiry = time.Hour
+	global.Config.Auth.MFATokenExpiry = 5 * time.Minute
+	global.Config.Auth.MFASecretKey = "test-mfa-secret"
+	global.Config.Auth.PasswordChangeTokenExpiry = 5 * time.Minute
+	global.Config.WebAuthn = setting.WebAuthnSetting{
+		RPID:            "app.example.test",
+		RPDisplayName:   "App",
+		RPOrigins:       []string{testOrigin},
+		ChallengeExpiry: 5 * time.Minute,
+	}
+}
+
+// newTestUser returns an active user stored in the fake repository
+func newTestUser(users *fakeUserRepo) *model.User {
+	active := true
+	user := &model.User{ID: uuid.New(), Username: "alice", Email: "alice@example.test", IsActive: &active}
+	users.users = append(users.users, user)
+	return user
+}
+
+// newTestUserService builds the service over the fakes, the dependencies a test does not reach stay nil
+func newTestUserService(users *fakeUserRepo) *userService {
+	return NewUserService(
+		users, &fakeMFARepo{}, nil, nil, nil, &fakeSessionRepo{}, nil, nil, &fakeWebAuthnRepo{}, nil,
+		nil, nil, nil, redis.NewRedisProvider(), nil, webauthn.NewWebAuthnProvider(), nil, nil,
+	).(*userService)
+}
+
+func TestUpdateUserPassword(t *testing.T) {
+	setupTestGlobals(t)
+	users := &fakeUserRepo{}
+	admin := newTestUser(users)
+	admin.SystemRole = "ADMIN"
+	admin.Password, _ = global.PasswordHasher.Hash("the admin password")
+	target := newTestUser(users)
+	target.SystemRole = "USER"
+	us := newTestUserService(users)
+	us.roleRepo = &fakeRoleRepo{roles: []*model.Role{{Name: "ADMIN"}, {Name: "USER"}}}
+	us.organizationRepo = &fakeOrganizationRepo{}
+	us.authorizationService = &fakeAuthorizationService{}
+	us.permissionService = &fakePermissionService{permissions: map[string][]string{
+		"ADMIN": {"user:update", "user:list"},
+		"USER":  {"user:list"},
+	}}
+
+	// The own password is changed with the current one through change_password
+	result := us.UpdateUser(context.Background(), admin.ID, dto.UserUpdateRequestDto{Password: "a new admin password"}, admin.SystemRole, admin.ID)
+	assertServiceError(t, "UpdateUser() of the own password", result, 403, response.ErrCodeUserPasswordSelf)
+	if ok, _ := global.PasswordHasher.Verify("the admin password", admin.Password); !ok {
+		t.Error("own password was changed")
+	}
+
+	result = us.UpdateUser(context.Background(), target.ID, dto.UserUpdateRequestDto{Password: "a temporary password"}, admin.SystemRole, admin.ID)
+	if result.Error != nil {
+		t.Fatalf("UpdateUser() error = %v", result.Error)
+	}
+	if ok, _ := global.PasswordHasher.Verify("a temporary password", target.Password); !ok || !target.MustChangePassword {
+		t.Errorf("password set %v, must change password %v, want a temporary password", ok, target.MustChangePassword)
+	}
+}
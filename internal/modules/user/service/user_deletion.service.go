@@ -0,0 +1,164 @@
+package service
+
+import (
+	"app/global"
+	"app/internal/modules/user/constants"
+	"app/internal/modules/user/dto"
+	"app/internal/modules/user/model"
+	"app/internal/modules/user/repo"
+	"app/internal/third_party/redis"
+	"app/pkg/response"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// purgeBatchSize is the number of users purged per statement, so one run does not hold a long transaction
+const purgeBatchSize = 100
+
+// IUserPurgeService permanently deletes the users deleted longer ago than the retention window, the purge job runs it
+type IUserPurgeService interface {
+	PurgeDeletedUsers(retention time.Duration) (int, error)
+}
+
+type userPurgeService struct {
+	userRepo      repo.IUserRepository
+	redisProvider *redis.RedisProvider
+}
+
+func NewUserPurgeService(userRepo repo.IUserRepository, redisProvider *redis.RedisProvider) IUserPurgeService {
+	return &userPurgeService{
+		userRepo:      userRepo,
+		redisProvider: redisProvider,
+	}
+}
+
+// PurgeDeletedUsers returns the number of purged users, their cache entries are cleared
+func (ps *userPurgeService) PurgeDeletedUsers(retention time.Duration) (int, error) {
+	deletedBefore := time.Now().Add(-retention)
+	purged := 0
+	for {
+		ids, err := ps.userRepo.PurgeDeletedUsers(deletedBefore, purgeBatchSize)
+		if err != nil {
+			return purged, err
+		}
+		purged += len(ids)
+
+		keys := make([]string, len(ids))
+		for i, id := range ids {
+			keys[i] = userCacheKey(id)
+		}
+		if len(keys) > 0 {
+			if err := ps.redisProvider.Del(context.Background(), keys...); err != nil {
+				global.Logger.Warn("Failed to clear cache of purged users: " + err.Error())
+			}
+		}
+
+		if len(ids) < purgeBatchSize {
+			return purged, nil
+		}
+	}
+}
+
+// DeleteUser soft deletes a user and signs it out everywhere, it can be restored until purged
+func (us *userService) DeleteUser(id uuid.UUID, actorID uuid.UUID, actorRole string, client dto.ClientInfo) *response.ServiceResult {
+	if id == actorID {
+		return response.NewServiceErrorWithCode(403, response.ErrCodeUserDeleteSelf)
+	}
+
+	user := us.userRepo.GetUserByID(id)
+	if user == nil {
+		return response.NewServiceErrorWithCode(404, response.ErrCodeUserNotFound)
+	}
+	// Nobody deletes a user holding more privileges than themselves
+	if us.checkAssignableRole(actorRole, user.SystemRole) != nil {
+		return response.NewServiceErrorWithCode(403, response.ErrCodeUserPermissionDenied)
+	}
+
+	deleted, err := us.userRepo.DeleteUser(id)
+	if err != nil {
+		global.Logger.Error("Failed to delete user: " + err.Error())
+		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+	if !deleted {
+		return response.NewServiceErrorWithCode(404, response.ErrCodeUserNotFound)
+	}
+
+	if err := us.revokeAllTokens(id); err != nil {
+		global.Logger.Error("Failed to revoke user tokens: " + err.Error())
+	}
+	us.clearUserCache(id)
+	us.recordUserAudit(constants.AuditActionUserDelete, id, actorID, client)
+
+	return response.NewServiceResult(nil)
+}
+
+// RestoreUser undoes a delete unless the username or email has been taken by another user meanwhile
+func (us *userService) RestoreUser(id uuid.UUID, actorID uuid.UUID, actorRole string, client dto.ClientInfo) *response.ServiceResult {
+	user := us.userRepo.GetDeletedUserByID(id)
+	if user == nil {
+		return response.NewServiceErrorWithCode(404, response.ErrCodeUserNotFound)
+	}
+	if us.checkAssignableRole(actorRole, user.SystemRole) != nil {
+		return response.NewServiceErrorWithCode(403, response.ErrCodeUserPermissionDenied)
+	}
+	if us.userRepo.GetUserByEmail(user.Email) != nil || us.userRepo.GetUserByUsername(user.Username) != nil {
+		return response.NewServiceErrorWithCode(409, response.ErrCodeUserHasExists)
+	}
+
+	restored, err := us.userRepo.RestoreUser(id)
+	if err != nil {
+		// Lost a race with a registration taking the username or email
+		global.Logger.Error("Failed to restore user: " + err.Error())
+		return response.NewServiceErrorWithCode(409, response.ErrCodeUserHasExists)
+	}
+	if !restored {
+		return response.NewServiceErrorWithCode(404, response.ErrCodeUserNotFound)
+	}
+
+	us.clearUserCache(id)
+	us.recordUserAudit(constants.AuditActionUserRestore, id, actorID, client)
+
+	return response.NewServiceResult(nil)
+}
+
+func (us *userService) GetListDeletedUser(req dto.UserListRequestDto) *response.ServiceResult {
+	if req.Limit == 0 {
+		req.Limit = 10
+	}
+
+	users, total, err := us.userRepo.GetListDeletedUser(req)
+	if err != nil {
+		global.Logger.Error("Failed to get deleted users from repository: " + err.Error())
+		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+
+	result := map[string]interface{}{
+		"total": total,
+		"data":  users,
+	}
+	return response.NewServiceResult(result)
+}
+
+func (us *userService) recordUserAudit(action string, userID uuid.UUID, actorID uuid.UUID, client dto.ClientInfo) {
+	us.auditService.Record(&model.AuditLog{
+		ActorID:   &actorID,
+		UserID:    &userID,
+		Action:    action,
+		IPAddress: client.IPAddress,
+		UserAgent: client.UserAgent,
+	})
+}
+
+func (us *userService) clearUserCache(id uuid.UUID) {
+	if err := us.redisProvider.Del(context.Background(), userCacheKey(id)); err != nil {
+		global.Logger.Warn("Failed to clear user cache: " + err.Error())
+	}
+}
+
+// userCacheKey is the Redis key of the cached user returned by GetUserByID
+func userCacheKey(id uuid.UUID) string {
+	return fmt.Sprintf("user:%s", id.String())
+}
@@ -0,0 +1,217 @@
+package service
+
+import (
+	"app/global"
+	"app/internal/modules/user/constants"
+	"app/internal/modules/user/dto"
+	"app/internal/modules/user/model"
+	"app/pkg/response"
+	"app/pkg/spreadsheet"
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// userExportFetchSize is the number of users read from the database cursor at a time
+const userExportFetchSize = 500
+
+type userExportColumn struct {
+	Name  string
+	Value func(user *model.User) any
+}
+
+// userExportColumns are the columns a user export can have, the password hash is never exported
+var userExportColumns = []userExportColumn{
+	{"id", func(u *model.User) any { return u.ID.String() }},
+	{"username", func(u *model.User) any { return u.Username }},
+	{"full_name", func(u *model.User) any { return u.FullName }},
+	{"email", func(u *model.User) any { return u.Email }},
+	{"phone_number", func(u *model.User) any { return u.PhoneNumber }},
+	{"gender", func(u *model.User) any { return u.Gender }},
+	{"address", func(u *model.User) any { return u.Address }},
+	{"system_role", func(u *model.User) any { return u.SystemRole }},
+	{"is_active", func(u *model.User) any { return exportBool(u.IsActive) }},
+	{"is_service_account", func(u *model.User) any { return u.IsServiceAccount }},
+	{"must_change_password", func(u *model.User) any { return u.MustChangePassword }},
+	{"email_verified_at", func(u *model.User) any { return exportTime(u.EmailVerifiedAt) }},
+	{"password_changed_at", func(u *model.User) any { return exportTime(u.PasswordChangedAt) }},
+	{"created_at", func(u *model.User) any { return u.CreatedAt }},
+	{"updated_at", func(u *model.User) any { return u.UpdatedAt }},
+}
+
+var userExportDefaultColumns = []string{"id", "username", "full_name", "email", "system_role", "is_active", "email_verified_at", "created_at"}
+
+// UserExport is an export ready to be streamed, its content type and file name are known before the first row
+type UserExport struct {
+	Format      string
+	ContentType string
+	FileName    string
+
+	ctx     context.Context
+	filter  dto.UserListRequestDto
+	columns []userExportColumn
+	stream  func(ctx context.Context, req dto.UserListRequestDto, fetchSize int, fn func(users []*model.User) error) error
+}
+
+// Stream writes the export to w and returns the number of users written. An error after the first rows
+// leaves w with a truncated file.
+func (e *UserExport) Stream(w io.Writer) (int, error) {
+	header := make([]string, len(e.columns))
+	for i, column := range e.columns {
+		header[i] = column.Name
+	}
+	writer, err := spreadsheet.NewWriter(w, e.Format, header)
+	if err != nil {
+		return 0, err
+	}
+
+	rows := 0
+	err = e.stream(e.ctx, e.filter, userExportFetchSize, func(users []*model.User) error {
+		for _, user := range users {
+			values := make([]any, len(e.columns))
+			for i, column := range e.columns {
+				values[i] = column.Value(user)
+			}
+			if err := writer.WriteRow(values); err != nil {
+				return err
+			}
+			rows++
+		}
+		return nil
+	})
+	if err != nil {
+		return rows, err
+	}
+	return rows, writer.Close()
+}
+
+// ExportUsers prepares an export of every user matching the filters, the rows are read when it is streamed.
+// The context bounds the export, a cancelled request stops reading users, and limits it to its tenant.
+func (us *userService) ExportUsers(ctx context.Context, req dto.UserExportRequestDto, actorID uuid.UUID, client dto.ClientInfo) *response.ServiceResult {
+	export, errResult := us.newUserExport(ctx, req)
+	if errResult != nil {
+		return errResult
+	}
+
+	us.recordUserExportAudit(actorID, client)
+	return response.NewServiceResult(export)
+}
+
+// StoreUserExport writes an export to object storage while reading it and returns a presigned URL to download it,
+// for exports too large to be streamed through the API
+func (us *userService) StoreUserExport(ctx context.Context, req dto.UserExportRequestDto, actorID uuid.UUID, client dto.ClientInfo) *response.ServiceResult {
+	export, errResult := us.newUserExport(ctx, req)
+	if errResult != nil {
+		return errResult
+	}
+
+	objectID, err := uuid.NewV7()
+	if err != nil {
+		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+	objectName := fmt.Sprintf("exports/users/%s/%s", objectID, export.FileName)
+
+	reader, writer := io.Pipe()
+	rows := make(chan int, 1)
+	go func() {
+		written, err := export.Stream(writer)
+		rows <- written
+		writer.CloseWithError(err)
+	}()
+
+	if err := us.s3Provider.UploadStream(ctx, objectName, reader, -1, export.ContentType); err != nil {
+		// Unblocks the export if the upload stopped reading
+		reader.CloseWithError(err)
+		<-rows
+		global.Logger.Error("Failed to store user export: " + err.Error())
+		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+
+	expiry := global.Config.Auth.UserExportURLExpiry
+	url, err := us.s3Provider.GetPresignedURL(ctx, objectName, expiry)
+	if err != nil {
+		global.Logger.Error("Failed to presign user export URL: " + err.Error())
+		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+
+	us.recordUserExportAudit(actorID, client)
+	return response.NewServiceResult(&dto.UserExportResponseDto{
+		URL:       url,
+		FileName:  export.FileName,
+		Format:    export.Format,
+		Rows:      <-rows,
+		ExpiresAt: time.Now().Add(expiry),
+	})
+}
+
+func (us *userService) newUserExport(ctx context.Context, req dto.UserExportRequestDto) (*UserExport, *response.ServiceResult) {
+	format := req.Format
+	if format == "" {
+		format = spreadsheet.FormatCSV
+	}
+
+	columns, err := parseUserExportColumns(req.Columns)
+	if err != nil {
+		return nil, response.NewServiceErrorWithCode(422, response.ErrCodeExportColumnInvalid)
+	}
+
+	return &UserExport{
+		Format:      format,
+		ContentType: spreadsheet.ContentType(format),
+		FileName:    fmt.Sprintf("users-%s.%s", time.Now().UTC().Format("20060102-150405"), format),
+		ctx:         ctx,
+		filter:      req.Filter(),
+		columns:     columns,
+		stream:      us.userRepo.ForContext(ctx).StreamUsers,
+	}, nil
+}
+
+// parseUserExportColumns returns the columns of a comma separated list in its order, the default columns when empty
+func parseUserExportColumns(list string) ([]userExportColumn, error) {
+	names := userExportDefaultColumns
+	if strings.TrimSpace(list) != "" {
+		names = strings.Split(list, ",")
+	}
+
+	columns := make([]userExportColumn, 0, len(names))
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		i := slices.IndexFunc(userExportColumns, func(column userExportColumn) bool { return column.Name == name })
+		if i < 0 {
+			return nil, fmt.Errorf("unknown column %q", name)
+		}
+		if slices.ContainsFunc(columns, func(column userExportColumn) bool { return column.Name == name }) {
+			return nil, fmt.Errorf("column %q requested twice", name)
+		}
+		columns = append(columns, userExportColumns[i])
+	}
+	return columns, nil
+}
+
+func (us *userService) recordUserExportAudit(actorID uuid.UUID, client dto.ClientInfo) {
+	us.auditService.Record(&model.AuditLog{
+		ActorID:   &actorID,
+		Action:    constants.AuditActionUserExport,
+		IPAddress: client.IPAddress,
+		UserAgent: client.UserAgent,
+	})
+}
+
+func exportBool(value *bool) any {
+	if value == nil {
+		return nil
+	}
+	return *value
+}
+
+func exportTime(value *time.Time) any {
+	if value == nil {
+		return nil
+	}
+	return *value
+}
@@ -0,0 +1,407 @@
+package service
+
+import (
+	"app/global"
+	"app/internal/modules/user/constants"
+	"app/internal/modules/user/dto"
+	"app/internal/third_party/mail"
+	"app/pkg/response"
+	"app/pkg/securetoken"
+	"app/pkg/spreadsheet"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"mime/multipart"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+)
+
+const (
+	// userImportBatchSize is the number of rows checked against existing users per query
+	userImportBatchSize = 500
+	// userImportProgressInterval is the number of rows processed between two progress updates
+	userImportProgressInterval = 20
+)
+
+// userImportRequiredColumns must be in the header row, full_name is optional and other columns are ignored
+var userImportRequiredColumns = []string{"email", "username", "system_role"}
+
+// userImportColumnNames maps the fields of dto.CreateUserDto to the columns of the import file
+var userImportColumnNames = map[string]string{
+	"Email":      "email",
+	"Username":   "username",
+	"FullName":   "full_name",
+	"SystemRole": "system_role",
+}
+
+// userImportJob is the state of an import job kept in Redis, the row errors make the report
+type userImportJob struct {
+	dto.UserImportJobDto
+	Errors []dto.UserImportRowErrorDto `json:"errors"`
+}
+
+type userImportRow struct {
+	Line int
+	User dto.CreateUserDto
+}
+
+// StartUserImport reads the file and starts a background job creating its users, the job progress is polled
+// with GetUserImportJob. Each user gets an email to choose a password.
+func (us *userService) StartUserImport(file *multipart.FileHeader, dryRun bool, actorID uuid.UUID, actorRole string) *response.ServiceResult {
+	config := global.Config.Auth
+	if file.Size > int64(config.UserImportMaxFileSize) {
+		return response.NewServiceErrorWithCode(422, response.ErrCodeImportFileInvalid)
+	}
+
+	src, err := file.Open()
+	if err != nil {
+		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+	defer src.Close()
+
+	// The header row comes on top of the allowed user rows
+	records, err := spreadsheet.ReadRows(src, file.Filename, config.UserImportMaxRows+1)
+	if err != nil {
+		global.Logger.Warn("Rejected user import file: " + err.Error())
+		return response.NewServiceErrorWithCode(422, response.ErrCodeImportFileInvalid)
+	}
+	rows, err := parseUserImportRows(records)
+	if err != nil {
+		global.Logger.Warn("Rejected user import file: " + err.Error())
+		return response.NewServiceErrorWithCode(422, response.ErrCodeImportFileInvalid)
+	}
+
+	jobID, err := uuid.NewV7()
+	if err != nil {
+		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+	job := &userImportJob{
+		UserImportJobDto: dto.UserImportJobDto{
+			ID:        jobID,
+			FileName:  file.Filename,
+			DryRun:    dryRun,
+			Status:    constants.JobStatusPending,
+			Total:     len(rows),
+			CreatedBy: actorID,
+			CreatedAt: time.Now(),
+		},
+		Errors: []dto.UserImportRowErrorDto{},
+	}
+	if err := us.saveUserImportJob(job); err != nil {
+		global.Logger.Error("Failed to store user import job: " + err.Error())
+		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+
+	go us.runUserImport(job, rows, actorRole)
+
+	return response.NewServiceResult(&job.UserImportJobDto)
+}
+
+func (us *userService) GetUserImportJob(id uuid.UUID) *response.ServiceResult {
+	job, errResult := us.getUserImportJob(id)
+	if errResult != nil {
+		return errResult
+	}
+	return response.NewServiceResult(&job.UserImportJobDto)
+}
+
+// GetUserImportReport returns the rejected rows of a job as CSV, rows rejected so far while it is running
+func (us *userService) GetUserImportReport(id uuid.UUID) *response.ServiceResult {
+	job, errResult := us.getUserImportJob(id)
+	if errResult != nil {
+		return errResult
+	}
+
+	var buffer bytes.Buffer
+	writer := csv.NewWriter(&buffer)
+	_ = writer.Write([]string{"row", "email", "username", "errors"})
+	for _, rowError := range job.Errors {
+		_ = writer.Write([]string{strconv.Itoa(rowError.Row), rowError.Email, rowError.Username, strings.Join(rowError.Errors, "; ")})
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+
+	return response.NewServiceResult(buffer.Bytes())
+}
+
+// parseUserImportRows maps the rows to users by the header row, empty rows are skipped
+func parseUserImportRows(records [][]string) ([]userImportRow, error) {
+	columns := make(map[string]int)
+	for i, name := range records[0] {
+		columns[strings.ReplaceAll(strings.ToLower(name), " ", "_")] = i
+	}
+	for _, column := range userImportRequiredColumns {
+		if _, found := columns[column]; !found {
+			return nil, fmt.Errorf("missing column %s", column)
+		}
+	}
+
+	cell := func(record []string, column string) string {
+		i, found := columns[column]
+		if !found || i >= len(record) {
+			return ""
+		}
+		return record[i]
+	}
+
+	rows := []userImportRow{}
+	for i, record := range records[1:] {
+		if !slices.ContainsFunc(record, func(value string) bool { return value != "" }) {
+			continue
+		}
+		rows = append(rows, userImportRow{
+			Line: i + 2,
+			User: dto.CreateUserDto{
+				Email:      cell(record, "email"),
+				Username:   cell(record, "username"),
+				FullName:   cell(record, "full_name"),
+				SystemRole: cell(record, "system_role"),
+			},
+		})
+	}
+	if len(rows) == 0 {
+		return nil, errors.New("no user rows")
+	}
+	return rows, nil
+}
+
+// runUserImport validates every row first, then creates the users of the valid rows unless it is a dry run
+func (us *userService) runUserImport(job *userImportJob, rows []userImportRow, actorRole string) {
+	defer func() {
+		if r := recover(); r != nil {
+			global.Logger.Error(fmt.Sprintf("User import job %s panicked: %v", job.ID, r))
+			us.finishUserImport(job, constants.JobStatusFailed)
+		}
+	}()
+
+	job.Status = constants.JobStatusRunning
+	if err := us.saveUserImportJob(job); err != nil {
+		global.Logger.Error("Failed to store user import job: " + err.Error())
+	}
+
+	rejected := us.validateUserImportRows(rows, actorRole)
+	if err := us.checkExistingImportUsers(rows, rejected); err != nil {
+		global.Logger.Error("Failed to check existing users of user import: " + err.Error())
+		us.finishUserImport(job, constants.JobStatusFailed)
+		return
+	}
+
+	for i, row := range rows {
+		errs := rejected[i]
+		if len(errs) == 0 && !job.DryRun {
+			if errResult := us.importUser(row.User); errResult != nil {
+				errs = append(errs, "user could not be created: "+response.GetMessage(errResult.ErrorCode))
+			}
+		}
+
+		if len(errs) > 0 {
+			job.Failed++
+			job.Errors = append(job.Errors, dto.UserImportRowErrorDto{
+				Row:      row.Line,
+				Email:    row.User.Email,
+				Username: row.User.Username,
+				Errors:   errs,
+			})
+		} else {
+			job.Succeeded++
+		}
+
+		job.Processed++
+		if job.Processed%userImportProgressInterval == 0 {
+			if err := us.saveUserImportJob(job); err != nil {
+				global.Logger.Warn("Failed to store user import progress: " + err.Error())
+			}
+		}
+	}
+
+	us.finishUserImport(job, constants.JobStatusCompleted)
+}
+
+// validateUserImportRows checks each row with the rules of dto.CreateUserDto, that its email and username
+// appear once in the file and that the actor may assign its role. It returns the errors by row index.
+func (us *userService) validateUserImportRows(rows []userImportRow, actorRole string) map[int][]string {
+	rejected := make(map[int][]string)
+	emails := make(map[string]int)
+	usernames := make(map[string]int)
+	assignable := make(map[string]bool)
+
+	for i, row := range rows {
+		errs := userImportValidationErrors(binding.Validator.ValidateStruct(&row.User))
+
+		if row.User.Email != "" {
+			if first, found := emails[row.User.Email]; found {
+				errs = append(errs, fmt.Sprintf("email is already used on row %d", rows[first].Line))
+			} else {
+				emails[row.User.Email] = i
+			}
+		}
+		if row.User.Username != "" {
+			if first, found := usernames[row.User.Username]; found {
+				errs = append(errs, fmt.Sprintf("username is already used on row %d", rows[first].Line))
+			} else {
+				usernames[row.User.Username] = i
+			}
+		}
+
+		if role := row.User.SystemRole; role != "" {
+			allowed, checked := assignable[role]
+			if !checked {
+				allowed = us.checkAssignableRole(actorRole, role) == nil
+				assignable[role] = allowed
+			}
+			if !allowed {
+				errs = append(errs, "system_role does not exist or cannot be assigned")
+			}
+		}
+
+		if len(errs) > 0 {
+			rejected[i] = errs
+		}
+	}
+	return rejected
+}
+
+// checkExistingImportUsers rejects the rows whose email or username is taken, with one query per batch of rows
+func (us *userService) checkExistingImportUsers(rows []userImportRow, rejected map[int][]string) error {
+	for start := 0; start < len(rows); start += userImportBatchSize {
+		batch := rows[start:min(start+userImportBatchSize, len(rows))]
+
+		emails := make([]string, 0, len(batch))
+		usernames := make([]string, 0, len(batch))
+		for _, row := range batch {
+			emails = append(emails, row.User.Email)
+			usernames = append(usernames, row.User.Username)
+		}
+		existingEmails, err := us.userRepo.GetExistingEmails(emails)
+		if err != nil {
+			return err
+		}
+		existingUsernames, err := us.userRepo.GetExistingUsernames(usernames)
+		if err != nil {
+			return err
+		}
+
+		for j, row := range batch {
+			if slices.Contains(existingEmails, row.User.Email) {
+				rejected[start+j] = append(rejected[start+j], "email already exists")
+			}
+			if slices.Contains(existingUsernames, row.User.Username) {
+				rejected[start+j] = append(rejected[start+j], "username already exists")
+			}
+		}
+	}
+	return nil
+}
+
+// importUser creates the user with a random password it must change, and emails it a link to choose one
+func (us *userService) importUser(user dto.CreateUserDto) *response.ServiceResult {
+	temporaryPassword, err := global.PasswordPolicy.GeneratePassword()
+	if err != nil {
+		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+
+	result := us.insertUser(dto.UserRequestDto{
+		Email:              user.Email,
+		Username:           user.Username,
+		FullName:           user.FullName,
+		Password:           temporaryPassword,
+		SystemRole:         user.SystemRole,
+		MustChangePassword: true,
+	})
+	if result.Error != nil {
+		return result
+	}
+
+	if err := us.sendAccountSetupEmail(result.Data.(uuid.UUID), user.Email, user.Username); err != nil {
+		global.Logger.Error("Failed to send account setup email: " + err.Error())
+	}
+	return nil
+}
+
+// sendAccountSetupEmail sends a password reset link valid as long as an invitation
+func (us *userService) sendAccountSetupEmail(userID uuid.UUID, email string, username string) error {
+	token, err := securetoken.Generate(32)
+	if err != nil {
+		return err
+	}
+
+	expiry := global.Config.Auth.InvitationExpiry
+	err = us.redisProvider.SetOneTimeToken(context.Background(), constants.TokenPurposePasswordReset, userID.String(), securetoken.Hash(token), userID.String(), expiry)
+	if err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s/reset-password?token=%s", global.Config.System.AppBaseURL, url.QueryEscape(token))
+	us.sendMailAsync(mail.Message{
+		To:      email,
+		Subject: "Your account has been created",
+		Body: fmt.Sprintf("Hello %s,\n\nAn account has been created for you. Use the link below to choose your password. It expires in %s and can only be used once.\n\n%s\n",
+			username, expiry, link),
+	})
+	return nil
+}
+
+func (us *userService) finishUserImport(job *userImportJob, status string) {
+	now := time.Now()
+	job.Status = status
+	job.FinishedAt = &now
+	if err := us.saveUserImportJob(job); err != nil {
+		global.Logger.Error("Failed to store user import job: " + err.Error())
+	}
+}
+
+func (us *userService) saveUserImportJob(job *userImportJob) error {
+	return us.redisProvider.SetJob(context.Background(), constants.JobKindUserImport, job.ID.String(), job, global.Config.Auth.UserImportJobTTL)
+}
+
+func (us *userService) getUserImportJob(id uuid.UUID) (*userImportJob, *response.ServiceResult) {
+	var job userImportJob
+	found, err := us.redisProvider.GetJob(context.Background(), constants.JobKindUserImport, id.String(), &job)
+	if err != nil {
+		global.Logger.Error("Failed to get user import job: " + err.Error())
+		return nil, response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+	if !found {
+		return nil, response.NewServiceErrorWithCode(404, response.ErrCodeImportJobNotFound)
+	}
+	return &job, nil
+}
+
+// userImportValidationErrors turns the validation errors of a row into messages naming the file columns
+func userImportValidationErrors(err error) []string {
+	if err == nil {
+		return nil
+	}
+
+	var fieldErrors validator.ValidationErrors
+	if !errors.As(err, &fieldErrors) {
+		return []string{err.Error()}
+	}
+
+	messages := make([]string, 0, len(fieldErrors))
+	for _, fieldError := range fieldErrors {
+		column := userImportColumnNames[fieldError.Field()]
+		switch fieldError.Tag() {
+		case "required":
+			messages = append(messages, column+" is required")
+		case "email":
+			messages = append(messages, column+" must be a valid email address")
+		case "max":
+			messages = append(messages, fmt.Sprintf("%s must be at most %s characters", column, fieldError.Param()))
+		default:
+			messages = append(messages, column+" is invalid")
+		}
+	}
+	return messages
+}
@@ -0,0 +1,94 @@
+package service
+
+import (
+	"app/global"
+	"app/internal/modules/user/constants"
+	"app/internal/modules/user/model"
+	"app/internal/third_party/mail"
+	"app/internal/third_party/redis"
+	"app/pkg/response"
+	"app/pkg/securetoken"
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// sendVerificationEmail mails a verification link for the user's current email.
+// The token is bound to that email so it cannot verify an address set later.
+func (us *userService) sendVerificationEmail(user *model.User) error {
+	token, err := securetoken.Generate(32)
+	if err != nil {
+		return err
+	}
+
+	expiry := global.Config.Auth.EmailVerificationExpiry
+	value := user.ID.String() + "|" + user.Email
+	err = us.redisProvider.SetOneTimeToken(context.Background(), constants.TokenPurposeEmailVerification, user.ID.String(), securetoken.Hash(token), value, expiry)
+	if err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s/verify-email?token=%s", global.Config.System.AppBaseURL, url.QueryEscape(token))
+	us.sendMailAsync(mail.Message{
+		To:      user.Email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf("Hello %s,\n\nPlease confirm your email address with the link below. It expires in %s.\n\n%s\n",
+			user.Username, expiry, link),
+	})
+	return nil
+}
+
+func (us *userService) VerifyEmail(token string) *response.ServiceResult {
+	value, err := us.redisProvider.ConsumeOneTimeToken(context.Background(), constants.TokenPurposeEmailVerification, securetoken.Hash(token))
+	if err != nil {
+		if errors.Is(err, redis.ErrOneTimeTokenNotFound) {
+			return response.NewServiceErrorWithCode(400, response.ErrCodeVerifyTokenInvalid)
+		}
+		global.Logger.Error("Failed to consume email verification token: " + err.Error())
+		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+
+	idValue, email, _ := strings.Cut(value, "|")
+	userID, err := uuid.Parse(idValue)
+	if err != nil {
+		return response.NewServiceErrorWithCode(400, response.ErrCodeVerifyTokenInvalid)
+	}
+	user := us.userRepo.GetUserByID(userID)
+	if user == nil || user.Email != email {
+		return response.NewServiceErrorWithCode(400, response.ErrCodeVerifyTokenInvalid)
+	}
+
+	if user.EmailVerifiedAt == nil {
+		now := time.Now()
+		if err := us.userRepo.SetEmailVerifiedAt(userID, &now); err != nil {
+			global.Logger.Error("Failed to mark email verified: " + err.Error())
+			return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+		}
+	}
+
+	return response.NewServiceResult(nil)
+}
+
+func (us *userService) ResendVerification(email string) *response.ServiceResult {
+	user, errResult := us.emailRecipient("email_verification_resend", email, global.Config.Auth.EmailVerificationResendPeriod, func(user *model.User) bool {
+		return user.EmailVerifiedAt == nil
+	})
+	if errResult != nil {
+		return errResult
+	}
+	if user == nil {
+		return response.NewServiceResult(nil)
+	}
+
+	if err := us.sendVerificationEmail(user); err != nil {
+		global.Logger.Error("Failed to send verification email: " + err.Error())
+		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+
+	return response.NewServiceResult(nil)
+}
@@ -0,0 +1,333 @@
+package service
+
+import (
+	"app/global"
+	"app/internal/modules/user/constants"
+	"app/internal/modules/user/dto"
+	"app/internal/modules/user/model"
+	"app/internal/third_party/redis"
+	"app/internal/third_party/webauthn"
+	"app/pkg/response"
+	"app/pkg/securetoken"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	gowebauthn "github.com/go-webauthn/webauthn/webauthn"
+	"github.com/google/uuid"
+)
+
+const defaultPasskeyName = "Passkey"
+
+var errPasskeyUserNotFound = errors.New("passkey user not found")
+
+// passkeyCeremony is kept server side under the hash of the challenge ID until the client answers.
+// UserID is the registering or authenticating user, unknown before a passwordless login completes,
+// and MFATokenID binds a second factor ceremony to the mfa token it was started with.
+type passkeyCeremony struct {
+	UserID     uuid.UUID            `json:"user_id"`
+	MFATokenID string               `json:"mfa_token_id,omitempty"`
+	Session    webauthn.SessionData `json:"session"`
+}
+
+func (us *userService) StartPasskeyRegistration(userID uuid.UUID) *response.ServiceResult {
+	user := us.userRepo.GetUserByID(userID)
+	if user == nil {
+		return response.NewServiceErrorWithCode(404, response.ErrCodeUserNotFound)
+	}
+	account, err := us.passkeyAccount(user)
+	if err != nil {
+		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+
+	options, session, err := us.webAuthnProvider.BeginRegistration(account)
+	if err != nil {
+		global.Logger.Error("Failed to start passkey registration: " + err.Error())
+		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+
+	return us.startPasskeyCeremony(constants.TokenPurposePasskeyRegister, userID.String(), passkeyCeremony{UserID: userID, Session: *session}, options)
+}
+
+func (us *userService) FinishPasskeyRegistration(userID uuid.UUID, req dto.PasskeyRegistrationRequestDto) *response.ServiceResult {
+	ceremony, errResult := us.consumePasskeyCeremony(constants.TokenPurposePasskeyRegister, req.ChallengeID)
+	if errResult != nil {
+		return errResult
+	}
+	if ceremony.UserID != userID {
+		return response.NewServiceErrorWithCode(400, response.ErrCodePasskeyInvalid)
+	}
+
+	user := us.userRepo.GetUserByID(userID)
+	if user == nil {
+		return response.NewServiceErrorWithCode(404, response.ErrCodeUserNotFound)
+	}
+	account, err := us.passkeyAccount(user)
+	if err != nil {
+		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+
+	credential, err := us.webAuthnProvider.FinishRegistration(account, ceremony.Session, req.Credential)
+	if err != nil {
+		global.Logger.Warn("Passkey registration failed: " + err.Error())
+		return response.NewServiceErrorWithCode(400, response.ErrCodePasskeyInvalid)
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		name = defaultPasskeyName
+	}
+	transports := make([]string, 0, len(credential.Transport))
+	for _, transport := range credential.Transport {
+		transports = append(transports, string(transport))
+	}
+	record := &model.WebAuthnCredential{
+		ID:              uuid.New(),
+		UserID:          userID,
+		Name:            name,
+		CredentialID:    credential.ID,
+		PublicKey:       credential.PublicKey,
+		AttestationType: credential.AttestationType,
+		AAGUID:          credential.Authenticator.AAGUID,
+		SignCount:       credential.Authenticator.SignCount,
+		Transports:      strings.Join(transports, ","),
+		UserVerified:    credential.Flags.UserVerified,
+		BackupEligible:  credential.Flags.BackupEligible,
+		BackupState:     credential.Flags.BackupState,
+	}
+	if err := us.webAuthnRepo.CreateCredential(record); err != nil {
+		global.Logger.Error("Failed to save passkey: " + err.Error())
+		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+
+	return response.NewServiceResult(record)
+}
+
+func (us *userService) GetPasskeys(userID uuid.UUID) *response.ServiceResult {
+	credentials, err := us.webAuthnRepo.GetCredentialsByUserID(userID)
+	if err != nil {
+		global.Logger.Error("Failed to get passkeys: " + err.Error())
+		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+
+	return response.NewServiceResult(credentials)
+}
+
+func (us *userService) DeletePasskey(userID uuid.UUID, id uuid.UUID) *response.ServiceResult {
+	deleted, err := us.webAuthnRepo.DeleteCredential(userID, id)
+	if err != nil {
+		global.Logger.Error("Failed to delete passkey: " + err.Error())
+		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+	if !deleted {
+		return response.NewServiceErrorWithCode(404, response.ErrCodePasskeyNotFound)
+	}
+
+	return response.NewServiceResult(nil)
+}
+
+// StartPasskeyLogin starts a passwordless login, the client chooses one of its discoverable credentials
+func (us *userService) StartPasskeyLogin() *response.ServiceResult {
+	options, session, err := us.webAuthnProvider.BeginLogin(nil)
+	if err != nil {
+		global.Logger.Error("Failed to start passkey login: " + err.Error())
+		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+
+	return us.startPasskeyCeremony(constants.TokenPurposePasskeyLogin, "", passkeyCeremony{Session: *session}, options)
+}
+
+// PasskeyLogin replaces both the password and the second factor, the passkey proves possession and user verification
+func (us *userService) PasskeyLogin(req dto.PasskeyLoginRequestDto, client dto.ClientInfo) *response.ServiceResult {
+	ceremony, errResult := us.consumePasskeyCeremony(constants.TokenPurposePasskeyLogin, req.ChallengeID)
+	if errResult != nil {
+		return errResult
+	}
+	if ceremony.UserID != uuid.Nil || ceremony.MFATokenID != "" {
+		return response.NewServiceErrorWithCode(400, response.ErrCodePasskeyInvalid)
+	}
+
+	var user *model.User
+	lookup := func(userID uuid.UUID) (*webauthn.Account, error) {
+		user = us.userRepo.GetUserByID(userID)
+		if user == nil || user.IsServiceAccount {
+			return nil, errPasskeyUserNotFound
+		}
+		return us.passkeyAccount(user)
+	}
+	_, credential, err := us.webAuthnProvider.FinishDiscoverableLogin(lookup, ceremony.Session, req.Credential)
+	if err != nil {
+		global.Logger.Warn("Passkey login failed: " + err.Error())
+		return response.NewServiceErrorWithCode(401, response.ErrCodePasskeyInvalid)
+	}
+	us.recordPasskeyUse(user.ID, credential)
+
+	if user.IsActive != nil && !*user.IsActive {
+		return response.NewServiceErrorWithCode(403, response.ErrCodeAccountLock)
+	}
+	if global.Config.Auth.RequireEmailVerification && user.EmailVerifiedAt == nil {
+		return response.NewServiceErrorWithCode(403, response.ErrCodeEmailNotVerified)
+	}
+	if user.MustChangePassword || global.PasswordPolicy.IsExpired(user.PasswordChangedAt, time.Now()) {
+		return us.startPasswordChange(user)
+	}
+
+	authResponse, err := us.generateAuthTokens(user, client)
+	if err != nil {
+		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+	return response.NewServiceResult(authResponse)
+}
+
+// StartPasskeyMFA starts a passkey ceremony as the second factor of a login, limited to the credentials of the user
+func (us *userService) StartPasskeyMFA(mfaToken string) *response.ServiceResult {
+	claims, user, errResult := us.pendingMFAUser(mfaToken)
+	if errResult != nil {
+		return errResult
+	}
+	account, err := us.passkeyAccount(user)
+	if err != nil {
+		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+	if len(account.Credentials) == 0 {
+		return response.NewServiceErrorWithCode(400, response.ErrCodeMFANotEnabled)
+	}
+
+	options, session, err := us.webAuthnProvider.BeginLogin(account)
+	if err != nil {
+		global.Logger.Error("Failed to start passkey MFA: " + err.Error())
+		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+
+	ceremony := passkeyCeremony{UserID: user.ID, MFATokenID: claims.ID, Session: *session}
+	return us.startPasskeyCeremony(constants.TokenPurposePasskeyLogin, claims.ID, ceremony, options)
+}
+
+func (us *userService) LoginPasskeyMFA(req dto.PasskeyMFARequestDto, client dto.ClientInfo) *response.ServiceResult {
+	claims, user, errResult := us.pendingMFAUser(req.MFAToken)
+	if errResult != nil {
+		return errResult
+	}
+	ceremony, errResult := us.consumePasskeyCeremony(constants.TokenPurposePasskeyLogin, req.ChallengeID)
+	if errResult != nil {
+		return errResult
+	}
+	if ceremony.UserID != user.ID || ceremony.MFATokenID != claims.ID {
+		return response.NewServiceErrorWithCode(400, response.ErrCodePasskeyInvalid)
+	}
+
+	account, err := us.passkeyAccount(user)
+	if err != nil {
+		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+	credential, err := us.webAuthnProvider.FinishLogin(account, ceremony.Session, req.Credential)
+	if err != nil {
+		global.Logger.Warn("Passkey MFA failed: " + err.Error())
+		return response.NewServiceErrorWithCode(401, response.ErrCodePasskeyInvalid)
+	}
+	us.recordPasskeyUse(user.ID, credential)
+
+	// The mfa token is single use
+	_ = us.redisProvider.DenyToken(context.Background(), claims.ID, time.Until(claims.ExpiresAt.Time))
+
+	authResponse, err := us.generateAuthTokens(user, client)
+	if err != nil {
+		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+	return response.NewServiceResult(authResponse)
+}
+
+// startPasskeyCeremony stores the session data of a ceremony under a random challenge ID, only the latest ceremony
+// of a subject stays valid. Without a subject the challenge ID is its own subject.
+func (us *userService) startPasskeyCeremony(purpose string, subject string, ceremony passkeyCeremony, options any) *response.ServiceResult {
+	challengeID, err := securetoken.Generate(32)
+	if err != nil {
+		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+	value, err := json.Marshal(ceremony)
+	if err != nil {
+		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+
+	challengeHash := securetoken.Hash(challengeID)
+	if subject == "" {
+		subject = challengeHash
+	}
+	err = us.redisProvider.SetOneTimeToken(context.Background(), purpose, subject, challengeHash, string(value), global.Config.WebAuthn.ChallengeExpiry)
+	if err != nil {
+		global.Logger.Error("Failed to store passkey challenge: " + err.Error())
+		return response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+
+	return response.NewServiceResult(&dto.PasskeyChallengeResponseDto{ChallengeID: challengeID, Options: options})
+}
+
+func (us *userService) consumePasskeyCeremony(purpose string, challengeID string) (*passkeyCeremony, *response.ServiceResult) {
+	value, err := us.redisProvider.ConsumeOneTimeToken(context.Background(), purpose, securetoken.Hash(challengeID))
+	if err != nil {
+		if errors.Is(err, redis.ErrOneTimeTokenNotFound) {
+			return nil, response.NewServiceErrorWithCode(400, response.ErrCodePasskeyInvalid)
+		}
+		global.Logger.Error("Failed to consume passkey challenge: " + err.Error())
+		return nil, response.NewServiceErrorWithCode(500, response.ErrCodeInternalError)
+	}
+
+	var ceremony passkeyCeremony
+	if err := json.Unmarshal([]byte(value), &ceremony); err != nil {
+		return nil, response.NewServiceErrorWithCode(400, response.ErrCodePasskeyInvalid)
+	}
+	return &ceremony, nil
+}
+
+// passkeyAccount builds the WebAuthn user entity of the user with the registered credentials
+func (us *userService) passkeyAccount(user *model.User) (*webauthn.Account, error) {
+	records, err := us.webAuthnRepo.GetCredentialsByUserID(user.ID)
+	if err != nil {
+		global.Logger.Error("Failed to get passkeys: " + err.Error())
+		return nil, err
+	}
+
+	credentials := make([]webauthn.Credential, 0, len(records))
+	for _, record := range records {
+		var transports []protocol.AuthenticatorTransport
+		for _, transport := range strings.Split(record.Transports, ",") {
+			if transport != "" {
+				transports = append(transports, protocol.AuthenticatorTransport(transport))
+			}
+		}
+		credentials = append(credentials, webauthn.Credential{
+			ID:              record.CredentialID,
+			PublicKey:       record.PublicKey,
+			AttestationType: record.AttestationType,
+			Transport:       transports,
+			Flags: gowebauthn.CredentialFlags{
+				UserPresent:    true,
+				UserVerified:   record.UserVerified,
+				BackupEligible: record.BackupEligible,
+				BackupState:    record.BackupState,
+			},
+			Authenticator: gowebauthn.Authenticator{
+				AAGUID:    record.AAGUID,
+				SignCount: record.SignCount,
+			},
+		})
+	}
+
+	return &webauthn.Account{
+		ID:          user.ID,
+		Name:        user.Username,
+		DisplayName: user.Username,
+		Credentials: credentials,
+	}, nil
+}
+
+// recordPasskeyUse stores the new signature counter so a replayed or cloned authenticator is detected next time
+func (us *userService) recordPasskeyUse(userID uuid.UUID, credential *webauthn.Credential) {
+	err := us.webAuthnRepo.UpdateCredentialUsage(userID, credential.ID, credential.Authenticator.SignCount, credential.Flags.BackupState)
+	if err != nil {
+		global.Logger.Error("Failed to update passkey usage: " + err.Error())
+	}
+}
@@ -0,0 +1,213 @@
+package service
+
+import (
+	"app/internal/modules/user/dto"
+	"app/internal/modules/user/model"
+	"app/internal/third_party/webauthn/webauthntest"
+	"app/pkg/response"
+	"app/pkg/totp"
+	"testing"
+	"time"
+
+	"github.com/go-webauthn/webauthn/protocol"
+)
+
+// registerPasskey registers the authenticator on the user through the service
+func registerPasskey(t *testing.T, us *userService, user *model.User, authenticator *webauthntest.Authenticator) {
+	t.Helper()
+	started := us.StartPasskeyRegistration(user.ID)
+	if started.Error != nil {
+		t.Fatalf("StartPasskeyRegistration() error = %v", started.Error)
+	}
+	challenge := started.Data.(*dto.PasskeyChallengeResponseDto)
+	credential, err := authenticator.Register(challenge.Options.(*protocol.CredentialCreation))
+	if err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+
+	result := us.FinishPasskeyRegistration(user.ID, dto.PasskeyRegistrationRequestDto{ChallengeID: challenge.ChallengeID, Credential: credential})
+	if result.Error != nil {
+		t.Fatalf("FinishPasskeyRegistration() error = %v", result.Error)
+	}
+}
+
+// startPasskeyMFA starts a passkey ceremony for the mfa token and answers it with the authenticator
+func startPasskeyMFA(t *testing.T, us *userService, token string, authenticator *webauthntest.Authenticator) (string, []byte) {
+	t.Helper()
+	started := us.StartPasskeyMFA(token)
+	if started.Error != nil {
+		t.Fatalf("StartPasskeyMFA() error = %v", started.Error)
+	}
+	challenge := started.Data.(*dto.PasskeyChallengeResponseDto)
+	credential, err := authenticator.Assert(challenge.Options.(*protocol.CredentialAssertion))
+	if err != nil {
+		t.Fatalf("Assert() error = %v", err)
+	}
+	return challenge.ChallengeID, credential
+}
+
+func assertPasskeyInvalid(t *testing.T, name string, result *response.ServiceResult) {
+	t.Helper()
+	if result.StatusCode != 400 || result.ErrorCode != response.ErrCodePasskeyInvalid {
+		t.Errorf("%s = %d/%d, want 400/%d", name, result.StatusCode, result.ErrorCode, response.ErrCodePasskeyInvalid)
+	}
+}
+
+func newPasskeyTest(t *testing.T) (*userService, *model.User, *webauthntest.Authenticator) {
+	t.Helper()
+	setupTestGlobals(t)
+	users := &fakeUserRepo{}
+	user := newTestUser(users)
+	us := newTestUserService(users)
+
+	authenticator, err := webauthntest.NewAuthenticator(testOrigin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return us, user, authenticator
+}
+
+func TestFinishPasskeyRegistrationRejectsReplayedChallenge(t *testing.T) {
+	us, user, authenticator := newPasskeyTest(t)
+
+	started := us.StartPasskeyRegistration(user.ID)
+	if started.Error != nil {
+		t.Fatalf("StartPasskeyRegistration() error = %v", started.Error)
+	}
+	challenge := started.Data.(*dto.PasskeyChallengeResponseDto)
+	credential, err := authenticator.Register(challenge.Options.(*protocol.CredentialCreation))
+	if err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	req := dto.PasskeyRegistrationRequestDto{ChallengeID: challenge.ChallengeID, Credential: credential}
+
+	if result := us.FinishPasskeyRegistration(user.ID, req); result.Error != nil {
+		t.Fatalf("FinishPasskeyRegistration() error = %v", result.Error)
+	}
+	assertPasskeyInvalid(t, "replayed FinishPasskeyRegistration()", us.FinishPasskeyRegistration(user.ID, req))
+	if credentials, _ := us.webAuthnRepo.GetCredentialsByUserID(user.ID); len(credentials) != 1 {
+		t.Errorf("stored %d passkeys, want 1", len(credentials))
+	}
+}
+
+func TestPasskeyLoginRejectsReplayedChallenge(t *testing.T) {
+	us, user, authenticator := newPasskeyTest(t)
+	registerPasskey(t, us, user, authenticator)
+
+	started := us.StartPasskeyLogin()
+	if started.Error != nil {
+		t.Fatalf("StartPasskeyLogin() error = %v", started.Error)
+	}
+	challenge := started.Data.(*dto.PasskeyChallengeResponseDto)
+	authenticator.SignCount = 1
+	credential, err := authenticator.Assert(challenge.Options.(*protocol.CredentialAssertion))
+	if err != nil {
+		t.Fatalf("Assert() error = %v", err)
+	}
+	req := dto.PasskeyLoginRequestDto{ChallengeID: challenge.ChallengeID, Credential: credential}
+
+	if result := us.PasskeyLogin(req, dto.ClientInfo{}); result.Error != nil {
+		t.Fatalf("PasskeyLogin() error = %v", result.Error)
+	}
+	assertPasskeyInvalid(t, "replayed PasskeyLogin()", us.PasskeyLogin(req, dto.ClientInfo{}))
+}
+
+func TestLoginPasskeyMFARejectsReplayedChallenge(t *testing.T) {
+	us, user, authenticator := newPasskeyTest(t)
+	registerPasskey(t, us, user, authenticator)
+
+	token := mfaToken(t, us, user)
+	authenticator.SignCount = 1
+	challengeID, credential := startPasskeyMFA(t, us, token, authenticator)
+	if result := us.LoginPasskeyMFA(dto.PasskeyMFARequestDto{MFAToken: token, ChallengeID: challengeID, Credential: credential}, dto.ClientInfo{}); result.Error != nil {
+		t.Fatalf("LoginPasskeyMFA() error = %v", result.Error)
+	}
+
+	// The first mfa token is spent, a new login must not be able to reuse the answered challenge
+	replay := dto.PasskeyMFARequestDto{MFAToken: mfaToken(t, us, user), ChallengeID: challengeID, Credential: credential}
+	assertPasskeyInvalid(t, "replayed LoginPasskeyMFA()", us.LoginPasskeyMFA(replay, dto.ClientInfo{}))
+}
+
+func TestLoginPasskeyMFARejectsCeremonyOfAnotherMFAToken(t *testing.T) {
+	us, user, authenticator := newPasskeyTest(t)
+	registerPasskey(t, us, user, authenticator)
+
+	first := mfaToken(t, us, user)
+	second := mfaToken(t, us, user)
+	authenticator.SignCount = 1
+	challengeID, credential := startPasskeyMFA(t, us, first, authenticator)
+
+	result := us.LoginPasskeyMFA(dto.PasskeyMFARequestDto{MFAToken: second, ChallengeID: challengeID, Credential: credential}, dto.ClientInfo{})
+	assertPasskeyInvalid(t, "LoginPasskeyMFA() with another mfa token", result)
+
+	// The rejected attempt consumed the ceremony, it is not left for the token it was started with
+	result = us.LoginPasskeyMFA(dto.PasskeyMFARequestDto{MFAToken: first, ChallengeID: challengeID, Credential: credential}, dto.ClientInfo{})
+	assertPasskeyInvalid(t, "LoginPasskeyMFA() after a rejected attempt", result)
+}
+
+func TestPasskeyLoginRequiresPasswordChange(t *testing.T) {
+	expired := time.Now().Add(-100 * 24 * time.Hour)
+	tests := []struct {
+		name   string
+		update func(user *model.User)
+	}{
+		{"must change password", func(user *model.User) { user.MustChangePassword = true }},
+		{"expired password", func(user *model.User) { user.PasswordChangedAt = &expired }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			us, user, authenticator := newPasskeyTest(t)
+			registerPasskey(t, us, user, authenticator)
+			tt.update(user)
+
+			started := us.StartPasskeyLogin()
+			if started.Error != nil {
+				t.Fatalf("StartPasskeyLogin() error = %v", started.Error)
+			}
+			challenge := started.Data.(*dto.PasskeyChallengeResponseDto)
+			authenticator.SignCount = 1
+			credential, err := authenticator.Assert(challenge.Options.(*protocol.CredentialAssertion))
+			if err != nil {
+				t.Fatalf("Assert() error = %v", err)
+			}
+
+			result := us.PasskeyLogin(dto.PasskeyLoginRequestDto{ChallengeID: challenge.ChallengeID, Credential: credential}, dto.ClientInfo{})
+			if result.Error != nil {
+				t.Fatalf("PasskeyLogin() error = %v", result.Error)
+			}
+			if auth := result.Data.(*dto.AuthResponseDto); !auth.PasswordChangeRequired || auth.Token != "" {
+				t.Errorf("PasskeyLogin() = %+v, want only a password change token", auth)
+			}
+		})
+	}
+}
+
+func TestLoginMFARejectsTOTPEnrollmentOfPasskeyUser(t *testing.T) {
+	for _, required := range []bool{false, true} {
+		us, user, authenticator := newPasskeyTest(t)
+		registerPasskey(t, us, user, authenticator)
+		mfaRepo := us.mfaRepo.(*fakeMFARepo)
+		if required {
+			user.SystemRole = "ADMIN"
+			mfaRepo.requiredRoles = []string{"ADMIN"}
+		}
+		// A TOTP enrollment the user started from their settings and never confirmed
+		enroll := us.EnrollMFA(user.ID)
+		if enroll.Error != nil {
+			t.Fatalf("EnrollMFA() error = %v", enroll.Error)
+		}
+		code, err := totp.GenerateCode(enroll.Data.(*dto.MFAEnrollResponseDto).Secret, time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// The password alone must not replace the passkey by a new TOTP factor
+		token := mfaToken(t, us, user)
+		assertServiceError(t, "StartLoginMFAEnrollment()", us.StartLoginMFAEnrollment(token), 400, response.ErrCodeMFANotEnabled)
+		assertServiceError(t, "LoginMFA()", us.LoginMFA(token, code, "", dto.ClientInfo{}), 400, response.ErrCodeMFANotEnabled)
+		if mfa := mfaRepo.GetUserMFA(user.ID); mfa.EnabledAt != nil {
+			t.Errorf("role requires MFA %v: pending TOTP enrollment was enabled", required)
+		}
+	}
+}
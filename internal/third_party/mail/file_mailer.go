@@ -0,0 +1,34 @@
+package mail
+
+import (
+	"app/pkg/setting"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+)
+
+var unsafeFileChars = regexp.MustCompile(`[^a-zA-Z0-9._@-]`)
+
+// FileMailer writes every message as an .eml file, for local development
+type FileMailer struct {
+	config setting.MailSetting
+}
+
+func NewFileMailer(config setting.MailSetting) *FileMailer {
+	return &FileMailer{config: config}
+}
+
+func (m *FileMailer) Send(ctx context.Context, msg Message) error {
+	if err := os.MkdirAll(m.config.FileDir, 0o755); err != nil {
+		return fmt.Errorf("failed to create mail directory: %w", err)
+	}
+
+	name := fmt.Sprintf("%d-%s.eml", time.Now().UnixNano(), unsafeFileChars.ReplaceAllString(msg.To, "_"))
+	if err := os.WriteFile(filepath.Join(m.config.FileDir, name), buildMessage(m.config.From, msg), 0o644); err != nil {
+		return fmt.Errorf("failed to write mail: %w", err)
+	}
+	return nil
+}
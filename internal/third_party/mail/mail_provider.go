@@ -0,0 +1,40 @@
+package mail
+
+import (
+	"app/global"
+	"context"
+	"fmt"
+)
+
+// Mail drivers
+const (
+	DriverSMTP   = "smtp"
+	DriverFile   = "file"
+	DriverMemory = "memory"
+)
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers plain text emails
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// NewMailer returns the mailer selected by MAIL_DRIVER, an unknown driver is an error rather than mails
+// silently going elsewhere
+func NewMailer() (Mailer, error) {
+	config := global.Config.Mail
+	switch config.Driver {
+	case DriverSMTP:
+		return NewSMTPMailer(config), nil
+	case DriverFile:
+		return NewFileMailer(config), nil
+	case DriverMemory:
+		return NewMemoryMailer(), nil
+	}
+	return nil, fmt.Errorf("unknown mail driver %q, expected %s, %s or %s", config.Driver, DriverSMTP, DriverFile, DriverMemory)
+}
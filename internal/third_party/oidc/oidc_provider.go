@@ -0,0 +1,145 @@
+package oidc
+
+import (
+	"app/global"
+	"app/pkg/setting"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	gooidc "github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+var (
+	ErrProviderNotFound = errors.New("oidc provider not configured")
+	ErrNonceMismatch    = errors.New("oidc id token nonce mismatch")
+)
+
+// Claims are the ID token claims used to find or provision the local account
+type Claims struct {
+	Subject           string `json:"sub"`
+	Email             string `json:"email"`
+	EmailVerified     bool   `json:"email_verified"`
+	Name              string `json:"name"`
+	PreferredUsername string `json:"preferred_username"`
+	Nonce             string `json:"nonce"`
+}
+
+// OIDCProvider is the relying party of every configured identity provider.
+// Discovery runs on first use so an unreachable issuer does not prevent startup.
+type OIDCProvider struct {
+	redirectURL string
+	names       []string
+	settings    map[string]setting.OIDCProviderSetting
+	mu          sync.Mutex
+	clients     map[string]*client
+}
+
+type client struct {
+	oauth2   oauth2.Config
+	verifier *gooidc.IDTokenVerifier
+}
+
+func NewOIDCProvider() *OIDCProvider {
+	names := make([]string, 0, len(global.Config.OIDC.Providers))
+	settings := make(map[string]setting.OIDCProviderSetting)
+	for _, provider := range global.Config.OIDC.Providers {
+		names = append(names, provider.Name)
+		settings[provider.Name] = provider
+	}
+	return &OIDCProvider{
+		redirectURL: global.Config.OIDC.RedirectURL,
+		names:       names,
+		settings:    settings,
+		clients:     make(map[string]*client),
+	}
+}
+
+// Names returns the configured provider names
+func (p *OIDCProvider) Names() []string {
+	return p.names
+}
+
+// Setting returns the configuration of a provider
+func (p *OIDCProvider) Setting(name string) (setting.OIDCProviderSetting, bool) {
+	provider, ok := p.settings[name]
+	return provider, ok
+}
+
+// AuthCodeURL builds the authorization request with a PKCE S256 challenge and a nonce bound to the ID token
+func (p *OIDCProvider) AuthCodeURL(ctx context.Context, name string, state string, nonce string, codeVerifier string) (string, error) {
+	c, err := p.client(ctx, name)
+	if err != nil {
+		return "", err
+	}
+	return c.oauth2.AuthCodeURL(state, gooidc.Nonce(nonce), oauth2.S256ChallengeOption(codeVerifier)), nil
+}
+
+// Exchange redeems the authorization code and returns the claims of the verified ID token
+func (p *OIDCProvider) Exchange(ctx context.Context, name string, code string, codeVerifier string, nonce string) (*Claims, error) {
+	c, err := p.client(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := c.oauth2.Exchange(ctx, code, oauth2.VerifierOption(codeVerifier))
+	if err != nil {
+		return nil, fmt.Errorf("oidc code exchange failed: %w", err)
+	}
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return nil, errors.New("oidc token response has no id_token")
+	}
+
+	// Checks signature, issuer, audience and expiry
+	idToken, err := c.verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return nil, fmt.Errorf("oidc id token invalid: %w", err)
+	}
+
+	var claims Claims
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, err
+	}
+	if claims.Nonce != nonce {
+		return nil, ErrNonceMismatch
+	}
+	return &claims, nil
+}
+
+// GenerateCodeVerifier returns a random PKCE code verifier
+func GenerateCodeVerifier() string {
+	return oauth2.GenerateVerifier()
+}
+
+func (p *OIDCProvider) client(ctx context.Context, name string) (*client, error) {
+	config, ok := p.settings[name]
+	if !ok {
+		return nil, ErrProviderNotFound
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if c, ok := p.clients[name]; ok {
+		return c, nil
+	}
+
+	provider, err := gooidc.NewProvider(ctx, config.Issuer)
+	if err != nil {
+		return nil, fmt.Errorf("oidc discovery for %s failed: %w", name, err)
+	}
+	c := &client{
+		oauth2: oauth2.Config{
+			ClientID:     config.ClientID,
+			ClientSecret: config.ClientSecret,
+			Endpoint:     provider.Endpoint(),
+			RedirectURL:  p.redirectURL,
+			Scopes:       config.Scopes,
+		},
+		verifier: provider.Verifier(&gooidc.Config{ClientID: config.ClientID}),
+	}
+	p.clients[name] = c
+	return c, nil
+}
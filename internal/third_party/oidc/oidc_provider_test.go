@@ -0,0 +1,87 @@
+package oidc_test
+
+import (
+	"app/global"
+	"app/internal/third_party/oidc"
+	"app/internal/third_party/oidc/oidctest"
+	"app/pkg/setting"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+const testProvider = "test"
+
+func newTestProvider(t *testing.T) (*oidc.OIDCProvider, *oidctest.Issuer) {
+	t.Helper()
+	issuer, err := oidctest.NewIssuer("app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(issuer.Close)
+
+	global.Config.OIDC = setting.OIDCSetting{
+		RedirectURL: "https://app.example.test/oidc/callback",
+		Providers: []setting.OIDCProviderSetting{
+			{Name: testProvider, Issuer: issuer.URL, ClientID: "app", Scopes: []string{"openid", "email"}},
+		},
+	}
+	return oidc.NewOIDCProvider(), issuer
+}
+
+// authorize runs the authorization request of the provider through the issuer and returns the code
+func authorize(t *testing.T, provider *oidc.OIDCProvider, issuer *oidctest.Issuer, nonce string, verifier string, claims map[string]any) string {
+	t.Helper()
+	authorizationURL, err := provider.AuthCodeURL(context.Background(), testProvider, "state", nonce, verifier)
+	if err != nil {
+		t.Fatalf("AuthCodeURL() error = %v", err)
+	}
+	code, state, err := issuer.Authorize(authorizationURL, claims)
+	if err != nil {
+		t.Fatalf("Authorize() error = %v", err)
+	}
+	if state != "state" {
+		t.Fatalf("state = %q, want it passed through", state)
+	}
+	return code
+}
+
+func TestExchangeReturnsVerifiedClaims(t *testing.T) {
+	provider, issuer := newTestProvider(t)
+	verifier := oidc.GenerateCodeVerifier()
+	code := authorize(t, provider, issuer, "nonce", verifier, map[string]any{"sub": "alice", "email": "alice@example.test", "email_verified": true})
+
+	claims, err := provider.Exchange(context.Background(), testProvider, code, verifier, "nonce")
+	if err != nil {
+		t.Fatalf("Exchange() error = %v", err)
+	}
+	if claims.Subject != "alice" || claims.Email != "alice@example.test" || !claims.EmailVerified {
+		t.Errorf("Exchange() = %+v", claims)
+	}
+
+	// The code is single use
+	if _, err := provider.Exchange(context.Background(), testProvider, code, verifier, "nonce"); err == nil {
+		t.Error("Exchange() with a redeemed code succeeded")
+	}
+}
+
+func TestExchangeRejectsNonceMismatch(t *testing.T) {
+	provider, issuer := newTestProvider(t)
+	verifier := oidc.GenerateCodeVerifier()
+	code := authorize(t, provider, issuer, "nonce", verifier, map[string]any{"sub": "alice", "nonce": "other"})
+
+	if _, err := provider.Exchange(context.Background(), testProvider, code, verifier, "nonce"); !errors.Is(err, oidc.ErrNonceMismatch) {
+		t.Errorf("Exchange() error = %v, want ErrNonceMismatch", err)
+	}
+}
+
+func TestExchangeRejectsBadCodeVerifier(t *testing.T) {
+	provider, issuer := newTestProvider(t)
+	code := authorize(t, provider, issuer, "nonce", oidc.GenerateCodeVerifier(), map[string]any{"sub": "alice"})
+
+	_, err := provider.Exchange(context.Background(), testProvider, code, oidc.GenerateCodeVerifier(), "nonce")
+	if err == nil || !strings.Contains(err.Error(), "invalid_grant") {
+		t.Errorf("Exchange() error = %v, want invalid_grant", err)
+	}
+}
@@ -0,0 +1,36 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func jobKey(kind string, id string) string {
+	return fmt.Sprintf("job:%s:%s", kind, id)
+}
+
+// SetJob stores the state of a background job as JSON, any instance can then report its progress
+func (r *RedisProvider) SetJob(ctx context.Context, kind string, id string, job any, expiration time.Duration) error {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	return r.client.Set(ctx, jobKey(kind, id), data, expiration).Err()
+}
+
+// GetJob loads the state of a background job into job, found is false when it does not exist or has expired
+func (r *RedisProvider) GetJob(ctx context.Context, kind string, id string, job any) (bool, error) {
+	data, err := r.client.Get(ctx, jobKey(kind, id)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, json.Unmarshal(data, job)
+}
@@ -0,0 +1,58 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var ErrOneTimeTokenNotFound = errors.New("one-time token not found or already used")
+
+// setOneTimeTokenScript stores a token and drops the previous token issued to the same subject
+var setOneTimeTokenScript = redis.NewScript(`
+local previous = redis.call('GET', KEYS[2])
+if previous then
+	redis.call('DEL', previous)
+end
+redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2])
+redis.call('SET', KEYS[2], KEYS[1], 'PX', ARGV[2])
+return 1
+`)
+
+func oneTimeTokenKey(purpose string, tokenHash string) string {
+	return fmt.Sprintf("one_time_token:%s:%s", purpose, tokenHash)
+}
+
+func oneTimeTokenSubjectKey(purpose string, subject string) string {
+	return fmt.Sprintf("one_time_token_subject:%s:%s", purpose, subject)
+}
+
+// SetOneTimeToken stores value under the token hash for the given purpose (password reset, email verification...).
+// Only the latest token of a subject stays valid.
+func (r *RedisProvider) SetOneTimeToken(ctx context.Context, purpose string, subject string, tokenHash string, value string, expiration time.Duration) error {
+	return setOneTimeTokenScript.Run(ctx, r.client,
+		[]string{oneTimeTokenKey(purpose, tokenHash), oneTimeTokenSubjectKey(purpose, subject)},
+		value, expiration.Milliseconds(),
+	).Err()
+}
+
+// GetOneTimeToken returns the value stored under the token hash without using the token up
+func (r *RedisProvider) GetOneTimeToken(ctx context.Context, purpose string, tokenHash string) (string, error) {
+	value, err := r.client.Get(ctx, oneTimeTokenKey(purpose, tokenHash)).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", ErrOneTimeTokenNotFound
+	}
+	return value, err
+}
+
+// ConsumeOneTimeToken returns the value stored under the token hash and deletes it, so a token works only once
+func (r *RedisProvider) ConsumeOneTimeToken(ctx context.Context, purpose string, tokenHash string) (string, error) {
+	value, err := r.client.GetDel(ctx, oneTimeTokenKey(purpose, tokenHash)).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", ErrOneTimeTokenNotFound
+	}
+	return value, err
+}
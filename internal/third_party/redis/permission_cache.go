@@ -0,0 +1,56 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func rolePermissionsKey(role string) string {
+	return fmt.Sprintf("role_permissions:%s", role)
+}
+
+// GetRolePermissions returns the cached effective permissions of a role, found is false on a cache miss
+func (r *RedisProvider) GetRolePermissions(ctx context.Context, role string) ([]string, bool, error) {
+	data, err := r.client.Get(ctx, rolePermissionsKey(role)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	var permissions []string
+	if err := json.Unmarshal(data, &permissions); err != nil {
+		return nil, false, err
+	}
+	return permissions, true, nil
+}
+
+// SetRolePermissions caches the effective permissions of a role, an empty list is cached too
+func (r *RedisProvider) SetRolePermissions(ctx context.Context, role string, permissions []string, expiration time.Duration) error {
+	if permissions == nil {
+		permissions = []string{}
+	}
+	data, err := json.Marshal(permissions)
+	if err != nil {
+		return err
+	}
+	return r.client.Set(ctx, rolePermissionsKey(role), data, expiration).Err()
+}
+
+// DeleteRolePermissions drops the cached permissions of the given roles
+func (r *RedisProvider) DeleteRolePermissions(ctx context.Context, roles ...string) error {
+	if len(roles) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(roles))
+	for _, role := range roles {
+		keys = append(keys, rolePermissionsKey(role))
+	}
+	return r.client.Del(ctx, keys...).Err()
+}
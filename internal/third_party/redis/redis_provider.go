@@ -22,10 +22,31 @@ func (r *RedisProvider) Set(ctx context.Context, key string, value interface{},
 	return r.client.Set(ctx, key, value, expiration).Err()
 }
 
+// SetNX sets the key only if it does not exist yet and reports whether it was set
+func (r *RedisProvider) SetNX(ctx context.Context, key string, value interface{}, expiration time.Duration) (bool, error) {
+	return r.client.SetNX(ctx, key, value, expiration).Result()
+}
+
+// Incr increments a counter, the expiration is only set when the counter is created
+func (r *RedisProvider) Incr(ctx context.Context, key string, expiration time.Duration) (int64, error) {
+	pipe := r.client.TxPipeline()
+	incr := pipe.Incr(ctx, key)
+	pipe.ExpireNX(ctx, key, expiration)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+	return incr.Val(), nil
+}
+
 func (r *RedisProvider) Get(ctx context.Context, key string) (string, error) {
 	return r.client.Get(ctx, key).Result()
 }
 
+// TTL returns the remaining time to live of the key, a negative duration when the key is missing or persistent
+func (r *RedisProvider) TTL(ctx context.Context, key string) (time.Duration, error) {
+	return r.client.TTL(ctx, key).Result()
+}
+
 func (r *RedisProvider) Del(ctx context.Context, keys ...string) error {
 	return r.client.Del(ctx, keys...).Err()
 }
@@ -0,0 +1,79 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	ErrRefreshFamilyNotFound = errors.New("refresh token family not found")
+	ErrRefreshTokenReused    = errors.New("refresh token reused")
+)
+
+// rotateRefreshFamilyScript swaps the current token id of a family atomically.
+// Presenting a token id that is no longer current revokes the whole family.
+var rotateRefreshFamilyScript = redis.NewScript(`
+local current = redis.call('HGET', KEYS[1], 'token_id')
+if not current then
+	return 0
+end
+if current ~= ARGV[1] then
+	redis.call('DEL', KEYS[1])
+	return -1
+end
+redis.call('HSET', KEYS[1], 'token_id', ARGV[2])
+redis.call('PEXPIRE', KEYS[1], ARGV[3])
+return 1
+`)
+
+func refreshFamilyKey(familyID string) string {
+	return fmt.Sprintf("refresh_family:%s", familyID)
+}
+
+// CreateRefreshFamily starts a new refresh token family whose current token is tokenID
+func (r *RedisProvider) CreateRefreshFamily(ctx context.Context, familyID string, userID string, tokenID string, expiration time.Duration) error {
+	key := refreshFamilyKey(familyID)
+	pipe := r.client.TxPipeline()
+	pipe.HSet(ctx, key, "user_id", userID, "token_id", tokenID)
+	pipe.Expire(ctx, key, expiration)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// RotateRefreshFamily replaces oldTokenID with newTokenID as the current token of the family.
+// It returns ErrRefreshTokenReused (and revokes the family) when oldTokenID was already rotated.
+func (r *RedisProvider) RotateRefreshFamily(ctx context.Context, familyID string, oldTokenID string, newTokenID string, expiration time.Duration) error {
+	result, err := rotateRefreshFamilyScript.Run(ctx, r.client,
+		[]string{refreshFamilyKey(familyID)},
+		oldTokenID, newTokenID, expiration.Milliseconds(),
+	).Int()
+	if err != nil {
+		return err
+	}
+
+	switch result {
+	case 0:
+		return ErrRefreshFamilyNotFound
+	case -1:
+		return ErrRefreshTokenReused
+	}
+	return nil
+}
+
+// RevokeRefreshFamily invalidates every refresh token of the family
+func (r *RedisProvider) RevokeRefreshFamily(ctx context.Context, familyID string) error {
+	return r.client.Del(ctx, refreshFamilyKey(familyID)).Err()
+}
+
+// RefreshFamilyExists reports whether the family is still alive, i.e. its session was not revoked and has not expired
+func (r *RedisProvider) RefreshFamilyExists(ctx context.Context, familyID string) (bool, error) {
+	n, err := r.client.Exists(ctx, refreshFamilyKey(familyID)).Result()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
@@ -0,0 +1,62 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func deniedTokenKey(tokenID string) string {
+	return fmt.Sprintf("token_denylist:%s", tokenID)
+}
+
+func tokenVersionKey(userID string) string {
+	return fmt.Sprintf("token_version:%s", userID)
+}
+
+// DenyToken puts a token id on the denylist until the token would have expired anyway
+func (r *RedisProvider) DenyToken(ctx context.Context, tokenID string, expiration time.Duration) error {
+	if expiration <= 0 {
+		return nil
+	}
+	return r.client.Set(ctx, deniedTokenKey(tokenID), 1, expiration).Err()
+}
+
+// ConsumeToken denies a single use token and reports whether this call did it, so only one concurrent use succeeds
+func (r *RedisProvider) ConsumeToken(ctx context.Context, tokenID string, expiration time.Duration) (bool, error) {
+	if expiration <= 0 {
+		return false, nil
+	}
+	return r.client.SetNX(ctx, deniedTokenKey(tokenID), 1, expiration).Result()
+}
+
+// ReleaseToken takes a token consumed by ConsumeToken off the denylist, for a use that failed before taking effect
+func (r *RedisProvider) ReleaseToken(ctx context.Context, tokenID string) error {
+	return r.client.Del(ctx, deniedTokenKey(tokenID)).Err()
+}
+
+// IsTokenDenied reports whether a token id was revoked through DenyToken
+func (r *RedisProvider) IsTokenDenied(ctx context.Context, tokenID string) (bool, error) {
+	n, err := r.client.Exists(ctx, deniedTokenKey(tokenID)).Result()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// GetTokenVersion returns the user's current token version, 0 when it was never bumped
+func (r *RedisProvider) GetTokenVersion(ctx context.Context, userID string) (int64, error) {
+	version, err := r.client.Get(ctx, tokenVersionKey(userID)).Int64()
+	if errors.Is(err, redis.Nil) {
+		return 0, nil
+	}
+	return version, err
+}
+
+// BumpTokenVersion increments the user's token version, revoking every token issued before
+func (r *RedisProvider) BumpTokenVersion(ctx context.Context, userID string) (int64, error) {
+	return r.client.Incr(ctx, tokenVersionKey(userID)).Result()
+}
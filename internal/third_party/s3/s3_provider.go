@@ -4,6 +4,7 @@ import (
 	"app/global"
 	"context"
 	"fmt"
+	"io"
 	"mime/multipart"
 	"net/url"
 	"time"
@@ -55,6 +56,20 @@ func (s *S3Provider) UploadFile(ctx context.Context, file *multipart.FileHeader,
 	return fmt.Sprintf("%s://%s/%s/%s", protocol, global.Config.MinIO.Endpoint, s.bucketName, objectName), nil
 }
 
+// UploadStream uploads an object from a reader, a size of -1 uploads an object of unknown size in parts so it
+// is never held in memory
+func (s *S3Provider) UploadStream(ctx context.Context, objectName string, reader io.Reader, size int64, contentType string) error {
+	info, err := s.client.PutObject(ctx, s.bucketName, objectName, reader, size, minio.PutObjectOptions{
+		ContentType: contentType,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to upload stream: %w", err)
+	}
+
+	global.Logger.Info(fmt.Sprintf("Successfully uploaded %s of size %d", objectName, info.Size))
+	return nil
+}
+
 // GetPresignedURL generates a presigned URL for a file
 func (s *S3Provider) GetPresignedURL(ctx context.Context, objectName string, expiry time.Duration) (string, error) {
 	reqParams := make(url.Values)
@@ -64,3 +79,11 @@ func (s *S3Provider) GetPresignedURL(ctx context.Context, objectName string, exp
 	}
 	return presignedURL.String(), nil
 }
+
+// DeleteFile deletes an object, deleting a missing object is not an error
+func (s *S3Provider) DeleteFile(ctx context.Context, objectName string) error {
+	if err := s.client.RemoveObject(ctx, s.bucketName, objectName, minio.RemoveObjectOptions{}); err != nil {
+		return fmt.Errorf("failed to delete file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+package webauthn
+
+import (
+	"app/global"
+	"errors"
+	"fmt"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	gowebauthn "github.com/go-webauthn/webauthn/webauthn"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrNotConfigured = errors.New("webauthn relying party not configured")
+	ErrCloneWarning  = errors.New("webauthn signature counter did not increase, the authenticator may be cloned")
+)
+
+type (
+	Credential  = gowebauthn.Credential
+	SessionData = gowebauthn.SessionData
+)
+
+// Account is the WebAuthn user entity of a local user, the user handle is the raw user ID
+type Account struct {
+	ID          uuid.UUID
+	Name        string
+	DisplayName string
+	Credentials []Credential
+}
+
+func (a *Account) WebAuthnID() []byte {
+	return a.ID[:]
+}
+
+func (a *Account) WebAuthnName() string {
+	return a.Name
+}
+
+func (a *Account) WebAuthnDisplayName() string {
+	return a.DisplayName
+}
+
+func (a *Account) WebAuthnCredentials() []Credential {
+	return a.Credentials
+}
+
+// WebAuthnProvider runs the registration and authentication ceremonies of the relying party.
+// An invalid configuration is reported when a ceremony starts so it does not prevent startup.
+type WebAuthnProvider struct {
+	webauthn *gowebauthn.WebAuthn
+	err      error
+}
+
+func NewWebAuthnProvider() *WebAuthnProvider {
+	config := global.Config.WebAuthn
+	webauthn, err := gowebauthn.New(&gowebauthn.Config{
+		RPID:          config.RPID,
+		RPDisplayName: config.RPDisplayName,
+		RPOrigins:     config.RPOrigins,
+	})
+	if err != nil {
+		err = fmt.Errorf("%w: %v", ErrNotConfigured, err)
+	}
+	return &WebAuthnProvider{webauthn: webauthn, err: err}
+}
+
+// BeginRegistration returns the creation options for a new credential, excluding the ones the account already has
+func (p *WebAuthnProvider) BeginRegistration(account *Account) (*protocol.CredentialCreation, *SessionData, error) {
+	if p.err != nil {
+		return nil, nil, p.err
+	}
+	return p.webauthn.BeginRegistration(account,
+		gowebauthn.WithExclusions(gowebauthn.Credentials(account.Credentials).CredentialDescriptors()),
+		gowebauthn.WithResidentKeyRequirement(protocol.ResidentKeyRequirementPreferred),
+	)
+}
+
+// FinishRegistration verifies the attestation response of the client and returns the new credential
+func (p *WebAuthnProvider) FinishRegistration(account *Account, session SessionData, response []byte) (*Credential, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	parsed, err := protocol.ParseCredentialCreationResponseBytes(response)
+	if err != nil {
+		return nil, err
+	}
+	return p.webauthn.CreateCredential(account, session, parsed)
+}
+
+// BeginLogin returns the request options for the credentials of the account. Without an account the
+// client picks a discoverable credential (passkey) and user verification is required, as it replaces the password.
+func (p *WebAuthnProvider) BeginLogin(account *Account) (*protocol.CredentialAssertion, *SessionData, error) {
+	if p.err != nil {
+		return nil, nil, p.err
+	}
+	if account == nil {
+		return p.webauthn.BeginDiscoverableLogin(gowebauthn.WithUserVerification(protocol.VerificationRequired))
+	}
+	return p.webauthn.BeginLogin(account)
+}
+
+// FinishLogin verifies the assertion of one of the credentials of the account
+func (p *WebAuthnProvider) FinishLogin(account *Account, session SessionData, response []byte) (*Credential, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	parsed, err := protocol.ParseCredentialRequestResponseBytes(response)
+	if err != nil {
+		return nil, err
+	}
+	credential, err := p.webauthn.ValidateLogin(account, session, parsed)
+	if err != nil {
+		return nil, err
+	}
+	if credential.Authenticator.CloneWarning {
+		return nil, ErrCloneWarning
+	}
+	return credential, nil
+}
+
+// FinishDiscoverableLogin verifies the assertion of a discoverable credential, lookup resolves the account of its user handle
+func (p *WebAuthnProvider) FinishDiscoverableLogin(lookup func(userID uuid.UUID) (*Account, error), session SessionData, response []byte) (*Account, *Credential, error) {
+	if p.err != nil {
+		return nil, nil, p.err
+	}
+	parsed, err := protocol.ParseCredentialRequestResponseBytes(response)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var account *Account
+	handler := func(rawID, userHandle []byte) (gowebauthn.User, error) {
+		userID, err := uuid.FromBytes(userHandle)
+		if err != nil {
+			return nil, err
+		}
+		account, err = lookup(userID)
+		if err != nil {
+			return nil, err
+		}
+		return account, nil
+	}
+	credential, err := p.webauthn.ValidateDiscoverableLogin(handler, session, parsed)
+	if err != nil {
+		return nil, nil, err
+	}
+	if credential.Authenticator.CloneWarning {
+		return nil, nil, ErrCloneWarning
+	}
+	return account, credential, nil
+}
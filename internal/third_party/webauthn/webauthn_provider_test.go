@@ -0,0 +1,131 @@
+package webauthn_test
+
+import (
+	"app/global"
+	"app/internal/third_party/webauthn"
+	"app/internal/third_party/webauthn/webauthntest"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+const testOrigin = "https://app.example.test"
+
+func newTestProvider(t *testing.T) *webauthn.WebAuthnProvider {
+	t.Helper()
+	global.Config.WebAuthn.RPID = "app.example.test"
+	global.Config.WebAuthn.RPDisplayName = "App"
+	global.Config.WebAuthn.RPOrigins = []string{testOrigin}
+	return webauthn.NewWebAuthnProvider()
+}
+
+// registerAccount registers the authenticator on a new account and returns the account with its credential
+func registerAccount(t *testing.T, provider *webauthn.WebAuthnProvider, authenticator *webauthntest.Authenticator) *webauthn.Account {
+	t.Helper()
+	account := &webauthn.Account{ID: uuid.New(), Name: "alice", DisplayName: "alice"}
+
+	options, session, err := provider.BeginRegistration(account)
+	if err != nil {
+		t.Fatalf("BeginRegistration() error = %v", err)
+	}
+	response, err := authenticator.Register(options)
+	if err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	credential, err := provider.FinishRegistration(account, *session, response)
+	if err != nil {
+		t.Fatalf("FinishRegistration() error = %v", err)
+	}
+
+	account.Credentials = append(account.Credentials, *credential)
+	return account
+}
+
+// login runs a login ceremony of the account and stores the new counter like the service does
+func login(t *testing.T, provider *webauthn.WebAuthnProvider, authenticator *webauthntest.Authenticator, account *webauthn.Account) error {
+	t.Helper()
+	options, session, err := provider.BeginLogin(account)
+	if err != nil {
+		t.Fatalf("BeginLogin() error = %v", err)
+	}
+	response, err := authenticator.Assert(options)
+	if err != nil {
+		t.Fatalf("Assert() error = %v", err)
+	}
+	credential, err := provider.FinishLogin(account, *session, response)
+	if err != nil {
+		return err
+	}
+	account.Credentials[0].Authenticator.SignCount = credential.Authenticator.SignCount
+	return nil
+}
+
+func TestFinishLoginAcceptsIncreasingSignCount(t *testing.T) {
+	provider := newTestProvider(t)
+	authenticator, err := webauthntest.NewAuthenticator(testOrigin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	account := registerAccount(t, provider, authenticator)
+
+	for _, count := range []uint32{1, 2, 10} {
+		authenticator.SignCount = count
+		if err := login(t, provider, authenticator, account); err != nil {
+			t.Fatalf("login with sign count %d: error = %v", count, err)
+		}
+	}
+	if got := account.Credentials[0].Authenticator.SignCount; got != 10 {
+		t.Errorf("stored sign count = %d, want 10", got)
+	}
+}
+
+func TestFinishLoginRejectsSignCountRegression(t *testing.T) {
+	provider := newTestProvider(t)
+	authenticator, err := webauthntest.NewAuthenticator(testOrigin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	account := registerAccount(t, provider, authenticator)
+
+	authenticator.SignCount = 5
+	if err := login(t, provider, authenticator, account); err != nil {
+		t.Fatalf("first login: error = %v", err)
+	}
+
+	for _, count := range []uint32{5, 3} {
+		authenticator.SignCount = count
+		if err := login(t, provider, authenticator, account); !errors.Is(err, webauthn.ErrCloneWarning) {
+			t.Errorf("login with sign count %d: error = %v, want ErrCloneWarning", count, err)
+		}
+	}
+}
+
+func TestFinishDiscoverableLoginRejectsSignCountRegression(t *testing.T) {
+	provider := newTestProvider(t)
+	authenticator, err := webauthntest.NewAuthenticator(testOrigin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	account := registerAccount(t, provider, authenticator)
+	account.Credentials[0].Authenticator.SignCount = 7
+	lookup := func(userID uuid.UUID) (*webauthn.Account, error) {
+		if userID != account.ID {
+			return nil, errors.New("unknown user")
+		}
+		return account, nil
+	}
+
+	options, session, err := provider.BeginLogin(nil)
+	if err != nil {
+		t.Fatalf("BeginLogin() error = %v", err)
+	}
+	authenticator.SignCount = 6
+	response, err := authenticator.Assert(options)
+	if err != nil {
+		t.Fatalf("Assert() error = %v", err)
+	}
+	if _, _, err := provider.FinishDiscoverableLogin(lookup, *session, response); !errors.Is(err, webauthn.ErrCloneWarning) {
+		t.Errorf("FinishDiscoverableLogin() error = %v, want ErrCloneWarning", err)
+	}
+}
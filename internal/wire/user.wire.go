@@ -4,11 +4,16 @@ package wire
 
 import (
 	"app/global"
+	"app/internal/middlewares"
 	"app/internal/modules/user/controller"
 	"app/internal/modules/user/repo"
 	"app/internal/modules/user/service"
 
+	"app/internal/third_party/mail"
+	"app/internal/third_party/oidc"
 	"app/internal/third_party/redis"
+	"app/internal/third_party/s3"
+	"app/internal/third_party/webauthn"
 
 	"github.com/google/wire"
 	"gorm.io/gorm"
@@ -22,9 +27,55 @@ func InitUserRouterHandler() (*controller.UserController, error) {
 	wire.Build(
 		ProvideDB,
 		redis.NewRedisProvider,
+		mail.NewMailer,
+		oidc.NewOIDCProvider,
+		webauthn.NewWebAuthnProvider,
+		s3.NewS3Provider,
 		repo.NewUserRepository,
+		repo.NewMFARepository,
+		repo.NewInvitationRepository,
+		repo.NewRoleRepository,
+		repo.NewAPIKeyRepository,
+		repo.NewSessionRepository,
+		repo.NewIdentityRepository,
+		repo.NewAuditLogRepository,
+		repo.NewWebAuthnRepository,
+		repo.NewOrganizationRepository,
+		service.NewPermissionService,
+		service.NewAuthorizationService,
+		service.NewAuditService,
 		service.NewUserService,
 		controller.NewUserController,
 	)
 	return new(controller.UserController), nil
 }
+
+func InitAuthMiddleware() (*middlewares.AuthMiddleware, error) {
+	wire.Build(
+		ProvideDB,
+		redis.NewRedisProvider,
+		repo.NewUserRepository,
+		repo.NewRoleRepository,
+		repo.NewAPIKeyRepository,
+		repo.NewSessionRepository,
+		repo.NewAuditLogRepository,
+		repo.NewOrganizationRepository,
+		service.NewPermissionService,
+		service.NewAPIKeyService,
+		service.NewSessionService,
+		service.NewAuditService,
+		service.NewMembershipService,
+		middlewares.NewAuthMiddleware,
+	)
+	return new(middlewares.AuthMiddleware), nil
+}
+
+func InitUserPurgeService() (service.IUserPurgeService, error) {
+	wire.Build(
+		ProvideDB,
+		redis.NewRedisProvider,
+		repo.NewUserRepository,
+		service.NewUserPurgeService,
+	)
+	return nil, nil
+}
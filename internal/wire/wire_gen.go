@@ -8,10 +8,15 @@ package wire
 
 import (
 	"app/global"
+	"app/internal/middlewares"
 	"app/internal/modules/user/controller"
 	"app/internal/modules/user/repo"
 	"app/internal/modules/user/service"
+	"app/internal/third_party/mail"
+	"app/internal/third_party/oidc"
 	"app/internal/third_party/redis"
+	"app/internal/third_party/s3"
+	"app/internal/third_party/webauthn"
 	"gorm.io/gorm"
 )
 
@@ -20,12 +25,57 @@ import (
 func InitUserRouterHandler() (*controller.UserController, error) {
 	db := ProvideDB()
 	iUserRepository := repo.NewUserRepository(db)
+	imfaRepository := repo.NewMFARepository(db)
+	iInvitationRepository := repo.NewInvitationRepository(db)
+	iRoleRepository := repo.NewRoleRepository(db)
+	iapiKeyRepository := repo.NewAPIKeyRepository(db)
+	iSessionRepository := repo.NewSessionRepository(db)
+	iIdentityRepository := repo.NewIdentityRepository(db)
+	iAuditLogRepository := repo.NewAuditLogRepository(db)
+	iWebAuthnRepository := repo.NewWebAuthnRepository(db)
+	iOrganizationRepository := repo.NewOrganizationRepository(db)
 	redisProvider := redis.NewRedisProvider()
-	iUserService := service.NewUserService(iUserRepository, redisProvider)
+	iPermissionService := service.NewPermissionService(iRoleRepository, redisProvider)
+	iAuthorizationService := service.NewAuthorizationService(iPermissionService, iOrganizationRepository)
+	iAuditService := service.NewAuditService(iAuditLogRepository)
+	oidcProvider := oidc.NewOIDCProvider()
+	webAuthnProvider := webauthn.NewWebAuthnProvider()
+	s3Provider := s3.NewS3Provider()
+	mailer, err := mail.NewMailer()
+	if err != nil {
+		return nil, err
+	}
+	iUserService := service.NewUserService(iUserRepository, imfaRepository, iInvitationRepository, iRoleRepository, iapiKeyRepository, iSessionRepository, iIdentityRepository, iAuditLogRepository, iWebAuthnRepository, iOrganizationRepository, iPermissionService, iAuthorizationService, iAuditService, redisProvider, oidcProvider, webAuthnProvider, s3Provider, mailer)
 	userController := controller.NewUserController(iUserService)
 	return userController, nil
 }
 
+func InitAuthMiddleware() (*middlewares.AuthMiddleware, error) {
+	redisProvider := redis.NewRedisProvider()
+	db := ProvideDB()
+	iapiKeyRepository := repo.NewAPIKeyRepository(db)
+	iUserRepository := repo.NewUserRepository(db)
+	iapiKeyService := service.NewAPIKeyService(iapiKeyRepository, iUserRepository)
+	iSessionRepository := repo.NewSessionRepository(db)
+	iSessionService := service.NewSessionService(iSessionRepository, redisProvider)
+	iAuditLogRepository := repo.NewAuditLogRepository(db)
+	iAuditService := service.NewAuditService(iAuditLogRepository)
+	iRoleRepository := repo.NewRoleRepository(db)
+	iPermissionService := service.NewPermissionService(iRoleRepository, redisProvider)
+	iOrganizationRepository := repo.NewOrganizationRepository(db)
+	iMembershipService := service.NewMembershipService(iOrganizationRepository)
+	authMiddleware := middlewares.NewAuthMiddleware(redisProvider, iapiKeyService, iSessionService, iAuditService, iPermissionService, iMembershipService)
+	return authMiddleware, nil
+}
+
+func InitUserPurgeService() (service.IUserPurgeService, error) {
+	db := ProvideDB()
+	iUserRepository := repo.NewUserRepository(db)
+	redisProvider := redis.NewRedisProvider()
+	iUserPurgeService := service.NewUserPurgeService(iUserRepository, redisProvider)
+	return iUserPurgeService, nil
+}
+
 // user.wire.go:
 
 func ProvideDB() *gorm.DB {
@@ -0,0 +1,4 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS email_verified_at TIMESTAMP NULL;
+
+-- Users registered before email verification count as verified, AUTH_REQUIRE_EMAIL_VERIFICATION would lock them out
+UPDATE users SET email_verified_at = created_at WHERE email_verified_at IS NULL;
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS invitations (
+    id UUID PRIMARY KEY,
+    email VARCHAR(255) NOT NULL,
+    system_role VARCHAR(50) NOT NULL DEFAULT 'USER',
+    invited_by UUID NOT NULL REFERENCES users (id),
+    expires_at TIMESTAMP NOT NULL,
+    accepted_at TIMESTAMP NULL,
+    revoked_at TIMESTAMP NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_invitations_email ON invitations (email);
+CREATE INDEX IF NOT EXISTS idx_invitations_created_at ON invitations (created_at DESC);
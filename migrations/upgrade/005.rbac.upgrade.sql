@@ -0,0 +1,48 @@
+CREATE TABLE IF NOT EXISTS roles (
+    name VARCHAR(50) PRIMARY KEY,
+    description VARCHAR(255) NOT NULL DEFAULT '',
+    parent_role VARCHAR(50) NULL REFERENCES roles (name),
+    is_system BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS permissions (
+    name VARCHAR(100) PRIMARY KEY,
+    description VARCHAR(255) NOT NULL DEFAULT ''
+);
+
+CREATE TABLE IF NOT EXISTS role_permissions (
+    role_name VARCHAR(50) NOT NULL REFERENCES roles (name) ON DELETE CASCADE,
+    permission_name VARCHAR(100) NOT NULL REFERENCES permissions (name) ON DELETE CASCADE,
+    PRIMARY KEY (role_name, permission_name)
+);
+
+-- A role inherits every permission of its parent role
+INSERT INTO roles (name, description, parent_role, is_system) VALUES
+    ('USER', 'Regular user', NULL, TRUE),
+    ('ADMIN', 'Administrator', 'USER', TRUE),
+    ('SUPER_ADMIN', 'Super administrator', 'ADMIN', TRUE)
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO permissions (name, description) VALUES
+    ('user:list', 'List users'),
+    ('user:create', 'Create users'),
+    ('user:update', 'Update any user, including role and activation'),
+    ('user:unlock', 'Unlock users locked out by failed logins'),
+    ('invitation:manage', 'Create, list and revoke invitations'),
+    ('mfa_policy:manage', 'Manage per-role MFA policies'),
+    ('role:manage', 'Manage roles and their permissions')
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO role_permissions (role_name, permission_name) VALUES
+    ('ADMIN', 'user:list'),
+    ('ADMIN', 'user:create'),
+    ('ADMIN', 'user:update'),
+    ('ADMIN', 'user:unlock'),
+    ('ADMIN', 'invitation:manage'),
+    ('ADMIN', 'mfa_policy:manage'),
+    ('SUPER_ADMIN', 'role:manage')
+ON CONFLICT DO NOTHING;
+
+ALTER TABLE users ADD CONSTRAINT fk_users_system_role FOREIGN KEY (system_role) REFERENCES roles (name);
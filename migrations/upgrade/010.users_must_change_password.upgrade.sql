@@ -0,0 +1,2 @@
+-- Set on admin created accounts, login only issues a restricted token until the password is changed
+ALTER TABLE users ADD COLUMN IF NOT EXISTS must_change_password BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,29 @@
+-- Audit trail of impersonation: start and stop, and every write made with an impersonation token.
+-- Entries outlive the users they mention
+CREATE TABLE IF NOT EXISTS audit_logs (
+    id UUID PRIMARY KEY,
+    actor_id UUID NULL REFERENCES users (id) ON DELETE SET NULL,
+    user_id UUID NULL REFERENCES users (id) ON DELETE SET NULL,
+    action VARCHAR(100) NOT NULL,
+    token_id VARCHAR(64) NOT NULL DEFAULT '',
+    method VARCHAR(10) NOT NULL DEFAULT '',
+    path VARCHAR(255) NOT NULL DEFAULT '',
+    status INT NOT NULL DEFAULT 0,
+    ip_address VARCHAR(45) NOT NULL DEFAULT '',
+    user_agent VARCHAR(255) NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_logs_actor_id ON audit_logs (actor_id);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_user_id ON audit_logs (user_id);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_created_at ON audit_logs (created_at);
+
+INSERT INTO permissions (name, description) VALUES
+    ('user:impersonate', 'Act as another user with a short-lived token'),
+    ('audit_log:view', 'View the audit log')
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO role_permissions (role_name, permission_name) VALUES
+    ('SUPER_ADMIN', 'user:impersonate'),
+    ('SUPER_ADMIN', 'audit_log:view')
+ON CONFLICT DO NOTHING;
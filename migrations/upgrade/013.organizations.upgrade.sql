@@ -0,0 +1,30 @@
+-- Customer organizations (tenants). Users are global, membership gives them a role inside an organization
+CREATE TABLE IF NOT EXISTS organizations (
+    id UUID PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    slug VARCHAR(100) NOT NULL UNIQUE,
+    is_active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS organization_members (
+    organization_id UUID NOT NULL REFERENCES organizations (id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    role VARCHAR(50) NOT NULL DEFAULT 'MEMBER',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (organization_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_organization_members_user_id ON organization_members (user_id);
+
+-- The organization selected in a session, carried by its access tokens as the "org" claim
+ALTER TABLE user_sessions ADD COLUMN IF NOT EXISTS organization_id UUID NULL REFERENCES organizations (id) ON DELETE SET NULL;
+
+INSERT INTO permissions (name, description) VALUES
+    ('organization:manage', 'Create and manage every organization and its members')
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO role_permissions (role_name, permission_name) VALUES
+    ('SUPER_ADMIN', 'organization:manage')
+ON CONFLICT DO NOTHING;
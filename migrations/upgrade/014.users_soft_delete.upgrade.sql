@@ -0,0 +1,22 @@
+-- Soft delete: deleted users are hidden from every query and purged for good after the retention window
+ALTER TABLE users ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP NULL;
+
+-- A deleted user keeps its username and email, they can be taken again until it is restored
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_username_key;
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_email_key;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_username ON users (username) WHERE deleted_at IS NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email ON users (email) WHERE deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_users_deleted_at ON users (deleted_at) WHERE deleted_at IS NOT NULL;
+
+-- Invitations outlive the purged users who sent them
+ALTER TABLE invitations ALTER COLUMN invited_by DROP NOT NULL;
+ALTER TABLE invitations DROP CONSTRAINT IF EXISTS invitations_invited_by_fkey;
+ALTER TABLE invitations ADD CONSTRAINT invitations_invited_by_fkey FOREIGN KEY (invited_by) REFERENCES users (id) ON DELETE SET NULL;
+
+INSERT INTO permissions (name, description) VALUES
+    ('user:delete', 'Delete, restore and list deleted users')
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO role_permissions (role_name, permission_name) VALUES
+    ('ADMIN', 'user:delete')
+ON CONFLICT DO NOTHING;
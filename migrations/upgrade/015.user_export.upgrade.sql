@@ -0,0 +1,9 @@
+-- Exporting the user directory is granted apart from listing it, an export has no row limit
+INSERT INTO permissions (name, description) VALUES
+    ('user:export', 'Export the user directory as CSV, JSON Lines or XLSX')
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO role_permissions (role_name, permission_name) VALUES
+    ('SUPER_ADMIN', 'user:export'),
+    ('ADMIN', 'user:export')
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,2 @@
+-- Object storage prefix of the avatar thumbnails, empty for users without avatar
+ALTER TABLE users ADD COLUMN IF NOT EXISTS avatar_key VARCHAR(255) NOT NULL DEFAULT '';
@@ -0,0 +1,55 @@
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"net/http"
+	"slices"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+var (
+	ErrUnsupportedFormat = errors.New("unsupported image format, expected JPEG, PNG, GIF or WebP")
+	ErrTooLarge          = errors.New("image has too many pixels")
+)
+
+// contentTypes are the image types Decode accepts, sniffed from the content rather than trusted from the client
+var contentTypes = []string{"image/jpeg", "image/png", "image/gif", "image/webp"}
+
+// Decode sniffs the type of the data and decodes it, the first frame of a GIF. The dimensions are checked before
+// decoding so a small file declaring a huge image is rejected without allocating it.
+func Decode(data []byte, maxPixels int) (image.Image, error) {
+	if !slices.Contains(contentTypes, http.DetectContentType(data)) {
+		return nil, ErrUnsupportedFormat
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if config.Width <= 0 || config.Height <= 0 || config.Width*config.Height > maxPixels {
+		return nil, ErrTooLarge
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	return img, err
+}
+
+// Thumbnail crops the center square of the image and scales it to size x size pixels
+func Thumbnail(img image.Image, size int) image.Image {
+	bounds := img.Bounds()
+	side := min(bounds.Dx(), bounds.Dy())
+	x := bounds.Min.X + (bounds.Dx()-side)/2
+	y := bounds.Min.Y + (bounds.Dy()-side)/2
+	square := image.Rect(x, y, x+side, y+side)
+
+	thumbnail := image.NewRGBA(image.Rect(0, 0, size, size))
+	draw.CatmullRom.Scale(thumbnail, thumbnail.Bounds(), img, square, draw.Src, nil)
+	return thumbnail
+}
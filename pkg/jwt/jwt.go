@@ -13,27 +13,163 @@ var (
 	ErrExpiredToken = errors.New("token expired")
 )
 
+// Token types, also used as the token audience so one kind can never be accepted as the other
+const (
+	TokenTypeAccess     = "access"
+	TokenTypeRefresh    = "refresh"
+	TokenTypeMFAPending = "mfa_pending"
+	TokenTypeInvitation = "invitation"
+	// TokenTypePasswordChange only allows changing the password, issued by login when a change is required
+	TokenTypePasswordChange = "password_change"
+)
+
+// JWTClaims TokenVersion must match the user's current token version, bumping it revokes every issued token.
+// FamilyID is the refresh token family, it identifies the login session on access and refresh tokens.
 type JWTClaims struct {
-	UserID     uuid.UUID `json:"user_id"`
-	Email      string    `json:"email"`
-	SystemRole string    `json:"system_role"`
+	UserID       uuid.UUID `json:"user_id"`
+	Email        string    `json:"email"`
+	SystemRole   string    `json:"system_role"`
+	TokenType    string    `json:"token_type"`
+	FamilyID     string    `json:"family_id,omitempty"`
+	TokenVersion int64     `json:"ver"`
+	// Act is set on impersonation tokens, UserID is then the impersonated user
+	Act *ActorClaim `json:"act,omitempty"`
+	// OrganizationID is the organization selected in the session of an access token
+	OrganizationID *uuid.UUID `json:"org,omitempty"`
 	jwt.RegisteredClaims
 }
 
-// GenerateToken Generate JWT token
-func GenerateToken(userID uuid.UUID, email, role string, secretKey string, expireTime time.Duration) (string, error) {
+// ActorClaim identifies who really makes the requests of a token issued on behalf of another user (RFC 8693 "act")
+type ActorClaim struct {
+	Subject uuid.UUID `json:"sub"`
+}
+
+// GenerateToken Generate JWT access token identified by a random jti and bound to the session of familyID,
+// organizationID is the organization selected in the session, nil for none
+func GenerateToken(userID uuid.UUID, email, role, familyID string, organizationID *uuid.UUID, tokenVersion int64, keySet *KeySet, expireTime time.Duration) (string, error) {
+	claims := JWTClaims{
+		UserID:         userID,
+		Email:          email,
+		SystemRole:     role,
+		TokenType:      TokenTypeAccess,
+		FamilyID:       familyID,
+		OrganizationID: organizationID,
+		TokenVersion:   tokenVersion,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.NewString(),
+			Audience:  jwt.ClaimStrings{TokenTypeAccess},
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(expireTime)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
+	}
+
+	return signToken(claims, keySet)
+}
+
+// GenerateImpersonationToken Generate a short-lived access token of userID used by actorID. It belongs to no
+// session and has no refresh token, its jti is tokenID so the impersonation can be audited and stopped
+func GenerateImpersonationToken(userID uuid.UUID, email, role string, actorID uuid.UUID, tokenID string, tokenVersion int64, keySet *KeySet, expireTime time.Duration) (string, error) {
+	claims := JWTClaims{
+		UserID:       userID,
+		Email:        email,
+		SystemRole:   role,
+		TokenType:    TokenTypeAccess,
+		TokenVersion: tokenVersion,
+		Act:          &ActorClaim{Subject: actorID},
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        tokenID,
+			Audience:  jwt.ClaimStrings{TokenTypeAccess},
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(expireTime)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
+	}
+
+	return signToken(claims, keySet)
+}
+
+// GenerateRefreshToken Generate JWT refresh token belonging to a refresh token family
+func GenerateRefreshToken(userID uuid.UUID, familyID, tokenID string, tokenVersion int64, keySet *KeySet, expireTime time.Duration) (string, error) {
+	claims := JWTClaims{
+		UserID:       userID,
+		TokenType:    TokenTypeRefresh,
+		FamilyID:     familyID,
+		TokenVersion: tokenVersion,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        tokenID,
+			Audience:  jwt.ClaimStrings{TokenTypeRefresh},
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(expireTime)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
+	}
+
+	return signToken(claims, keySet)
+}
+
+// GenerateScopedToken Generate a short-lived JWT that only grants the next step of a flow, e.g. the MFA step of login.
+// It carries the token version so revoking the user's tokens also ends the flow
+func GenerateScopedToken(userID uuid.UUID, tokenType string, tokenVersion int64, keySet *KeySet, expireTime time.Duration) (string, error) {
+	claims := JWTClaims{
+		UserID:       userID,
+		TokenType:    tokenType,
+		TokenVersion: tokenVersion,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.NewString(),
+			Audience:  jwt.ClaimStrings{tokenType},
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(expireTime)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
+	}
+
+	return signToken(claims, keySet)
+}
+
+// ValidateScopedToken Parse and validate a JWT generated by GenerateScopedToken
+func ValidateScopedToken(tokenString string, tokenType string, keySet *KeySet) (*JWTClaims, error) {
+	return parseToken(tokenString, keySet, tokenType)
+}
+
+// GenerateInvitationToken Generate a signed invitation link token carrying the invited email and preset role,
+// its jti is the invitation id
+func GenerateInvitationToken(invitationID uuid.UUID, email, role string, keySet *KeySet, expireTime time.Duration) (string, error) {
 	claims := JWTClaims{
-		UserID:     userID,
 		Email:      email,
 		SystemRole: role,
+		TokenType:  TokenTypeInvitation,
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        invitationID.String(),
+			Audience:  jwt.ClaimStrings{TokenTypeInvitation},
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(expireTime)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString([]byte(secretKey))
+	return signToken(claims, keySet)
+}
+
+// ValidateToken Parse and validate JWT access token
+func ValidateToken(tokenString string, keySet *KeySet) (*JWTClaims, error) {
+	return parseToken(tokenString, keySet, TokenTypeAccess)
+}
+
+// ValidateRefreshToken Parse and validate JWT refresh token
+func ValidateRefreshToken(tokenString string, keySet *KeySet) (*JWTClaims, error) {
+	claims, err := parseToken(tokenString, keySet, TokenTypeRefresh)
+	if err != nil {
+		return nil, err
+	}
+	if claims.FamilyID == "" {
+		return nil, ErrInvalidToken
+	}
+	return claims, nil
+}
+
+func signToken(claims JWTClaims, keySet *KeySet) (string, error) {
+	key := keySet.active
+	token := jwt.NewWithClaims(key.Method, claims)
+	if key.ID != "" {
+		token.Header["kid"] = key.ID
+	}
+	tokenString, err := token.SignedString(key.SignKey)
 	if err != nil {
 		return "", err
 	}
@@ -41,10 +177,13 @@ func GenerateToken(userID uuid.UUID, email, role string, secretKey string, expir
 	return tokenString, nil
 }
 
-// ValidateToken Parse and validate JWT token
-func ValidateToken(tokenString, secretKey string) (*JWTClaims, error) {
+func parseToken(tokenString string, keySet *KeySet, tokenType string) (*JWTClaims, error) {
 	token, err := jwt.ParseWithClaims(tokenString, &JWTClaims{}, func(token *jwt.Token) (interface{}, error) {
-		return []byte(secretKey), nil
+		key, err := keySet.lookup(token)
+		if err != nil {
+			return nil, err
+		}
+		return key.VerifyKey, nil
 	})
 
 	if err != nil {
@@ -59,5 +198,9 @@ func ValidateToken(tokenString, secretKey string) (*JWTClaims, error) {
 		return nil, ErrInvalidToken
 	}
 
+	if claims.ID == "" || claims.TokenType != tokenType || !claims.VerifyAudience(tokenType, true) {
+		return nil, ErrInvalidToken
+	}
+
 	return claims, nil
 }
@@ -0,0 +1,264 @@
+package jwt
+
+import (
+	"app/pkg/setting"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+var (
+	ErrUnsupportedAlgorithm = errors.New("unsupported signing algorithm")
+	ErrUnsupportedKey       = errors.New("unsupported key type")
+)
+
+// Key is a signing or verification key identified by its kid
+type Key struct {
+	ID        string
+	Method    jwt.SigningMethod
+	SignKey   interface{}
+	VerifyKey interface{}
+}
+
+// KeySet holds the active signing key and every key still accepted for verification
+type KeySet struct {
+	active *Key
+	keys   map[string]*Key
+	order  []*Key
+}
+
+// JWK is a public key in JSON Web Key format (RFC 7517)
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// JWKSet is the document served at /.well-known/jwks.json
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// NewKeySet builds the key set from the JWT settings.
+// HS256 signs with the shared secret, RS256/ES256/EdDSA sign with the private key in SigningKeyFile.
+func NewKeySet(config setting.JWTSetting) (*KeySet, error) {
+	ks := &KeySet{keys: make(map[string]*Key)}
+
+	active, err := loadSigningKey(config)
+	if err != nil {
+		return nil, err
+	}
+	ks.active = active
+	ks.keys[active.ID] = active
+	ks.order = append(ks.order, active)
+
+	// Retired keys stay here during their grace period so already issued tokens remain valid
+	for _, keyConfig := range config.VerificationKeys {
+		if _, exists := ks.keys[keyConfig.KeyID]; exists || keyConfig.KeyID == "" {
+			return nil, fmt.Errorf("invalid or duplicate jwt key id %q", keyConfig.KeyID)
+		}
+		key, err := loadVerificationKey(keyConfig)
+		if err != nil {
+			return nil, err
+		}
+		ks.keys[key.ID] = key
+		ks.order = append(ks.order, key)
+	}
+
+	return ks, nil
+}
+
+func loadSigningKey(config setting.JWTSetting) (*Key, error) {
+	method := jwt.GetSigningMethod(config.SigningAlgorithm)
+	if method == nil {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedAlgorithm, config.SigningAlgorithm)
+	}
+
+	if _, ok := method.(*jwt.SigningMethodHMAC); ok {
+		if config.SecretKey == "" {
+			return nil, errors.New("jwt secret key is required for " + method.Alg())
+		}
+		return &Key{ID: config.SigningKeyID, Method: method, SignKey: []byte(config.SecretKey), VerifyKey: []byte(config.SecretKey)}, nil
+	}
+
+	if config.SigningKeyID == "" {
+		return nil, errors.New("jwt signing key id is required for " + method.Alg())
+	}
+
+	data, err := os.ReadFile(config.SigningKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read jwt signing key: %w", err)
+	}
+	privateKey, err := parsePrivateKey(data)
+	if err != nil {
+		return nil, err
+	}
+
+	keyMethod, err := methodForKey(privateKey.Public())
+	if err != nil {
+		return nil, err
+	}
+	if keyMethod.Alg() != method.Alg() {
+		return nil, fmt.Errorf("jwt signing key does not match algorithm %s", method.Alg())
+	}
+
+	return &Key{ID: config.SigningKeyID, Method: method, SignKey: privateKey, VerifyKey: privateKey.Public()}, nil
+}
+
+func loadVerificationKey(config setting.JWTKeySetting) (*Key, error) {
+	data, err := os.ReadFile(config.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read jwt verification key %q: %w", config.KeyID, err)
+	}
+
+	publicKey, err := parsePublicKey(data)
+	if err != nil {
+		return nil, err
+	}
+	method, err := methodForKey(publicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Key{ID: config.KeyID, Method: method, VerifyKey: publicKey}, nil
+}
+
+// parsePrivateKey accepts PKCS#8, PKCS#1 (RSA) and SEC 1 (EC) PEM blocks
+func parsePrivateKey(data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("jwt key is not PEM encoded")
+	}
+
+	var key interface{}
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, ErrUnsupportedKey
+	}
+	return signer, nil
+}
+
+// parsePublicKey accepts a PKIX public key or any private key accepted by parsePrivateKey
+func parsePublicKey(data []byte) (crypto.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("jwt key is not PEM encoded")
+	}
+
+	switch block.Type {
+	case "PUBLIC KEY":
+		return x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	}
+
+	signer, err := parsePrivateKey(data)
+	if err != nil {
+		return nil, err
+	}
+	return signer.Public(), nil
+}
+
+func methodForKey(publicKey crypto.PublicKey) (jwt.SigningMethod, error) {
+	switch key := publicKey.(type) {
+	case *rsa.PublicKey:
+		return jwt.SigningMethodRS256, nil
+	case *ecdsa.PublicKey:
+		switch key.Curve {
+		case elliptic.P256():
+			return jwt.SigningMethodES256, nil
+		case elliptic.P384():
+			return jwt.SigningMethodES384, nil
+		case elliptic.P521():
+			return jwt.SigningMethodES512, nil
+		}
+	case ed25519.PublicKey:
+		return jwt.SigningMethodEdDSA, nil
+	}
+	return nil, ErrUnsupportedKey
+}
+
+// lookup finds the verification key for a token. Tokens without a kid are only
+// accepted while the active key is the shared HS256 secret.
+func (ks *KeySet) lookup(token *jwt.Token) (*Key, error) {
+	kid, _ := token.Header["kid"].(string)
+
+	key, ok := ks.keys[kid]
+	if kid == "" {
+		_, ok = ks.active.Method.(*jwt.SigningMethodHMAC)
+		key = ks.active
+	}
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+	// Never let the token pick the algorithm, e.g. HS256 signed with an RSA public key
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, ErrInvalidToken
+	}
+	return key, nil
+}
+
+// JWKS returns the public keys of every asymmetric key in the set
+func (ks *KeySet) JWKS() JWKSet {
+	set := JWKSet{Keys: []JWK{}}
+	for _, key := range ks.order {
+		if jwk, ok := toJWK(key); ok {
+			set.Keys = append(set.Keys, jwk)
+		}
+	}
+	return set
+}
+
+func toJWK(key *Key) (JWK, bool) {
+	jwk := JWK{Kid: key.ID, Use: "sig", Alg: key.Method.Alg()}
+
+	switch publicKey := key.VerifyKey.(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(publicKey.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(publicKey.E)).Bytes())
+	case *ecdsa.PublicKey:
+		size := (publicKey.Curve.Params().BitSize + 7) / 8
+		jwk.Kty = "EC"
+		jwk.Crv = publicKey.Curve.Params().Name
+		jwk.X = base64.RawURLEncoding.EncodeToString(publicKey.X.FillBytes(make([]byte, size)))
+		jwk.Y = base64.RawURLEncoding.EncodeToString(publicKey.Y.FillBytes(make([]byte, size)))
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(publicKey)
+	default:
+		return JWK{}, false
+	}
+	return jwk, true
+}
@@ -0,0 +1,81 @@
+package pagination
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
+)
+
+var (
+	ErrInvalidSort   = errors.New("invalid sort")
+	ErrInvalidCursor = errors.New("invalid cursor")
+)
+
+// SortField is a field of a sort, Desc for descending order
+type SortField struct {
+	Field string
+	Desc  bool
+}
+
+// ParseSort parses a comma separated list of fields, a field prefixed by "-" is sorted in descending order,
+// e.g. "-created_at,username". Only allowed fields may appear, once each. An empty list gives the default.
+func ParseSort(spec string, allowed []string, defaultSpec string) ([]SortField, error) {
+	if strings.TrimSpace(spec) == "" {
+		spec = defaultSpec
+	}
+
+	var fields []SortField
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		field := SortField{Field: strings.TrimPrefix(item, "-"), Desc: strings.HasPrefix(item, "-")}
+		if !slices.Contains(allowed, field.Field) {
+			return nil, ErrInvalidSort
+		}
+		if slices.ContainsFunc(fields, func(f SortField) bool { return f.Field == field.Field }) {
+			return nil, ErrInvalidSort
+		}
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+// FormatSort is the inverse of ParseSort
+func FormatSort(fields []SortField) string {
+	items := make([]string, len(fields))
+	for i, field := range fields {
+		items[i] = field.Field
+		if field.Desc {
+			items[i] = "-" + field.Field
+		}
+	}
+	return strings.Join(items, ",")
+}
+
+// Cursor is the position after the last item of a page: the values of its sort fields, in the sort order.
+// Sort is kept to reject a cursor used with another sort.
+type Cursor struct {
+	Sort   string   `json:"s"`
+	Values []string `json:"v"`
+}
+
+// Encode returns the cursor as an opaque URL safe string
+func (c Cursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeCursor reads a cursor returned by Encode for the given sort
+func DecodeCursor(value string, sort string) (Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	var cursor Cursor
+	if err := json.Unmarshal(data, &cursor); err != nil || cursor.Sort != sort {
+		return Cursor{}, ErrInvalidCursor
+	}
+	return cursor, nil
+}
@@ -0,0 +1,65 @@
+package password
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// rangePrefixLength is the length of the SHA-1 prefix used by the k-anonymity range API of Have I Been Pwned
+const rangePrefixLength = 5
+
+// BreachedList holds SHA-1 hashes of breached passwords indexed by hash prefix, the same way the
+// k-anonymity range API splits them, so a lookup only scans the suffixes sharing the prefix
+type BreachedList struct {
+	ranges map[string][]string
+}
+
+// LoadBreachedList reads a file with one uppercase or lowercase SHA-1 hex hash per line, optionally
+// followed by ":<count>" as in the downloadable Pwned Passwords list. Empty lines and lines starting
+// with # are skipped
+func LoadBreachedList(path string) (*BreachedList, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	list := &BreachedList{ranges: make(map[string][]string)}
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		entry := strings.TrimSpace(scanner.Text())
+		if entry == "" || strings.HasPrefix(entry, "#") {
+			continue
+		}
+		hash, _, _ := strings.Cut(entry, ":")
+		hash = strings.ToUpper(hash)
+		if len(hash) != sha1.Size*2 {
+			return nil, fmt.Errorf("breached password list %s line %d: invalid SHA-1 hash", path, line)
+		}
+		if _, err := hex.DecodeString(hash); err != nil {
+			return nil, fmt.Errorf("breached password list %s line %d: invalid SHA-1 hash", path, line)
+		}
+		prefix := hash[:rangePrefixLength]
+		list.ranges[prefix] = append(list.ranges[prefix], hash[rangePrefixLength:])
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// Contains reports whether the password is in the list
+func (b *BreachedList) Contains(password string) bool {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	for _, suffix := range b.ranges[hash[:rangePrefixLength]] {
+		if suffix == hash[rangePrefixLength:] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,53 @@
+package password
+
+import (
+	"crypto/rand"
+	"math/big"
+)
+
+const (
+	lowercaseLetters = "abcdefghijkmnopqrstuvwxyz"
+	uppercaseLetters = "ABCDEFGHJKLMNPQRSTUVWXYZ"
+	digits           = "23456789"
+	symbols          = "!#$%&*+-=?@_"
+)
+
+// Generate returns a random password of length characters containing a lowercase and an uppercase
+// letter, a digit and a symbol, so it satisfies any character class rule of the policy.
+// Characters easily confused with each other (l, I, O, 0, 1) are left out.
+func Generate(length int) (string, error) {
+	classes := []string{lowercaseLetters, uppercaseLetters, digits, symbols}
+	all := lowercaseLetters + uppercaseLetters + digits + symbols
+	length = max(length, len(classes))
+
+	password := make([]byte, length)
+	for i := range password {
+		charset := all
+		if i < len(classes) {
+			charset = classes[i]
+		}
+		c, err := randomChar(charset)
+		if err != nil {
+			return "", err
+		}
+		password[i] = c
+	}
+
+	// Shuffle so the guaranteed characters are not always first
+	for i := len(password) - 1; i > 0; i-- {
+		j, err := rand.Int(rand.Reader, big.NewInt(int64(i+1)))
+		if err != nil {
+			return "", err
+		}
+		password[i], password[j.Int64()] = password[j.Int64()], password[i]
+	}
+	return string(password), nil
+}
+
+func randomChar(charset string) (byte, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(int64(len(charset))))
+	if err != nil {
+		return 0, err
+	}
+	return charset[n.Int64()], nil
+}
@@ -0,0 +1,163 @@
+package password
+
+import (
+	"app/pkg/setting"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	AlgorithmArgon2id = "argon2id"
+	AlgorithmBcrypt   = "bcrypt"
+
+	argon2SaltLength = 16
+	argon2KeyLength  = 32
+)
+
+var (
+	ErrUnsupportedAlgorithm = errors.New("unsupported password hash algorithm")
+	ErrInvalidHash          = errors.New("invalid password hash")
+)
+
+// Hasher hashes passwords with the configured algorithm and verifies hashes of every supported algorithm.
+// Argon2id hashes use the PHC string format, bcrypt hashes its own modular crypt format ($2a$, $2b$, $2y$).
+type Hasher struct {
+	config setting.PasswordSetting
+}
+
+type argon2Params struct {
+	memory      uint32
+	iterations  uint32
+	parallelism uint8
+	salt        []byte
+	key         []byte
+}
+
+func NewHasher(config setting.PasswordSetting) (*Hasher, error) {
+	switch config.HashAlgorithm {
+	case AlgorithmArgon2id:
+		if config.Argon2Memory < 8*config.Argon2Parallelism || config.Argon2Iterations < 1 ||
+			config.Argon2Parallelism < 1 || config.Argon2Parallelism > 255 {
+			return nil, fmt.Errorf("invalid argon2id parameters m=%d t=%d p=%d",
+				config.Argon2Memory, config.Argon2Iterations, config.Argon2Parallelism)
+		}
+	case AlgorithmBcrypt:
+		if config.BcryptCost < bcrypt.MinCost || config.BcryptCost > bcrypt.MaxCost {
+			return nil, fmt.Errorf("invalid bcrypt cost %d", config.BcryptCost)
+		}
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedAlgorithm, config.HashAlgorithm)
+	}
+	return &Hasher{config: config}, nil
+}
+
+// Hash returns the hash of password with the configured algorithm and parameters
+func (h *Hasher) Hash(password string) (string, error) {
+	if h.config.HashAlgorithm == AlgorithmBcrypt {
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), h.config.BcryptCost)
+		return string(hash), err
+	}
+
+	params := h.argon2Params()
+	params.salt = make([]byte, argon2SaltLength)
+	if _, err := rand.Read(params.salt); err != nil {
+		return "", err
+	}
+	params.key = argon2.IDKey([]byte(password), params.salt, params.iterations, params.memory, params.parallelism, argon2KeyLength)
+	return params.encode(), nil
+}
+
+// Verify reports whether password matches the hash, whatever algorithm produced it
+func (h *Hasher) Verify(password string, hash string) (bool, error) {
+	if isBcrypt(hash) {
+		err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return false, nil
+		}
+		return err == nil, err
+	}
+
+	params, err := decodeArgon2(hash)
+	if err != nil {
+		return false, err
+	}
+	key := argon2.IDKey([]byte(password), params.salt, params.iterations, params.memory, params.parallelism, uint32(len(params.key)))
+	return subtle.ConstantTimeCompare(key, params.key) == 1, nil
+}
+
+// NeedsRehash reports whether the hash was made with another algorithm or other parameters than configured
+func (h *Hasher) NeedsRehash(hash string) bool {
+	if isBcrypt(hash) {
+		if h.config.HashAlgorithm != AlgorithmBcrypt {
+			return true
+		}
+		cost, err := bcrypt.Cost([]byte(hash))
+		return err != nil || cost != h.config.BcryptCost
+	}
+
+	params, err := decodeArgon2(hash)
+	if err != nil || h.config.HashAlgorithm != AlgorithmArgon2id {
+		return true
+	}
+	want := h.argon2Params()
+	return params.memory != want.memory || params.iterations != want.iterations ||
+		params.parallelism != want.parallelism || len(params.key) != argon2KeyLength
+}
+
+func (h *Hasher) argon2Params() *argon2Params {
+	return &argon2Params{
+		memory:      uint32(h.config.Argon2Memory),
+		iterations:  uint32(h.config.Argon2Iterations),
+		parallelism: uint8(h.config.Argon2Parallelism),
+	}
+}
+
+func isBcrypt(hash string) bool {
+	return strings.HasPrefix(hash, "$2a$") || strings.HasPrefix(hash, "$2b$") || strings.HasPrefix(hash, "$2y$")
+}
+
+// encode formats the hash as $argon2id$v=19$m=<memory>,t=<iterations>,p=<parallelism>$<salt>$<key>
+func (p *argon2Params) encode() string {
+	return fmt.Sprintf("$%s$v=%d$m=%d,t=%d,p=%d$%s$%s", AlgorithmArgon2id, argon2.Version,
+		p.memory, p.iterations, p.parallelism,
+		base64.RawStdEncoding.EncodeToString(p.salt), base64.RawStdEncoding.EncodeToString(p.key))
+}
+
+func decodeArgon2(hash string) (*argon2Params, error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[0] != "" {
+		return nil, ErrInvalidHash
+	}
+	if parts[1] != AlgorithmArgon2id {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedAlgorithm, parts[1])
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return nil, ErrInvalidHash
+	}
+
+	params := &argon2Params{}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.memory, &params.iterations, &params.parallelism); err != nil {
+		return nil, ErrInvalidHash
+	}
+	if params.iterations < 1 || params.parallelism < 1 {
+		return nil, ErrInvalidHash
+	}
+
+	var err error
+	if params.salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return nil, ErrInvalidHash
+	}
+	if params.key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil || len(params.key) == 0 {
+		return nil, ErrInvalidHash
+	}
+	return params, nil
+}
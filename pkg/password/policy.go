@@ -0,0 +1,142 @@
+package password
+
+import (
+	"app/pkg/setting"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Policy rules, returned in violations so clients can show one message per rule
+const (
+	RuleMinLength = "min_length"
+	RuleMaxLength = "max_length"
+	RuleUppercase = "uppercase"
+	RuleLowercase = "lowercase"
+	RuleDigit     = "digit"
+	RuleSymbol    = "symbol"
+	RuleUserInfo  = "user_info"
+	RuleBreached  = "breached"
+	RuleHistory   = "history"
+)
+
+const (
+	// minUserInfoLength keeps short usernames from rejecting every password that happens to contain them
+	minUserInfoLength = 3
+	// generatedPasswordLength is the length of generated passwords unless the policy asks for more
+	generatedPasswordLength = 20
+)
+
+// Violation is a rule a new password does not satisfy
+type Violation struct {
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// Policy checks new passwords against the configured rules and the breached password list
+type Policy struct {
+	config   setting.PasswordSetting
+	breached *BreachedList
+}
+
+func NewPolicy(config setting.PasswordSetting) (*Policy, error) {
+	policy := &Policy{config: config}
+	if config.BreachedListFile != "" {
+		breached, err := LoadBreachedList(config.BreachedListFile)
+		if err != nil {
+			return nil, err
+		}
+		policy.breached = breached
+	}
+	return policy, nil
+}
+
+// Validate returns the violated rules, userInfo (username, email) must not appear in the password
+func (p *Policy) Validate(password string, userInfo ...string) []Violation {
+	violations := []Violation{}
+
+	length := utf8.RuneCountInString(password)
+	if length < p.config.MinLength {
+		violations = append(violations, Violation{RuleMinLength, fmt.Sprintf("Password must be at least %d characters long", p.config.MinLength)})
+	}
+	if p.config.MaxLength > 0 && length > p.config.MaxLength {
+		violations = append(violations, Violation{RuleMaxLength, fmt.Sprintf("Password must be at most %d characters long", p.config.MaxLength)})
+	}
+
+	var hasUpper, hasLower, hasDigit, hasSymbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r):
+			hasSymbol = true
+		}
+	}
+	if p.config.RequireUppercase && !hasUpper {
+		violations = append(violations, Violation{RuleUppercase, "Password must contain an uppercase letter"})
+	}
+	if p.config.RequireLowercase && !hasLower {
+		violations = append(violations, Violation{RuleLowercase, "Password must contain a lowercase letter"})
+	}
+	if p.config.RequireDigit && !hasDigit {
+		violations = append(violations, Violation{RuleDigit, "Password must contain a digit"})
+	}
+	if p.config.RequireSymbol && !hasSymbol {
+		violations = append(violations, Violation{RuleSymbol, "Password must contain a symbol"})
+	}
+
+	if p.config.DisallowUserInfo && containsUserInfo(password, userInfo) {
+		violations = append(violations, Violation{RuleUserInfo, "Password must not contain the username or email"})
+	}
+	if p.breached != nil && p.breached.Contains(password) {
+		violations = append(violations, Violation{RuleBreached, "Password appears in a list of breached passwords"})
+	}
+	return violations
+}
+
+// HistorySize is the number of previous passwords that cannot be reused
+func (p *Policy) HistorySize() int {
+	return p.config.HistorySize
+}
+
+// HistoryViolation is reported when the password matches the current one or one of the last HistorySize passwords
+func (p *Policy) HistoryViolation() Violation {
+	if p.config.HistorySize <= 0 {
+		return Violation{RuleHistory, "Password must differ from the current password"}
+	}
+	return Violation{RuleHistory, fmt.Sprintf("Password must differ from the last %d passwords", p.config.HistorySize)}
+}
+
+// GeneratePassword returns a random password satisfying the length and character class rules
+func (p *Policy) GeneratePassword() (string, error) {
+	return Generate(max(generatedPasswordLength, p.config.MinLength))
+}
+
+// IsExpired reports whether a password changed at changedAt is older than the maximum age
+func (p *Policy) IsExpired(changedAt *time.Time, now time.Time) bool {
+	if p.config.MaxAge <= 0 || changedAt == nil {
+		return false
+	}
+	return now.Sub(*changedAt) > p.config.MaxAge
+}
+
+func containsUserInfo(password string, userInfo []string) bool {
+	password = strings.ToLower(password)
+	for _, info := range userInfo {
+		info = strings.ToLower(info)
+		// The local part of an email is checked on its own as well
+		if local, _, found := strings.Cut(info, "@"); found && len(local) >= minUserInfoLength && strings.Contains(password, local) {
+			return true
+		}
+		if len(info) >= minUserInfoLength && strings.Contains(password, info) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,21 @@
+package policy
+
+import "context"
+
+type subjectKey struct{}
+
+// WithSubject returns a context carrying the attributes of the subject making the request
+func WithSubject(ctx context.Context, subject Attributes) context.Context {
+	return context.WithValue(ctx, subjectKey{}, subject)
+}
+
+// SubjectFromContext returns a copy of the subject attributes of the context, empty for an anonymous request
+func SubjectFromContext(ctx context.Context) Attributes {
+	subject := Attributes{}
+	if attributes, ok := ctx.Value(subjectKey{}).(Attributes); ok {
+		for name, value := range attributes {
+			subject[name] = value
+		}
+	}
+	return subject
+}
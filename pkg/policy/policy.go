@@ -0,0 +1,258 @@
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+)
+
+// Rule effects, a matching deny rule always wins over allow rules
+const (
+	EffectAllow = "allow"
+	EffectDeny  = "deny"
+)
+
+// Condition operators
+const (
+	OperatorEq          = "eq"
+	OperatorNe          = "ne"
+	OperatorIn          = "in"
+	OperatorNotIn       = "not_in"
+	OperatorContains    = "contains"
+	OperatorNotContains = "not_contains"
+	OperatorIntersects  = "intersects"
+)
+
+var operators = []string{OperatorEq, OperatorNe, OperatorIn, OperatorNotIn, OperatorContains, OperatorNotContains, OperatorIntersects}
+
+// Attributes of the subject or the resource of a request, values are strings, numbers, booleans,
+// fmt.Stringer (e.g. uuid.UUID) or slices of them
+type Attributes map[string]any
+
+// Condition compares an attribute, e.g. "subject.role", with a literal value or with another attribute
+// given as reference, e.g. "resource.id"
+type Condition struct {
+	Attribute string `json:"attribute"`
+	Operator  string `json:"operator"`
+	Value     any    `json:"value,omitempty"`
+	Reference string `json:"reference,omitempty"`
+}
+
+// Rule applies its effect to the actions when every condition holds, "*" and "user:*" match several actions
+type Rule struct {
+	ID          string      `json:"id"`
+	Description string      `json:"description,omitempty"`
+	Effect      string      `json:"effect"`
+	Actions     []string    `json:"actions"`
+	Conditions  []Condition `json:"conditions"`
+}
+
+// Request is the question asked to the engine: may the subject do the action on the resource
+type Request struct {
+	Subject  Attributes
+	Action   string
+	Resource Attributes
+}
+
+// Decision is the answer of the engine, RuleID is the deciding rule and empty when no rule matched
+type Decision struct {
+	Allowed bool
+	RuleID  string
+}
+
+// Engine evaluates requests against a set of rules. Nothing is allowed unless a rule allows it.
+type Engine struct {
+	rules []Rule
+}
+
+// Load reads a policy file, a JSON document {"rules": [...]}
+func Load(path string) (*Engine, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	engine, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("policy file %s: %w", path, err)
+	}
+	return engine, nil
+}
+
+// Parse validates the rules of a policy document so mistakes are reported at startup, not at evaluation
+func Parse(data []byte) (*Engine, error) {
+	var document struct {
+		Rules []Rule `json:"rules"`
+	}
+	if err := json.Unmarshal(data, &document); err != nil {
+		return nil, err
+	}
+
+	ids := make(map[string]bool)
+	for i, rule := range document.Rules {
+		if rule.ID == "" {
+			return nil, fmt.Errorf("rule %d: missing id", i)
+		}
+		if ids[rule.ID] {
+			return nil, fmt.Errorf("rule %s: duplicate id", rule.ID)
+		}
+		ids[rule.ID] = true
+		if rule.Effect != EffectAllow && rule.Effect != EffectDeny {
+			return nil, fmt.Errorf("rule %s: effect must be %q or %q", rule.ID, EffectAllow, EffectDeny)
+		}
+		if len(rule.Actions) == 0 {
+			return nil, fmt.Errorf("rule %s: no actions", rule.ID)
+		}
+		for _, condition := range rule.Conditions {
+			if err := validateCondition(condition); err != nil {
+				return nil, fmt.Errorf("rule %s: %w", rule.ID, err)
+			}
+		}
+	}
+	return &Engine{rules: document.Rules}, nil
+}
+
+func validateCondition(condition Condition) error {
+	if !validPath(condition.Attribute) {
+		return fmt.Errorf("attribute %q must start with subject. or resource.", condition.Attribute)
+	}
+	if !slices.Contains(operators, condition.Operator) {
+		return fmt.Errorf("unknown operator %q", condition.Operator)
+	}
+	if condition.Reference != "" {
+		if condition.Value != nil {
+			return fmt.Errorf("attribute %q has both a value and a reference", condition.Attribute)
+		}
+		if !validPath(condition.Reference) {
+			return fmt.Errorf("reference %q must start with subject. or resource.", condition.Reference)
+		}
+	}
+	return nil
+}
+
+func validPath(path string) bool {
+	scope, name, found := strings.Cut(path, ".")
+	return found && name != "" && (scope == "subject" || scope == "resource")
+}
+
+// Evaluate returns the decision for the request: denied when a deny rule matches, otherwise allowed
+// when an allow rule matches, otherwise denied
+func (e *Engine) Evaluate(request Request) Decision {
+	decision := Decision{}
+	for _, rule := range e.rules {
+		if !matchesAction(rule.Actions, request.Action) || !e.matchesConditions(rule, request) {
+			continue
+		}
+		if rule.Effect == EffectDeny {
+			return Decision{Allowed: false, RuleID: rule.ID}
+		}
+		if !decision.Allowed {
+			decision = Decision{Allowed: true, RuleID: rule.ID}
+		}
+	}
+	return decision
+}
+
+func matchesAction(actions []string, action string) bool {
+	for _, pattern := range actions {
+		if pattern == "*" || pattern == action {
+			return true
+		}
+		if prefix, found := strings.CutSuffix(pattern, "*"); found && strings.HasPrefix(action, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesConditions reports whether every condition of the rule holds. A missing attribute, e.g. the id of an
+// anonymous subject, fails the conditions of an allow rule but holds for a deny rule, so rules fail closed.
+func (e *Engine) matchesConditions(rule Rule, request Request) bool {
+	for _, condition := range rule.Conditions {
+		left, found := lookup(request, condition.Attribute)
+		right := normalize(condition.Value)
+		if found && condition.Reference != "" {
+			right, found = lookup(request, condition.Reference)
+		}
+		if !found {
+			if rule.Effect == EffectDeny {
+				continue
+			}
+			return false
+		}
+		if !compare(condition.Operator, left, right) {
+			return false
+		}
+	}
+	return true
+}
+
+func lookup(request Request, path string) (any, bool) {
+	scope, name, _ := strings.Cut(path, ".")
+	attributes := request.Subject
+	if scope == "resource" {
+		attributes = request.Resource
+	}
+	value, found := attributes[name]
+	if !found || value == nil {
+		return nil, false
+	}
+	return normalize(value), true
+}
+
+func compare(operator string, left, right any) bool {
+	switch operator {
+	case OperatorEq:
+		return left == right
+	case OperatorNe:
+		return left != right
+	case OperatorIn:
+		return slices.Contains(asList(right), left)
+	case OperatorNotIn:
+		return !slices.Contains(asList(right), left)
+	case OperatorContains:
+		return slices.Contains(asList(left), right)
+	case OperatorNotContains:
+		return !slices.Contains(asList(left), right)
+	case OperatorIntersects:
+		values := asList(right)
+		return slices.ContainsFunc(asList(left), func(value any) bool { return slices.Contains(values, value) })
+	}
+	return false
+}
+
+// normalize brings Go and JSON values to comparable forms: numbers to float64, fmt.Stringer to string
+// and slices to []any
+func normalize(value any) any {
+	switch v := value.(type) {
+	case nil, string, bool, float64:
+		return v
+	case int:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case fmt.Stringer:
+		return v.String()
+	case []any:
+		list := make([]any, len(v))
+		for i, item := range v {
+			list[i] = normalize(item)
+		}
+		return list
+	case []string:
+		list := make([]any, len(v))
+		for i, item := range v {
+			list[i] = item
+		}
+		return list
+	}
+	return value
+}
+
+func asList(value any) []any {
+	if list, ok := value.([]any); ok {
+		return list
+	}
+	return nil
+}
@@ -0,0 +1,203 @@
+package policy
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+const defaultPolicyPath = "../../internal/initialize/policies/default.policy.json"
+
+func TestEvaluateDefaultPolicy(t *testing.T) {
+	engine, err := Load(defaultPolicyPath)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	self := uuid.New()
+	other := uuid.New().String()
+	organization := uuid.New().String()
+
+	tests := []struct {
+		name     string
+		subject  Attributes
+		action   string
+		resource Attributes
+		allowed  bool
+		ruleID   string
+	}{
+		{
+			name:     "self reads own account",
+			subject:  Attributes{"id": self},
+			action:   "user:read",
+			resource: Attributes{"id": self.String(), "is_active": true},
+			allowed:  true,
+			ruleID:   "user-self",
+		},
+		{
+			name:     "self updates own account",
+			subject:  Attributes{"id": self},
+			action:   "user:update",
+			resource: Attributes{"id": self.String()},
+			allowed:  true,
+			ruleID:   "user-self",
+		},
+		{
+			name:     "self cannot manage own account",
+			subject:  Attributes{"id": self},
+			action:   "user:manage",
+			resource: Attributes{"id": self.String()},
+			allowed:  false,
+		},
+		{
+			name:     "other user is denied",
+			subject:  Attributes{"id": self, "permissions": []string{}},
+			action:   "user:read",
+			resource: Attributes{"id": other, "is_active": true},
+			allowed:  false,
+		},
+		{
+			name:     "user:list holder reads any user",
+			subject:  Attributes{"id": self, "permissions": []string{"user:list"}},
+			action:   "user:read",
+			resource: Attributes{"id": other, "is_active": false},
+			allowed:  true,
+			ruleID:   "user-read-by-permission",
+		},
+		{
+			name:     "user:list holder cannot update",
+			subject:  Attributes{"id": self, "permissions": []string{"user:list"}},
+			action:   "user:update",
+			resource: Attributes{"id": other},
+			allowed:  false,
+		},
+		{
+			name:     "same organization reads an active user",
+			subject:  Attributes{"id": self, "organizations": []string{organization}},
+			action:   "user:read",
+			resource: Attributes{"id": other, "is_active": true, "organizations": []string{organization}},
+			allowed:  true,
+			ruleID:   "user-read-same-organization",
+		},
+		{
+			name:     "same organization cannot read an inactive user",
+			subject:  Attributes{"id": self, "organizations": []string{organization}},
+			action:   "user:read",
+			resource: Attributes{"id": other, "is_active": false, "organizations": []string{organization}},
+			allowed:  false,
+		},
+		{
+			name:     "other organization is denied",
+			subject:  Attributes{"id": self, "organizations": []string{uuid.New().String()}},
+			action:   "user:read",
+			resource: Attributes{"id": other, "is_active": true, "organizations": []string{organization}},
+			allowed:  false,
+		},
+		{
+			name:     "api key scoped to user:list reads any user",
+			subject:  Attributes{"id": self, "scopes": []string{"user:list"}, "permissions": []string{"user:list"}},
+			action:   "user:read",
+			resource: Attributes{"id": other, "is_active": true},
+			allowed:  true,
+			ruleID:   "user-read-by-permission",
+		},
+		{
+			name:     "api key scoped without user:update cannot update",
+			subject:  Attributes{"id": self, "scopes": []string{"user:list"}, "permissions": []string{"user:list"}},
+			action:   "user:update",
+			resource: Attributes{"id": other},
+			allowed:  false,
+		},
+		{
+			name:     "api key scoped to user:update manages any user",
+			subject:  Attributes{"id": self, "scopes": []string{"user:update"}, "permissions": []string{"user:update"}},
+			action:   "user:manage",
+			resource: Attributes{"id": other},
+			allowed:  true,
+			ruleID:   "user-update-by-permission",
+		},
+		{
+			name:     "anonymous is denied",
+			subject:  Attributes{},
+			action:   "user:read",
+			resource: Attributes{"id": other, "is_active": true},
+			allowed:  false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decision := engine.Evaluate(Request{Subject: tt.subject, Action: tt.action, Resource: tt.resource})
+			if decision.Allowed != tt.allowed || decision.RuleID != tt.ruleID {
+				t.Errorf("Evaluate() = %+v, want allowed %v by %q", decision, tt.allowed, tt.ruleID)
+			}
+		})
+	}
+}
+
+func TestEvaluateDenyRuleWithMissingAttribute(t *testing.T) {
+	engine, err := Parse([]byte(`{"rules": [
+		{"id": "allow-all", "effect": "allow", "actions": ["*"]},
+		{"id": "deny-other-tenant", "effect": "deny", "actions": ["user:*"], "conditions": [
+			{"attribute": "resource.tenant", "operator": "ne", "reference": "subject.tenant"}
+		]},
+		{"id": "deny-blocked", "effect": "deny", "actions": ["user:read"], "conditions": [
+			{"attribute": "subject.status", "operator": "not_in", "value": ["ok"]}
+		]}
+	]}`))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		subject  Attributes
+		resource Attributes
+		allowed  bool
+		ruleID   string
+	}{
+		{"same tenant", Attributes{"tenant": "a", "status": "ok"}, Attributes{"tenant": "a"}, true, "allow-all"},
+		{"other tenant", Attributes{"tenant": "a", "status": "ok"}, Attributes{"tenant": "b"}, false, "deny-other-tenant"},
+		{"subject without tenant", Attributes{"status": "ok"}, Attributes{"tenant": "b"}, false, "deny-other-tenant"},
+		{"resource without tenant", Attributes{"tenant": "a", "status": "ok"}, Attributes{}, false, "deny-other-tenant"},
+		{"subject without status", Attributes{"tenant": "a"}, Attributes{"tenant": "a"}, false, "deny-blocked"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decision := engine.Evaluate(Request{Subject: tt.subject, Action: "user:read", Resource: tt.resource})
+			if decision.Allowed != tt.allowed || decision.RuleID != tt.ruleID {
+				t.Errorf("Evaluate() = %+v, want allowed %v by %q", decision, tt.allowed, tt.ruleID)
+			}
+		})
+	}
+}
+
+func TestParseRejectsMalformedDocuments(t *testing.T) {
+	tests := []struct {
+		name     string
+		document string
+		err      string
+	}{
+		{"invalid json", `{"rules": [`, "unexpected end of JSON input"},
+		{"missing id", `{"rules": [{"effect": "allow", "actions": ["*"]}]}`, "rule 0: missing id"},
+		{"duplicate id", `{"rules": [{"id": "a", "effect": "allow", "actions": ["*"]}, {"id": "a", "effect": "deny", "actions": ["*"]}]}`, "rule a: duplicate id"},
+		{"unknown effect", `{"rules": [{"id": "a", "effect": "maybe", "actions": ["*"]}]}`, "rule a: effect must be"},
+		{"no actions", `{"rules": [{"id": "a", "effect": "allow", "actions": []}]}`, "rule a: no actions"},
+		{"attribute without scope", `{"rules": [{"id": "a", "effect": "allow", "actions": ["*"], "conditions": [{"attribute": "role", "operator": "eq", "value": "ADMIN"}]}]}`, `attribute "role" must start with`},
+		{"unknown scope", `{"rules": [{"id": "a", "effect": "allow", "actions": ["*"], "conditions": [{"attribute": "request.ip", "operator": "eq", "value": "::1"}]}]}`, `attribute "request.ip" must start with`},
+		{"unknown operator", `{"rules": [{"id": "a", "effect": "allow", "actions": ["*"], "conditions": [{"attribute": "subject.role", "operator": "like", "value": "ADMIN"}]}]}`, `unknown operator "like"`},
+		{"value and reference", `{"rules": [{"id": "a", "effect": "allow", "actions": ["*"], "conditions": [{"attribute": "subject.id", "operator": "eq", "value": "x", "reference": "resource.id"}]}]}`, "both a value and a reference"},
+		{"invalid reference", `{"rules": [{"id": "a", "effect": "allow", "actions": ["*"], "conditions": [{"attribute": "subject.id", "operator": "eq", "reference": "id"}]}]}`, `reference "id" must start with`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(tt.document))
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("Parse() error = %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
@@ -4,12 +4,63 @@ const (
 	ErrCodeSuccess              = 2001  //Success
 	ErrCodeInvalidParams        = 2002  //Email invalid
 	ErrInvalidToken             = 3001  //Token invalid
+	ErrCodeRefreshTokenReused   = 3002  // Refresh token reused, token family revoked
+	ErrCodeTokenRevoked         = 3003  // Token has been revoked
+	ErrCodeResetTokenInvalid    = 3004  // Password reset token invalid, expired or already used
+	ErrCodeVerifyTokenInvalid   = 3005  // Email verification token invalid, expired or already used
+	ErrCodeMFACodeInvalid       = 3006  // MFA code or recovery code invalid
+	ErrCodeInvitationInvalid    = 3007  // Invitation invalid, expired, revoked or already used
+	ErrCodeAPIKeyInvalid        = 3008  // API key invalid, expired or revoked
+	ErrCodeOIDCStateInvalid     = 3009  // OIDC state invalid, expired or already used
+	ErrCodeOIDCLoginFailed      = 3010  // OIDC provider rejected the login or returned an invalid ID token
+	ErrCodeMagicLinkInvalid     = 3011  // Magic link invalid, expired or already used
+	ErrCodePasskeyInvalid       = 3012  // Passkey challenge invalid, expired or already used, or the passkey response failed verification
 	ErrCodeUserHasExists        = 50001 // User already exist
 	ErrCodeUserNotFound         = 4000  // User not found
 	ErrCodeInvalidLogin         = 4001  // Invalid login credentials
 	ErrCodeAccessDenied         = 4003  // Access denied
 	ErrCodeAccountLock          = 4004  // Your account has been locked
 	ErrCodeUserPermissionDenied = 4005  // You do not have permission to interact with this user
+	ErrCodeEmailNotVerified     = 4006  // Email address has not been verified
+	ErrCodeMFAAlreadyEnabled    = 4007  // MFA already enabled
+	ErrCodeMFANotEnabled        = 4008  // MFA not enabled or enrollment not started
+	ErrCodeMFARequired          = 4009  // MFA is required for this role
+	ErrCodeLoginLocked          = 4011  // Too many failed logins, temporarily locked
+	ErrCodeRegistrationClosed   = 4012  // Self-registration is closed or requires an invitation
+	ErrCodeRoleNotAllowed       = 4013  // Role cannot be chosen
+	ErrCodeRoleNotFound         = 4014  // Role not found
+	ErrCodeRoleHasExists        = 4015  // Role already exists
+	ErrCodeRoleInUse            = 4016  // Role is a system role, assigned to users or inherited by another role
+	ErrCodeRoleHierarchyInvalid = 4017  // Parent role not found or the hierarchy would contain a cycle
+	ErrCodePermissionNotFound   = 4018  // Permission not found
+	ErrCodeAPIKeyNotFound       = 4019  // API key not found or already revoked
+	ErrCodeScopeNotAllowed      = 4020  // API key scope is not a permission of the key owner
+	ErrCodeSessionNotFound      = 4021  // Session not found or already revoked
+	ErrCodeOIDCProviderNotFound = 4022  // OIDC provider not configured
+	ErrCodeOIDCAccountNotLinked = 4023  // No account is linked to the identity and none can be linked or provisioned
+	ErrCodeIdentityLinked       = 4024  // Identity already linked to another account
+	ErrCodeIdentityNotFound     = 4025  // Linked identity not found
+	ErrCodePasswordPolicy       = 4026  // Password does not satisfy the password policy
+	ErrCodeImpersonation        = 4027  // User cannot be impersonated, or the action is not allowed while impersonating
+	ErrCodeMagicLinkDisabled    = 4028  // Magic link login is disabled
+	ErrCodePasskeyNotFound      = 4029  // Passkey not found
+	ErrCodeOrgNotFound          = 4030  // Organization not found
+	ErrCodeOrgHasExists         = 4031  // Organization slug already taken
+	ErrCodeOrgRequired          = 4032  // The request needs an organization, from the token or the X-Organization-ID header
+	ErrCodeOrgAccessDenied      = 4033  // Not an active member of the organization, or the member role is not allowed
+	ErrCodeOrgMemberNotFound    = 4034  // Organization member not found
+	ErrCodeOrgMemberHasExists   = 4035  // User is already a member of the organization
+	ErrCodeOrgLastOwner         = 4036  // The last owner of an organization cannot be removed or demoted
+	ErrCodeUserDeleteSelf       = 4037  // Users cannot delete their own account through the admin API
+	ErrCodeImportFileInvalid    = 4038  // Import file unreadable, of an unsupported format, too large or without the required columns
+	ErrCodeImportJobNotFound    = 4039  // Import job not found or expired
+	ErrCodeExportColumnInvalid  = 4040  // Export column unknown or requested twice
+	ErrCodeAvatarInvalid        = 4041  // Avatar missing, too large or not a JPEG, PNG, GIF or WebP image
+	ErrCodeInvalidSort          = 4042  // Sort field unknown or given twice
+	ErrCodeInvalidCursor        = 4043  // Page cursor malformed or of another sort
+	ErrCodeUserPasswordSelf     = 4044  // Users change their own password through change_password, with the current one
+	ErrCodePermissionNotAllowed = 4045  // Permission cannot be granted by somebody who does not hold it
+	ErrCodeTooManyRequests      = 4290  // Too many requests
 	ErrCodeInternalError        = 5000  // Internal server error
 	ErrCodeInvalidData          = 4221  // Invalid request data
 	ErrCodeUnauthorized         = 4010  // Unauthorized
@@ -18,13 +69,38 @@ const (
 var (
 	msg = map[int]string{
 		//	common
-		ErrCodeSuccess:       "SUCCESS",
-		ErrInvalidToken:      "TOKEN_INVALID",
-		ErrCodeInvalidLogin:  "LOGIN_FAILED",
-		ErrCodeAccessDenied:  "ACCESS_DENIED",
-		ErrCodeInternalError: "INTERNAL_SERVER_ERROR",
-		ErrCodeInvalidData:   "INVALID_DATA",
-		ErrCodeUnauthorized:  "UNAUTHORIZED",
+		ErrCodeSuccess:         "SUCCESS",
+		ErrInvalidToken:        "TOKEN_INVALID",
+		ErrCodeInvalidLogin:    "LOGIN_FAILED",
+		ErrCodeAccessDenied:    "ACCESS_DENIED",
+		ErrCodeInternalError:   "INTERNAL_SERVER_ERROR",
+		ErrCodeInvalidData:     "INVALID_DATA",
+		ErrCodeUnauthorized:    "UNAUTHORIZED",
+		ErrCodeTooManyRequests: "TOO_MANY_REQUESTS",
+
+		//	auth
+		ErrCodeRefreshTokenReused:   "REFRESH_TOKEN_REUSED",
+		ErrCodeTokenRevoked:         "TOKEN_REVOKED",
+		ErrCodeResetTokenInvalid:    "RESET_TOKEN_INVALID",
+		ErrCodeVerifyTokenInvalid:   "VERIFY_TOKEN_INVALID",
+		ErrCodeMFACodeInvalid:       "MFA_CODE_INVALID",
+		ErrCodeMFAAlreadyEnabled:    "MFA_ALREADY_ENABLED",
+		ErrCodeMFANotEnabled:        "MFA_NOT_ENABLED",
+		ErrCodeMFARequired:          "MFA_REQUIRED_FOR_ROLE",
+		ErrCodeLoginLocked:          "LOGIN_TEMPORARILY_LOCKED",
+		ErrCodeInvitationInvalid:    "INVITATION_INVALID",
+		ErrCodeRegistrationClosed:   "REGISTRATION_CLOSED",
+		ErrCodeRoleNotAllowed:       "ROLE_NOT_ALLOWED",
+		ErrCodeAPIKeyInvalid:        "API_KEY_INVALID",
+		ErrCodeAPIKeyNotFound:       "API_KEY_NOT_FOUND",
+		ErrCodeScopeNotAllowed:      "SCOPE_NOT_ALLOWED",
+		ErrCodeOIDCStateInvalid:     "OIDC_STATE_INVALID",
+		ErrCodeOIDCLoginFailed:      "OIDC_LOGIN_FAILED",
+		ErrCodeOIDCProviderNotFound: "OIDC_PROVIDER_NOT_FOUND",
+		ErrCodeOIDCAccountNotLinked: "OIDC_ACCOUNT_NOT_LINKED",
+		ErrCodeMagicLinkInvalid:     "MAGIC_LINK_INVALID",
+		ErrCodeMagicLinkDisabled:    "MAGIC_LINK_DISABLED",
+		ErrCodePasskeyInvalid:       "PASSKEY_INVALID",
 
 		//	user
 		ErrCodeInvalidParams:        "EMAIL_INVALID",
@@ -32,6 +108,38 @@ var (
 		ErrCodeUserNotFound:         "USER_NOT_FOUND",
 		ErrCodeAccountLock:          "USER_ACCOUNT_LOCKED",
 		ErrCodeUserPermissionDenied: "YOU_DO_NOT_HAVE_PERMISSION_TO_INTERACT_WITH_THIS_USER",
+		ErrCodeEmailNotVerified:     "EMAIL_NOT_VERIFIED",
+		ErrCodeSessionNotFound:      "SESSION_NOT_FOUND",
+		ErrCodeIdentityLinked:       "IDENTITY_ALREADY_LINKED",
+		ErrCodeIdentityNotFound:     "IDENTITY_NOT_FOUND",
+		ErrCodePasswordPolicy:       "PASSWORD_POLICY_VIOLATION",
+		ErrCodeImpersonation:        "IMPERSONATION_NOT_ALLOWED",
+		ErrCodePasskeyNotFound:      "PASSKEY_NOT_FOUND",
+		ErrCodeUserDeleteSelf:       "CANNOT_DELETE_YOURSELF",
+		ErrCodeImportFileInvalid:    "IMPORT_FILE_INVALID",
+		ErrCodeImportJobNotFound:    "IMPORT_JOB_NOT_FOUND",
+		ErrCodeExportColumnInvalid:  "EXPORT_COLUMN_INVALID",
+		ErrCodeAvatarInvalid:        "AVATAR_INVALID",
+		ErrCodeInvalidSort:          "INVALID_SORT",
+		ErrCodeInvalidCursor:        "INVALID_CURSOR",
+		ErrCodeUserPasswordSelf:     "USE_CHANGE_PASSWORD",
+		ErrCodePermissionNotAllowed: "PERMISSION_NOT_ALLOWED",
+
+		//	organization
+		ErrCodeOrgNotFound:        "ORGANIZATION_NOT_FOUND",
+		ErrCodeOrgHasExists:       "ORGANIZATION_ALREADY_EXISTS",
+		ErrCodeOrgRequired:        "ORGANIZATION_REQUIRED",
+		ErrCodeOrgAccessDenied:    "ORGANIZATION_ACCESS_DENIED",
+		ErrCodeOrgMemberNotFound:  "ORGANIZATION_MEMBER_NOT_FOUND",
+		ErrCodeOrgMemberHasExists: "ORGANIZATION_MEMBER_ALREADY_EXISTS",
+		ErrCodeOrgLastOwner:       "ORGANIZATION_LAST_OWNER",
+
+		//	role
+		ErrCodeRoleNotFound:         "ROLE_NOT_FOUND",
+		ErrCodeRoleHasExists:        "ROLE_ALREADY_EXISTS",
+		ErrCodeRoleInUse:            "ROLE_IN_USE",
+		ErrCodeRoleHierarchyInvalid: "ROLE_HIERARCHY_INVALID",
+		ErrCodePermissionNotFound:   "PERMISSION_NOT_FOUND",
 	}
 )
 
@@ -1,7 +1,7 @@
 package response
 
 import (
-	"fmt"
+	"errors"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -19,6 +19,7 @@ type ServiceResult struct {
 	Error      error
 	StatusCode int
 	ErrorCode  int
+	Headers    map[string]string
 }
 
 // NewServiceResult - Create a successful ServiceResult
@@ -45,12 +46,27 @@ func NewServiceError(err error, statusCode int, errorCode int) *ServiceResult {
 func NewServiceErrorWithCode(statusCode int, errorCode int) *ServiceResult {
 	return &ServiceResult{
 		Data:       nil,
-		Error:      fmt.Errorf(GetMessage(errorCode)),
+		Error:      errors.New(GetMessage(errorCode)),
 		StatusCode: statusCode,
 		ErrorCode:  errorCode,
 	}
 }
 
+// WithHeader - Add a response header (e.g. Retry-After) to the ServiceResult
+func (r *ServiceResult) WithHeader(key string, value string) *ServiceResult {
+	if r.Headers == nil {
+		r.Headers = make(map[string]string)
+	}
+	r.Headers[key] = value
+	return r
+}
+
+// WithData - Attach details (e.g. validation violations) to an error ServiceResult
+func (r *ServiceResult) WithData(data interface{}) *ServiceResult {
+	r.Data = data
+	return r
+}
+
 // DataDetailResponse - Return response with custom code, message, and data
 func DataDetailResponse(c *gin.Context, statusCode int, code int, data interface{}) {
 	c.JSON(statusCode, Response{
@@ -72,10 +88,14 @@ func ErrorResponse(c *gin.Context, statusCode int, data interface{}) {
 
 // HandleServiceResult - Handle ServiceResult automatically
 func HandleServiceResult(c *gin.Context, result *ServiceResult) {
+	for key, value := range result.Headers {
+		c.Header(key, value)
+	}
+
 	if result.Error != nil {
 		if result.ErrorCode != 0 {
 			// Use DataDetailResponse for errors with error code
-			DataDetailResponse(c, result.StatusCode, result.ErrorCode, nil)
+			DataDetailResponse(c, result.StatusCode, result.ErrorCode, result.Data)
 		} else {
 			// Use ErrorResponse for normal errors
 			ErrorResponse(c, result.StatusCode, result.Error.Error())
@@ -14,15 +14,22 @@ type Config struct {
 	MinIO    MinIOSetting    `map_structure:"minio"`
 	Logger   LoggerSetting   `map_structure:"logger"`
 	JWT      JWTSetting      `map_structure:"jwt"`
+	Mail     MailSetting     `map_structure:"mail"`
+	Auth     AuthSetting     `map_structure:"auth"`
+	OIDC     OIDCSetting     `map_structure:"oidc"`
+	WebAuthn WebAuthnSetting `map_structure:"webauthn"`
+	Password PasswordSetting `map_structure:"password"`
+	Policy   PolicySetting   `map_structure:"policy"`
 }
 
 type ServerSetting struct {
-	Port int    `map_structure:"port"`
-	Mode string `map_structure:"mode"`
+	Port           int      `map_structure:"port"`
+	Mode           string   `map_structure:"mode"`
+	TrustedProxies []string `map_structure:"trusted_proxies"`
 }
 
 type SystemSetting struct {
-	DefaultPassWord string `map_structure:"default_password"`
+	AppBaseURL string `map_structure:"app_base_url"`
 }
 
 type PostgresSetting struct {
@@ -69,7 +76,115 @@ type MinIOSetting struct {
 }
 
 type JWTSetting struct {
-	SecretKey     string        `map_structure:"secret_key"`
-	TokenExpiry   time.Duration `map_structure:"token_expiry"`
-	RefreshExpiry time.Duration `map_structure:"refresh_expiry"`
+	SecretKey        string          `map_structure:"secret_key"`
+	TokenExpiry      time.Duration   `map_structure:"token_expiry"`
+	RefreshExpiry    time.Duration   `map_structure:"refresh_expiry"`
+	SigningAlgorithm string          `map_structure:"signing_algorithm"`
+	SigningKeyID     string          `map_structure:"signing_key_id"`
+	SigningKeyFile   string          `map_structure:"signing_key_file"`
+	VerificationKeys []JWTKeySetting `map_structure:"verification_keys"`
+}
+
+// JWTKeySetting is a retired public key still accepted for verification during its grace period
+type JWTKeySetting struct {
+	KeyID   string `map_structure:"key_id"`
+	KeyFile string `map_structure:"key_file"`
+}
+
+type MailSetting struct {
+	Driver   string `map_structure:"driver"`
+	Host     string `map_structure:"host"`
+	Port     int    `map_structure:"port"`
+	Username string `map_structure:"username"`
+	Password string `map_structure:"password"`
+	From     string `map_structure:"from"`
+	FileDir  string `map_structure:"file_dir"`
+}
+
+type AuthSetting struct {
+	PasswordResetExpiry           time.Duration `map_structure:"password_reset_expiry"`
+	PasswordResetRequestPeriod    time.Duration `map_structure:"password_reset_request_period"`
+	RequireEmailVerification      bool          `map_structure:"require_email_verification"`
+	EmailVerificationExpiry       time.Duration `map_structure:"email_verification_expiry"`
+	EmailVerificationResendPeriod time.Duration `map_structure:"email_verification_resend_period"`
+	MFAIssuer                     string        `map_structure:"mfa_issuer"`
+	MFASecretKey                  string        `map_structure:"mfa_secret_key"`
+	MFATokenExpiry                time.Duration `map_structure:"mfa_token_expiry"`
+	PasswordChangeTokenExpiry     time.Duration `map_structure:"password_change_token_expiry"`
+	ImpersonationTokenExpiry      time.Duration `map_structure:"impersonation_token_expiry"`
+	MagicLinkEnabled              bool          `map_structure:"magic_link_enabled"`
+	MagicLinkExpiry               time.Duration `map_structure:"magic_link_expiry"`
+	MagicLinkResendPeriod         time.Duration `map_structure:"magic_link_resend_period"`
+	LoginAttemptWindow            time.Duration `map_structure:"login_attempt_window"`
+	LoginDelayAfter               int           `map_structure:"login_delay_after"`
+	LoginBaseDelay                time.Duration `map_structure:"login_base_delay"`
+	LoginMaxDelay                 time.Duration `map_structure:"login_max_delay"`
+	LoginMaxAttempts              int           `map_structure:"login_max_attempts"`
+	LoginMaxAttemptsPerIP         int           `map_structure:"login_max_attempts_per_ip"`
+	LoginLockoutDuration          time.Duration `map_structure:"login_lockout_duration"`
+	RegistrationMode              string        `map_structure:"registration_mode"`
+	SelfRegistrationRoles         []string      `map_structure:"self_registration_roles"`
+	InvitationExpiry              time.Duration `map_structure:"invitation_expiry"`
+	PermissionCacheTTL            time.Duration `map_structure:"permission_cache_ttl"`
+	DeletedUserRetention          time.Duration `map_structure:"deleted_user_retention"`
+	DeletedUserPurgeInterval      time.Duration `map_structure:"deleted_user_purge_interval"`
+	UserImportMaxRows             int           `map_structure:"user_import_max_rows"`
+	UserImportMaxFileSize         int           `map_structure:"user_import_max_file_size"`
+	UserImportJobTTL              time.Duration `map_structure:"user_import_job_ttl"`
+	UserExportURLExpiry           time.Duration `map_structure:"user_export_url_expiry"`
+	AvatarMaxFileSize             int           `map_structure:"avatar_max_file_size"`
+	AvatarMaxPixels               int           `map_structure:"avatar_max_pixels"`
+	AvatarURLExpiry               time.Duration `map_structure:"avatar_url_expiry"`
+}
+
+type OIDCSetting struct {
+	RedirectURL string                `map_structure:"redirect_url"`
+	StateExpiry time.Duration         `map_structure:"state_expiry"`
+	Providers   []OIDCProviderSetting `map_structure:"providers"`
+}
+
+// OIDCProviderSetting an OpenID Connect identity provider, its endpoints are discovered from the issuer
+type OIDCProviderSetting struct {
+	Name           string   `map_structure:"name"`
+	Issuer         string   `map_structure:"issuer"`
+	ClientID       string   `map_structure:"client_id"`
+	ClientSecret   string   `map_structure:"client_secret"`
+	Scopes         []string `map_structure:"scopes"`
+	LinkByEmail    bool     `map_structure:"link_by_email"`
+	AutoProvision  bool     `map_structure:"auto_provision"`
+	DefaultRole    string   `map_structure:"default_role"`
+	AllowedDomains []string `map_structure:"allowed_domains"`
+}
+
+// WebAuthnSetting the relying party of passkey registration and login, origins are the full origins of the frontend
+type WebAuthnSetting struct {
+	RPID            string        `map_structure:"rp_id"`
+	RPDisplayName   string        `map_structure:"rp_display_name"`
+	RPOrigins       []string      `map_structure:"rp_origins"`
+	ChallengeExpiry time.Duration `map_structure:"challenge_expiry"`
+}
+
+// PasswordSetting the algorithm and work factors of new password hashes, stored hashes using other
+// parameters are rehashed on the next successful login, and the policy new passwords must satisfy
+type PasswordSetting struct {
+	HashAlgorithm     string        `map_structure:"hash_algorithm"`
+	BcryptCost        int           `map_structure:"bcrypt_cost"`
+	Argon2Memory      int           `map_structure:"argon2_memory"`
+	Argon2Iterations  int           `map_structure:"argon2_iterations"`
+	Argon2Parallelism int           `map_structure:"argon2_parallelism"`
+	MinLength         int           `map_structure:"min_length"`
+	MaxLength         int           `map_structure:"max_length"`
+	RequireUppercase  bool          `map_structure:"require_uppercase"`
+	RequireLowercase  bool          `map_structure:"require_lowercase"`
+	RequireDigit      bool          `map_structure:"require_digit"`
+	RequireSymbol     bool          `map_structure:"require_symbol"`
+	DisallowUserInfo  bool          `map_structure:"disallow_user_info"`
+	HistorySize       int           `map_structure:"history_size"`
+	MaxAge            time.Duration `map_structure:"max_age"`
+	BreachedListFile  string        `map_structure:"breached_list_file"`
+}
+
+// PolicySetting the access policy file, the built-in default policy is used when empty
+type PolicySetting struct {
+	File string `map_structure:"file"`
 }
@@ -0,0 +1,95 @@
+package spreadsheet
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+var (
+	ErrUnsupportedFormat = errors.New("unsupported file format, expected .csv or .xlsx")
+	ErrTooManyRows       = errors.New("file has too many rows")
+	ErrEmpty             = errors.New("file has no rows")
+)
+
+// utf8BOM is written at the start of CSV files by spreadsheet applications
+const utf8BOM = "\ufeff"
+
+// ReadRows returns the rows of a CSV file or of the first sheet of an XLSX workbook, the format is
+// chosen by the file extension. Cells are trimmed and rows may have different lengths. The header
+// row counts, more than maxRows rows is an error.
+func ReadRows(r io.Reader, fileName string, maxRows int) ([][]string, error) {
+	var rows [][]string
+	var err error
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".csv":
+		rows, err = readCSV(r, maxRows)
+	case ".xlsx":
+		rows, err = readXLSX(r)
+	default:
+		return nil, ErrUnsupportedFormat
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(rows) == 0 {
+		return nil, ErrEmpty
+	}
+	if len(rows) > maxRows {
+		return nil, ErrTooManyRows
+	}
+	for _, row := range rows {
+		for i := range row {
+			row[i] = strings.TrimSpace(row[i])
+		}
+	}
+	if len(rows[0]) > 0 {
+		rows[0][0] = strings.TrimPrefix(rows[0][0], utf8BOM)
+	}
+	return rows, nil
+}
+
+func readCSV(r io.Reader, maxRows int) ([][]string, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+
+	var rows [][]string
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		// Stop reading early, one row past the limit is enough to report it
+		if len(rows) > maxRows {
+			return rows, nil
+		}
+		rows = append(rows, record)
+	}
+}
+
+func readXLSX(r io.Reader) ([][]string, error) {
+	workbook, err := excelize.OpenReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer workbook.Close()
+
+	sheets := workbook.GetSheetList()
+	if len(sheets) == 0 {
+		return nil, ErrEmpty
+	}
+	rows, err := workbook.GetRows(sheets[0])
+	if err != nil {
+		return nil, fmt.Errorf("read sheet %s: %w", sheets[0], err)
+	}
+	return rows, nil
+}
@@ -0,0 +1,174 @@
+package spreadsheet
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// Formats written by NewWriter
+const (
+	FormatCSV   = "csv"
+	FormatJSONL = "jsonl"
+	FormatXLSX  = "xlsx"
+)
+
+var contentTypes = map[string]string{
+	FormatCSV:   "text/csv; charset=utf-8",
+	FormatJSONL: "application/x-ndjson",
+	FormatXLSX:  "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+}
+
+// ContentType returns the MIME type of a format, empty for an unknown format
+func ContentType(format string) string {
+	return contentTypes[format]
+}
+
+// Writer writes rows one at a time so a file of any size can be streamed. Values are strings, numbers,
+// booleans, time.Time or nil for an empty cell. Close must be called to complete the file.
+type Writer interface {
+	WriteRow(values []any) error
+	Close() error
+}
+
+// NewWriter returns a writer of the format, CSV and XLSX files start with the header row and JSON Lines
+// use the header as keys of every object
+func NewWriter(w io.Writer, format string, header []string) (Writer, error) {
+	switch format {
+	case FormatCSV:
+		return newCSVWriter(w, header)
+	case FormatJSONL:
+		return &jsonlWriter{writer: bufio.NewWriter(w), header: header}, nil
+	case FormatXLSX:
+		return newXLSXWriter(w, header)
+	}
+	return nil, fmt.Errorf("unsupported format %q", format)
+}
+
+type csvWriter struct {
+	writer *csv.Writer
+}
+
+func newCSVWriter(w io.Writer, header []string) (*csvWriter, error) {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(header); err != nil {
+		return nil, err
+	}
+	return &csvWriter{writer: writer}, nil
+}
+
+func (cw *csvWriter) WriteRow(values []any) error {
+	record := make([]string, len(values))
+	for i, value := range values {
+		record[i] = formatCell(value)
+	}
+	return cw.writer.Write(record)
+}
+
+func (cw *csvWriter) Close() error {
+	cw.writer.Flush()
+	return cw.writer.Error()
+}
+
+func formatCell(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case bool:
+		return strconv.FormatBool(v)
+	case time.Time:
+		return v.Format(time.RFC3339)
+	}
+	return fmt.Sprint(value)
+}
+
+// jsonlWriter writes an object per line with the keys in the order of the header
+type jsonlWriter struct {
+	writer *bufio.Writer
+	header []string
+}
+
+func (jw *jsonlWriter) WriteRow(values []any) error {
+	if len(values) != len(jw.header) {
+		return fmt.Errorf("row has %d values for %d columns", len(values), len(jw.header))
+	}
+
+	jw.writer.WriteByte('{')
+	for i, value := range values {
+		if i > 0 {
+			jw.writer.WriteByte(',')
+		}
+		key, err := json.Marshal(jw.header[i])
+		if err != nil {
+			return err
+		}
+		data, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		jw.writer.Write(key)
+		jw.writer.WriteByte(':')
+		jw.writer.Write(data)
+	}
+	jw.writer.WriteByte('}')
+	return jw.writer.WriteByte('\n')
+}
+
+func (jw *jsonlWriter) Close() error {
+	return jw.writer.Flush()
+}
+
+// xlsxWriter writes the rows to the first sheet of a workbook. The rows are kept by excelize, on disk past
+// a few megabytes, until Close writes the workbook out.
+type xlsxWriter struct {
+	out      io.Writer
+	workbook *excelize.File
+	stream   *excelize.StreamWriter
+	row      int
+}
+
+func newXLSXWriter(w io.Writer, header []string) (*xlsxWriter, error) {
+	workbook := excelize.NewFile()
+	stream, err := workbook.NewStreamWriter(workbook.GetSheetName(0))
+	if err != nil {
+		workbook.Close()
+		return nil, err
+	}
+
+	xw := &xlsxWriter{out: w, workbook: workbook, stream: stream}
+	values := make([]any, len(header))
+	for i, name := range header {
+		values[i] = name
+	}
+	if err := xw.WriteRow(values); err != nil {
+		workbook.Close()
+		return nil, err
+	}
+	return xw, nil
+}
+
+func (xw *xlsxWriter) WriteRow(values []any) error {
+	xw.row++
+	cell, err := excelize.CoordinatesToCellName(1, xw.row)
+	if err != nil {
+		return err
+	}
+	return xw.stream.SetRow(cell, values)
+}
+
+func (xw *xlsxWriter) Close() error {
+	defer xw.workbook.Close()
+	if err := xw.stream.Flush(); err != nil {
+		return err
+	}
+	_, err := xw.workbook.WriteTo(xw.out)
+	return err
+}